@@ -86,7 +86,13 @@ func SetupComsExg(args *config.CmdArgs) *errs.Error {
 	if err != nil {
 		return err
 	}
-	return orm.InitExg(exg.Default)
+	for _, exchange := range exg.AllExchanges() {
+		err = orm.InitExg(exchange)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func RefreshPairs(showLog bool, timeMS int64, pBar *utils.StagedPrg) ([]string, map[string]map[string]float64, *errs.Error) {
@@ -310,6 +316,8 @@ func ResetVars() {
 	accLiveOdMgrs = make(map[string]*LiveOrderMgr)
 	accOdMgrs = make(map[string]IOrderMgr)
 	accWallets = make(map[string]*BanWallets)
+	intraCaches = make(map[int64]*intraCache)
+	barRanges = make(map[string][2]float64)
 	core.LastBarMs = 0
 	core.OdBooks = make(map[string]*banexg.OrderBook)
 	ormo.HistODs = make([]*ormo.InOutOrder, 0)
@@ -0,0 +1,177 @@
+package biz
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/banbox/banbot/config"
+	"github.com/banbox/banbot/goods"
+	"github.com/banbox/banbot/strat"
+)
+
+/*
+RunConfigCheck
+Validate merged yaml configs: schema of keys and types, registered strategies and their params, pair filters,
+timeframes and account references. Each problem is printed with file and line, exit with code 1 if any error found.
+校验合并后的yaml配置：键和类型、已注册的策略及其参数、品种过滤器、时间周期和账户引用。每个问题输出文件和行号，有错误时以状态码1退出
+*/
+func RunConfigCheck(args []string) error {
+	var cmdArgs config.CmdArgs
+	parser := flag.NewFlagSet("", flag.ExitOnError)
+	parser.Var(&cmdArgs.Configs, "config", "config path to use, Multiple -config options may be used")
+	parser.StringVar(&cmdArgs.DataDir, "datadir", "", "Path to data dir.")
+	parser.BoolVar(&cmdArgs.NoDefault, "nodefault", false, "ignore default: config.yml, config.local.yml")
+	err_ := parser.Parse(args)
+	if err_ != nil {
+		return err_
+	}
+	cmdArgs.Init()
+	paths := config.GetConfigPaths(&cmdArgs)
+	if len(paths) == 0 {
+		return errors.New("no config file found, use -config or -datadir")
+	}
+	checker, err := config.NewCfgChecker(paths)
+	if err != nil {
+		return err
+	}
+	checker.CheckSchema()
+	cfg, err := checker.LoadMerged(&cmdArgs)
+	if err != nil {
+		checker.Add(config.IssueError, nil, "load merged config fail: %s", err.Short())
+	} else {
+		checker.CheckConfig(cfg)
+		checkCfgStrats(checker, cfg)
+		checkCfgFilters(checker, []string{"pairlists"}, cfg.PairFilters)
+	}
+	checker.SortIssues()
+	fmt.Printf("checked %d files: %s\n", len(paths), strings.Join(paths, ", "))
+	for _, it := range checker.Issues {
+		fmt.Println(it.String())
+	}
+	errNum := checker.ErrorNum()
+	fmt.Printf("%d errors, %d warnings\n", errNum, len(checker.Issues)-errNum)
+	if errNum > 0 {
+		os.Exit(1)
+	}
+	return nil
+}
+
+// checkCfgStrats strategies should be registered, params should be read by strategy 策略应已注册，参数应被策略读取
+func checkCfgStrats(c *config.CfgChecker, cfg *config.Config) {
+	for i, pol := range cfg.RunPolicy {
+		idx := strconv.Itoa(i)
+		checkCfgFilters(c, []string{"run_policy", idx, "filters"}, pol.Filters)
+		if pol.Name == "" {
+			continue
+		}
+		makeFn, ok := strat.StratMake[pol.Name]
+		if !ok {
+			msg := "strategy not registered: " + pol.Name
+			if guess := config.ClosestKey(pol.Name, strat.StratMake); guess != "" {
+				msg += fmt.Sprintf(", did you mean `%s`?", guess)
+			}
+			c.Add(config.IssueError, []string{"run_policy", idx, "name"}, msg)
+			continue
+		}
+		used, err := initStratParams(makeFn, pol)
+		if err != nil {
+			c.Add(config.IssueWarn, []string{"run_policy", idx, "name"}, "init strategy fail, skip params check: %v", err)
+			continue
+		}
+		// params read lazily after init can't be detected, so only warn 初始化后才读取的参数无法检测，故只警告
+		for key := range pol.Params {
+			if !used[key] {
+				c.Add(config.IssueWarn, []string{"run_policy", idx, "params", key}, unknownParamMsg(key, used))
+			}
+		}
+		for pair, items := range pol.PairParams {
+			for key := range items {
+				if !used[key] {
+					c.Add(config.IssueWarn, []string{"run_policy", idx, "pair_params", pair, key}, unknownParamMsg(key, used))
+				}
+			}
+		}
+	}
+}
+
+func initStratParams(makeFn strat.FuncMakeStrat, pol *config.RunPolicyConfig) (res map[string]bool, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	tmp := pol.Clone()
+	tmp.TrackParams()
+	makeFn(tmp)
+	return tmp.UsedParams(), nil
+}
+
+func unknownParamMsg(key string, used map[string]bool) string {
+	msg := "param not used by strategy on init"
+	if guess := config.ClosestKey(key, used); guess != "" {
+		msg += fmt.Sprintf(", did you mean `%s`?", guess)
+	}
+	return msg
+}
+
+// checkCfgFilters filter names should be registered in goods, keys should be fields of the filter 过滤器名称应已注册，键应为过滤器的字段
+func checkCfgFilters(c *config.CfgChecker, path []string, items []*config.CommonPairFilter) {
+	for i, item := range items {
+		itemPath := append(slices.Clone(path), strconv.Itoa(i))
+		if item == nil {
+			continue
+		}
+		if item.Name == "" {
+			c.Add(config.IssueError, itemPath, "filter name is required")
+			continue
+		}
+		maker, ok := goods.FilterMake[item.Name]
+		if !ok {
+			msg := "unknown filter: " + item.Name
+			if guess := config.ClosestKey(item.Name, goods.FilterMake); guess != "" {
+				msg += fmt.Sprintf(", did you mean `%s`?", guess)
+			}
+			c.Add(config.IssueError, append(itemPath, "name"), msg)
+			continue
+		}
+		fields := make(map[string]bool)
+		collectFieldTags(reflect.TypeOf(maker(goods.BaseFilter{Name: item.Name})), fields)
+		for key := range item.Items {
+			if !fields[key] {
+				msg := fmt.Sprintf("unknown key of %s", item.Name)
+				if guess := config.ClosestKey(key, fields); guess != "" {
+					msg += fmt.Sprintf(", did you mean `%s`?", guess)
+				}
+				c.Add(config.IssueError, append(slices.Clone(itemPath), key), msg)
+			}
+		}
+	}
+}
+
+// collectFieldTags names of fields by mapstructure tags, including embedded structs 按mapstructure标签收集字段名，包括嵌入的结构体
+func collectFieldTags(tp reflect.Type, res map[string]bool) {
+	for tp.Kind() == reflect.Pointer {
+		tp = tp.Elem()
+	}
+	if tp.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < tp.NumField(); i++ {
+		f := tp.Field(i)
+		if f.Anonymous {
+			collectFieldTags(f.Type, res)
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("mapstructure"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		res[name] = true
+	}
+}
@@ -0,0 +1,48 @@
+package biz
+
+import (
+	"github.com/banbox/banbot/orm/ormo"
+	"github.com/sasha-s/go-deadlock"
+)
+
+// FnWalletChange called after wallets are updated from exchange balances 钱包从交易所余额更新后调用
+type FnWalletChange func(wallets *BanWallets)
+
+// FnTriggerChange called after a stop loss/take profit/trailing stop order is placed or cancelled on exchange, key is ormo.OdActionStopLoss etc. 止损/止盈/跟踪止损单在交易所下单或撤销后调用
+type FnTriggerChange func(acc string, od *ormo.InOutOrder, key string)
+
+var (
+	walletSubs  []FnWalletChange  // listeners of wallet update events 钱包更新事件监听者
+	triggerSubs []FnTriggerChange // listeners of trigger order change events 触发单变化事件监听者
+	lockEvtSub  deadlock.Mutex
+)
+
+func AddWalletSub(cb FnWalletChange) {
+	lockEvtSub.Lock()
+	walletSubs = append(walletSubs, cb)
+	lockEvtSub.Unlock()
+}
+
+func AddTriggerSub(cb FnTriggerChange) {
+	lockEvtSub.Lock()
+	triggerSubs = append(triggerSubs, cb)
+	lockEvtSub.Unlock()
+}
+
+func fireWalletChange(wallets *BanWallets) {
+	lockEvtSub.Lock()
+	subs := walletSubs
+	lockEvtSub.Unlock()
+	for _, cb := range subs {
+		cb(wallets)
+	}
+}
+
+func fireTriggerChange(acc string, od *ormo.InOutOrder, key string) {
+	lockEvtSub.Lock()
+	subs := triggerSubs
+	lockEvtSub.Unlock()
+	for _, cb := range subs {
+		cb(acc, od, key)
+	}
+}
@@ -0,0 +1,255 @@
+package biz
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/banbox/banbot/btime"
+	"github.com/banbox/banbot/config"
+	"github.com/banbox/banbot/core"
+	"github.com/banbox/banbot/exg"
+	"github.com/banbox/banbot/orm"
+	"github.com/banbox/banbot/orm/ormo"
+	"github.com/banbox/banbot/utils"
+	"github.com/banbox/banexg"
+	"github.com/banbox/banexg/errs"
+	"github.com/banbox/banexg/log"
+	utils2 "github.com/banbox/banexg/utils"
+	"github.com/sasha-s/go-deadlock"
+	"go.uber.org/zap"
+)
+
+const (
+	incomeFundingFee = "FUNDING_FEE"
+	fundFetchGapMS   = 600000 // min interval between fetches of funding rates 两次获取资金费率的最小间隔
+)
+
+type fundCache struct {
+	rates   []*banexg.FundingRate // sorted by time 按时间排序
+	sinceMS int64                 // rates after this time are loaded 此时间之后的费率已加载
+	untilMS int64                 // rates before this time are loaded 此时间之前的费率已加载
+	fetchAt int64                 // wall clock of the latest fetch 最近一次获取的时钟时间
+}
+
+var (
+	fundCaches = make(map[string]*fundCache) // venue key: funding rate history
+	lockFunds  deadlock.Mutex
+)
+
+// fundingDue whether the position is held at fundMS and not charged yet 订单在fundMS时持仓且尚未扣除资金费
+func fundingDue(od *ormo.InOutOrder, fundMS int64) bool {
+	if od.Status < ormo.InOutStatusPartEnter || od.Status >= ormo.InOutStatusFullExit || od.HoldAmount() <= 0 {
+		return false
+	}
+	return od.RealEnterMS() < fundMS && od.GetInfoInt64(ormo.OdInfoFundAt) < fundMS
+}
+
+/*
+addFundingFee
+Share the funding fee settled at fundMS among positions held at that time by holding amount, fee is positive if paid.
+Return the number of charged orders.
+按持仓数量将fundMS时结算的资金费分摊给此时持仓的订单，支付为正。返回扣费的订单数
+*/
+func addFundingFee(orders []*ormo.InOutOrder, fundMS int64, fee float64) int {
+	var dues []*ormo.InOutOrder
+	var total float64
+	for _, od := range orders {
+		if fundingDue(od, fundMS) {
+			dues = append(dues, od)
+			total += od.HoldAmount()
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	for _, od := range dues {
+		od.AddFunding(fee * od.HoldAmount() / total)
+		od.SetInfo(ormo.OdInfoFundAt, fundMS)
+	}
+	return len(dues)
+}
+
+/*
+getFundRates
+Funding rates of the symbol settled in (startMS, endMS], fetched from exchange and cached.
+Backtest loads the whole range at once, fetch failures are retried after a while.
+品种在(startMS, endMS]内结算的资金费率，从交易所获取并缓存。回测一次加载整个区间，获取失败时稍后重试
+*/
+func getFundRates(key string, startMS, endMS int64) []*banexg.FundingRate {
+	lockFunds.Lock()
+	defer lockFunds.Unlock()
+	c, ok := fundCaches[key]
+	if !ok {
+		c = &fundCache{sinceMS: startMS, untilMS: startMS}
+		fundCaches[key] = c
+	}
+	if endMS > c.untilMS && btime.UTCStamp()-c.fetchAt > fundFetchGapMS {
+		c.load(key, endMS)
+	}
+	start, _ := slices.BinarySearchFunc(c.rates, startMS+1, func(r *banexg.FundingRate, t int64) int {
+		return cmp.Compare(r.Timestamp, t)
+	})
+	end, _ := slices.BinarySearchFunc(c.rates, endMS+1, func(r *banexg.FundingRate, t int64) int {
+		return cmp.Compare(r.Timestamp, t)
+	})
+	return c.rates[start:end]
+}
+
+func (c *fundCache) load(key string, endMS int64) {
+	c.fetchAt = btime.UTCStamp()
+	exgName, market, pair := core.ParseVenueKey(key)
+	if exgName == "" {
+		exgName, market = core.ExgName, core.Market
+	}
+	exchange, err := exg.GetVenue(exgName, market)
+	if err != nil {
+		log.Warn("load funding rates fail", zap.String("pair", key), zap.Error(err))
+		return
+	}
+	if !core.LiveMode {
+		endMS = max(endMS, config.TimeRange.EndMS)
+	}
+	sinceMS := c.sinceMS
+	if len(c.rates) > 0 {
+		// continue after the latest record, so records published late are not missed 从最新记录之后继续，避免漏掉延迟发布的记录
+		sinceMS = c.rates[len(c.rates)-1].Timestamp
+	}
+	for sinceMS < endMS {
+		arr, err := exchange.FetchFundingRateHistory(pair, sinceMS+1, 1000, nil)
+		if err != nil {
+			log.Warn("fetch funding rates fail", zap.String("pair", key), zap.Error(err))
+			return
+		}
+		for _, r := range arr {
+			if r.Timestamp > sinceMS {
+				c.rates = append(c.rates, r)
+				sinceMS = r.Timestamp
+			}
+		}
+		if len(arr) < 1000 {
+			break
+		}
+	}
+	c.untilMS = endMS
+}
+
+/*
+applyFunding
+Charge funding fees settled before the end of the bar to filled positions, for backtest and dry run
+为已成交的仓位扣除bar结束前结算的资金费，用于回测和模拟运行
+*/
+func applyFunding(orders []*ormo.InOutOrder, bar *orm.InfoKline) {
+	if len(orders) == 0 {
+		return
+	}
+	exgName, market, _ := core.ParseVenueKey(bar.Symbol)
+	if exgName == "" {
+		market = core.Market
+	}
+	if !banexg.IsContract(market) {
+		return
+	}
+	var startMS int64
+	for _, od := range orders {
+		if od.Status < ormo.InOutStatusPartEnter || od.Status >= ormo.InOutStatusFullExit {
+			continue
+		}
+		odStart := max(od.RealEnterMS(), od.GetInfoInt64(ormo.OdInfoFundAt))
+		if startMS == 0 || odStart < startMS {
+			startMS = odStart
+		}
+	}
+	if startMS == 0 {
+		return
+	}
+	endMS := bar.Time + int64(utils2.TFToSecs(bar.TimeFrame)*1000)
+	for _, r := range getFundRates(bar.Symbol, startMS, endMS) {
+		for _, od := range orders {
+			if !fundingDue(od, r.Timestamp) {
+				continue
+			}
+			fee := od.HoldAmount() * bar.Close * r.FundingRate
+			if od.Short {
+				fee = -fee
+			}
+			od.AddFunding(fee)
+			od.SetInfo(ormo.OdInfoFundAt, r.Timestamp)
+		}
+	}
+}
+
+/*
+SyncFundingFees
+Record funding fee incomes of all accounts to open orders in real trading
+实盘时将所有账户的资金费收入记录到未平仓订单
+*/
+func SyncFundingFees() {
+	for account := range config.Accounts {
+		odMgr := GetLiveOdMgr(account)
+		if odMgr == nil {
+			continue
+		}
+		err := odMgr.syncFundingFees()
+		if err != nil {
+			log.Error("sync funding fees fail", zap.String("acc", account), zap.Error(err))
+		}
+	}
+}
+
+func (o *LiveOrderMgr) syncFundingFees() *errs.Error {
+	if !o.isContract {
+		return nil
+	}
+	openOds, lock := ormo.GetOpenODs(o.Account)
+	pairOds := make(map[string][]*ormo.InOutOrder)
+	var sinceMS int64
+	lock.Lock()
+	for _, od := range openOds {
+		if od.Status < ormo.InOutStatusPartEnter || od.Status >= ormo.InOutStatusFullExit {
+			continue
+		}
+		pairOds[od.Symbol] = append(pairOds[od.Symbol], od)
+		odStart := max(od.RealEnterMS(), od.GetInfoInt64(ormo.OdInfoFundAt))
+		if sinceMS == 0 || odStart < sinceMS {
+			sinceMS = odStart
+		}
+	}
+	lock.Unlock()
+	if len(pairOds) == 0 {
+		return nil
+	}
+	incomes, err := o.exchange.FetchIncomeHistory(incomeFundingFee, "", sinceMS+1, 1000, map[string]interface{}{
+		banexg.ParamAccount: o.Account,
+	})
+	if err != nil {
+		return err
+	}
+	// long and short positions in hedge mode have separate records at the same time 双向持仓时多空在同一时间有不同记录
+	type fundKey struct {
+		pair   string
+		timeMS int64
+	}
+	fees := make(map[fundKey]float64)
+	for _, inc := range incomes {
+		if _, ok := pairOds[inc.Symbol]; ok {
+			// income is positive if received 收入为正表示收取
+			fees[fundKey{inc.Symbol, inc.Time}] -= inc.Income
+		}
+	}
+	keys := utils.KeysOfMap(fees)
+	slices.SortFunc(keys, func(a, b fundKey) int {
+		return cmp.Compare(a.timeMS, b.timeMS)
+	})
+	for _, k := range keys {
+		ods := pairOds[k.pair]
+		locks := make([]*deadlock.Mutex, 0, len(ods))
+		for _, od := range ods {
+			locks = append(locks, od.Lock())
+		}
+		addFundingFee(ods, k.timeMS, fees[k])
+		for _, lock := range locks {
+			lock.Unlock()
+		}
+	}
+	return ormo.SaveDirtyODs(orm.DbTrades, o.Account)
+}
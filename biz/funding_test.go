@@ -0,0 +1,50 @@
+package biz
+
+import (
+	"math"
+	"testing"
+
+	"github.com/banbox/banbot/orm/ormo"
+)
+
+func fundTestOd(short bool, filled float64, enterMS int64) *ormo.InOutOrder {
+	return &ormo.InOutOrder{
+		IOrder: &ormo.IOrder{Status: ormo.InOutStatusFullEnter, Short: short, EnterAt: enterMS},
+		Enter:  &ormo.ExOrder{Enter: true, Filled: filled, Amount: filled, UpdateAt: enterMS},
+		Info:   map[string]interface{}{},
+	}
+}
+
+func TestAddFundingFee(t *testing.T) {
+	od1 := fundTestOd(false, 1, 1000)
+	od2 := fundTestOd(false, 3, 2000)
+	od3 := fundTestOd(false, 5, 9000)
+	closed := fundTestOd(false, 2, 1000)
+	closed.Status = ormo.InOutStatusFullExit
+	orders := []*ormo.InOutOrder{od1, od2, od3, closed}
+	if num := addFundingFee(orders, 8000, 4); num != 2 {
+		t.Fatalf("expect 2 orders charged, got %d", num)
+	}
+	// charged once for the same settle time 同一结算时间仅扣一次
+	if num := addFundingFee(orders, 8000, 4); num != 0 {
+		t.Errorf("expect no order charged again, got %d", num)
+	}
+	addFundingFee(orders, 16000, -9)
+	cases := []struct {
+		od      *ormo.InOutOrder
+		funding float64
+	}{
+		{od1, 1 - 1},
+		{od2, 3 - 3},
+		{od3, -5},
+		{closed, 0},
+	}
+	for i, c := range cases {
+		if res := c.od.GetInfoFloat64(ormo.OdInfoFunding); math.Abs(res-c.funding) > 1e-9 {
+			t.Errorf("order %d funding expect %v, got %v", i, c.funding, res)
+		}
+	}
+	if od1.GetInfoInt64(ormo.OdInfoFundAt) != 16000 {
+		t.Errorf("FundAt should be updated to the latest settle time")
+	}
+}
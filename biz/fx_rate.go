@@ -0,0 +1,200 @@
+package biz
+
+import (
+	"slices"
+
+	"github.com/banbox/banbot/config"
+	"github.com/banbox/banbot/core"
+	"github.com/banbox/banbot/exg"
+	"github.com/banbox/banbot/orm"
+	"github.com/banbox/banexg"
+	"github.com/banbox/banexg/errs"
+	"github.com/banbox/banexg/utils"
+)
+
+/*
+fxSeries
+K-lines of <coin>/<fiat>, used as historical conversion rates of a non-fiat currency in backtest
+<coin>/<fiat>的K线，回测中作为非法币币种的历史汇率
+*/
+type fxSeries struct {
+	pair    string
+	tfMSecs int64
+	bars    []*banexg.Kline
+	idx     int // index of the next bar to apply 下一个待应用bar的索引
+}
+
+var (
+	fxRates   = make(map[string]*fxSeries) // coin: rates of coin 币种：汇率
+	fxRangeMS [2]int64                     // time range of loaded fxRates 已加载汇率的时间范围
+)
+
+const fxTimeFrame = "1h"
+
+/*
+fxCoins
+Non-fiat currencies whose legal prices are required: stake currencies, quotes of pairs, wallet coins and report currency
+需要法币价格的非法币币种：stake_currency、交易对定价币、钱包币种和报告币种
+*/
+func fxCoins() []string {
+	coins := make(map[string]bool)
+	for _, code := range config.StakeCurrency {
+		coins[code] = true
+	}
+	for code := range config.WalletAmounts {
+		coins[code] = true
+	}
+	for _, pair := range core.Pairs {
+		coins[core.QuoteCode(pair)] = true
+	}
+	coins[config.ReportCurrency] = true
+	res := make([]string, 0, len(coins))
+	for code := range coins {
+		if code != "" && !core.IsFiat(code) {
+			res = append(res, code)
+		}
+	}
+	slices.Sort(res)
+	return res
+}
+
+var (
+	fxLivePairs = make(map[string]*orm.ExSymbol) // coin: <coin>/<fiat> fetched for rates in real trading 实盘中获取汇率的<coin>/<fiat>
+	fxFiat      = "USDT"                         // fiat currency of rates 汇率的法币
+)
+
+/*
+InitFxRates
+Load conversion rates of non-fiat currencies from <coin>/<fiat>.
+Backtest loads historical K-lines applied by UpdateFxRates, real trading fetches ticker prices refreshed by RefreshFxRates.
+fiat is the first fiat currency of stake_currency, USDT by default. Rates are kept between runs of the same time range.
+An error is returned if the rate of any currency can not be loaded.
+从<coin>/<fiat>加载非法币币种的汇率。回测加载历史K线由UpdateFxRates应用，实盘获取ticker价格由RefreshFxRates刷新。
+fiat为stake_currency中第一个法币，默认USDT。相同时间范围的多次运行复用已加载汇率。任一币种汇率无法加载时返回错误。
+*/
+func InitFxRates() *errs.Error {
+	fiat := "USDT"
+	for _, code := range config.StakeCurrency {
+		if core.IsFiat(code) {
+			fiat = code
+			break
+		}
+	}
+	if core.LiveMode {
+		fxFiat = fiat
+		return RefreshFxRates()
+	}
+	tfMSecs := int64(utils.TFToSecs(fxTimeFrame) * 1000)
+	// load one more day before start, so the rate is known at start
+	// 多加载开始前一天，确保开始时汇率已知
+	startMS, endMS := config.TimeRange.StartMS-tfMSecs*24, config.TimeRange.EndMS
+	if fxRangeMS != [2]int64{startMS, endMS} {
+		fxRates = make(map[string]*fxSeries)
+		fxRangeMS = [2]int64{startMS, endMS}
+	}
+	// backtest runs on the default account only 回测仅使用默认账户
+	exchange := exg.Get(config.DefAcc)
+	for _, code := range fxCoins() {
+		if s, ok := fxRates[code]; ok {
+			s.idx = 0
+			continue
+		}
+		exs, err := getFxSymbol(exchange, code, fiat)
+		if err != nil {
+			return err
+		}
+		_, bars, err := orm.AutoFetchOHLCV(exchange, exs, fxTimeFrame, startMS, endMS, 0, false, nil)
+		if err != nil {
+			return err
+		}
+		if len(bars) == 0 {
+			return errs.NewMsg(core.ErrRunTime, "no klines of %s for conversion rate", exs.Symbol)
+		}
+		fxRates[code] = &fxSeries{
+			pair:    exs.Symbol,
+			tfMSecs: tfMSecs,
+			bars:    bars,
+		}
+	}
+	UpdateFxRates(config.TimeRange.StartMS)
+	return nil
+}
+
+// getFxSymbol find the spot or perpetual pair of code/fiat 查找code/fiat的现货或永续合约交易对
+func getFxSymbol(exchange banexg.BanExchange, code, fiat string) (*orm.ExSymbol, *errs.Error) {
+	for _, pair := range []string{code + "/" + fiat, code + "/" + fiat + ":" + fiat} {
+		exs, err := orm.GetExSymbol(exchange, pair)
+		if err == nil {
+			return exs, nil
+		}
+	}
+	return nil, errs.NewMsg(core.ErrBadConfig, "no pair to convert %s to %s", code, fiat)
+}
+
+/*
+RefreshFxRates
+Fetch latest prices of <coin>/<fiat> as legal prices of non-fiat currencies in real trading,
+currencies of newly added pairs are also loaded
+实盘时获取<coin>/<fiat>的最新价格作为非法币币种的法币价格，新增交易对的币种也会加载
+*/
+func RefreshFxRates() *errs.Error {
+	for _, code := range fxCoins() {
+		exs, ok := fxLivePairs[code]
+		if !ok {
+			var err *errs.Error
+			exs, err = getFxSymbol(exg.Default, code, fxFiat)
+			if err != nil {
+				return err
+			}
+			fxLivePairs[code] = exs
+		}
+		exchange, err := exg.GetVenue(exs.Exchange, exs.Market)
+		if err != nil {
+			return err
+		}
+		res, err := exchange.FetchTickerPrice(exs.Symbol, nil)
+		if err != nil {
+			return err
+		}
+		price, ok := res[exs.Symbol]
+		if !ok || price <= 0 {
+			return errs.NewMsg(core.ErrRunTime, "price not found for %s", exs.Symbol)
+		}
+		core.SetPrices(map[string]float64{code: price})
+	}
+	return nil
+}
+
+/*
+UpdateFxRates
+Apply the close price of the last finished bar before timeMS as the legal price of each loaded currency
+将timeMS前最后一个完成bar的收盘价作为已加载币种的法币价格
+*/
+func UpdateFxRates(timeMS int64) {
+	for _, s := range fxRates {
+		idx := s.idx
+		for idx < len(s.bars) && s.bars[idx].Time+s.tfMSecs <= timeMS {
+			idx += 1
+		}
+		if idx == s.idx || idx == 0 {
+			continue
+		}
+		s.idx = idx
+		core.SetBarPrice(s.pair, s.bars[idx-1].Close)
+	}
+}
+
+/*
+LegalToReport
+Convert a value in legal currency to config.ReportCurrency with the current rate
+以当前汇率将法币价值换算为config.ReportCurrency计价
+*/
+func LegalToReport(val float64) float64 {
+	rate := core.LegalRate(config.ReportCurrency)
+	if rate <= 0 {
+		// InitFxRates fails if the rate can not be loaded, only happens before init
+		// 汇率无法加载时InitFxRates会失败，仅在初始化前出现
+		return val
+	}
+	return val / rate
+}
@@ -0,0 +1,146 @@
+package biz
+
+import (
+	"sort"
+
+	"github.com/banbox/banbot/config"
+	"github.com/banbox/banbot/core"
+	"github.com/banbox/banbot/orm"
+	"github.com/banbox/banbot/orm/ormo"
+	"github.com/banbox/banexg"
+	"github.com/banbox/banexg/log"
+	"github.com/banbox/banexg/utils"
+	"go.uber.org/zap"
+)
+
+/*
+intraPath
+Bars of config.BTIntraTF inside a bar of the order timeframe. Fills and triggers are resolved on them one by one,
+rates of a sub bar are mapped back to the rate of the whole bar.
+订单周期的一个bar内，config.BTIntraTF周期的K线。成交和触发在子bar上逐个解析，子bar内的比率会映射回整个bar的比率
+*/
+type intraPath struct {
+	bars     []*banexg.Kline
+	barMS    int64 // start time of the parent bar 父bar开始时间
+	tfMSecs  int64 // parent timeframe 父周期
+	subMSecs int64
+}
+
+// span start rate and size rate of the i-th sub bar in the parent bar 第i个子bar在父bar中的开始比率和大小比率
+func (p *intraPath) span(i int) (float64, float64) {
+	start := float64(p.bars[i].Time-p.barMS) / float64(p.tfMSecs)
+	return start, float64(p.subMSecs) / float64(p.tfMSecs)
+}
+
+// find index of the sub bar containing rate 查找包含rate的子bar索引
+func (p *intraPath) find(rate float64) int {
+	timeMS := p.barMS + int64(rate*float64(p.tfMSecs))
+	idx := sort.Search(len(p.bars), func(i int) bool {
+		return p.bars[i].Time+p.subMSecs > timeMS
+	})
+	return min(idx, len(p.bars)-1)
+}
+
+// subRate rate inside the i-th sub bar for the rate of parent bar 父bar的比率在第i个子bar内的比率
+func (p *intraPath) subRate(i int, rate float64) float64 {
+	start, size := p.span(i)
+	return min(1, max(0, (rate-start)/size))
+}
+
+func (p *intraPath) marketPrice(rate float64) float64 {
+	i := p.find(rate)
+	return simMarketPrice(p.bars[i], p.subRate(i, rate))
+}
+
+/*
+limitFill
+Simulate fill of a limit order on sub bars after minRate, return fill price and rate of the parent bar, ok is false if not filled
+在minRate后的子bar上模拟限价单成交，返回成交价和父bar中的比率，未成交时ok为false
+*/
+func (p *intraPath) limitFill(price float64, isBuy bool, minRate float64) (float64, float64, bool) {
+	for i := p.find(minRate); i < len(p.bars); i++ {
+		sub := p.bars[i]
+		if isBuy && price < sub.Low || !isBuy && price > sub.High {
+			continue
+		}
+		start, size := p.span(i)
+		subMin := p.subRate(i, minRate)
+		fillPrice := price
+		if isBuy && price > sub.Open || !isBuy && price < sub.Open {
+			// Better than the market, fill at the market price
+			// 优于市价，以市价成交
+			fillPrice = simMarketPrice(sub, subMin)
+		}
+		return fillPrice, start + simMarketRate(sub, price, isBuy, false, subMin)*size, true
+	}
+	return 0, 0, false
+}
+
+type intraCache struct {
+	tf      string
+	startMS int64
+	endMS   int64
+	bars    []*banexg.Kline
+}
+
+var intraCaches = make(map[int64]*intraCache) // sid: cache of config.BTIntraTF bars
+
+const intraBatch = 3000
+
+/*
+getIntraPath
+Load config.BTIntraTF bars inside bar for od from database, in batches to avoid querying every bar.
+Return nil when disabled, not in backtest, or sub bars incomplete, callers then fall back to the OHLC approximation.
+从数据库加载od的bar内config.BTIntraTF周期K线，分批加载避免每个bar都查询。
+未启用、非回测或子bar不完整时返回nil，调用方回退到OHLC近似
+*/
+func getIntraPath(od *ormo.InOutOrder, bar *banexg.Kline) *intraPath {
+	if config.BTIntraTF == "" || core.LiveMode || bar == nil {
+		return nil
+	}
+	subMSecs := int64(utils.TFToSecs(config.BTIntraTF) * 1000)
+	tfMSecs := int64(utils.TFToSecs(od.Timeframe) * 1000)
+	if subMSecs <= 0 || subMSecs >= tfMSecs || tfMSecs%subMSecs != 0 {
+		return nil
+	}
+	barEnd := bar.Time + tfMSecs
+	cache, _ := intraCaches[od.Sid]
+	if cache == nil || cache.tf != config.BTIntraTF || bar.Time < cache.startMS || barEnd > cache.endMS {
+		exs := orm.GetSymbolByID(int32(od.Sid))
+		if exs == nil {
+			return nil
+		}
+		endMS := max(barEnd, min(bar.Time+subMSecs*intraBatch, config.TimeRange.EndMS))
+		sess, conn, err := orm.Conn(nil)
+		if err != nil {
+			log.Warn("get conn for intra bars fail", zap.Error(err))
+			return nil
+		}
+		_, bars, err := sess.GetOHLCV(exs, config.BTIntraTF, bar.Time, endMS, int((endMS-bar.Time)/subMSecs), false)
+		conn.Release()
+		if err != nil {
+			log.Warn("load intra bars fail", zap.String("pair", exs.Symbol), zap.Error(err))
+			return nil
+		}
+		cache = &intraCache{tf: config.BTIntraTF, startMS: bar.Time, endMS: endMS, bars: bars}
+		intraCaches[od.Sid] = cache
+	}
+	start := sort.Search(len(cache.bars), func(i int) bool {
+		return cache.bars[i].Time >= bar.Time
+	})
+	stop := sort.Search(len(cache.bars), func(i int) bool {
+		return cache.bars[i].Time >= barEnd
+	})
+	subs := cache.bars[start:stop]
+	if int64(len(subs)) != tfMSecs/subMSecs {
+		// Lower timeframe data lacks, unable to replay exactly
+		// 小周期数据缺失，无法精确回放
+		return nil
+	}
+	return &intraPath{
+		bars:     subs,
+		barMS:    bar.Time,
+		tfMSecs:  tfMSecs,
+		subMSecs: subMSecs,
+	}
+}
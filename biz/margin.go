@@ -0,0 +1,284 @@
+package biz
+
+import (
+	"github.com/banbox/banbot/btime"
+	"github.com/banbox/banbot/config"
+	"github.com/banbox/banbot/core"
+	"github.com/banbox/banbot/exg"
+	"github.com/banbox/banbot/orm/ormo"
+	"github.com/banbox/banbot/strat"
+	"github.com/banbox/banexg"
+	"github.com/banbox/banexg/errs"
+	"github.com/banbox/banexg/log"
+	"go.uber.org/zap"
+)
+
+var barRanges = make(map[string][2]float64) // venue key: low & high of the last bar 品种场所键：最近bar的最低价和最高价
+
+// marginConf config.Margin, defaults when config is not loaded 未加载配置时使用默认值
+func marginConf() *config.MarginConfig {
+	if config.Margin != nil {
+		return config.Margin
+	}
+	return &config.MarginConfig{Mode: core.MarginCross, LiqTrigger: core.LiqByMark}
+}
+
+/*
+maintTier
+Maintenance rate and deduction for notional by tiers, maintenance margin = notional * rate - deduction.
+The deduction keeps the maintenance margin continuous across tiers, same as exchanges.
+按档位计算名义价值的维持保证金率和速算扣除数，维持保证金=名义价值*费率-扣除数。扣除数使维持保证金在档位间连续，与交易所一致
+*/
+func maintTier(tiers []*config.MarginTier, notional float64) (float64, float64) {
+	var deduct float64
+	for i, t := range tiers {
+		if i > 0 {
+			prev := tiers[i-1]
+			deduct += prev.MaxNotional * (t.MaintRate - prev.MaintRate)
+		}
+		if t.MaxNotional == 0 || notional <= t.MaxNotional || i == len(tiers)-1 {
+			return t.MaintRate, deduct
+		}
+	}
+	return 0, 0
+}
+
+// maintRate by margin.tiers, or by the exchange if not configured 按margin.tiers计算，未配置时按交易所计算
+func maintRate(exchange banexg.BanExchange, symbol string, notional float64) (float64, float64, *errs.Error) {
+	if tiers := marginConf().Tiers; len(tiers) > 0 {
+		rate, deduct := maintTier(tiers, notional)
+		return rate, deduct, nil
+	}
+	if notional <= 0 {
+		return 0, 0, nil
+	}
+	maint, err := exchange.CalcMaintMargin(symbol, notional)
+	if err != nil {
+		return 0, 0, err
+	}
+	return maint / notional, 0, nil
+}
+
+func positionPnl(od *ormo.InOutOrder, price float64) float64 {
+	pnl := od.Enter.Filled * (price - od.Enter.Average)
+	if od.Short {
+		return -pnl
+	}
+	return pnl
+}
+
+/*
+solveLiqPrice
+Price where collateral + unrealized pnl = maintenance margin for a linear contract, 0 if never liquidated.
+Long: C + Q(P-E) = QPr - d; Short: C + Q(E-P) = QPr - d
+线性合约中抵押+未实现盈亏=维持保证金时的价格，不会强平时为0
+*/
+func solveLiqPrice(od *ormo.InOutOrder, collateral, rate, deduct float64) float64 {
+	qty, entPrice := od.Enter.Filled, od.Enter.Average
+	var price float64
+	if od.Short {
+		price = (qty*entPrice + collateral + deduct) / (qty * (1 + rate))
+	} else {
+		price = (qty*entPrice - collateral - deduct) / (qty * (1 - rate))
+	}
+	return max(0, price)
+}
+
+type liqItem struct {
+	od    *ormo.InOutOrder
+	pnl   float64 // unrealized pnl at current price 当前价格的未实现盈亏
+	maint float64 // maintenance margin at current price 当前价格的维持保证金
+}
+
+/*
+LiqPrices
+Liquidation prices of orders of a settle currency under config.Margin, 0 means never liquidated.
+isolated: the collateral is the initial margin of the order.
+cross: the collateral is the wallet balance plus pnl minus maintenance margin of other positions at current prices.
+按config.Margin计算某结算币的订单强平价格，0表示不会强平。
+isolated逐仓：抵押为订单初始保证金。cross全仓：抵押为钱包余额加上其他仓位按当前价格的盈亏减维持保证金
+*/
+func (w *BanWallets) LiqPrices(odList []*ormo.InOutOrder, currency string) (map[int64]float64, *errs.Error) {
+	exchange := exg.Get(w.Account)
+	items := make([]*liqItem, 0, len(odList))
+	var sumPnl, sumMaint float64
+	for _, od := range odList {
+		if od.Enter == nil || od.Enter.Filled == 0 || od.Enter.Average == 0 {
+			continue
+		}
+		price := od.CurPrice()
+		if price <= 0 {
+			continue
+		}
+		notional := od.Enter.Filled * price
+		rate, deduct, err := maintRate(exchange, od.Symbol, notional)
+		if err != nil {
+			return nil, err
+		}
+		it := &liqItem{od: od, pnl: positionPnl(od, price), maint: notional*rate - deduct}
+		items = append(items, it)
+		sumPnl += it.pnl
+		sumMaint += it.maint
+	}
+	isolated := marginConf().Mode == core.MarginIsolated
+	balance := w.Get(currency).Total(false)
+	res := make(map[int64]float64, len(items))
+	for _, it := range items {
+		od := it.od
+		var collateral float64
+		if isolated {
+			collateral = od.Enter.Filled * od.Enter.Average / max(1, od.Leverage)
+		} else {
+			collateral = balance + (sumPnl - it.pnl) - (sumMaint - it.maint)
+		}
+		// maintenance tier depends on notional at the liquidation price, iterate to converge
+		// 维持保证金档位取决于强平价格处的名义价值，迭代收敛
+		price := od.RefPrice()
+		for i := 0; i < 3; i++ {
+			rate, deduct, err := maintRate(exchange, od.Symbol, od.Enter.Filled*price)
+			if err != nil {
+				return nil, err
+			}
+			newPrice := solveLiqPrice(od, collateral, rate, deduct)
+			if newPrice == price || newPrice == 0 {
+				price = newPrice
+				break
+			}
+			price = newPrice
+		}
+		res[od.ID] = price
+	}
+	return res, nil
+}
+
+// setBarRange record low and high of the bar for liquidation by last price 记录bar的最低最高价，用于按最新价强平
+func setBarRange(bar *banexg.Kline, key string) {
+	barRanges[key] = [2]float64{bar.Low, bar.High}
+}
+
+/*
+liqTrigPrice
+Price compared with the liquidation price: current price for mark, the adverse extreme of the last bar for last
+与强平价格比较的价格：mark为当前价格，last为最近bar的不利极值
+*/
+func liqTrigPrice(od *ormo.InOutOrder) float64 {
+	key := od.VenueKey()
+	price := core.GetPriceSafe(key)
+	if price <= 0 || marginConf().LiqTrigger != core.LiqByLast {
+		return price
+	}
+	if rg, ok := barRanges[key]; ok {
+		if od.Short {
+			return max(price, rg[1])
+		}
+		return min(price, rg[0])
+	}
+	return price
+}
+
+func isLiqHit(od *ormo.InOutOrder, trigPrice, liqPrice float64) bool {
+	if liqPrice <= 0 || trigPrice <= 0 {
+		return false
+	}
+	if od.Short {
+		return trigPrice >= liqPrice
+	}
+	return trigPrice <= liqPrice
+}
+
+/*
+checkLiquidation
+Liquidate contract positions of a settle currency by config.Margin, and record liquidation prices of the rest.
+isolated: each hit position is closed at its liquidation price. cross: when the equity at trigger prices is not more
+than the total maintenance margin, all positions are closed at trigger prices and core.ErrLiquidation is returned.
+Return orders still open.
+按config.Margin强平某结算币的合约仓位，并记录其余仓位的强平价格。
+isolated：触及的仓位按其强平价格平仓。cross：按触发价格计算的权益不超过总维持保证金时，所有仓位按触发价格平仓并返回core.ErrLiquidation。
+返回仍未平仓的订单
+*/
+func (o *LocalOrderMgr) checkLiquidation(wallets *BanWallets, orders []*ormo.InOutOrder, currency string) ([]*ormo.InOutOrder, *errs.Error) {
+	if _, market := config.GetAccVenue(o.Account); len(orders) == 0 || market != banexg.MarketLinear {
+		return orders, nil
+	}
+	liqPrices, err := wallets.LiqPrices(orders, currency)
+	if err != nil {
+		return orders, err
+	}
+	if marginConf().Mode == core.MarginIsolated {
+		res := make([]*ormo.InOutOrder, 0, len(orders))
+		for _, od := range orders {
+			liqPrice, ok := liqPrices[od.ID]
+			if ok {
+				od.SetInfo(ormo.OdInfoLiqPrice, liqPrice)
+			}
+			if ok && isLiqHit(od, liqTrigPrice(od), liqPrice) {
+				err = o.liquidateOrder(od, liqPrice)
+				if err != nil {
+					return orders, err
+				}
+				continue
+			}
+			res = append(res, od)
+		}
+		return res, nil
+	}
+	exchange := exg.Get(wallets.Account)
+	equity := wallets.Get(currency).Total(false)
+	var sumMaint float64
+	for _, od := range orders {
+		liqPrice, ok := liqPrices[od.ID]
+		if !ok {
+			continue
+		}
+		od.SetInfo(ormo.OdInfoLiqPrice, liqPrice)
+		trigPrice := liqTrigPrice(od)
+		notional := od.Enter.Filled * trigPrice
+		rate, deduct, err := maintRate(exchange, od.Symbol, notional)
+		if err != nil {
+			return orders, err
+		}
+		equity += positionPnl(od, trigPrice)
+		sumMaint += notional*rate - deduct
+	}
+	if sumMaint == 0 || equity > sumMaint {
+		return orders, nil
+	}
+	log.Warn("cross margin liquidation", zap.String("acc", wallets.Account), zap.String("code", currency),
+		zap.Float64("equity", equity), zap.Float64("maint", sumMaint),
+		zap.String("at", btime.ToDateStr(btime.TimeMS(), "")))
+	res := make([]*ormo.InOutOrder, 0)
+	for _, od := range orders {
+		if _, ok := liqPrices[od.ID]; !ok {
+			res = append(res, od)
+			continue
+		}
+		err = o.liquidateOrder(od, liqTrigPrice(od))
+		if err != nil {
+			return orders, err
+		}
+	}
+	return res, errs.NewMsg(core.ErrLiquidation, "cross margin liquidation of %s", currency)
+}
+
+/*
+liquidateOrder
+Close the order at price by liquidation, and charge margin.liq_fee_rate of the notional as liquidation fee
+以price强平订单，并按名义价值收取margin.liq_fee_rate的清算费
+*/
+func (o *LocalOrderMgr) liquidateOrder(od *ormo.InOutOrder, price float64) *errs.Error {
+	err := od.LocalExit(btime.TimeMS(), core.ExitTagLiquidation, price, "", banexg.OdTypeMarket)
+	if err != nil {
+		return err
+	}
+	if feeRate := marginConf().LiqFeeRate; feeRate > 0 {
+		od.Exit.Fee += od.Exit.Filled * price * feeRate
+		od.UpdateProfits(price)
+	}
+	wallets := GetWallets(o.Account)
+	wallets.ExitOd(od, od.Exit.Amount)
+	_ = o.finishOrder(od, nil)
+	wallets.ConfirmOdExit(od, price)
+	o.callBack(od, false)
+	strat.FireOdChange(o.Account, od, strat.OdChgExitFill)
+	return nil
+}
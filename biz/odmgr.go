@@ -243,7 +243,13 @@ func (o *OrderMgr) ProcessOrders(sess *ormo.Queries, job *strat.StratJob) ([]*or
 	job.Entrys = nil
 	job.Exits = nil
 	exs := job.Symbol
-	var entOrders, extOrders []*ormo.InOutOrder
+	var legs []*strat.EnterReq
+	enters, legs = splitHedgeLegs(enters)
+	mainReqs := enters
+	enters, exits, entOrders, extOrders, err := o.routeAccOrders(sess, job, enters, exits)
+	if err != nil {
+		return entOrders, extOrders, err
+	}
 	if len(enters) > 0 {
 		rawNum := len(enters)
 		var reasons map[string]int
@@ -259,6 +265,14 @@ func (o *OrderMgr) ProcessOrders(sess *ormo.Queries, job *strat.StratJob) ([]*or
 			entOrders = append(entOrders, iorder)
 		}
 	}
+	if len(legs) > 0 {
+		// Legs are opened after the main orders of their groups 对冲腿在所在组的主订单之后开仓
+		legOrders, err := o.enterHedgeLegs(sess, job, legs, mainReqs, entOrders)
+		entOrders = append(entOrders, legOrders...)
+		if err != nil {
+			return entOrders, extOrders, err
+		}
+	}
 	if len(exits) > 0 {
 		for _, exit := range exits {
 			iorders, err := o.ExitOpenOrders(sess, exs.Symbol, exit)
@@ -267,6 +281,18 @@ func (o *OrderMgr) ProcessOrders(sess *ormo.Queries, job *strat.StratJob) ([]*or
 			}
 			extOrders = append(extOrders, iorders...)
 		}
+		// Exit other legs together with any exited leg 与已退出的腿一起退出其他腿
+		groups := make(map[string]bool)
+		for _, od := range extOrders {
+			if grp := od.GroupID(); grp != "" && !groups[grp] {
+				groups[grp] = true
+				iorders, err := exitGroupLegs(sess, grp, &strat.ExitReq{Tag: core.ExitTagGroupExit})
+				if err != nil {
+					return entOrders, extOrders, err
+				}
+				extOrders = append(extOrders, iorders...)
+			}
+		}
 	}
 	if job.Strat.OnOrderChange != nil && (len(entOrders) > 0 || len(extOrders) > 0) {
 		for _, od := range entOrders {
@@ -287,19 +313,143 @@ func (o *OrderMgr) ProcessOrders(sess *ormo.Queries, job *strat.StratJob) ([]*or
 	return entOrders, extOrders, nil
 }
 
+/*
+routeAccOrders
+Process requests targeting other accounts (EnterReq.Account/ExitReq.Account) with the order manager of that account,
+the symbol is ExitReq.Symbol when given, or the job's on the exchange and market of the target account.
+Enter requests with their own symbol are hedge legs and opened by enterHedgeLegs. Return the remaining requests of this job.
+使用目标账户的订单管理器处理指向其他账户的请求（EnterReq.Account/ExitReq.Account），品种为ExitReq.Symbol（如有），
+否则为目标账户交易所和市场上的任务品种。带品种的入场请求为对冲腿，由enterHedgeLegs开仓。返回属于当前任务的剩余请求
+*/
+func (o *OrderMgr) routeAccOrders(sess *ormo.Queries, job *strat.StratJob, enters []*strat.EnterReq, exits []*strat.ExitReq) (
+	[]*strat.EnterReq, []*strat.ExitReq, []*ormo.InOutOrder, []*ormo.InOutOrder, *errs.Error) {
+	var entOrders, extOrders []*ormo.InOutOrder
+	getAccExs := func(account, symbol string) (IOrderMgr, *orm.ExSymbol, *errs.Error) {
+		var odMgr IOrderMgr
+		var err *errs.Error
+		if account == "" || account == o.Account {
+			account = o.Account
+			odMgr = GetOdMgr(account)
+			if odMgr == nil {
+				err = errs.NewMsg(errs.CodeParamInvalid, "order manager not found: %s", account)
+			}
+		} else {
+			odMgr, err = getRouteOdMgr(account)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if symbol != "" {
+			exs, err := orm.GetExSymbolCur(symbol)
+			return odMgr, exs, err
+		}
+		exgName, market := config.GetAccVenue(account)
+		exs := orm.GetExSymbol2(exgName, market, job.Symbol.Symbol)
+		if exs == nil {
+			log.Warn("symbol not found for account", zap.String("acc", account),
+				zap.String("exg", exgName), zap.String("market", market), zap.String("pair", job.Symbol.Symbol))
+		}
+		return odMgr, exs, nil
+	}
+	var curEnters []*strat.EnterReq
+	var curExits []*strat.ExitReq
+	for _, ent := range enters {
+		if ent.Account == "" || ent.Account == o.Account {
+			curEnters = append(curEnters, ent)
+			continue
+		}
+		odMgr, exs, err := getAccExs(ent.Account, "")
+		if err != nil {
+			return curEnters, curExits, entOrders, extOrders, err
+		} else if exs == nil {
+			continue
+		}
+		iorder, err := odMgr.EnterOrder(sess, exs, job.TimeFrame, ent)
+		if err != nil {
+			return curEnters, curExits, entOrders, extOrders, err
+		}
+		if iorder != nil {
+			entOrders = append(entOrders, iorder)
+		}
+	}
+	for _, exit := range exits {
+		if exit.Group != "" {
+			iorders, err := exitGroupLegs(sess, exit.Group, exit)
+			if err != nil {
+				return curEnters, curExits, entOrders, extOrders, err
+			}
+			extOrders = append(extOrders, iorders...)
+			continue
+		}
+		if (exit.Account == "" || exit.Account == o.Account) && exit.Symbol == "" {
+			curExits = append(curExits, exit)
+			continue
+		}
+		odMgr, exs, err := getAccExs(exit.Account, exit.Symbol)
+		if err != nil {
+			return curEnters, curExits, entOrders, extOrders, err
+		} else if exs == nil {
+			continue
+		}
+		iorders, err := odMgr.ExitOpenOrders(sess, exs.Symbol, exit)
+		if err != nil {
+			return curEnters, curExits, entOrders, extOrders, err
+		}
+		extOrders = append(extOrders, iorders...)
+	}
+	return curEnters, curExits, entOrders, extOrders, nil
+}
+
+/*
+getRouteOdMgr
+Order manager of another account to route requests to. Only supported in real trading,
+as all accounts are merged into the default one in backtest and dry run.
+用于路由请求的其他账户的订单管理器。仅实盘支持，回测和模拟运行时所有账户合并为默认账户
+*/
+func getRouteOdMgr(account string) (IOrderMgr, *errs.Error) {
+	if !core.EnvReal {
+		return nil, errs.NewMsg(errs.CodeParamInvalid, "orders for other account %s are only supported in real trading", account)
+	}
+	odMgr := GetOdMgr(account)
+	if odMgr == nil {
+		return nil, errs.NewMsg(errs.CodeParamInvalid, "order manager not found: %s", account)
+	}
+	return odMgr, nil
+}
+
+/*
+splitHedgeLegs
+Separate enter requests of hedge legs (with EnterReq.Symbol) from normal requests
+从普通入场请求中分离出对冲腿（带EnterReq.Symbol）的请求
+*/
+func splitHedgeLegs(enters []*strat.EnterReq) ([]*strat.EnterReq, []*strat.EnterReq) {
+	var mains, legs []*strat.EnterReq
+	for _, ent := range enters {
+		if ent.Symbol != "" {
+			legs = append(legs, ent)
+		} else {
+			mains = append(mains, ent)
+		}
+	}
+	return mains, legs
+}
+
 func (o *LocalOrderMgr) EditOrder(od *ormo.InOutOrder, action string) {
 
 }
 
 func (o *OrderMgr) RelayOrders(sess *ormo.Queries, orders []*ormo.InOutOrder) *errs.Error {
-	symbolMap := orm.GetExSymbolMap(core.ExgName, core.Market)
+	symbolMap := orm.GetExSymbolMap(config.GetAccVenue(o.Account))
 	taskId := ormo.GetTaskID(o.Account)
 	for _, odr := range orders {
 		exs, ok := symbolMap[odr.Symbol]
 		if !ok {
 			return errs.NewMsg(errs.CodeNoMarketForPair, "%s not found", odr.Symbol)
 		}
-		price := core.GetPrice(odr.Symbol)
+		price := core.GetPriceSafe(exs.VenueKey())
+		if price <= 0 {
+			return errs.NewMsg(core.ErrRunTime, "price not loaded for %s", exs.VenueKey())
+		}
 		curTime := btime.TimeMS()
 		od := &ormo.InOutOrder{
 			IOrder: &ormo.IOrder{
@@ -363,7 +513,7 @@ func (o *OrderMgr) EnterOrder(sess *ormo.Queries, exs *orm.ExSymbol, tf string,
 }
 
 func (o *OrderMgr) enterOrder(sess *ormo.Queries, exs *orm.ExSymbol, tf string, req *strat.EnterReq, doCheck bool) (*ormo.InOutOrder, *errs.Error) {
-	isSpot := core.Market == banexg.MarketSpot
+	isSpot := exs.Market == banexg.MarketSpot
 	if req.Short && isSpot {
 		return nil, errs.NewMsg(core.ErrRunTime, "short oder is invalid for spot")
 	}
@@ -377,7 +527,7 @@ func (o *OrderMgr) enterOrder(sess *ormo.Queries, exs *orm.ExSymbol, tf string,
 	if req.Leverage == 0 {
 		req.Leverage = 1
 		if !isSpot {
-			exchange := exg.Default
+			exchange := exg.Get(o.Account)
 			exInfo := exchange.Info()
 			if exInfo.FixedLvg {
 				req.Leverage, _ = exchange.GetLeverage(exs.Symbol, 0, o.Account)
@@ -402,7 +552,7 @@ func (o *OrderMgr) enterOrder(sess *ormo.Queries, exs *orm.ExSymbol, tf string,
 			Short:     req.Short,
 			Status:    ormo.InOutStatusInit,
 			EnterTag:  req.Tag,
-			InitPrice: core.GetPrice(exs.Symbol),
+			InitPrice: core.GetPriceSafe(exs.VenueKey()),
 			Leverage:  req.Leverage,
 			EnterAt:   curTimeMS,
 			Strategy:  req.StratName,
@@ -437,6 +587,9 @@ func (o *OrderMgr) enterOrder(sess *ormo.Queries, exs *orm.ExSymbol, tf string,
 			od.SetInfo(ormo.OdInfoStopAfter, stopAfter)
 		}
 	}
+	if od.InitPrice <= 0 {
+		return nil, errs.NewMsg(core.ErrRunTime, "price not loaded for %s", exs.VenueKey())
+	}
 	od.SetInfo(ormo.OdInfoLegalCost, req.LegalCost)
 	if req.StopLoss > 0 {
 		od.SetStopLoss(&ormo.ExitTrigger{
@@ -444,6 +597,7 @@ func (o *OrderMgr) enterOrder(sess *ormo.Queries, exs *orm.ExSymbol, tf string,
 			Limit: req.StopLossLimit,
 			Rate:  req.StopLossRate,
 			Tag:   req.StopLossTag,
+			OCO:   req.Bracket,
 		})
 	}
 	if req.TakeProfit > 0 {
@@ -452,11 +606,27 @@ func (o *OrderMgr) enterOrder(sess *ormo.Queries, exs *orm.ExSymbol, tf string,
 			Limit: req.TakeProfitLimit,
 			Rate:  req.TakeProfitRate,
 			Tag:   req.TakeProfitTag,
+			OCO:   req.Bracket,
+		})
+	}
+	if req.TrailCallBack > 0 {
+		od.SetTrailStop(&ormo.ExitTrigger{
+			CallBack:   req.TrailCallBack,
+			Activation: req.TrailActivation,
+			Rate:       req.TrailRate,
+			Tag:        req.TrailTag,
+			OCO:        req.Bracket,
 		})
 	}
 	if req.ClientID != "" {
 		od.SetInfo(ormo.OdInfoClientID, req.ClientID)
 	}
+	if req.ExecAlgo != nil {
+		od.SetExecAlgo(true, req.ExecAlgo)
+	}
+	if req.Group != "" {
+		od.SetInfo(ormo.OdInfoGroup, req.Group)
+	}
 	err := od.Save(sess)
 	if err != nil {
 		return od, err
@@ -497,7 +667,7 @@ func (o *OrderMgr) ExitOpenOrders(sess *ormo.Queries, pairs string, req *strat.E
 				continue
 			}
 			if len(pairMap) > 0 {
-				if _, ok := pairMap[od.Symbol]; !ok {
+				if !pairMap[od.Symbol] && !pairMap[od.VenueKey()] {
 					continue
 				}
 			}
@@ -550,7 +720,7 @@ func (o *OrderMgr) ExitOpenOrders(sess *ormo.Queries, pairs string, req *strat.E
 				return nil, errs.NewMsg(errs.CodeParamInvalid, "ExitReq.Limit invalid for multi pairs")
 			}
 		}
-		price := core.GetPrice(symbol)
+		price := matches[0].CurPrice()
 		if price > 0 && (req.Limit-price)*float64(req.Dirt) > 0 {
 			isTakeProfit = true
 		}
@@ -647,7 +817,7 @@ func (o *OrderMgr) ExitOrder(sess *ormo.Queries, od *ormo.InOutOrder, req *strat
 		return nil, errs.NewMsg(errs.CodeParamInvalid, "`ExitReq.Dirt` mismatch with Order")
 	}
 	if req.Limit > 0 && core.IsLimitOrder(req.OrderType) {
-		price := core.GetPrice(od.Symbol)
+		price := od.CurPrice()
 		if price > 0 && (req.Limit-price)*float64(req.Dirt) > 0 {
 			// It is a valid limit order, set to take profit
 			// 是有效的限价出场单，设置到止盈中
@@ -656,6 +826,7 @@ func (o *OrderMgr) ExitOrder(sess *ormo.Queries, od *ormo.InOutOrder, req *strat
 				Rate:  req.ExitRate,
 				Tag:   req.Tag,
 			})
+			od.SetInfo(ormo.OdInfoExitSignal, req.Limit)
 			return o.postOrderExit(sess, od)
 		}
 	}
@@ -680,7 +851,14 @@ func (o *OrderMgr) exitOrder(sess *ormo.Queries, od *ormo.InOutOrder, req *strat
 		}
 		return o.exitOrder(sess, part, req)
 	}
+	if price := od.CurPrice(); price > 0 {
+		// record the signal price for slippage attribution 记录信号价格，用于滑点归因
+		od.SetInfo(ormo.OdInfoExitSignal, price)
+	}
 	od.SetExit(0, req.Tag, odType, 0)
+	if req.ExecAlgo != nil {
+		od.SetExecAlgo(false, req.ExecAlgo)
+	}
 	return o.postOrderExit(sess, od)
 }
 
@@ -702,7 +880,7 @@ Use the price to update the profit of the order, etc. It may trigger a margin ca
 */
 func (o *OrderMgr) UpdateByBar(allOpens []*ormo.InOutOrder, bar *orm.InfoKline) *errs.Error {
 	for _, od := range allOpens {
-		if od.Symbol != bar.Symbol || od.Timeframe != bar.TimeFrame || od.Status >= ormo.InOutStatusFullExit {
+		if od.VenueKey() != bar.Symbol || od.Timeframe != bar.TimeFrame || od.Status >= ormo.InOutStatusFullExit {
 			continue
 		}
 		od.UpdateProfits(bar.Close)
@@ -778,3 +956,47 @@ func CloseAccOrders(acc string, odList []*ormo.InOutOrder, req *strat.ExitReq) (
 	}
 	return closeNum, failNum, nil
 }
+
+/*
+EnterAccOrder
+Open an order for the account by EnterOrder, so risk limits and notifications apply as orders from strategies.
+Return an error if the order is rejected by risk limits.
+通过EnterOrder为账户开单，与策略的订单一样应用风控限制和通知。被风控拒绝时返回错误
+*/
+func EnterAccOrder(acc string, exs *orm.ExSymbol, tf string, req *strat.EnterReq) (*ormo.InOutOrder, *errs.Error) {
+	odMgr := GetOdMgr(acc)
+	if odMgr == nil {
+		return nil, errs.NewMsg(errs.CodeParamInvalid, "order manager not found: %s", acc)
+	}
+	sess, conn, err := ormo.Conn(orm.DbTrades, true)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	od, err := odMgr.EnterOrder(sess, exs, tf, req)
+	if err != nil {
+		return od, err
+	}
+	if od == nil {
+		return nil, errs.NewMsg(errs.CodeRunTime, "enter rejected by risk limits: %s", exs.Symbol)
+	}
+	return od, nil
+}
+
+/*
+ExitAccOrders
+Exit open orders of pairs (comma separated) matching req for the account by ExitOpenOrders
+通过ExitOpenOrders退出账户中匹配req的指定品种（逗号分隔）未平仓订单
+*/
+func ExitAccOrders(acc string, pairs string, req *strat.ExitReq) ([]*ormo.InOutOrder, *errs.Error) {
+	odMgr := GetOdMgr(acc)
+	if odMgr == nil {
+		return nil, errs.NewMsg(errs.CodeParamInvalid, "order manager not found: %s", acc)
+	}
+	sess, conn, err := ormo.Conn(orm.DbTrades, true)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	return odMgr.ExitOpenOrders(sess, pairs, req)
+}
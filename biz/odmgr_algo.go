@@ -0,0 +1,393 @@
+package biz
+
+import (
+	"fmt"
+	"github.com/banbox/banbot/btime"
+	"github.com/banbox/banbot/config"
+	"github.com/banbox/banbot/core"
+	"github.com/banbox/banbot/exg"
+	"github.com/banbox/banbot/orm/ormo"
+	"github.com/banbox/banbot/strat"
+	"github.com/banbox/banexg"
+	"github.com/banbox/banexg/errs"
+	"github.com/banbox/banexg/log"
+	"go.uber.org/zap"
+	"strings"
+	"time"
+)
+
+const algoClientTag = "algo"
+
+/*
+isAlgoClientId
+Whether the ClientOrderId is a child order of execution algorithm, in the form of: botName_algo{inOutId}_childNum
+是否是执行算法的子订单ClientOrderId，形如：botName_algo{inOutId}_childNum
+*/
+func isAlgoClientId(clientId string) bool {
+	return strings.HasPrefix(clientId, config.Name+"_"+algoClientTag)
+}
+
+/*
+startExecAlgo
+Register the order to WatchExecAlgos, the sub order will be split into child orders
+将订单注册到WatchExecAlgos，子订单将被拆分为多个小订单执行
+*/
+func (o *LiveOrderMgr) startExecAlgo(od *ormo.InOutOrder, isEnter bool) {
+	state := od.GetExecAlgo(isEnter)
+	if state.StartMS == 0 {
+		state.StartMS = btime.TimeMS()
+		state.NextMS = state.StartMS
+		od.DirtyInfo = true
+	}
+	o.lockAlgoOds.Lock()
+	o.algoOds[od.ID] = od
+	o.lockAlgoOds.Unlock()
+	subOd := od.Exit
+	if isEnter {
+		subOd = od.Enter
+	}
+	log.Info("start exec algo", zap.String("acc", o.Account), zap.String("key", od.Key()),
+		zap.String("algo", state.Name), zap.Float64("amt", subOd.Amount))
+}
+
+/*
+WatchExecAlgos
+Periodically submit child orders of TWAP/iceberg/POV, and roll up their fills into the parent order
+定期提交TWAP/冰山/POV的子订单，并将其成交汇总到父订单
+*/
+func (o *LiveOrderMgr) WatchExecAlgos() {
+	if o.isWatchAlgos {
+		return
+	}
+	o.isWatchAlgos = true
+	// Restore algos unfinished before restart
+	// 恢复重启前未完成的执行算法
+	openOds, lock := ormo.GetOpenODs(o.Account)
+	lock.Lock()
+	for _, od := range openOds {
+		enter := od.GetExecAlgo(true)
+		exit := od.GetExecAlgo(false)
+		if enter != nil && enter.StartMS > 0 && od.Enter.Status < ormo.OdStatusClosed ||
+			exit != nil && exit.StartMS > 0 && od.Exit != nil && od.Exit.Status < ormo.OdStatusClosed {
+			o.algoOds[od.ID] = od
+		}
+	}
+	lock.Unlock()
+	go func() {
+		defer func() {
+			o.isWatchAlgos = false
+		}()
+		for {
+			if !core.Sleep(time.Second * 3) {
+				return
+			}
+			o.lockAlgoOds.Lock()
+			ods := make([]*ormo.InOutOrder, 0, len(o.algoOds))
+			for _, od := range o.algoOds {
+				ods = append(ods, od)
+			}
+			o.lockAlgoOds.Unlock()
+			openIds := o.fetchAlgoOpenIds(ods)
+			for _, od := range ods {
+				odLock := od.Lock()
+				done := o.stepExecAlgo(od, openIds)
+				if od.IsDirty() {
+					err := od.Save(nil)
+					if err != nil {
+						log.Error("save algo order fail", zap.String("key", od.Key()), zap.Error(err))
+					}
+				}
+				odLock.Unlock()
+				if done {
+					o.lockAlgoOds.Lock()
+					delete(o.algoOds, od.ID)
+					o.lockAlgoOds.Unlock()
+				}
+			}
+		}
+	}()
+}
+
+/*
+fetchAlgoOpenIds
+Fetch open orders once for each symbol with pending child orders, return symbol: open order ids.
+Symbols failed to fetch are absent from the result.
+为每个有未完成子订单的品种获取一次挂单，返回品种: 挂单ID集合。获取失败的品种不在结果中
+*/
+func (o *LiveOrderMgr) fetchAlgoOpenIds(ods []*ormo.InOutOrder) map[string]map[string]bool {
+	symbols := make(map[string]bool)
+	for _, od := range ods {
+		odLock := od.Lock()
+		for _, isEnter := range []bool{true, false} {
+			state := od.GetExecAlgo(isEnter)
+			if state != nil && state.Child != "" {
+				symbols[od.Symbol] = true
+			}
+		}
+		odLock.Unlock()
+	}
+	result := make(map[string]map[string]bool)
+	args := map[string]interface{}{
+		banexg.ParamAccount: o.Account,
+	}
+	for symbol := range symbols {
+		exOds, err := o.exchange.FetchOpenOrders(symbol, 0, 0, args)
+		if err != nil {
+			log.Warn("fetch algo open orders fail", zap.String("acc", o.Account),
+				zap.String("pair", symbol), zap.String("err", err.Short()))
+			continue
+		}
+		ids := make(map[string]bool)
+		for _, exOd := range exOds {
+			ids[exOd.ID] = true
+		}
+		result[symbol] = ids
+	}
+	return result
+}
+
+/*
+stepExecAlgo
+Check the pending child order and submit the next one when it's time, return true when the algo is finished.
+openIds is from fetchAlgoOpenIds, the child is only fetched one by one when it's no longer open.
+检查未完成的子订单，到时间时提交下一个，执行算法结束时返回true。
+openIds来自fetchAlgoOpenIds，仅当子订单不再挂单时才单独查询
+*/
+func (o *LiveOrderMgr) stepExecAlgo(od *ormo.InOutOrder, openIds map[string]map[string]bool) bool {
+	isEnter := od.Enter.Status < ormo.OdStatusClosed
+	subOd := od.Exit
+	if isEnter {
+		subOd = od.Enter
+	}
+	state := od.GetExecAlgo(isEnter)
+	if od.Status >= ormo.InOutStatusFullExit || subOd == nil || state == nil || subOd.Status == ormo.OdStatusClosed {
+		if state != nil && state.Child != "" {
+			o.stopExecAlgo(od, isEnter)
+		}
+		return true
+	}
+	if state.StartMS == 0 || isEnter && od.ExitTag != "" {
+		// Exit algo not started yet, or the entering is being cancelled by tryExitPendingEnter
+		// 出场算法尚未开始，或入场正在被tryExitPendingEnter取消
+		return false
+	}
+	args := map[string]interface{}{
+		banexg.ParamAccount: o.Account,
+	}
+	curMS := btime.TimeMS()
+	if state.Child != "" {
+		ids, ok := openIds[od.Symbol]
+		if !ok {
+			// open orders of this symbol failed to fetch, retry next round
+			// 此品种挂单获取失败，下一轮重试
+			return false
+		}
+		var res *banexg.Order
+		var err *errs.Error
+		if ids[state.Child] {
+			res = &banexg.Order{ID: state.Child, Status: banexg.OdStatusOpen}
+		} else {
+			// Child is done (or not listed yet), fetch its final state
+			// 子订单已完成(或尚未列出)，查询其最终状态
+			res, err = o.exchange.FetchOrder(od.Symbol, state.Child, args)
+			if err != nil {
+				log.Warn("fetch algo child fail", zap.String("key", od.Key()), zap.String("err", err.Short()))
+				return false
+			}
+		}
+		if !banexg.IsOrderDone(res.Status) {
+			if state.Name == ormo.AlgoIceberg || curMS < state.NextMS {
+				return false
+			}
+			// Time of slice is up, cancel the unfilled child, the remaining goes to next slices
+			// 切片时间已到，撤销未成交的子订单，剩余数量移到后续切片
+			res, err = o.exchange.CancelOrder(state.Child, od.Symbol, args)
+			if err != nil {
+				log.Warn("cancel algo child fail", zap.String("key", od.Key()), zap.String("err", err.Short()))
+				return false
+			}
+		}
+		o.applyAlgoChild(od, isEnter, res)
+	}
+	remain, err := exg.PrecAmount(o.exchange, od.Symbol, subOd.Amount-state.Filled)
+	if err != nil || remain == 0 {
+		o.finishExecAlgo(od, isEnter)
+		return true
+	}
+	if curMS < state.NextMS {
+		return false
+	}
+	var vol float64
+	if state.Name == ormo.AlgoPOV {
+		_, vol, err = getPairMinsVol(config.AccVenueKey(o.Account, od.Symbol), 5)
+		if err != nil {
+			log.Warn("get pair vol for pov fail", zap.String("key", od.Key()), zap.String("err", err.Short()))
+			return false
+		}
+	}
+	amount := state.SliceAmount(subOd.Amount, curMS, vol)
+	state.NextMS = state.NextChildMS(curMS)
+	od.DirtyInfo = true
+	amount, err = exg.PrecAmount(o.exchange, od.Symbol, amount)
+	if err != nil || amount == 0 {
+		// The slice is too small, accumulate to next slice
+		// 切片太小，累积到下个切片
+		return false
+	}
+	err = o.submitAlgoChild(od, isEnter, amount)
+	if err != nil {
+		// Stop the algo to avoid retrying forever, the filled part is kept
+		// 停止执行算法避免无限重试，保留已成交部分
+		log.Error("submit algo child fail, stop algo", zap.String("key", od.Key()), zap.Error(err))
+		o.finishExecAlgo(od, isEnter)
+		return true
+	}
+	return false
+}
+
+func (o *LiveOrderMgr) submitAlgoChild(od *ormo.InOutOrder, isEnter bool, amount float64) *errs.Error {
+	subOd := od.Exit
+	if isEnter {
+		subOd = od.Enter
+	}
+	state := od.GetExecAlgo(isEnter)
+	clientId := fmt.Sprintf("%s_%s%v_%v", config.Name, algoClientTag, od.ID, state.ChildNum)
+	params := map[string]interface{}{
+		banexg.ParamAccount:       o.Account,
+		banexg.ParamClientOrderId: clientId,
+	}
+	if o.isContract {
+		params[banexg.ParamPositionSide] = "LONG"
+		if od.Short {
+			params[banexg.ParamPositionSide] = "SHORT"
+		}
+	}
+	var price float64
+	if subOd.OrderType != banexg.OdTypeMarket {
+		price = subOd.Price
+	}
+	res, err := o.exchange.CreateOrder(od.Symbol, subOd.OrderType, subOd.Side, amount, price, params)
+	if err != nil {
+		return err
+	}
+	state.ChildNum += 1
+	state.Child = res.ID
+	od.DirtyInfo = true
+	if banexg.IsOrderDone(res.Status) {
+		o.applyAlgoChild(od, isEnter, res)
+	}
+	return nil
+}
+
+/*
+applyAlgoChild
+Roll up a finished child order into the parent sub order
+将已完成的子订单汇总到父子订单
+*/
+func (o *LiveOrderMgr) applyAlgoChild(od *ormo.InOutOrder, isEnter bool, res *banexg.Order) {
+	subOd := od.Exit
+	if isEnter {
+		subOd = od.Enter
+	}
+	state := od.GetExecAlgo(isEnter)
+	state.Child = ""
+	od.DirtyInfo = true
+	if res == nil || res.Filled == 0 {
+		return
+	}
+	fillPrice := res.Average
+	if fillPrice == 0 {
+		fillPrice = res.Price
+	}
+	var fee float64
+	var feeType string
+	if res.Fee != nil {
+		fee, feeType = res.Fee.Cost, res.Fee.Currency
+	}
+	state.AddFill(res.Filled, fillPrice, fee, feeType)
+	if subOd.Filled == 0 && !isEnter {
+		od.ExitAt = res.Timestamp
+	}
+	subOd.Filled = state.Filled
+	subOd.Average = state.Average()
+	subOd.Fee = state.Fee
+	subOd.FeeType = state.FeeType
+	subOd.UpdateAt = res.Timestamp
+	subOd.Status = ormo.OdStatusPartOK
+	if isEnter {
+		od.Status = ormo.InOutStatusPartEnter
+		od.DirtyEnter = true
+		strat.FireOdChange(o.Account, od, strat.OdChgEnterFill)
+	} else {
+		od.DirtyExit = true
+	}
+	od.DirtyMain = true
+}
+
+/*
+stopExecAlgo
+Cancel the pending child order and roll up its fills, the sub order is left for the caller to close
+撤销未完成的子订单并汇总成交，子订单由调用方关闭
+*/
+func (o *LiveOrderMgr) stopExecAlgo(od *ormo.InOutOrder, isEnter bool) {
+	state := od.GetExecAlgo(isEnter)
+	if state == nil || state.Child == "" {
+		return
+	}
+	res, err := o.exchange.CancelOrder(state.Child, od.Symbol, map[string]interface{}{
+		banexg.ParamAccount: o.Account,
+	})
+	if err != nil {
+		log.Error("cancel algo child fail", zap.String("key", od.Key()), zap.String("err", err.Short()))
+		return
+	}
+	o.applyAlgoChild(od, isEnter, res)
+}
+
+/*
+finishExecAlgo
+Close the sub order after all child orders are done, same as a single order is done in updateOdByExgRes
+所有子订单完成后关闭子订单，和updateOdByExgRes中单个订单完成时一致
+*/
+func (o *LiveOrderMgr) finishExecAlgo(od *ormo.InOutOrder, isEnter bool) {
+	subOd := od.Exit
+	if isEnter {
+		subOd = od.Enter
+		od.DirtyEnter = true
+	} else {
+		od.DirtyExit = true
+	}
+	state := od.GetExecAlgo(isEnter)
+	subOd.Status = ormo.OdStatusClosed
+	if subOd.Filled > 0 && subOd.Average > 0 {
+		subOd.Price = subOd.Average
+	}
+	od.DirtyMain = true
+	log.Info("exec algo done", zap.String("acc", o.Account), zap.String("key", od.Key()),
+		zap.String("algo", state.Name), zap.Int("childs", state.ChildNum),
+		zap.Float64("amt", subOd.Amount), zap.Float64("filled", subOd.Filled))
+	if isEnter == (subOd.Filled > 0) {
+		od.Status = ormo.InOutStatusFullEnter
+	} else {
+		od.Status = ormo.InOutStatusFullExit
+	}
+	if od.Status == ormo.InOutStatusFullExit {
+		err := o.finishOrder(od, nil)
+		if err != nil {
+			log.Error("finish algo order fail", zap.String("key", od.Key()), zap.Error(err))
+		}
+		cancelTriggerOds(od)
+		o.callBack(od, false)
+		strat.FireOdChange(o.Account, od, strat.OdChgExitFill)
+		return
+	}
+	if isEnter {
+		// Place stop loss and take profit orders only after full entry
+		// 仅在完全入场后，下止损止盈单
+		o.editTriggerOd(od, ormo.OdActionStopLoss)
+		o.editTriggerOd(od, ormo.OdActionTakeProfit)
+		o.editTriggerOd(od, ormo.OdActionTrailStop)
+		o.callBack(od, true)
+	}
+	strat.FireOdChange(o.Account, od, strat.OdChgEnterFill)
+}
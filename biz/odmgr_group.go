@@ -0,0 +1,155 @@
+package biz
+
+import (
+	"github.com/banbox/banbot/core"
+	"github.com/banbox/banbot/exg"
+	"github.com/banbox/banbot/orm"
+	"github.com/banbox/banbot/orm/ormo"
+	"github.com/banbox/banbot/strat"
+	"github.com/banbox/banexg"
+	"github.com/banbox/banexg/errs"
+	"github.com/banbox/banexg/log"
+	"go.uber.org/zap"
+)
+
+var groupSubInit bool
+
+/*
+getOdExchange
+Exchange of the market the order is on, hedge legs may be on a market other than the account's
+订单所在市场的交易所，对冲腿可能位于非账户所属的市场
+*/
+func getOdExchange(od *ormo.InOutOrder) (banexg.BanExchange, *errs.Error) {
+	exs := orm.GetSymbolByID(int32(od.Sid))
+	if exs == nil {
+		return exg.Default, nil
+	}
+	exchange, err := exg.GetVenue(exs.Exchange, exs.Market)
+	if err != nil {
+		return nil, err
+	} else if exchange == exg.Default {
+		return exchange, nil
+	}
+	_, err = orm.LoadMarkets(exchange, false)
+	return exchange, err
+}
+
+/*
+enterHedgeLegs
+Open linked legs of hedge or spread requests. A leg is skipped when the main order of its group was requested but not created.
+开仓对冲或价差请求的关联腿。若所在组请求了主订单但未创建，则跳过此腿
+*/
+func (o *OrderMgr) enterHedgeLegs(sess *ormo.Queries, job *strat.StratJob, legs, mainReqs []*strat.EnterReq,
+	mains []*ormo.InOutOrder) ([]*ormo.InOutOrder, *errs.Error) {
+	reqGroups := make(map[string]bool)
+	for _, req := range mainReqs {
+		if req.Group != "" {
+			reqGroups[req.Group] = true
+		}
+	}
+	groups := make(map[string]bool)
+	for _, od := range mains {
+		if grp := od.GroupID(); grp != "" {
+			groups[grp] = true
+		}
+	}
+	var res []*ormo.InOutOrder
+	for _, leg := range legs {
+		if reqGroups[leg.Group] && !groups[leg.Group] {
+			log.Warn("skip hedge leg as main order not created", zap.String("group", leg.Group),
+				zap.String("symbol", leg.Symbol))
+			continue
+		}
+		exs, err := orm.GetExSymbolCur(leg.Symbol)
+		if err != nil {
+			return res, err
+		}
+		var iorder *ormo.InOutOrder
+		if leg.Account == "" || leg.Account == o.Account {
+			// The main order has passed the checks, don't skip legs by limits of open orders
+			// 主订单已通过检查，不因开单数量限制跳过对冲腿
+			iorder, err = o.enterOrder(sess, exs, job.TimeFrame, leg, false)
+		} else {
+			var odMgr IOrderMgr
+			odMgr, err = getRouteOdMgr(leg.Account)
+			if err != nil {
+				return res, err
+			}
+			iorder, err = odMgr.EnterOrder(sess, exs, job.TimeFrame, leg)
+		}
+		if err != nil {
+			return res, err
+		}
+		if iorder != nil {
+			res = append(res, iorder)
+		}
+	}
+	return res, nil
+}
+
+/*
+exitGroupLegs
+Exit all open orders sharing the group id on all accounts
+退出所有账户中共享此组ID的所有未平仓订单
+*/
+func exitGroupLegs(sess *ormo.Queries, group string, req *strat.ExitReq) ([]*ormo.InOutOrder, *errs.Error) {
+	var res []*ormo.InOutOrder
+	for _, od := range ormo.GetGroupODs(group) {
+		if od.ExitTag != "" || od.Exit != nil && od.Exit.Amount > 0 {
+			continue
+		}
+		odMgr := GetOdMgr(ormo.GetTaskAcc(od.TaskID))
+		if odMgr == nil {
+			continue
+		}
+		r := req.Clone()
+		r.Group = ""
+		r.Dirt = core.OdDirtBoth
+		r.OrderID = od.ID
+		r.StratName = od.Strategy
+		if r.Tag == "" {
+			r.Tag = core.ExitTagGroupExit
+		}
+		iorder, err := odMgr.ExitOrder(sess, od, r)
+		if err != nil {
+			return res, err
+		}
+		if iorder != nil {
+			res = append(res, iorder)
+		}
+	}
+	return res, nil
+}
+
+/*
+initGroupExitSub
+Exit the other legs when any leg of a group is fully exited, so that grouped positions are closed together
+当组内任意一条腿完全平仓时，退出其他腿，使组合仓位一起平仓
+*/
+func initGroupExitSub() {
+	if groupSubInit {
+		return
+	}
+	groupSubInit = true
+	strat.AddOdSub("*", func(acc string, od *ormo.InOutOrder, evt int) {
+		if evt != strat.OdChgExitFill || od.Status < ormo.InOutStatusFullExit {
+			return
+		}
+		group := od.GroupID()
+		if group == "" {
+			return
+		}
+		exitLegs := func() {
+			_, err := exitGroupLegs(nil, group, &strat.ExitReq{Tag: core.ExitTagGroupExit})
+			if err != nil {
+				log.Error("exit group legs fail", zap.String("group", group), zap.Error(err))
+			}
+		}
+		if core.LiveMode {
+			// order locks may be held by the caller in live mode 实盘时调用方可能持有订单锁
+			go exitLegs()
+		} else {
+			exitLegs()
+		}
+	})
+}
@@ -26,8 +26,21 @@ import (
 type FuncApplyMyTrade = func(od *ormo.InOutOrder, subOd *ormo.ExOrder, trade *banexg.MyTrade) *errs.Error
 type FuncHandleMyOrder = func(trade *banexg.Order) bool
 
+/*
+FuncTrailStopArgs
+Fill params for native trailing stop order and return the order type, return empty if not supported
+为原生跟踪止损单填充参数并返回订单类型，不支持时返回空
+*/
+type FuncTrailStopArgs = func(tg *ormo.TriggerState, params map[string]interface{}) string
+
 type LiveOrderMgr struct {
 	OrderMgr
+
+	// Exchange and market the account trades on 账户交易的交易所和市场
+	exchange   banexg.BanExchange
+	market     string
+	isContract bool
+
 	queue            chan *OdQItem
 	doneKeys         map[string]bool             // Completed Orders 已完成的订单：symbol+orderId
 	exgIdMap         map[string]*ormo.InOutOrder // symbol+orderId: InOutOrder
@@ -39,10 +52,14 @@ type LiveOrderMgr struct {
 	isTrialUnMatches bool                       // Is monitoring unmatched transactions? 是否正在监听未匹配交易
 	isConsumeOrderQ  bool                       // Is it consuming from the order queue? 是否正在从订单队列消费
 	isWatchAccConfig bool                       // Is the leverage ratio being monitored? 是否正在监听杠杆倍数变化
+	isWatchAlgos     bool                       // Is driving the execution algorithms? 是否正在驱动执行算法
 	unMatchTrades    map[string]*banexg.MyTrade // Transactions received from ws that have no matching orders 从ws收到的暂无匹配的订单的交易
 	lockUnMatches    deadlock.Mutex             // Prevent concurrent reading and writing of unMatchTrades 防止并发读写unMatchTrades
 	exitByMyOrder    FuncHandleMyOrder          // Try to use the transaction results of other end operations to update the current order status 尝试使用其他端操作的交易结果，更新当前订单状态
 	traceExgOrder    FuncHandleMyOrder
+	trailStopArgs    FuncTrailStopArgs          // Native trailing stop args, emulated on client side when nil 原生跟踪止损参数，为nil时客户端模拟
+	algoOds          map[int64]*ormo.InOutOrder // Orders executing by TWAP/iceberg/POV 正在按TWAP/冰山/POV执行的订单
+	lockAlgoOds      deadlock.Mutex
 }
 
 type OdQItem struct {
@@ -78,6 +95,7 @@ func InitLiveOrderMgr(callBack func(od *ormo.InOutOrder, isEnter bool)) {
 			mgr.callBack = callBack
 		}
 	}
+	initGroupExitSub()
 	if ormo.OdEditListener == nil {
 		ormo.OdEditListener = func(od *ormo.InOutOrder, action string) {
 			odMgr := GetOdMgr(ormo.GetTaskAcc(od.TaskID))
@@ -99,14 +117,20 @@ func newLiveOrderMgr(account string, callBack func(od *ormo.InOutOrder, isEnter
 		exgIdMap:      map[string]*ormo.InOutOrder{},
 		doneTrades:    map[string]bool{},
 		unMatchTrades: map[string]*banexg.MyTrade{},
+		algoOds:       map[int64]*ormo.InOutOrder{},
 	}
 	res.afterEnter = makeAfterEnter(res)
 	res.afterExit = makeAfterExit(res)
-	if core.ExgName == "binance" {
+	res.exchange = exg.Get(account)
+	_, res.market = config.GetAccVenue(account)
+	res.isContract = banexg.IsContract(res.market)
+	exgName := res.exchange.Info().ID
+	if exgName == "binance" {
 		res.exitByMyOrder = bnbExitByMyOrder(res)
 		res.traceExgOrder = bnbTraceExgOrder(res)
+		res.trailStopArgs = bnbTrailStopArgs(res)
 	} else {
-		panic("unsupport exchange for LiveOrderMgr: " + core.ExgName)
+		panic("unsupport exchange for LiveOrderMgr: " + exgName)
 	}
 	return res
 }
@@ -118,7 +142,7 @@ SyncLocalOrders 将交易所仓位和本地仓位对比，关闭本地多余仓
 */
 func (o *LiveOrderMgr) SyncLocalOrders() ([]*ormo.InOutOrder, *errs.Error) {
 	// 获取交易所所有持仓
-	posList, err := exg.Default.FetchAccountPositions(nil, map[string]interface{}{
+	posList, err := o.exchange.FetchAccountPositions(nil, map[string]interface{}{
 		banexg.ParamAccount: o.Account,
 	})
 	if err != nil {
@@ -255,7 +279,7 @@ For redundant positions, treat them as new orders opened by the user and create
 */
 func (o *LiveOrderMgr) SyncExgOrders() ([]*ormo.InOutOrder, []*ormo.InOutOrder, []*ormo.InOutOrder, *errs.Error) {
 	EnsurePricesLoaded()
-	exchange := exg.Default
+	exchange := o.exchange
 	task := ormo.GetTask(o.Account)
 	// Get the exchange order
 	// 获取交易所挂单
@@ -433,7 +457,7 @@ func (o *LiveOrderMgr) restoreInOutOrder(od *ormo.InOutOrder, exgOdMap map[strin
 		if !ok {
 			// The order has been cancelled or completed. Check the exchange order
 			// 订单已取消或已成交，查询交易所订单
-			exOd, err = exg.Default.FetchOrder(od.Symbol, tryOd.OrderID, map[string]interface{}{
+			exOd, err = o.exchange.FetchOrder(od.Symbol, tryOd.OrderID, map[string]interface{}{
 				banexg.ParamAccount: o.Account,
 			})
 			if err != nil {
@@ -491,7 +515,7 @@ func (o *LiveOrderMgr) syncPairOrders(pair, defTF string, longPos, shortPos *ban
 	// Get exchange order history and try to restore the order status.
 	// 从交易所获取订单记录，尝试恢复订单状态。
 	// 这里必须指定sinceMS，避免获取过早的订单创建冗余本地记录
-	exOrders, err = exg.Default.FetchOrders(pair, sinceMS, 300, map[string]interface{}{
+	exOrders, err = o.exchange.FetchOrders(pair, sinceMS, 300, map[string]interface{}{
 		banexg.ParamAccount: o.Account,
 		banexg.ParamUntil:   curMS,
 	})
@@ -578,10 +602,10 @@ func (o *LiveOrderMgr) syncPairOrders(pair, defTF string, longPos, shortPos *ban
 	}
 	if config.TakeOverStrat == "" {
 		if longBotAmt > AmtDust || shortBotAmt > AmtDust {
-			price := core.GetPrice(pair)
+			price, _ := o.getPrice(pair)
 			longCost := longBotAmt * price
 			shortCost := shortBotAmt * price
-			if longCost > 1 || shortCost > 1 {
+			if longCost > 1 || shortCost > 1 || price == 0 {
 				log.Error("unknown exchange position for bot", zap.String("pair", pair),
 					zap.Float64("long", longCost), zap.Float64("short", shortCost))
 			}
@@ -688,7 +712,7 @@ func calcHoldAmounts(orders []*banexg.Order, longAmt, shortAmt float64) (float64
 	return longBot, shortBot, longOther, shortOther
 }
 
-func getFeeNameCost(fee *banexg.Fee, pair, odType, side string, amount, price float64) (string, float64) {
+func getFeeNameCost(exchange banexg.BanExchange, fee *banexg.Fee, pair, odType, side string, amount, price float64) (string, float64) {
 	isMaker := false
 	if fee != nil {
 		if fee.Cost > 0 {
@@ -698,7 +722,7 @@ func getFeeNameCost(fee *banexg.Fee, pair, odType, side string, amount, price fl
 	} else {
 		isMaker = odType != banexg.OdTypeMarket
 	}
-	fee, err := exg.Default.CalculateFee(pair, odType, side, amount, price, isMaker, nil)
+	fee, err := exchange.CalculateFee(pair, odType, side, amount, price, isMaker, nil)
 	if err != nil {
 		log.Error("calc fee fail getFeeNameCost", zap.Error(err))
 		return "", 0
@@ -709,11 +733,11 @@ func getFeeNameCost(fee *banexg.Fee, pair, odType, side string, amount, price fl
 func (o *LiveOrderMgr) applyHisOrder(sess *ormo.Queries, ods []*ormo.InOutOrder, od *banexg.Order, defTF string) ([]*ormo.InOutOrder, *errs.Error) {
 	isShort := od.PositionSide == banexg.PosSideShort
 	isSell := od.Side == banexg.OdSideSell
-	exs, err := orm.GetExSymbolCur(od.Symbol)
+	exs, err := orm.GetExSymbol(o.exchange, od.Symbol)
 	if err != nil {
 		return ods, err
 	}
-	feeName, feeCost := getFeeNameCost(od.Fee, od.Symbol, od.Type, od.Side, od.Filled, od.Average)
+	feeName, feeCost := getFeeNameCost(o.exchange, od.Fee, od.Symbol, od.Type, od.Side, od.Filled, od.Average)
 	price, amount, odTime := od.Average, od.Filled, od.Timestamp
 	defTF = config.GetTakeOverTF(od.Symbol, defTF)
 
@@ -856,7 +880,7 @@ func (o *LiveOrderMgr) createOdFromPos(pos *banexg.Position, defTF string) (*orm
 		msg := fmt.Sprintf("take over job not found, %s %s", pos.Symbol, config.TakeOverStrat)
 		return nil, errs.NewMsg(core.ErrBadConfig, msg)
 	}
-	exs, err := orm.GetExSymbolCur(pos.Symbol)
+	exs, err := orm.GetExSymbol(o.exchange, pos.Symbol)
 	if err != nil {
 		return nil, err
 	}
@@ -864,7 +888,7 @@ func (o *LiveOrderMgr) createOdFromPos(pos *banexg.Position, defTF string) (*orm
 	isShort := pos.Side == banexg.PosSideShort
 	// There is no handling fee for position information. The handling fee is inferred directly from the current robot order type, which may be different from the actual handling fee.
 	//持仓信息没有手续费，直接从当前机器人订单类型推断手续费，可能和实际的手续费不同
-	feeName, feeCost := getFeeNameCost(nil, pos.Symbol, "", pos.Side, pos.Contracts, pos.EntryPrice)
+	feeName, feeCost := getFeeNameCost(o.exchange, nil, pos.Symbol, "", pos.Side, pos.Contracts, pos.EntryPrice)
 	tag := "LONG"
 	if isShort {
 		tag = "SHORT"
@@ -1048,7 +1072,7 @@ func (o *LiveOrderMgr) handleOrderQueue(od *ormo.InOutOrder, action string) {
 		err = o.execOrderEnter(od)
 	case ormo.OdActionExit:
 		err = o.execOrderExit(od)
-	case ormo.OdActionStopLoss, ormo.OdActionTakeProfit:
+	case ormo.OdActionStopLoss, ormo.OdActionTakeProfit, ormo.OdActionTrailStop:
 		o.editTriggerOd(od, action)
 	case ormo.OdActionLimitEnter, ormo.OdActionLimitExit:
 		err = o.editLimitOd(od, action)
@@ -1088,7 +1112,7 @@ func (o *LiveOrderMgr) WatchMyTrades() {
 	if o.isWatchMyTrade {
 		return
 	}
-	out, err := exg.Default.WatchMyTrades(map[string]interface{}{
+	out, err := o.exchange.WatchMyTrades(map[string]interface{}{
 		banexg.ParamAccount: o.Account,
 	})
 	if err != nil {
@@ -1114,6 +1138,11 @@ func (o *LiveOrderMgr) handleMyTrade(trade *banexg.MyTrade) {
 		// 忽略不处理的交易对
 		return
 	}
+	if isAlgoClientId(trade.ClientID) {
+		// Child orders of execution algorithms are polled by WatchExecAlgos
+		// 执行算法的子订单由WatchExecAlgos轮询
+		return
+	}
 	tradeKey := trade.Symbol + trade.ID
 	o.lockDoneTrades.Lock()
 	_, ok := o.doneTrades[tradeKey]
@@ -1185,6 +1214,7 @@ func (o *LiveOrderMgr) handleMyTrade(trade *banexg.MyTrade) {
 			// 仅在完全入场后，下止损止盈单
 			o.editTriggerOd(iod, ormo.OdActionStopLoss)
 			o.editTriggerOd(iod, ormo.OdActionTakeProfit)
+			o.editTriggerOd(iod, ormo.OdActionTrailStop)
 		}
 		err = iod.Save(nil)
 		if err != nil {
@@ -1290,15 +1320,18 @@ func (o *LiveOrderMgr) updateByMyTrade(od *ormo.InOutOrder, trade *banexg.MyTrad
 	}
 	sl := od.GetStopLoss()
 	tp := od.GetTakeProfit()
+	ts := od.GetTrailStop()
 	isSell := trade.Side == banexg.OdSideSell
 	isEnter := od.Short == isSell
 	subOd := od.Exit
 	dirtTag := "enter"
-	var isStopLoss, isTakeProfit bool
+	var isStopLoss, isTakeProfit, isTrailStop bool
 	if sl != nil && sl.OrderId == trade.Order {
 		isStopLoss = true
 	} else if tp != nil && tp.OrderId == trade.Order {
 		isTakeProfit = true
+	} else if ts != nil && ts.OrderId == trade.Order {
+		isTrailStop = true
 	}
 	if isEnter {
 		subOd = od.Enter
@@ -1310,6 +1343,8 @@ func (o *LiveOrderMgr) updateByMyTrade(od *ormo.InOutOrder, trade *banexg.MyTrad
 			od.SetExit(0, core.ExitTagStopLoss, banexg.OdTypeMarket, 0)
 		} else if isTakeProfit {
 			od.SetExit(0, core.ExitTagTakeProfit, banexg.OdTypeTakeProfit, 0)
+		} else if isTrailStop {
+			od.SetExit(0, core.ExitTagTrailStop, banexg.OdTypeMarket, 0)
 		} else {
 			// TODO: 检查是否是用户主动平仓，用户可能一次性平仓多个，需要更新相关订单状态
 			log.Error(fmt.Sprintf("%s subOd %s nil, trade state: %s", od.Key(), dirtTag, trade.State))
@@ -1384,6 +1419,9 @@ func (o *LiveOrderMgr) updateByMyTrade(od *ormo.InOutOrder, trade *banexg.MyTrad
 		} else if isTakeProfit {
 			tp.OrderId = ""
 			od.DirtyInfo = true
+		} else if isTrailStop {
+			ts.OrderId = ""
+			od.DirtyInfo = true
 		}
 		err := o.finishOrder(od, nil)
 		if err != nil {
@@ -1393,6 +1431,13 @@ func (o *LiveOrderMgr) updateByMyTrade(od *ormo.InOutOrder, trade *banexg.MyTrad
 		o.callBack(od, subOd.Enter)
 		strat.FireOdChange(o.Account, od, strat.OdChgExitFill)
 	} else {
+		if isStopLoss {
+			od.CancelOCO(ormo.OdInfoStopLoss)
+		} else if isTakeProfit {
+			od.CancelOCO(ormo.OdInfoTakeProfit)
+		} else if isTrailStop {
+			od.CancelOCO(ormo.OdInfoTrailStop)
+		}
 		strat.FireOdChange(o.Account, od, strat.OdChgEnterFill)
 	}
 	return nil
@@ -1437,10 +1482,13 @@ func (o *LiveOrderMgr) execOrderEnter(od *ormo.InOutOrder) *errs.Error {
 				}
 			}
 		}
-		realPrice := core.GetPrice(od.Symbol)
 		// The market price should be used to calculate the quantity here, because the input price may be very different from the market price
 		// 这里应使用市价计算数量，因传入价格可能和市价相差很大
-		od.Enter.Amount, err = exg.PrecAmount(exg.Default, od.Symbol, od.QuoteCost/realPrice)
+		var realPrice float64
+		realPrice, err = o.getPrice(od.Symbol)
+		if err == nil {
+			od.Enter.Amount, err = exg.PrecAmount(o.exchange, od.Symbol, od.QuoteCost/realPrice)
+		}
 		if err != nil {
 			forceDelOd(err)
 			return nil
@@ -1468,8 +1516,10 @@ func (o *LiveOrderMgr) tryExitPendingEnter(od *ormo.InOutOrder) *errs.Error {
 	}
 	// May not have entered yet, or may not have fully entered
 	// 可能尚未入场，或未完全入场
-	if od.Enter.OrderID != "" {
-		order, err := exg.Default.CancelOrder(od.Enter.OrderID, od.Symbol, map[string]interface{}{
+	if od.GetExecAlgo(true) != nil {
+		o.stopExecAlgo(od, true)
+	} else if od.Enter.OrderID != "" {
+		order, err := o.exchange.CancelOrder(od.Enter.OrderID, od.Symbol, map[string]interface{}{
 			banexg.ParamAccount: o.Account,
 		})
 		if err != nil {
@@ -1532,7 +1582,7 @@ func (o *LiveOrderMgr) submitExgOrder(od *ormo.InOutOrder, isEnter bool) *errs.E
 		}
 	}
 	var err *errs.Error
-	exchange := exg.Default
+	exchange := o.exchange
 	leverage, maxLeverage := exg.GetLeverage(od.Symbol, od.QuoteCost, o.Account)
 	if isEnter && od.Leverage > 0 && od.Leverage != leverage {
 		newLeverage := min(maxLeverage, od.Leverage)
@@ -1592,19 +1642,28 @@ func (o *LiveOrderMgr) submitExgOrder(od *ormo.InOutOrder, isEnter bool) *errs.E
 			return nil
 		}
 	}
+	if od.GetExecAlgo(isEnter) != nil {
+		// Child orders are submitted by WatchExecAlgos
+		// 子订单由WatchExecAlgos提交
+		o.startExecAlgo(od, isEnter)
+		return nil
+	}
 	side, amount, price := subOd.Side, subOd.Amount, subOd.Price
 	params := map[string]interface{}{
 		banexg.ParamAccount:       o.Account,
 		banexg.ParamClientOrderId: od.ClientId(true),
 	}
-	if core.IsContract {
+	if o.isContract {
 		params[banexg.ParamPositionSide] = "LONG"
 		if od.Short {
 			params[banexg.ParamPositionSide] = "SHORT"
 		}
 	}
+	startAt := btime.UTCStamp()
 	res, err := exchange.CreateOrder(od.Symbol, subOd.OrderType, side, amount, price, params)
+	core.ObserveMetric(core.MetricSubmitSeconds, float64(btime.UTCStamp()-startAt)/1000, "account", o.Account)
 	if err != nil {
+		core.AddMetric(core.MetricSubmitFailures, 1, "account", o.Account, "code", strconv.Itoa(err.Code))
 		if !isEnter && err.BizCode == -2022 {
 			msg := "ReduceOnly Order is rejected."
 			log.Error("close exg pos fail", zap.String("key", od.Key()), zap.Error(err))
@@ -1629,6 +1688,7 @@ func (o *LiveOrderMgr) submitExgOrder(od *ormo.InOutOrder, isEnter bool) *errs.E
 			// 仅在完全入场后，下止损止盈单
 			o.editTriggerOd(od, ormo.OdActionStopLoss)
 			o.editTriggerOd(od, ormo.OdActionTakeProfit)
+			o.editTriggerOd(od, ormo.OdActionTrailStop)
 		}
 	} else {
 		// Close a position and cancel associated orders
@@ -1727,7 +1787,7 @@ func (o *LiveOrderMgr) updateOdByExgRes(od *ormo.InOutOrder, isEnter bool, res *
 }
 
 func (o *LiveOrderMgr) hasNewTrades(res *banexg.Order) bool {
-	if core.IsContract {
+	if o.isContract {
 		// 期货市场未返回trades，直接认为需要更新
 		return true
 	}
@@ -1802,7 +1862,7 @@ func (o *LiveOrderMgr) getLimitPrice(pair string, waitSecs int) (float64, float6
 	}
 	// Invalid or expired, need to be recalculated
 	// 无效或过期，需要重新计算
-	avgVol, lastVol, err := getPairMinsVol(pair, 5)
+	avgVol, lastVol, err := getPairMinsVol(config.AccVenueKey(o.Account, pair), 5)
 	if err != nil {
 		log.Error("getPairMinsVol fail for getLimitPrice", zap.String("pair", pair), zap.Error(err))
 	}
@@ -1810,7 +1870,7 @@ func (o *LiveOrderMgr) getLimitPrice(pair string, waitSecs int) (float64, float6
 	// 5-minute trading volume per second * waiting seconds * 2: The final multiplication by 2 here is to prevent the trading volume from being too low
 	// 5分钟每秒成交量*等待秒数*2：这里最后乘2是以防成交量过低
 	depth := min(avgVol/30*secsFlt, lastVol/60*secsFlt)
-	book, err := exg.GetOdBook(pair)
+	book, err := exg.GetOdBook(o.Account, pair)
 	var buyPrice, sellPrice float64
 	if err != nil {
 		buyPrice, sellPrice = 0, 0
@@ -1838,6 +1898,28 @@ Get the average volume per minute and the volume of the last minute over a perio
 获取一段时间内，每分钟平均成交量，以及最后一分钟成交量
 此函数有缓存，每分钟更新
 */
+/*
+getPrice
+Latest price of the symbol on the exchange and market of the account, fetch prices from the exchange if not loaded
+账户所在交易所和市场上品种的最新价格，未加载时从交易所获取
+*/
+func (o *LiveOrderMgr) getPrice(pair string) (float64, *errs.Error) {
+	exgName, market := config.GetAccVenue(o.Account)
+	key := core.VenueKey(exgName, market, pair)
+	if price := core.GetPriceSafe(key); price > 0 {
+		return price, nil
+	}
+	res, err := o.exchange.FetchTickerPrice(pair, nil)
+	if err != nil {
+		return 0, err
+	}
+	core.SetVenuePrices(exgName, market, res)
+	if price := core.GetPriceSafe(key); price > 0 {
+		return price, nil
+	}
+	return 0, errs.NewMsg(core.ErrRunTime, "price not found for %s", key)
+}
+
 func getPairMinsVol(pair string, num int) (float64, float64, *errs.Error) {
 	cacheKey := fmt.Sprintf("%s_%v", pair, num)
 	lockPairVolMap.Lock()
@@ -1852,7 +1934,11 @@ func getPairMinsVol(pair string, num int) (float64, float64, *errs.Error) {
 		if err != nil {
 			return 0, 0, err
 		}
-		_, bars, err := orm.AutoFetchOHLCV(exg.Default, exs, "1m", 0, 0, num, false, nil)
+		exchange, err := exg.GetWith(exs.Exchange, exs.Market, "")
+		if err != nil {
+			return 0, 0, err
+		}
+		_, bars, err := orm.AutoFetchOHLCV(exchange, exs, "1m", 0, 0, num, false, nil)
 		if err != nil {
 			return 0, 0, err
 		} else if len(bars) == 0 {
@@ -1883,19 +1969,19 @@ func isFarEnter(od *ormo.InOutOrder) bool {
 	if stopAfter == 0 || stopAfter <= btime.TimeMS() {
 		return false
 	}
-	return isFarLimit(od.Enter)
+	return isFarLimit(ormo.GetTaskAcc(od.TaskID), od.Enter)
 }
 
 /*
 Determine whether an order is a limit order that is difficult to execute for a long time
 判断一个订单是否是长时间难以成交的限价单
 */
-func isFarLimit(od *ormo.ExOrder) bool {
+func isFarLimit(account string, od *ormo.ExOrder) bool {
 	if od.Price == 0 || !strings.Contains(od.OrderType, banexg.OdTypeLimit) {
 		// 非限价单，或没有指定价格，会很快成交
 		return false
 	}
-	secs, rate, err := getSecsByLimit(od.Symbol, od.Side, od.Price)
+	secs, rate, err := getSecsByLimit(account, od.Symbol, od.Side, od.Price)
 	if err != nil {
 		log.Error("getSecsByLimit for isFarLimit fail", zap.String("pair", od.Symbol),
 			zap.String("side", od.Side), zap.Float64("price", od.Price), zap.Error(err))
@@ -1941,11 +2027,11 @@ func verifyAccountTriggerOds(account string) {
 		var book *banexg.OrderBook
 		// Calculate the past 50 minutes, average volume, and last minute volume
 		// 计算过去50分钟，平均成交量，以及最后一分钟成交量
-		avgVol, lastVol, err := getPairMinsVol(pair, 50)
+		avgVol, lastVol, err := getPairMinsVol(config.AccVenueKey(account, pair), 50)
 		if err == nil {
 			secsVol = max(avgVol, lastVol) / 60
 			if secsVol > 0 {
-				book, err = exg.GetOdBook(pair)
+				book, err = exg.GetOdBook(account, pair)
 			} else {
 				zeros = append(zeros, pair)
 			}
@@ -1980,7 +2066,7 @@ func verifyAccountTriggerOds(account string) {
 			} else {
 				stopAfter := od.GetInfoInt64(ormo.OdInfoStopAfter)
 				if stopAfter <= btime.TimeMS() {
-					cancelTimeoutEnter(odMgr, od)
+					cancelTimeoutEnter(odMgr, od, "reach StopEnterBars")
 					saves = append(saves, od)
 				} else {
 					leftOds[od.ID] = od
@@ -2020,8 +2106,8 @@ getSecsByLimit
 Based on the target price, calculate the approximate waiting time for the transaction.
 根据目标价格，计算大概成交需要等待的时长。
 */
-func getSecsByLimit(pair, side string, price float64) (int, float64, *errs.Error) {
-	avgVol, lastVol, err := getPairMinsVol(pair, 50)
+func getSecsByLimit(account, pair, side string, price float64) (int, float64, *errs.Error) {
+	avgVol, lastVol, err := getPairMinsVol(config.AccVenueKey(account, pair), 50)
 	if err != nil {
 		return 0, 1, err
 	}
@@ -2029,7 +2115,7 @@ func getSecsByLimit(pair, side string, price float64) (int, float64, *errs.Error
 	if secsVol == 0 {
 		return 0, 1, nil
 	}
-	book, err := exg.GetOdBook(pair)
+	book, err := exg.GetOdBook(account, pair)
 	if err != nil {
 		return 0, 1, err
 	}
@@ -2054,11 +2140,11 @@ func saveIOrders(saveOds []*ormo.InOutOrder) {
 	}
 }
 
-func cancelTimeoutEnter(odMgr *LiveOrderMgr, od *ormo.InOutOrder) {
+func cancelTimeoutEnter(odMgr *LiveOrderMgr, od *ormo.InOutOrder, msg string) {
 	lock := od.Lock()
 	defer lock.Unlock()
 	if od.Enter.OrderID != "" {
-		res, err := exg.Default.CancelOrder(od.Enter.OrderID, od.Symbol, map[string]interface{}{
+		res, err := odMgr.exchange.CancelOrder(od.Enter.OrderID, od.Symbol, map[string]interface{}{
 			banexg.ParamAccount: odMgr.Account,
 		})
 		if err != nil {
@@ -2073,10 +2159,10 @@ func cancelTimeoutEnter(odMgr *LiveOrderMgr, od *ormo.InOutOrder) {
 	if od.Enter.Filled == 0 {
 		// Not yet filled, exit directly
 		// 尚未入场，直接退出
-		err := od.LocalExit(0, core.ExitTagForceExit, od.InitPrice, "reach StopEnterBars", "")
+		err := od.LocalExit(0, core.ExitTagForceExit, od.InitPrice, msg, "")
 		strat.FireOdChange(odMgr.Account, od, strat.OdChgExitFill)
 		if err != nil {
-			log.Error("local exit for StopEnterBars fail", zap.String("key", od.Key()), zap.Error(err))
+			log.Error("local exit for "+msg+" fail", zap.String("key", od.Key()), zap.Error(err))
 		}
 	} else {
 		// Partial filled, set to fully admitted
@@ -2094,11 +2180,11 @@ func (o *LiveOrderMgr) editLimitOd(od *ormo.InOutOrder, action string) *errs.Err
 	if action == ormo.OdActionLimitExit {
 		subOd = od.Exit
 	}
-	exchange := exg.Default
+	exchange := o.exchange
 	args := map[string]interface{}{
 		banexg.ParamAccount: o.Account,
 	}
-	if core.Market != banexg.MarketLinear && core.Market != banexg.MarketInverse {
+	if o.market != banexg.MarketLinear && o.market != banexg.MarketInverse {
 		// Spot, Margin, Options. Cancel the old order first, then create a new order
 		// 现货，保证金，期权。先取消旧订单，再创建新订单
 		_, err := exchange.CancelOrder(subOd.OrderID, od.Symbol, args)
@@ -2133,11 +2219,22 @@ func (o *LiveOrderMgr) editTriggerOd(od *ormo.InOutOrder, prefix string) {
 	}
 	tg.SaveOld()
 	od.DirtyInfo = true
-	if tg.Price <= 0 {
-		// Stop loss/take profit is not set, or needs to be cancelled
-		// 未设置止损/止盈，或需要撤销
+	params := map[string]interface{}{
+		banexg.ParamAccount:       o.Account,
+		banexg.ParamClientOrderId: od.ClientId(true),
+	}
+	var nativeType string
+	if prefix == ormo.OdActionTrailStop && tg.CallBack > 0 && o.trailStopArgs != nil {
+		// Place trailing stop on exchange when supported, otherwise emulate it by stop loss order moved with price
+		// 交易所支持时直接下跟踪止损单，否则使用随价格移动的止损单模拟
+		nativeType = o.trailStopArgs(tg, params)
+	}
+	tg.Native = nativeType != ""
+	if tg.Price <= 0 && !tg.Native {
+		// Stop loss/take profit is not set, or needs to be cancelled, or trailing stop is not activated
+		// 未设置止损/止盈，或需要撤销，或跟踪止损尚未激活
 		if tg.OrderId != "" {
-			_, err := exg.Default.CancelOrder(tg.OrderId, od.Symbol, map[string]interface{}{
+			_, err := o.exchange.CancelOrder(tg.OrderId, od.Symbol, map[string]interface{}{
 				banexg.ParamAccount: o.Account,
 			})
 			if err != nil {
@@ -2145,14 +2242,11 @@ func (o *LiveOrderMgr) editTriggerOd(od *ormo.InOutOrder, prefix string) {
 			}
 			tg.OrderId = ""
 			od.SetExitTrigger(prefix, nil)
+			fireTriggerChange(o.Account, od, prefix)
 		}
 		return
 	}
-	params := map[string]interface{}{
-		banexg.ParamAccount:       o.Account,
-		banexg.ParamClientOrderId: od.ClientId(true),
-	}
-	if core.IsContract {
+	if o.isContract {
 		params[banexg.ParamPositionSide] = "LONG"
 		if od.Short {
 			params[banexg.ParamPositionSide] = "SHORT"
@@ -2160,19 +2254,25 @@ func (o *LiveOrderMgr) editTriggerOd(od *ormo.InOutOrder, prefix string) {
 	}
 	var odType = banexg.OdTypeMarket
 	var price = tg.Price
-	if tg.Limit > 0 {
+	if tg.Native {
+		odType = nativeType
+		price = 0
+	} else if tg.Limit > 0 {
 		odType = banexg.OdTypeLimit
 		price = tg.Limit
 	}
 	// 这里不应设置ClosePosition仓位止盈止损，否则多策略或多个订单止盈止损会互相覆盖
 	// 双向持仓无需设置ReduceOnly
-	if prefix == ormo.OdActionStopLoss {
-		params[banexg.ParamStopLossPrice] = tg.Price
-	} else if prefix == ormo.OdActionTakeProfit {
-		params[banexg.ParamTakeProfitPrice] = tg.Price
-	} else {
-		log.Error("invalid trigger ", zap.String("prefix", prefix))
-		return
+	// 原生跟踪止损的参数已由trailStopArgs填充
+	if !tg.Native {
+		if prefix == ormo.OdActionStopLoss || prefix == ormo.OdActionTrailStop {
+			params[banexg.ParamStopLossPrice] = tg.Price
+		} else if prefix == ormo.OdActionTakeProfit {
+			params[banexg.ParamTakeProfitPrice] = tg.Price
+		} else {
+			log.Error("invalid trigger ", zap.String("prefix", prefix))
+			return
+		}
 	}
 	side := banexg.OdSideSell
 	if od.Short {
@@ -2185,7 +2285,7 @@ func (o *LiveOrderMgr) editTriggerOd(od *ormo.InOutOrder, prefix string) {
 	log.Debug("set trigger", zap.String("acc", o.Account), zap.String("key", od.Key()),
 		zap.Float64("amt", od.Enter.Amount), zap.Float64("qmt", amt),
 		zap.Float64("price", od.Enter.Average))
-	res, err := exg.Default.CreateOrder(od.Symbol, odType, side, amt, price, params)
+	res, err := o.exchange.CreateOrder(od.Symbol, odType, side, amt, price, params)
 	if err != nil {
 		if err.BizCode == -2021 {
 			// Stop loss and stop profit are executed immediately, and the position is closed at the market price
@@ -2217,13 +2317,14 @@ func (o *LiveOrderMgr) editTriggerOd(od *ormo.InOutOrder, prefix string) {
 		od.DirtyInfo = true
 	}
 	if orderId != "" && (res == nil || res.Status == "open") {
-		_, err = exg.Default.CancelOrder(orderId, od.Symbol, map[string]interface{}{
+		_, err = o.exchange.CancelOrder(orderId, od.Symbol, map[string]interface{}{
 			banexg.ParamAccount: o.Account,
 		})
 		if err != nil {
 			log.Error("cancel old trigger fail", zap.String("key", od.Key()), zap.Error(err))
 		}
 	}
+	fireTriggerChange(o.Account, od, prefix)
 }
 
 /*
@@ -2232,35 +2333,26 @@ Cancel the associated order of the order. When the order is closed, the associat
 取消订单的关联订单。订单在平仓时，关联的止损单止盈单不会自动退出，需要调用此方法退出
 */
 func cancelTriggerOds(od *ormo.InOutOrder) {
-	sl := od.GetStopLoss()
-	tp := od.GetTakeProfit()
-	if sl == nil && tp == nil {
-		return
-	}
 	odKey := od.Key()
+	account := ormo.GetTaskAcc(od.TaskID)
 	args := map[string]interface{}{
-		banexg.ParamAccount: ormo.GetTaskAcc(od.TaskID),
+		banexg.ParamAccount: account,
 	}
 	var logFields []zap.Field
-	if sl != nil && sl.OrderId != "" {
-		_, err := exg.Default.CancelOrder(sl.OrderId, od.Symbol, args)
-		if err != nil {
-			log.Warn("cancel stopLoss fail", zap.String("key", odKey), zap.String("err", err.Short()))
-		} else {
-			logFields = append(logFields, zap.String("sl", sl.OrderId))
+	for _, key := range ormo.ExitTriggerKeys {
+		tg := od.GetExitTrigger(key)
+		if tg == nil || tg.OrderId == "" {
+			continue
 		}
-		sl.OrderId = ""
-		od.DirtyInfo = true
-	}
-	if tp != nil && tp.OrderId != "" {
-		_, err := exg.Default.CancelOrder(tp.OrderId, od.Symbol, args)
+		_, err := exg.Get(account).CancelOrder(tg.OrderId, od.Symbol, args)
 		if err != nil {
-			log.Warn("cancel takeProfit fail", zap.String("key", odKey), zap.String("err", err.Short()))
+			log.Warn("cancel "+key+" fail", zap.String("key", odKey), zap.String("err", err.Short()))
 		} else {
-			logFields = append(logFields, zap.String("tp", tp.OrderId))
+			logFields = append(logFields, zap.String(key, tg.OrderId))
 		}
-		tp.OrderId = ""
+		tg.OrderId = ""
 		od.DirtyInfo = true
+		fireTriggerChange(account, od, key)
 	}
 	if len(logFields) > 0 {
 		logFields = append(logFields, zap.String("key", odKey))
@@ -2291,10 +2383,10 @@ func (o *LiveOrderMgr) finishOrder(od *ormo.InOutOrder, sess *ormo.Queries) *err
 }
 
 func (o *LiveOrderMgr) WatchLeverages() {
-	if !core.IsContract || o.isWatchAccConfig {
+	if !o.isContract || o.isWatchAccConfig {
 		return
 	}
-	out, err := exg.Default.WatchAccountConfig(map[string]interface{}{
+	out, err := o.exchange.WatchAccountConfig(map[string]interface{}{
 		banexg.ParamAccount: o.Account,
 	})
 	if err != nil {
@@ -2384,6 +2476,28 @@ func calcFatalLoss(wallets *BanWallets, orders []*ormo.InOutOrder, backMins int)
 	return lossVal / (lossVal + totalLegal)
 }
 
+// trailMoveMinRate min change rate of emulated trailing stop to re-place the stop order 模拟跟踪止损重新挂单的最小变化率
+const trailMoveMinRate = 0.002
+
+/*
+UpdateByBar
+Besides updating profits, move the stop price of trailing stops which are emulated on client side
+除更新利润外，移动客户端模拟的跟踪止损的止损价格
+*/
+func (o *LiveOrderMgr) UpdateByBar(allOpens []*ormo.InOutOrder, bar *orm.InfoKline) *errs.Error {
+	err := o.OrderMgr.UpdateByBar(allOpens, bar)
+	if err != nil {
+		return err
+	}
+	for _, od := range allOpens {
+		if od.Symbol != bar.Symbol || od.Timeframe != bar.TimeFrame || od.Status != ormo.InOutStatusFullEnter {
+			continue
+		}
+		od.UpdateTrailStop(bar.High, bar.Low, trailMoveMinRate)
+	}
+	return nil
+}
+
 func (o *LiveOrderMgr) OnEnvEnd(bar *banexg.PairTFKline, adj *orm.AdjInfo) *errs.Error {
 	sess, conn, err := ormo.Conn(orm.DbTrades, true)
 	if err != nil {
@@ -2407,8 +2521,158 @@ func (o *LiveOrderMgr) ExitAndFill(sess *ormo.Queries, orders []*ormo.InOutOrder
 	return nil
 }
 
+var (
+	shutPolicy     string // policy chosen for this shutdown by SetShutdownPolicy 通过SetShutdownPolicy为本次退出选择的策略
+	lockShutPolicy deadlock.Mutex
+)
+
+/*
+SetShutdownPolicy
+Choose the policy for this shutdown (e.g. from api), config.Shutdown is left unchanged
+为本次退出选择策略(如来自api)，不修改config.Shutdown
+*/
+func SetShutdownPolicy(policy string) {
+	lockShutPolicy.Lock()
+	shutPolicy = policy
+	lockShutPolicy.Unlock()
+}
+
+/*
+GetShutdownPolicy
+Policy for this shutdown: the one from SetShutdownPolicy, or config.Shutdown.Policy, default core.ShutKeep
+本次退出的策略：SetShutdownPolicy设置的，或config.Shutdown.Policy，默认core.ShutKeep
+*/
+func GetShutdownPolicy() string {
+	lockShutPolicy.Lock()
+	policy := shutPolicy
+	lockShutPolicy.Unlock()
+	if policy == "" && config.Shutdown != nil {
+		policy = config.Shutdown.Policy
+	}
+	if policy == "" {
+		policy = core.ShutKeep
+	}
+	return policy
+}
+
+/*
+CleanUp
+Apply shutdown policy on exit: cancel pending entries, ensure stop loss on exchange, close positions by policy,
+and save dirty orders. The order queue is consumed here because its consumer stops with core.Ctx.
+退出时应用shutdown策略：取消未成交的入场单，确保交易所止损单，按策略平仓，保存未保存的订单。
+订单队列的消费者随core.Ctx停止，这里直接消费队列
+*/
 func (o *LiveOrderMgr) CleanUp() *errs.Error {
-	return nil
+	policy := GetShutdownPolicy()
+	openOds, lock := ormo.GetOpenODs(o.Account)
+	lock.Lock()
+	odList := utils.ValsOfMap(openOds)
+	lock.Unlock()
+	cancelNum, holds, closes, unprotected := planShutdown(odList, policy, func(od *ormo.InOutOrder) {
+		cancelTimeoutEnter(o, od, "bot shutdown")
+	}, o.ensureStopLoss)
+	if len(closes) > 0 {
+		closeNum, failNum, err := CloseAccOrders(o.Account, closes, &strat.ExitReq{
+			Tag:   core.ExitTagBotStop,
+			Force: true,
+		})
+		if err != nil {
+			log.Error("close orders on shutdown fail", zap.String("acc", o.Account), zap.Error(err))
+		}
+		log.Info("close orders on shutdown", zap.String("acc", o.Account), zap.String("policy", policy),
+			zap.Int("closed", closeNum), zap.Int("fail", failNum))
+	}
+	o.drainQueue()
+	if len(unprotected) > 0 {
+		log.Warn("keep orders without stop loss on exchange", zap.String("acc", o.Account),
+			zap.Strings("keys", unprotected))
+	}
+	log.Info("shutdown order manager", zap.String("acc", o.Account), zap.String("policy", policy),
+		zap.Int("cancel_enter", cancelNum), zap.Int("hold", len(holds)-len(closes)))
+	return ormo.SaveDirtyODs(orm.DbTrades, o.Account)
+}
+
+/*
+planShutdown
+Cancel pending entries of open orders by cancelEnter, and split filled orders by policy. protect ensures the stop loss
+of an order on exchange and returns whether it's protected, it's skipped for close_all.
+Return number of cancelled entries, filled orders, orders to close and keys of unprotected orders kept.
+通过cancelEnter取消未平仓订单的未成交入场单，并按策略拆分已成交订单。protect确保订单在交易所的止损并返回是否受保护，close_all时跳过。
+返回取消的入场单数量、已成交订单、待平仓订单、保留的未受保护订单键
+*/
+func planShutdown(odList []*ormo.InOutOrder, policy string, cancelEnter func(od *ormo.InOutOrder),
+	protect func(od *ormo.InOutOrder) bool) (int, []*ormo.InOutOrder, []*ormo.InOutOrder, []string) {
+	var holds []*ormo.InOutOrder
+	cancelNum := 0
+	for _, od := range odList {
+		if od.Status >= ormo.InOutStatusFullExit {
+			continue
+		}
+		if od.Enter.Status < ormo.OdStatusClosed {
+			cancelEnter(od)
+			cancelNum += 1
+		}
+		if od.Status < ormo.InOutStatusFullExit && od.Enter.Filled > 0 {
+			holds = append(holds, od)
+		}
+	}
+	var closes []*ormo.InOutOrder
+	var unprotected []string
+	for _, od := range holds {
+		if policy == core.ShutCloseAll || !protect(od) {
+			if policy == core.ShutKeep {
+				unprotected = append(unprotected, od.Key())
+			} else {
+				closes = append(closes, od)
+			}
+		}
+	}
+	return cancelNum, holds, closes, unprotected
+}
+
+/*
+ensureStopLoss
+Submit stop loss or trailing stop of the order to exchange if not yet, return whether it's protected on exchange
+订单的止损或跟踪止损尚未提交到交易所时提交，返回是否已在交易所受保护
+*/
+func (o *LiveOrderMgr) ensureStopLoss(od *ormo.InOutOrder) bool {
+	lock := od.Lock()
+	defer lock.Unlock()
+	for _, key := range []string{ormo.OdActionStopLoss, ormo.OdActionTrailStop} {
+		tg := od.GetExitTrigger(key)
+		if tg == nil || tg.ExitTrigger == nil || tg.Hit {
+			continue
+		}
+		if tg.OrderId == "" && (tg.Price > 0 || tg.CallBack > 0) {
+			// clear old to submit again 清空旧值以重新提交
+			tg.Old = nil
+			o.editTriggerOd(od, key)
+		}
+	}
+	return od.Status < ormo.InOutStatusFullExit && IsProtected(od)
+}
+
+// drainQueue handle queued items synchronously 同步处理队列中的项
+func (o *LiveOrderMgr) drainQueue() {
+	for {
+		select {
+		case item := <-o.queue:
+			o.handleOrderQueue(item.Order, item.Action)
+		default:
+			return
+		}
+	}
+}
+
+// IsProtected whether stop loss or trailing stop of the order is placed on exchange 订单的止损或跟踪止损是否已挂到交易所
+func IsProtected(od *ormo.InOutOrder) bool {
+	for _, key := range []string{ormo.OdActionStopLoss, ormo.OdActionTrailStop} {
+		tg := od.GetExitTrigger(key)
+		if tg != nil && tg.OrderId != "" && !tg.Hit {
+			return true
+		}
+	}
+	return false
 }
 
 func StartLiveOdMgr() {
@@ -2425,5 +2689,7 @@ func StartLiveOdMgr() {
 		odMgr.ConsumeOrderQueue()
 		// Monitor leverage changes 监听杠杆倍数变化
 		odMgr.WatchLeverages()
+		// Drive child orders of execution algorithms 驱动执行算法的子订单
+		odMgr.WatchExecAlgos()
 	}
 }
@@ -2,13 +2,13 @@ package biz
 
 import (
 	"github.com/banbox/banbot/config"
-	"github.com/banbox/banbot/core"
 	"github.com/banbox/banbot/orm"
 	"github.com/banbox/banbot/orm/ormo"
 	"github.com/banbox/banexg"
 	"github.com/banbox/banexg/errs"
 	"github.com/banbox/banexg/log"
 	"go.uber.org/zap"
+	"math"
 )
 
 func bnbExitByMyOrder(o *LiveOrderMgr) FuncHandleMyOrder {
@@ -87,7 +87,7 @@ func bnbExitByMyOrder(o *LiveOrderMgr) FuncHandleMyOrder {
 
 func (o *LiveOrderMgr) makeInOutOd(sess *ormo.Queries, pair string, short bool, average, filled float64, odType string,
 	feeCost float64, feeName string, enterAt int64, entStatus int, entOdId string) *ormo.InOutOrder {
-	exs, err := orm.GetExSymbolCur(pair)
+	exs, err := orm.GetExSymbol(o.exchange, pair)
 	if err != nil {
 		log.Error("get exSymbol fail", zap.Error(err))
 		return nil
@@ -119,7 +119,7 @@ func bnbTraceExgOrder(o *LiveOrderMgr) FuncHandleMyOrder {
 			return false
 		}
 		isShort := od.PositionSide == banexg.PosSideShort
-		if core.IsContract {
+		if o.isContract {
 			if !isShort && od.Side == banexg.OdSideSell || isShort && od.Side == banexg.OdSideBuy {
 				// Ignore closed orders 忽略平仓的订单
 				return false
@@ -134,7 +134,7 @@ func bnbTraceExgOrder(o *LiveOrderMgr) FuncHandleMyOrder {
 			return true
 		}
 		defer conn.Close()
-		feeName, feeCost := getFeeNameCost(od.Fee, od.Symbol, od.Type, od.Side, od.Amount, od.Average)
+		feeName, feeCost := getFeeNameCost(o.exchange, od.Fee, od.Symbol, od.Type, od.Side, od.Amount, od.Average)
 		iod := o.makeInOutOd(sess, od.Symbol, isShort, od.Average, od.Filled, od.Type, feeCost, feeName,
 			od.Timestamp, ormo.OdStatusClosed, od.ID)
 		if iod != nil {
@@ -143,3 +143,31 @@ func bnbTraceExgOrder(o *LiveOrderMgr) FuncHandleMyOrder {
 		return true
 	}
 }
+
+const (
+	bnbOdTypeTrailStop      = "TRAILING_STOP_MARKET"
+	bnbParamCallbackRate    = "callbackRate"
+	bnbParamActivationPrice = "activationPrice"
+)
+
+/*
+bnbTrailStopArgs
+Only binance futures support native trailing stop, callbackRate is percent in [0.1, 10] with precision 0.1
+仅币安合约支持原生跟踪止损，callbackRate为百分比，范围[0.1, 10]，精度0.1
+*/
+func bnbTrailStopArgs(o *LiveOrderMgr) FuncTrailStopArgs {
+	return func(tg *ormo.TriggerState, params map[string]interface{}) string {
+		if !o.isContract {
+			return ""
+		}
+		rate := math.Round(tg.CallBack*1000) / 10
+		if rate < 0.1 || rate > 10 {
+			return ""
+		}
+		params[bnbParamCallbackRate] = rate
+		if tg.Activation > 0 {
+			params[bnbParamActivationPrice] = tg.Activation
+		}
+		return bnbOdTypeTrailStop
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"github.com/banbox/banbot/btime"
 	"github.com/banbox/banbot/config"
 	"github.com/banbox/banbot/core"
-	"github.com/banbox/banbot/exg"
 	"github.com/banbox/banbot/orm"
 	"github.com/banbox/banbot/orm/ormo"
 	"github.com/banbox/banbot/strat"
@@ -13,6 +12,7 @@ import (
 	"github.com/banbox/banexg/log"
 	"github.com/banbox/banexg/utils"
 	"go.uber.org/zap"
+	"math"
 	"strings"
 )
 
@@ -39,6 +39,7 @@ func InitLocalOrderMgr(callBack FnOdCb, showLog bool) {
 			accOdMgrs[account] = mgr
 		}
 	}
+	initGroupExitSub()
 }
 
 func (o *LocalOrderMgr) ProcessOrders(sess *ormo.Queries, job *strat.StratJob) ([]*ormo.InOutOrder, []*ormo.InOutOrder, *errs.Error) {
@@ -54,7 +55,7 @@ func (o *LocalOrderMgr) UpdateByBar(allOpens []*ormo.InOutOrder, bar *orm.InfoKl
 	var curOrders []*ormo.InOutOrder
 	var curMap = make(map[int64]bool)
 	for _, od := range allOpens {
-		if od.Symbol == bar.Symbol {
+		if od.VenueKey() == bar.Symbol {
 			curOrders = append(curOrders, od)
 			curMap[od.ID] = true
 		}
@@ -62,16 +63,24 @@ func (o *LocalOrderMgr) UpdateByBar(allOpens []*ormo.InOutOrder, bar *orm.InfoKl
 	if len(curOrders) == 0 && !core.CheckWallets {
 		return nil
 	}
+	setBarRange(&bar.Kline, bar.Symbol)
 	curOrders, err := o.fillPendingOrdersAll(curOrders, curMap, bar)
 	if err != nil {
 		return err
 	}
+	applyFunding(curOrders, bar)
 	// Update all orders to profit at the end of the bar
 	// 更新所有订单在bar结束时利润
 	err = o.OrderMgr.UpdateByBar(curOrders, bar)
 	if err != nil {
 		return err
 	}
+	if len(curOrders) > 0 && !core.LiveMode {
+		err = o.exitDelisted(bar)
+		if err != nil {
+			return err
+		}
+	}
 	if core.IsContract && core.CheckWallets {
 		// Update all order margins and wallet status of this pricing currency for the contract
 		// 为合约更新此定价币的所有订单保证金和钱包情况
@@ -84,6 +93,11 @@ func (o *LocalOrderMgr) UpdateByBar(allOpens []*ormo.InOutOrder, bar *orm.InfoKl
 			}
 		}
 		wallets := GetWallets(o.Account)
+		orders, err = o.checkLiquidation(wallets, orders, code)
+		if err != nil {
+			_ = wallets.UpdateOds(orders, code)
+			return err
+		}
 		err = wallets.UpdateOds(orders, code)
 	}
 	return err
@@ -101,7 +115,7 @@ func (o *LocalOrderMgr) fillPendingOrdersAll(orders []*ormo.InOutOrder, curMap m
 		var newOds []*ormo.InOutOrder
 		lock.Lock()
 		for _, od := range openOds {
-			if _, ok := curMap[od.ID]; !ok && (bar == nil || od.Symbol == bar.Symbol) {
+			if _, ok := curMap[od.ID]; !ok && (bar == nil || od.VenueKey() == bar.Symbol) {
 				newOds = append(newOds, od)
 				orders = append(orders, od)
 				curMap[od.ID] = true
@@ -140,6 +154,21 @@ func (o *LocalOrderMgr) fillPendingOrders(orders []*ormo.InOutOrder, bar *orm.In
 		if bar != nil && bar.TimeFrame != od.Timeframe {
 			continue
 		}
+		if od.ExitTag != "" && od.Enter.Status < ormo.OdStatusClosed && bar != nil {
+			// Exit while the entering algo is running, commit the filled part first
+			// 入场执行算法未完成时出场，先提交已成交的部分
+			if state := od.GetExecAlgo(true); state != nil && state.Filled > 0 {
+				od.Enter.Amount = state.Filled
+				od.QuoteCost = state.Cost
+				err := o.fillPendingEnter(od, state.Average(), bar.Time)
+				if err != nil {
+					return 0, err
+				}
+				if od.Exit != nil {
+					od.Exit.Amount = od.Enter.Filled
+				}
+			}
+		}
 		var exOrder *ormo.ExOrder
 		if od.ExitTag != "" && od.Exit != nil && od.Exit.Status < ormo.OdStatusClosed {
 			exOrder = od.Exit
@@ -159,15 +188,40 @@ func (o *LocalOrderMgr) fillPendingOrders(orders []*ormo.InOutOrder, bar *orm.In
 		if exOrder.OrderType != "" {
 			odType = exOrder.OrderType
 		}
+		if bar != nil {
+			if state := od.GetExecAlgo(exOrder.Enter); state != nil {
+				done, ok, err := o.fillAlgoOrder(od, exOrder, state, odType, bar)
+				if err != nil {
+					return 0, err
+				}
+				if ok {
+					if done {
+						affectNum += 1
+					}
+					continue
+				}
+			}
+		}
 		price := exOrder.Price
 		odTFSecs := utils.TFToSecs(od.Timeframe)
 		fillMS := exOrder.CreateAt + int64(config.BTNetCost*1000)
 		barStartMS := utils.AlignTfMSecs(fillMS, int64(odTFSecs*1000))
 		var fillBarRate float64
 		if bar == nil {
-			price = core.GetPrice(od.Symbol)
+			price = od.RefPrice()
 		} else if odType == banexg.OdTypeLimit && exOrder.Price > 0 {
-			if exOrder.Side == banexg.OdSideBuy {
+			odIsBuy := exOrder.Side == banexg.OdSideBuy
+			minRate := float64((exOrder.CreateAt-barStartMS)/1000) / float64(odTFSecs)
+			path := getIntraPath(od, &bar.Kline)
+			if path != nil {
+				// Replay lower timeframe bars for the exact fill time and price
+				// 回放更小周期K线，得到精确的成交时间和价格
+				var ok bool
+				price, fillBarRate, ok = path.limitFill(exOrder.Price, odIsBuy, minRate)
+				if !ok {
+					continue
+				}
+			} else if exOrder.Side == banexg.OdSideBuy {
 				if price < bar.Low {
 					continue
 				} else if price > bar.Open {
@@ -184,14 +238,18 @@ func (o *LocalOrderMgr) fillPendingOrders(orders []*ormo.InOutOrder, bar *orm.In
 					price = bar.Open
 				}
 			}
-			odIsBuy := exOrder.Side == banexg.OdSideBuy
-			minRate := float64((exOrder.CreateAt-barStartMS)/1000) / float64(odTFSecs)
-			fillBarRate = simMarketRate(&bar.Kline, exOrder.Price, odIsBuy, false, minRate)
+			if path == nil {
+				fillBarRate = simMarketRate(&bar.Kline, exOrder.Price, odIsBuy, false, minRate)
+			}
 			fillMS = bar.Time + int64(float64(odTFSecs)*fillBarRate)*1000
 		} else {
 			// 按网络延迟，模拟成交价格，和开盘价接近According to the network delay, the simulated transaction price is close to the opening price
 			fillBarRate = float64((fillMS-barStartMS)/1000) / float64(odTFSecs)
-			price = simMarketPrice(&bar.Kline, fillBarRate)
+			if path := getIntraPath(od, &bar.Kline); path != nil {
+				price = path.marketPrice(fillBarRate)
+			} else {
+				price = simMarketPrice(&bar.Kline, fillBarRate)
+			}
 		}
 		var err *errs.Error
 		if exOrder.Enter {
@@ -242,7 +300,10 @@ func (o *LocalOrderMgr) fillPendingEnter(od *ormo.InOutOrder, price float64, fil
 		}
 		return err
 	}
-	exchange := exg.Default
+	exchange, err := getOdExchange(od)
+	if err != nil {
+		return err
+	}
 	market, err := exchange.GetMarket(od.Symbol)
 	if err != nil {
 		return err
@@ -253,7 +314,7 @@ func (o *LocalOrderMgr) fillPendingEnter(od *ormo.InOutOrder, price float64, fil
 	}
 	exOrder := od.Enter
 	if exOrder.Amount == 0 {
-		if od.Short && !core.IsContract {
+		if od.Short && !banexg.IsContract(market.Type) {
 			// Spot short order, quantity must be given
 			// 现货空单，必须给定数量
 			return errs.NewMsg(core.ErrInvalidCost, "EnterAmount is required")
@@ -301,6 +362,90 @@ func (o *LocalOrderMgr) fillPendingEnter(od *ormo.InOutOrder, price float64, fil
 	return nil
 }
 
+/*
+fillAlgoOrder
+Simulate child orders of the execution algorithm within the bar; the sub order is filled at the average price
+once all child orders are done. ok is false when the algo can't be simulated and should be filled as a normal order.
+在bar内模拟执行算法的子订单；所有子订单完成后按均价成交子订单。ok为false表示无法模拟，应按普通订单成交
+*/
+func (o *LocalOrderMgr) fillAlgoOrder(od *ormo.InOutOrder, exOrder *ormo.ExOrder, state *ormo.AlgoState,
+	odType string, bar *orm.InfoKline) (bool, bool, *errs.Error) {
+	tfMSecs := int64(utils.TFToSecs(od.Timeframe) * 1000)
+	barEndMS := bar.Time + tfMSecs
+	if state.StartMS == 0 {
+		state.StartMS = exOrder.CreateAt + int64(config.BTNetCost*1000)
+		od.DirtyInfo = true
+	}
+	if state.StartMS >= barEndMS {
+		return false, true, nil
+	}
+	var startRate float64
+	if state.StartMS > bar.Time {
+		startRate = float64(state.StartMS-bar.Time) / float64(tfMSecs)
+	}
+	if exOrder.Amount == 0 {
+		exchange, err := getOdExchange(od)
+		if err != nil || !exOrder.Enter {
+			return false, false, nil
+		}
+		market, err := exchange.GetMarket(od.Symbol)
+		if err != nil || od.Short && !banexg.IsContract(market.Type) {
+			return false, false, nil
+		}
+		amount, err := exchange.PrecAmount(market, od.QuoteCost/simMarketPrice(&bar.Kline, startRate))
+		if err != nil || amount == 0 {
+			return false, false, nil
+		}
+		exOrder.Amount = amount
+	}
+	endRate := 1.0
+	remain := exOrder.Amount - state.Filled
+	var amount float64
+	switch state.Name {
+	case ormo.AlgoTWAP:
+		amount = state.SliceAmount(exOrder.Amount, barEndMS, 0)
+		if endMS := state.EndMS(); endMS < barEndMS {
+			endRate = max(startRate, float64(endMS-bar.Time)/float64(tfMSecs))
+		}
+	case ormo.AlgoPOV:
+		amount = min(remain, bar.Volume*(1-startRate)*state.MaxRate)
+	default:
+		// The hidden part of iceberg is invisible to backtest, only limited by MaxRate
+		// 冰山单的隐藏部分在回测中不可见，仅受MaxRate限制
+		amount = remain
+		if state.MaxRate > 0 {
+			amount = min(remain, bar.Volume*(1-startRate)*state.MaxRate)
+		}
+	}
+	price := simMarketPrice(&bar.Kline, (startRate+endRate)/2)
+	if odType == banexg.OdTypeLimit && exOrder.Price > 0 {
+		isBuy := exOrder.Side == banexg.OdSideBuy
+		if isBuy && exOrder.Price < bar.Low || !isBuy && exOrder.Price > bar.High {
+			amount = 0
+		} else if isBuy && price > exOrder.Price || !isBuy && price < exOrder.Price {
+			price = exOrder.Price
+		}
+	}
+	if amount > 0 {
+		state.AddFill(amount, price, 0, "")
+		od.DirtyInfo = true
+	}
+	if state.Filled < exOrder.Amount*0.9999 {
+		return false, true, nil
+	}
+	fillMS := barEndMS
+	if endMS := state.EndMS(); endMS > 0 && endMS < fillMS {
+		fillMS = endMS
+	}
+	var err *errs.Error
+	if exOrder.Enter {
+		err = o.fillPendingEnter(od, state.Average(), fillMS)
+	} else {
+		err = o.fillPendingExit(od, state.Average(), fillMS)
+	}
+	return true, true, err
+}
+
 func (o *LocalOrderMgr) fillPendingExit(od *ormo.InOutOrder, price float64, fillMS int64) *errs.Error {
 	wallets := GetWallets(o.Account)
 	exOrder := od.Exit
@@ -329,39 +474,60 @@ func (o *LocalOrderMgr) fillPendingExit(od *ormo.InOutOrder, price float64, fill
 }
 
 func (o *LocalOrderMgr) tryFillTriggers(od *ormo.InOutOrder, bar *banexg.Kline, afterRate float64) *errs.Error {
-	sl := od.GetStopLoss()
-	tp := od.GetTakeProfit()
-	if sl == nil && tp == nil {
+	if od.GetStopLoss() == nil && od.GetTakeProfit() == nil && od.GetTrailStop() == nil {
 		return nil
 	}
-	if sl != nil && !sl.Hit {
-		// 空单止损，最高价超过止损价触发
-		// Short order stop loss, triggered when the highest price exceeds the stop loss price
-		// 多单止损，最低价跌破止损价触发
-		// Stop loss for long orders, triggered when the lowest price falls below the stop loss price
-		sl.Hit = od.Short && bar.High >= sl.Price || !od.Short && bar.Low <= sl.Price
+	path := getIntraPath(od, bar)
+	if path == nil {
+		_, err := o.fillTriggers(od, bar, afterRate, 0, 1)
+		return err
 	}
-	if tp != nil && !tp.Hit {
-		// 空单止盈，最低价跌破止盈价触发
-		// Short order stop profit, the lowest price falls below the stop profit price to trigger
-		// 多单止盈，最高价突破止盈价触发
-		// Long order stop profit, the highest price breaks through the stop profit price to trigger
-		tp.Hit = od.Short && bar.Low <= tp.Price || !od.Short && bar.High >= tp.Price
+	// Replay lower timeframe bars one by one, the first triggered one decides the exit
+	// 逐个回放更小周期K线，首个触发的决定出场
+	for i := path.find(afterRate); i < len(path.bars); i++ {
+		spanStart, spanSize := path.span(i)
+		done, err := o.fillTriggers(od, path.bars[i], path.subRate(i, afterRate), spanStart, spanSize)
+		if err != nil || done {
+			return err
+		}
 	}
-	if (sl == nil || !sl.Hit) && (tp == nil || !tp.Hit) {
-		// 止损和止盈都未触发
-		return nil
+	return nil
+}
+
+/*
+fillTriggers
+Check and fill stop loss, take profit and trailing stop of od within bar, which spans [spanStart, spanStart+spanSize)
+of the order bar. Return whether the order exited.
+检查并在bar内成交od的止损、止盈和跟踪止损，bar占订单bar的[spanStart, spanStart+spanSize)。返回订单是否已退出
+*/
+func (o *LocalOrderMgr) fillTriggers(od *ormo.InOutOrder, bar *banexg.Kline, afterRate, spanStart, spanSize float64) (bool, *errs.Error) {
+	hitKey, trailRate := hitTriggers(od, bar, afterRate)
+	if hitKey == "" {
+		return false, nil
 	}
-	od.DirtyInfo = true
+	sl := od.GetStopLoss()
+	tp := od.GetTakeProfit()
+	ts := od.GetTrailStop()
+	useTrail := hitKey == ormo.OdInfoTrailStop
 	tfSecs := float64(utils.TFToSecs(od.Timeframe))
 	var fillPrice, trigPrice, amtRate float64
 	var exitTag string
-	if sl != nil && sl.Hit {
+	if useTrail {
+		// Trigger trailing stop, exit at market price
+		// 触发跟踪止损，市价出场
+		trigPrice = ts.Price
+		amtRate = ts.Rate
+		if ts.Tag != "" {
+			exitTag = ts.Tag
+		} else {
+			exitTag = core.ExitTagTrailStop
+		}
+	} else if sl != nil && sl.Hit {
 		// Trigger stop loss and calculate execution price
 		// 触发止损，计算执行价格
 		trigPrice = sl.Price
 		amtRate = sl.Rate
-		fillPrice = getExcPrice(od, bar, sl.Price, sl.Limit, afterRate, tfSecs)
+		fillPrice = getExcPrice(od, bar, sl.Price, sl.Limit, afterRate, tfSecs*spanSize)
 		if sl.Tag != "" {
 			exitTag = sl.Tag
 		} else {
@@ -376,7 +542,7 @@ func (o *LocalOrderMgr) tryFillTriggers(od *ormo.InOutOrder, bar *banexg.Kline,
 		// 触发止盈，计算执行价格
 		trigPrice = tp.Price
 		amtRate = tp.Rate
-		fillPrice = getExcPrice(od, bar, tp.Price, tp.Limit, afterRate, tfSecs)
+		fillPrice = getExcPrice(od, bar, tp.Price, tp.Limit, afterRate, tfSecs*spanSize)
 		if fillPrice == 0 && tp.Limit > 0 {
 			// 设置了限价止盈，强制使用止盈价出场
 			fillPrice = tp.Limit
@@ -387,10 +553,10 @@ func (o *LocalOrderMgr) tryFillTriggers(od *ormo.InOutOrder, bar *banexg.Kline,
 			exitTag = core.ExitTagTakeProfit
 		}
 	} else {
-		return nil
+		return false, nil
 	}
 	if fillPrice < 0 {
-		return nil
+		return false, nil
 	}
 	curMS := btime.TimeMS()
 	// The time when the simulation is triggered
@@ -403,7 +569,11 @@ func (o *LocalOrderMgr) tryFillTriggers(od *ormo.InOutOrder, bar *banexg.Kline,
 	} else {
 		// Trigger time + network delay
 		// 触发时间+网络延迟
-		rate += simMarketRate(bar, trigPrice, od.Short, true, afterRate)
+		if useTrail {
+			rate += trailRate
+		} else {
+			rate += simMarketRate(bar, trigPrice, od.Short, true, afterRate)
+		}
 		// Stop loss at market price and sell immediately
 		// 市价止损，立刻卖出
 		fillPrice = simMarketPrice(bar, rate)
@@ -412,18 +582,15 @@ func (o *LocalOrderMgr) tryFillTriggers(od *ormo.InOutOrder, bar *banexg.Kline,
 		// Partial withdrawal
 		// 部分退出
 		part := o.CutOrder(od, amtRate, 0)
-		if sl != nil && sl.Hit {
-			od.SetStopLoss(nil)
-		} else {
-			od.SetTakeProfit(nil)
-		}
+		od.CancelOCO(hitKey)
+		od.SetExitTrigger(hitKey, nil)
 		err := od.Save(nil)
 		if err != nil {
 			log.Error("save cutPart parent order fail", zap.String("key", od.Key()), zap.Error(err))
 		}
 		od = part
 	}
-	cutSecs := tfSecs * (1 - rate)
+	cutSecs := tfSecs * (1 - spanStart - rate*spanSize)
 	exitAt := curMS - int64(cutSecs*1000)
 	err := od.LocalExit(exitAt, exitTag, fillPrice, "", odType)
 	wallets := GetWallets(o.Account)
@@ -432,7 +599,7 @@ func (o *LocalOrderMgr) tryFillTriggers(od *ormo.InOutOrder, bar *banexg.Kline,
 	wallets.ConfirmOdExit(od, od.Exit.Price)
 	o.callBack(od, false)
 	strat.FireOdChange(o.Account, od, strat.OdChgExitFill)
-	return err
+	return true, err
 }
 
 func (o *LocalOrderMgr) onLowFunds() {
@@ -459,6 +626,30 @@ func (o *LocalOrderMgr) OnEnvEnd(bar *banexg.PairTFKline, adj *orm.AdjInfo) *err
 	return err
 }
 
+/*
+exitDelisted
+Force exit orders of the symbol at the last bar before it's delisted in backtest
+回测时在品种退市前的最后一个bar强制平仓
+*/
+func (o *LocalOrderMgr) exitDelisted(bar *orm.InfoKline) *errs.Error {
+	exs, err := orm.GetExSymbolCur(bar.Symbol)
+	if err != nil {
+		return nil
+	}
+	_, delistMS, ok := exs.ListPeriodAt(bar.Time)
+	tfMSecs := int64(utils.TFToSecs(bar.TimeFrame) * 1000)
+	if !ok || delistMS == 0 || bar.Time+tfMSecs < delistMS {
+		return nil
+	}
+	log.Info("exit orders as symbol delisted", zap.String("symbol", bar.Symbol),
+		zap.String("at", btime.ToDateStr(delistMS, "")))
+	return o.exitAndFill(nil, &strat.ExitReq{
+		Tag:   core.ExitTagDelist,
+		Dirt:  core.OdDirtBoth,
+		Force: true,
+	}, bar)
+}
+
 func (o *LocalOrderMgr) exitAndFill(sess *ormo.Queries, req *strat.ExitReq, bar *orm.InfoKline) *errs.Error {
 	pairs := ""
 	if bar != nil {
@@ -493,8 +684,7 @@ func (o *LocalOrderMgr) ExitAndFill(sess *ormo.Queries, orders []*ormo.InOutOrde
 	}
 	timeMS := btime.TimeMS()
 	for _, od := range orders {
-		price := core.GetPrice(od.Symbol)
-		err := o.fillPendingExit(od, price, timeMS)
+		err := o.fillPendingExit(od, od.RefPrice(), timeMS)
 		if err != nil {
 			return err
 		}
@@ -765,6 +955,117 @@ func simMarketRate(bar *banexg.Kline, price float64, isBuy, isTrigger bool, minR
 	}
 }
 
+/*
+hitTriggers
+Mark hit stop loss, take profit and trailing stop of od within bar, return the info key of the trigger to exit by
+(empty if none hit) and the position of trailing stop in bar. Stop loss is prior to take profit, trailing stop takes effect only when hit earlier than both.
+标记bar内触发的止损、止盈和跟踪止损，返回用于出场的触发的信息键(未触发时为空)和跟踪止损在bar中的位置。止损优先于止盈，跟踪止损仅在比两者更早触发时生效
+*/
+func hitTriggers(od *ormo.InOutOrder, bar *banexg.Kline, afterRate float64) (string, float64) {
+	sl := od.GetStopLoss()
+	tp := od.GetTakeProfit()
+	ts := od.GetTrailStop()
+	if sl != nil && !sl.Hit {
+		// 空单止损，最高价超过止损价触发
+		// Short order stop loss, triggered when the highest price exceeds the stop loss price
+		// 多单止损，最低价跌破止损价触发
+		// Stop loss for long orders, triggered when the lowest price falls below the stop loss price
+		sl.Hit = od.Short && bar.High >= sl.Price || !od.Short && bar.Low <= sl.Price
+	}
+	if tp != nil && !tp.Hit {
+		// 空单止盈，最低价跌破止盈价触发
+		// Short order stop profit, the lowest price falls below the stop profit price to trigger
+		// 多单止盈，最高价突破止盈价触发
+		// Long order stop profit, the highest price breaks through the stop profit price to trigger
+		tp.Hit = od.Short && bar.Low <= tp.Price || !od.Short && bar.High >= tp.Price
+	}
+	var trailRate float64
+	if ts != nil && !ts.Hit && ts.ExitTrigger != nil && ts.CallBack > 0 {
+		// Simulate trailing stop along the intra-bar price path
+		// 沿bar内价格路径模拟跟踪止损
+		ts.Hit, trailRate = simTrailStop(bar, ts, od.Short, afterRate)
+		od.DirtyInfo = true
+	}
+	if (sl == nil || !sl.Hit) && (tp == nil || !tp.Hit) && (ts == nil || !ts.Hit) {
+		// 止损、止盈和跟踪止损都未触发
+		return "", 0
+	}
+	od.DirtyInfo = true
+	if ts != nil && ts.Hit {
+		// Trailing stop takes effect only when hit earlier than stop loss and take profit
+		// 跟踪止损仅在比止损止盈更早触发时生效
+		if sl != nil && sl.Hit && simMarketRate(bar, sl.Price, od.Short, true, afterRate) <= trailRate ||
+			tp != nil && tp.Hit && simMarketRate(bar, tp.Price, od.Short, true, afterRate) <= trailRate {
+			ts.Hit = false
+		} else {
+			return ormo.OdInfoTrailStop, trailRate
+		}
+	}
+	if sl != nil && sl.Hit {
+		return ormo.OdInfoStopLoss, 0
+	}
+	return ormo.OdInfoTakeProfit, 0
+}
+
+/*
+simTrailStop
+Simulate trailing stop along the same intra-bar price path as simMarketPrice, the best price and stop price of tg are updated.
+Return whether it's hit and the bar rate when hit.
+按和simMarketPrice相同的bar内价格路径模拟跟踪止损，会更新tg的最优价格和止损价格。返回是否触发，以及触发时的bar内比率
+*/
+func simTrailStop(bar *banexg.Kline, tg *ormo.TriggerState, short bool, afterRate float64) (bool, float64) {
+	path := []float64{bar.Open, bar.Low, bar.High, bar.Close}
+	if bar.Open > bar.Close {
+		path = []float64{bar.Open, bar.High, bar.Low, bar.Close}
+	}
+	isHit := func(price float64) bool {
+		return tg.Best > 0 && (short && price >= tg.Price || !short && price <= tg.Price)
+	}
+	var totalLen float64
+	for i := 1; i < len(path); i++ {
+		totalLen += math.Abs(path[i] - path[i-1])
+	}
+	if totalLen == 0 {
+		tg.UpdateTrail(short, bar.Close, bar.Close)
+		return isHit(bar.Close), afterRate
+	}
+	startLen := totalLen * afterRate
+	var passLen float64
+	for i := 1; i < len(path); i++ {
+		from, to := path[i-1], path[i]
+		segLen := math.Abs(to - from)
+		if passLen+segLen <= startLen {
+			passLen += segLen
+			continue
+		}
+		if passLen < startLen {
+			// The order takes effect in the middle of this segment
+			// 订单在此段中间生效
+			if to > from {
+				from += startLen - passLen
+			} else {
+				from -= startLen - passLen
+			}
+			passLen = startLen
+		}
+		if isHit(from) {
+			// Already beyond the stop price, triggered immediately
+			// 已越过止损价格，立刻触发
+			return true, passLen / totalLen
+		}
+		tg.UpdateTrail(short, from, from)
+		if short == (to < from) {
+			// Moving in favor, only the best price is updated
+			// 向有利方向移动，只更新最优价格
+			tg.UpdateTrail(short, to, to)
+		} else if isHit(to) {
+			return true, (passLen + math.Abs(tg.Price-from)) / totalLen
+		}
+		passLen += math.Abs(to - from)
+	}
+	return false, 0
+}
+
 /*
 计算平仓成交价格，0市价，-1不平仓，>0指定价格
 Calculate the transaction price for closing the position, 0 market price, -1 for not closing the position, >0 specified price
@@ -2,8 +2,11 @@ package biz
 
 import (
 	"fmt"
+	"github.com/banbox/banbot/config"
+	"github.com/banbox/banbot/core"
 	"github.com/banbox/banbot/exg"
 	"github.com/banbox/banbot/orm"
+	"github.com/banbox/banbot/orm/ormo"
 	"github.com/banbox/banexg"
 	"math"
 	"testing"
@@ -45,7 +48,7 @@ func TestCalcSecsForPrice(t *testing.T) {
 	if secsVol == 0 {
 		panic(err)
 	}
-	book, err := exg.GetOdBook(pair)
+	book, err := exg.GetOdBook(config.DefAcc, pair)
 	if err != nil {
 		panic(err)
 	}
@@ -63,3 +66,255 @@ func TestCalcSecsForPrice(t *testing.T) {
 			side, price, minWaitSecs, rate*100)
 	}
 }
+
+func TestSimTrailStop(t *testing.T) {
+	// bull bar path: 100 -> 98 -> 110 -> 104
+	bar := &banexg.Kline{Open: 100, High: 110, Low: 98, Close: 104}
+	tg := &ormo.TriggerState{ExitTrigger: &ormo.ExitTrigger{CallBack: 0.05}}
+	hit, rate := simTrailStop(bar, tg, false, 0)
+	if !hit || math.Abs(rate-0.975) > 1e-6 || math.Abs(tg.Price-104.5) > 1e-6 {
+		t.Errorf("long trail stop fail, hit: %v, rate: %v, price: %v", hit, rate, tg.Price)
+	}
+	// activation price not reached for short order
+	tg = &ormo.TriggerState{ExitTrigger: &ormo.ExitTrigger{CallBack: 0.05, Activation: 90}}
+	hit, _ = simTrailStop(bar, tg, true, 0)
+	if hit || tg.Best != 0 || tg.Price != 0 {
+		t.Errorf("short trail stop should not active, hit: %v, best: %v", hit, tg.Best)
+	}
+}
+
+func TestHitTriggers(t *testing.T) {
+	newOd := func() *ormo.InOutOrder {
+		return &ormo.InOutOrder{IOrder: &ormo.IOrder{Symbol: "BTC/USDT", Status: ormo.InOutStatusFullEnter, InitPrice: 100}}
+	}
+	// both hit in one bar, stop loss is prior
+	od := newOd()
+	od.SetBracket(&ormo.ExitTrigger{Price: 95}, &ormo.ExitTrigger{Price: 105})
+	key, _ := hitTriggers(od, &banexg.Kline{Open: 100, High: 106, Low: 94, Close: 100}, 0)
+	if key != ormo.OdInfoStopLoss {
+		t.Errorf("stop loss should be prior to take profit, got %s", key)
+	}
+	od = newOd()
+	od.SetBracket(&ormo.ExitTrigger{Price: 95}, &ormo.ExitTrigger{Price: 105})
+	key, _ = hitTriggers(od, &banexg.Kline{Open: 100, High: 106, Low: 98, Close: 104}, 0)
+	if key != ormo.OdInfoTakeProfit {
+		t.Errorf("take profit should hit, got %s", key)
+	}
+	key, _ = hitTriggers(newOd(), &banexg.Kline{Open: 100, High: 106, Low: 94, Close: 100}, 0)
+	if key != "" {
+		t.Errorf("no trigger should hit, got %s", key)
+	}
+	// bear bar path: 100 -> 104 -> 89 -> 90, trail stop 101.92 hit before stop loss 90
+	od = newOd()
+	od.SetStopLoss(&ormo.ExitTrigger{Price: 90})
+	od.SetTrailStop(&ormo.ExitTrigger{CallBack: 0.02})
+	key, rate := hitTriggers(od, &banexg.Kline{Open: 100, High: 104, Low: 89, Close: 90}, 0)
+	if key != ormo.OdInfoTrailStop || math.Abs(rate-0.304) > 1e-6 {
+		t.Errorf("trail stop should hit first, got %s, rate: %v", key, rate)
+	}
+	// bull bar path: 100 -> 89 -> 104 -> 101, stop loss 90 hit before trail stop activated at 103
+	od = newOd()
+	od.SetStopLoss(&ormo.ExitTrigger{Price: 90})
+	od.SetTrailStop(&ormo.ExitTrigger{CallBack: 0.02, Activation: 103})
+	key, _ = hitTriggers(od, &banexg.Kline{Open: 100, High: 104, Low: 89, Close: 101}, 0)
+	if key != ormo.OdInfoStopLoss || od.GetTrailStop().Hit {
+		t.Errorf("stop loss should hit before trail stop, got %s", key)
+	}
+}
+
+func TestIntraPath(t *testing.T) {
+	// 1h bar replayed by 15m bars, the dip happens in the 2nd one
+	path := &intraPath{
+		bars: []*banexg.Kline{
+			{Time: 0, Open: 100, High: 101, Low: 99.5, Close: 100.5},
+			{Time: 900000, Open: 100.5, High: 100.8, Low: 97, Close: 98},
+			{Time: 1800000, Open: 98, High: 99, Low: 96, Close: 98.5},
+			{Time: 2700000, Open: 98.5, High: 104, Low: 98, Close: 103},
+		},
+		tfMSecs:  3600000,
+		subMSecs: 900000,
+	}
+	price, rate, ok := path.limitFill(97.5, true, 0)
+	if !ok || price != 97.5 || math.Abs(rate-(0.25+3.6/5.1*0.25)) > 1e-6 {
+		t.Errorf("limit buy fail, ok: %v, price: %v, rate: %v", ok, price, rate)
+	}
+	price, rate, ok = path.limitFill(101, true, 0)
+	if !ok || price != 100 || rate != 0 {
+		t.Errorf("limit buy above market should fill at open, ok: %v, price: %v, rate: %v", ok, price, rate)
+	}
+	if _, _, ok = path.limitFill(95, true, 0); ok {
+		t.Error("limit buy below all lows should not fill")
+	}
+	if _, _, ok = path.limitFill(97.5, true, 0.5); !ok {
+		t.Error("limit buy should fill in the 3rd bar after minRate")
+	}
+	if price = path.marketPrice(0.5); price != 98 {
+		t.Errorf("market price at 0.5 should be open of the 3rd bar, got %v", price)
+	}
+}
+
+func TestLiqPrice(t *testing.T) {
+	tiers := []*config.MarginTier{
+		{MaxNotional: 50000, MaintRate: 0.004},
+		{MaxNotional: 250000, MaintRate: 0.005},
+		{MaintRate: 0.01},
+	}
+	cases := []struct {
+		notional float64
+		rate     float64
+		deduct   float64
+	}{
+		{10000, 0.004, 0},
+		{100000, 0.005, 50},
+		{300000, 0.01, 1300},
+	}
+	for _, c := range cases {
+		rate, deduct := maintTier(tiers, c.notional)
+		if rate != c.rate || math.Abs(deduct-c.deduct) > 1e-9 {
+			t.Errorf("tier of %v: expect %v %v, got %v %v", c.notional, c.rate, c.deduct, rate, deduct)
+		}
+	}
+	// 10x long of 1 unit at 100, margin 10, liquidated when 10 + (P-100) = P*0.004
+	long := &ormo.InOutOrder{
+		IOrder: &ormo.IOrder{Leverage: 10},
+		Enter:  &ormo.ExOrder{Average: 100, Filled: 1},
+	}
+	if res := solveLiqPrice(long, 10, 0.004, 0); math.Abs(res-90/0.996) > 1e-9 {
+		t.Errorf("long liq price expect %v, got %v", 90/0.996, res)
+	}
+	short := &ormo.InOutOrder{
+		IOrder: &ormo.IOrder{Leverage: 10, Short: true},
+		Enter:  &ormo.ExOrder{Average: 100, Filled: 1},
+	}
+	if res := solveLiqPrice(short, 10, 0.004, 0); math.Abs(res-110/1.004) > 1e-9 {
+		t.Errorf("short liq price expect %v, got %v", 110/1.004, res)
+	}
+	if !isLiqHit(long, 90, 90/0.996) || isLiqHit(long, 95, 90/0.996) || !isLiqHit(short, 110, 110/1.004) {
+		t.Error("isLiqHit wrong")
+	}
+	// collateral more than the position value, never liquidated
+	if res := solveLiqPrice(long, 200, 0.004, 0); res != 0 {
+		t.Errorf("long liq price expect 0, got %v", res)
+	}
+}
+
+func TestIsAlgoClientId(t *testing.T) {
+	oldName := config.Name
+	defer func() {
+		config.Name = oldName
+	}()
+	config.Name = "my_bot"
+	cases := map[string]bool{
+		"my_bot_algo12_3": true,
+		"my_bot_12":       false,
+		"other_algo12_3":  false,
+		"my_algo12_3":     false,
+	}
+	for id, expect := range cases {
+		if isAlgoClientId(id) != expect {
+			t.Errorf("isAlgoClientId(%s) should be %v", id, expect)
+		}
+	}
+}
+
+func TestGetRouteOdMgr(t *testing.T) {
+	// other accounts are merged into the default one outside real trading, routing must fail instead of
+	// placing on the job's account silently
+	if core.EnvReal {
+		t.Skip("only for backtest and dry run")
+	}
+	if _, err := getRouteOdMgr("acc2"); err == nil {
+		t.Error("routing to other account should fail outside real trading")
+	}
+}
+
+func newShutOd(id, status, enterStatus int64, filled float64) *ormo.InOutOrder {
+	return &ormo.InOutOrder{
+		IOrder: &ormo.IOrder{ID: id, Symbol: "BTC/USDT:USDT", Status: status, EnterAt: id, InitPrice: 100},
+		Enter:  &ormo.ExOrder{Status: enterStatus, Amount: 1, Filled: filled},
+	}
+}
+
+func TestPlanShutdown(t *testing.T) {
+	makeOds := func() []*ormo.InOutOrder {
+		pending := newShutOd(1, ormo.InOutStatusInit, ormo.OdStatusInit, 0)
+		partial := newShutOd(2, ormo.InOutStatusPartEnter, ormo.OdStatusPartOK, 0.5)
+		protected := newShutOd(3, ormo.InOutStatusFullEnter, ormo.OdStatusClosed, 1)
+		protected.SetStopLoss(&ormo.ExitTrigger{Price: 90})
+		protected.GetStopLoss().OrderId = "sl3"
+		naked := newShutOd(4, ormo.InOutStatusFullEnter, ormo.OdStatusClosed, 1)
+		done := newShutOd(5, ormo.InOutStatusFullExit, ormo.OdStatusClosed, 1)
+		return []*ormo.InOutOrder{pending, partial, protected, naked, done}
+	}
+	// same as cancelTimeoutEnter: unfilled orders are exited, partial filled ones become fully entered
+	cancelEnter := func(od *ormo.InOutOrder) {
+		od.Enter.Status = ormo.OdStatusClosed
+		if od.Enter.Filled == 0 {
+			od.Status = ormo.InOutStatusFullExit
+		} else {
+			od.Status = ormo.InOutStatusFullEnter
+		}
+	}
+	ids := func(ods []*ormo.InOutOrder) []int64 {
+		res := make([]int64, 0, len(ods))
+		for _, od := range ods {
+			res = append(res, od.ID)
+		}
+		return res
+	}
+	cases := []struct {
+		policy      string
+		closes      string
+		unprotected int
+	}{
+		{core.ShutKeep, "[]", 2},
+		{core.ShutCloseAll, "[2 3 4]", 0},
+		{core.ShutCloseUnprotected, "[2 4]", 0},
+	}
+	for _, c := range cases {
+		odList := makeOds()
+		cancelNum, holds, closes, unprotected := planShutdown(odList, c.policy, cancelEnter, IsProtected)
+		if cancelNum != 2 {
+			t.Errorf("%s: pending entries should be cancelled, got %d", c.policy, cancelNum)
+		}
+		if odList[0].Status != ormo.InOutStatusFullExit {
+			t.Errorf("%s: unfilled order should be exited", c.policy)
+		}
+		if got := fmt.Sprint(ids(holds)); got != "[2 3 4]" {
+			t.Errorf("%s: holds should be filled orders, got %s", c.policy, got)
+		}
+		if got := fmt.Sprint(ids(closes)); got != c.closes {
+			t.Errorf("%s: closes got %s, want %s", c.policy, got, c.closes)
+		}
+		if len(unprotected) != c.unprotected {
+			t.Errorf("%s: unprotected got %v, want %d", c.policy, unprotected, c.unprotected)
+		}
+	}
+}
+
+func TestEnsureStopLoss(t *testing.T) {
+	o := &LiveOrderMgr{}
+	od := newShutOd(1, ormo.InOutStatusFullEnter, ormo.OdStatusClosed, 1)
+	if o.ensureStopLoss(od) || IsProtected(od) {
+		t.Errorf("order without stop loss should not be protected")
+	}
+	od.SetStopLoss(&ormo.ExitTrigger{Price: 90})
+	od.GetStopLoss().OrderId = "sl1"
+	if !o.ensureStopLoss(od) {
+		t.Errorf("order with stop loss on exchange should be protected")
+	}
+	od.GetStopLoss().Hit = true
+	if o.ensureStopLoss(od) || IsProtected(od) {
+		t.Errorf("hit stop loss should not protect the order")
+	}
+	// trailing stop placed on exchange also protects 已挂到交易所的跟踪止损也能保护
+	od.SetTrailStop(&ormo.ExitTrigger{CallBack: 0.05})
+	od.GetTrailStop().OrderId = "ts1"
+	if !IsProtected(od) {
+		t.Errorf("order with trailing stop on exchange should be protected")
+	}
+	od.Status = ormo.InOutStatusFullExit
+	if o.ensureStopLoss(od) {
+		t.Errorf("exited order should not be protected")
+	}
+}
@@ -84,9 +84,31 @@ func (t *Trader) FeedKline(bar *orm.InfoKline) *errs.Error {
 			}
 		}
 	}
+	// Feed spreads subscribed by OnPairInfos when both legs are ready
+	// 两腿都就绪时，更新OnPairInfos订阅的价差
+	for _, spBar := range strat.FeedSpreads(bar) {
+		curErr := t.feedSpread(spBar, barExpired)
+		if curErr != nil {
+			log.Error("feed spread fail", zap.String("spread", spBar.Symbol), zap.Error(curErr))
+		}
+	}
 	return err
 }
 
+func (t *Trader) feedSpread(bar *orm.InfoKline, barExpired bool) *errs.Error {
+	env, err := t.OnEnvJobs(bar)
+	if err != nil || env == nil {
+		return err
+	}
+	for account := range config.Accounts {
+		err = t.onAccountKline(account, env, bar, barExpired)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (t *Trader) onAccountKline(account string, env *ta.BarEnv, bar *orm.InfoKline, barExpired bool) *errs.Error {
 	envKey := strings.Join([]string{bar.Symbol, bar.TimeFrame}, "_")
 	// Get strategy jobs 获取交易任务
@@ -104,7 +126,8 @@ func (t *Trader) onAccountKline(account string, env *ta.BarEnv, bar *orm.InfoKli
 	odMgr := GetOdMgr(account)
 	var err *errs.Error
 	isWarmup := bar.IsWarmUp
-	if !isWarmup && len(allOrders) > 0 {
+	isSpread := strat.IsSpreadKey(bar.Symbol)
+	if !isWarmup && len(allOrders) > 0 && !isSpread {
 		// The order status may be modified here
 		// 这里可能修改订单状态
 		err = odMgr.UpdateByBar(allOrders, bar)
@@ -116,7 +139,7 @@ func (t *Trader) onAccountKline(account string, env *ta.BarEnv, bar *orm.InfoKli
 	// 要在UpdateByBar后检索当前开放订单，过滤已平仓订单
 	var curOrders []*ormo.InOutOrder
 	for _, od := range allOrders {
-		if od.Status < ormo.InOutStatusFullExit && od.Symbol == bar.Symbol && od.Timeframe == bar.TimeFrame {
+		if od.Status < ormo.InOutStatusFullExit && od.VenueKey() == bar.Symbol && od.Timeframe == bar.TimeFrame {
 			curOrders = append(curOrders, od)
 		}
 	}
@@ -171,6 +194,17 @@ func (t *Trader) onAccountKline(account string, env *ta.BarEnv, bar *orm.InfoKli
 		if job.Strat.BatchInfo && job.Strat.OnBatchInfos != nil {
 			AddBatchJob(account, bar.TimeFrame, job, env)
 		}
+		if isSpread && !isWarmup {
+			// Spreads can be traded by StratJob.OpenSpread/CloseHedge in OnInfoBar
+			// 可在OnInfoBar中通过StratJob.OpenSpread/CloseHedge交易价差
+			if barExpired {
+				job.Entrys = nil
+			}
+			_, _, err = odMgr.ProcessOrders(sess, job)
+			if err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
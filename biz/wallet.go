@@ -451,9 +451,13 @@ func (w *BanWallets) EnterOd(od *ormo.InOutOrder) (float64, *errs.Error) {
 	if od.Enter.Amount != 0 {
 		price := od.Enter.Average
 		if price == 0 {
-			price = core.GetPrice(od.Symbol)
+			price = od.RefPrice()
 		}
-		legalCost = od.Enter.Amount * price
+		rate, err := core.GetLegalRate(core.QuoteCode(od.Symbol))
+		if err != nil {
+			return 0, err
+		}
+		legalCost = od.Enter.Amount * price * rate
 	} else {
 		legalCost = od.GetInfoFloat64(ormo.OdInfoLegalCost)
 	}
@@ -521,7 +525,7 @@ func (w *BanWallets) ConfirmOdEnter(od *ormo.InOutOrder, enterPrice float64) {
 	curFee := subOd.Fee
 
 	baseCode, quoteCode, _, _ := core.SplitSymbol(exs.Symbol)
-	if core.IsContract {
+	if banexg.IsContract(exs.Market) {
 		// Futures contracts only lock the fixed currency and do not involve the increase of base currency.
 		// 期货合约，只锁定定价币，不涉及base币的增加
 		quoteAmount /= od.Leverage
@@ -688,12 +692,15 @@ func (w *BanWallets) UpdateOds(odList []*ormo.InOutOrder, currency string) *errs
 		}
 	}
 
-	exchange := exg.Default
+	exchange := exg.Get(w.Account)
 	for _, od := range odList {
 		if od.Enter == nil || od.Enter.Filled == 0 {
 			continue
 		}
-		curPrice := core.GetPrice(od.Symbol)
+		curPrice := od.CurPrice()
+		if curPrice <= 0 {
+			continue
+		}
 		// Calculate nominal value
 		// 计算名义价值
 		quoteValue := od.Enter.Filled * curPrice
@@ -867,10 +874,16 @@ func (w *BanWallets) TryUpdateStakePctAmt() {
 		acc, ok := config.Accounts[w.Account]
 		if ok {
 			legalValue := w.TotalLegal(nil, true)
-			if banexg.IsContract(core.Market) && config.Leverage > 1 {
+			if _, market := config.GetAccVenue(w.Account); banexg.IsContract(market) && config.Leverage > 1 {
 				// 对于合约市场，百分比开单应基于带杠杆的名义资产价值
 				legalValue *= config.Leverage
 			}
+			// stake amount is in the report currency 开单金额以报告币种计价
+			reportRate := core.LegalRate(config.ReportCurrency)
+			if reportRate <= 0 {
+				return
+			}
+			legalValue /= reportRate
 			// Round to the nearest tenth place
 			// 四舍五入到十位
 			pctAmt := math.Round(legalValue*config.StakePct/1000) * 10
@@ -889,20 +902,36 @@ func (w *BanWallets) TryUpdateStakePctAmt() {
 }
 
 func EnsurePricesLoaded() {
-	if core.IsPriceEmpty() {
-		// A one-time refresh if a price is requested when all prices are not loaded
-		// 所有价格都未加载时，如果请求价格，则一次性刷新
-		res, err := exg.Default.FetchTickerPrice("", nil)
+	if !core.IsPriceEmpty() {
+		return
+	}
+	// A one-time refresh of all exchanges and markets of accounts if a price is requested when all prices are not loaded
+	// 所有价格都未加载时，如果请求价格，则一次性刷新所有账户所在交易所和市场的价格
+	res, err := exg.Default.FetchTickerPrice("", nil)
+	if err != nil {
+		log.Error("load ticker prices fail", zap.Error(err))
+	} else {
+		core.SetPrices(res)
+	}
+	venues := map[string]bool{core.ExgName + "_" + core.Market: true}
+	for account := range config.Accounts {
+		exgName, market := config.GetAccVenue(account)
+		if venues[exgName+"_"+market] {
+			continue
+		}
+		venues[exgName+"_"+market] = true
+		res, err = exg.Get(account).FetchTickerPrice("", nil)
 		if err != nil {
-			log.Error("load ticker prices fail", zap.Error(err))
-		} else {
-			core.SetPrices(res)
+			log.Error("load ticker prices fail", zap.String("exg", exgName), zap.String("market", market), zap.Error(err))
+			continue
 		}
+		core.SetVenuePrices(exgName, market, res)
 	}
 }
 
 func UpdateWalletByBalances(wallets *BanWallets, item *banexg.Balances) {
 	EnsurePricesLoaded()
+	_, market := config.GetAccVenue(wallets.Account)
 	var items []*banexg.Asset
 	var skips []string
 	for coin, it := range item.Assets {
@@ -926,7 +955,7 @@ func UpdateWalletByBalances(wallets *BanWallets, item *banexg.Balances) {
 			wallets.Items[coin] = record
 		}
 		record.lock.Lock()
-		if core.IsContract {
+		if banexg.IsContract(market) {
 			record.Pendings["*"] = it.Used
 			record.Frozens["*"] = 0
 		} else {
@@ -963,6 +992,7 @@ func UpdateWalletByBalances(wallets *BanWallets, item *banexg.Balances) {
 	if len(msgList) > 0 {
 		log.Debug(fmt.Sprintf("update balances %s: %s", wallets.Account, strings.Join(msgList, "  ")))
 	}
+	fireWalletChange(wallets)
 }
 
 /*
@@ -975,7 +1005,7 @@ func WatchLiveBalances() {
 		if wallets.IsWatch {
 			continue
 		}
-		out, err := exg.Default.WatchBalance(map[string]interface{}{
+		out, err := exg.Get(account).WatchBalance(map[string]interface{}{
 			banexg.ParamAccount: account,
 		})
 		if err != nil {
@@ -5,6 +5,7 @@ import (
 	"math"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -81,7 +82,6 @@ args: NoDefault, Configs, TimeRange, MaxPoolSize, StakeAmount, StakePct, TimeFra
 func GetConfig(args *CmdArgs, showLog bool) (*Config, *errs.Error) {
 	args.Init()
 	var paths []string
-	var res Config
 	if !args.NoDefault {
 		dataDir := GetDataDir()
 		if dataDir == "" {
@@ -123,6 +123,23 @@ func GetConfig(args *CmdArgs, showLog bool) (*Config, *errs.Error) {
 			paths = append(paths, args.Configs...)
 		}
 	}
+	res, err2 := mergeConfigFiles(paths, showLog)
+	if err2 != nil {
+		return nil, err2
+	}
+	if err2 = res.resolveSecrets(); err2 != nil {
+		return nil, err2
+	}
+	err := res.Apply(args)
+	if err != nil {
+		return nil, errs.New(errs.CodeRunTime, err)
+	}
+	return res, nil
+}
+
+// mergeConfigFiles later files override former ones, keys in noExtends are replaced instead of merged 后面的文件覆盖前面的，noExtends中的键整体替换而非合并
+func mergeConfigFiles(paths []string, showLog bool) (*Config, *errs.Error) {
+	var res Config
 	var merged = make(map[string]interface{})
 	for _, path := range paths {
 		if showLog {
@@ -148,10 +165,6 @@ func GetConfig(args *CmdArgs, showLog bool) (*Config, *errs.Error) {
 	if err != nil {
 		return nil, errs.NewFull(errs.CodeUnmarshalFail, err, "decode Config Fail")
 	}
-	err = res.Apply(args)
-	if err != nil {
-		return nil, errs.New(errs.CodeRunTime, err)
-	}
 	return &res, nil
 }
 
@@ -314,7 +327,13 @@ func ApplyConfig(args *CmdArgs, c *Config) *errs.Error {
 	if BTNetCost == 0 {
 		BTNetCost = 15
 	}
+	if c.BTIntraTF != "" && utils.TFToSecs(c.BTIntraTF) == 0 {
+		return errs.NewMsg(core.ErrBadConfig, "invalid bt_intra_tf: %s", c.BTIntraTF)
+	}
+	BTIntraTF = c.BTIntraTF
 	RelaySimUnFinish = c.RelaySimUnFinish
+	RegimeSymbol = c.RegimeSymbol
+	Benchmarks = c.Benchmarks
 	NTPLangCode = c.NTPLangCode
 	if NTPLangCode == "" {
 		NTPLangCode = "none"
@@ -335,6 +354,10 @@ func ApplyConfig(args *CmdArgs, c *Config) *errs.Error {
 	for _, curr := range c.StakeCurrency {
 		StakeCurrencyMap[curr] = true
 	}
+	ReportCurrency = c.ReportCurrency
+	if ReportCurrency == "" {
+		ReportCurrency = StakeCurrency[0]
+	}
 	FatalStop = make(map[int]float64)
 	if len(c.FatalStop) > 0 {
 		for text, rate := range c.FatalStop {
@@ -364,6 +387,27 @@ func ApplyConfig(args *CmdArgs, c *Config) *errs.Error {
 		c.StratPerf.Validate()
 	}
 	StratPerf = c.StratPerf
+	if c.Margin == nil {
+		c.Margin = &MarginConfig{}
+	}
+	if err := c.Margin.Validate(); err != nil {
+		return err
+	}
+	Margin = c.Margin
+	if c.Shutdown == nil {
+		c.Shutdown = &ShutdownConfig{}
+	}
+	if err := c.Shutdown.Validate(); err != nil {
+		return err
+	}
+	Shutdown = c.Shutdown
+	if c.HA == nil {
+		c.HA = &HAConfig{}
+	}
+	if err := c.HA.Validate(c.Name); err != nil {
+		return err
+	}
+	HA = c.HA
 	Pairs, _ = utils2.UniqueItems(c.Pairs)
 	SetRunPolicy(true, c.RunPolicy...)
 	_, needCalc := GetStaticPairs()
@@ -525,6 +569,60 @@ func (p *StratPerfConfig) Validate() {
 	}
 }
 
+func (s *ShutdownConfig) Validate() *errs.Error {
+	if s.Policy == "" {
+		s.Policy = core.ShutKeep
+	} else if s.Policy != core.ShutKeep && s.Policy != core.ShutCloseAll && s.Policy != core.ShutCloseUnprotected {
+		return errs.NewMsg(core.ErrBadConfig, "invalid shutdown.policy: %s", s.Policy)
+	}
+	if s.TimeoutSecs <= 0 {
+		s.TimeoutSecs = 60
+	}
+	return nil
+}
+
+func (h *HAConfig) Validate(name string) *errs.Error {
+	if h.Name == "" {
+		h.Name = name
+	}
+	if h.LeaseSecs <= 0 {
+		h.LeaseSecs = 30
+	}
+	if h.HeartbeatSecs <= 0 {
+		h.HeartbeatSecs = max(1, h.LeaseSecs/3)
+	}
+	if h.Enable && h.HeartbeatSecs*2 > h.LeaseSecs {
+		return errs.NewMsg(core.ErrBadConfig, "ha.heartbeat_secs should be at most half of ha.lease_secs")
+	}
+	return nil
+}
+
+func (m *MarginConfig) Validate() *errs.Error {
+	if m.Mode == "" {
+		m.Mode = core.MarginCross
+	} else if m.Mode != core.MarginCross && m.Mode != core.MarginIsolated {
+		return errs.NewMsg(core.ErrBadConfig, "invalid margin.mode: %s", m.Mode)
+	}
+	if m.LiqTrigger == "" {
+		m.LiqTrigger = core.LiqByMark
+	} else if m.LiqTrigger != core.LiqByMark && m.LiqTrigger != core.LiqByLast {
+		return errs.NewMsg(core.ErrBadConfig, "invalid margin.liq_trigger: %s", m.LiqTrigger)
+	}
+	if m.LiqFeeRate < 0 || m.LiqFeeRate >= 1 {
+		return errs.NewMsg(core.ErrBadConfig, "margin.liq_fee_rate should in [0, 1): %v", m.LiqFeeRate)
+	}
+	for i, t := range m.Tiers {
+		if t.MaintRate <= 0 || t.MaintRate >= 1 {
+			return errs.NewMsg(core.ErrBadConfig, "margin.tiers[%d].maint_rate should in (0, 1): %v", i, t.MaintRate)
+		}
+		last := i == len(m.Tiers)-1
+		if !last && (t.MaxNotional <= 0 || i > 0 && t.MaxNotional <= m.Tiers[i-1].MaxNotional) {
+			return errs.NewMsg(core.ErrBadConfig, "margin.tiers should be sorted by max_notional, only the last can be 0")
+		}
+	}
+	return nil
+}
+
 func ParsePath(path string) string {
 	if strings.HasPrefix(path, "$") {
 		path = strings.TrimLeft(path, "$\\/")
@@ -665,13 +763,17 @@ func (c *Config) Clone() *Config {
 		MinOpenRate:      c.MinOpenRate,
 		LowCostAction:    c.LowCostAction,
 		BTNetCost:        c.BTNetCost,
+		BTIntraTF:        c.BTIntraTF,
 		RelaySimUnFinish: c.RelaySimUnFinish,
+		RegimeSymbol:     c.RegimeSymbol,
+		Benchmarks:       c.Benchmarks,
 		OrderBarMax:      c.OrderBarMax,
 		MaxOpenOrders:    c.MaxOpenOrders,
 		MaxSimulOpen:     c.MaxSimulOpen,
 		WalletAmounts:    c.WalletAmounts,
 		DrawBalanceOver:  c.DrawBalanceOver,
 		StakeCurrency:    c.StakeCurrency,
+		ReportCurrency:   c.ReportCurrency,
 		FatalStop:        c.FatalStop,
 		FatalStopHours:   c.FatalStopHours,
 		TimeRangeRaw:     c.TimeRangeRaw,
@@ -683,6 +785,9 @@ func (c *Config) Clone() *Config {
 		WatchJobs:        c.WatchJobs,
 		RunPolicy:        c.RunPolicy,
 		StratPerf:        c.StratPerf,
+		Margin:           c.Margin,
+		Shutdown:         c.Shutdown,
+		HA:               c.HA,
 		Pairs:            c.Pairs,
 		PairMgr:          c.PairMgr,
 		PairFilters:      c.PairFilters,
@@ -698,9 +803,12 @@ Desensitize
 屏蔽配置对象中的敏感信息
 database.url
 exchange.account_*.*.(api_key|api_secret)
-rpc_channels.*.*secret
+rpc_channels.*.(*secret*|*token*|*pwd*|*password*)
 api_server.jwt_secret_key
+api_server.signal_secret
+api_server.metrics_token
 api_server.users[*].pwd
+api_server.tokens[*].token
 */
 func (c *Config) Desensitize() *Config {
 	var res = c.Clone()
@@ -729,7 +837,7 @@ func (c *Config) Desensitize() *Config {
 			chlType := utils.GetMapVal(channelConfig, "type", "")
 			resChannel := make(map[string]interface{})
 			for k, v := range channelConfig {
-				if !strings.Contains(strings.ToLower(k), "secret") {
+				if !isSecretKey(k) {
 					resChannel[k] = v
 				}
 			}
@@ -759,11 +867,29 @@ func (c *Config) Desensitize() *Config {
 				}
 			}
 		}
+		for _, tk := range c.APIServer.Tokens {
+			res.APIServer.Tokens = append(res.APIServer.Tokens, &APITokenConfig{
+				Name:     tk.Name,
+				Scopes:   tk.Scopes,
+				Accounts: tk.Accounts,
+				ExpireAt: tk.ExpireAt,
+			})
+		}
 	}
 
 	return res
 }
 
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, word := range []string{"secret", "token", "pwd", "password"} {
+		if strings.Contains(key, word) {
+			return true
+		}
+	}
+	return false
+}
+
 func DumpYaml(desensitize bool) ([]byte, *errs.Error) {
 	c := &Data
 	if desensitize {
@@ -812,6 +938,9 @@ func (c *RunPolicyConfig) OdDirt() int {
 }
 
 func (c *RunPolicyConfig) Param(k string, dv float64) float64 {
+	if c.usedKeys != nil {
+		c.usedKeys[k] = true
+	}
 	if v, ok := c.Params[k]; ok {
 		return v
 	}
@@ -861,6 +990,16 @@ func (c *RunPolicyConfig) DefInt(k string, dv int, p *core.Param) int {
 	return int(math.Round(val))
 }
 
+// TrackParams start recording names of params read by strategy, not thread safe 开始记录策略读取的参数名，非线程安全
+func (c *RunPolicyConfig) TrackParams() {
+	c.usedKeys = make(map[string]bool)
+}
+
+// UsedParams names of params read since TrackParams 自TrackParams以来读取的参数名
+func (c *RunPolicyConfig) UsedParams() map[string]bool {
+	return c.usedKeys
+}
+
 func (c *RunPolicyConfig) IsInt(k string) bool {
 	if p, ok := c.defs[k]; ok {
 		return p.IsInt
@@ -965,7 +1104,8 @@ func (a *AccountConfig) GetApiSecret() *ApiSecretConfig {
 	if a == nil || len(a.Exchanges) == 0 {
 		return &ApiSecretConfig{}
 	}
-	cfg, _ := a.Exchanges[Exchange.Name]
+	exgName, _ := a.Venue()
+	cfg, _ := a.Exchanges[exgName]
 	if cfg != nil {
 		if core.RunEnv != core.RunEnvTest && cfg.Prod != nil {
 			return cfg.Prod
@@ -976,6 +1116,62 @@ func (a *AccountConfig) GetApiSecret() *ApiSecretConfig {
 	return &ApiSecretConfig{}
 }
 
+/*
+Venue
+Exchange name and market type the account trades on, default to exchange.name and market_type
+账户交易的交易所名称和市场类型，默认为exchange.name和market_type
+*/
+func (a *AccountConfig) Venue() (string, string) {
+	exgName, market := Exchange.Name, core.Market
+	if a != nil && core.EnvReal {
+		// Only one account is enabled in non-production environment, always use the default exchange
+		// 非生产环境仅启用一个账户，始终使用默认交易所
+		if a.Exchange != "" {
+			exgName = a.Exchange
+		}
+		if a.Market != "" {
+			market = a.Market
+		}
+	}
+	return exgName, market
+}
+
+/*
+GetAccVenue
+Exchange name and market type of the account, see AccountConfig.Venue
+账户的交易所名称和市场类型，见AccountConfig.Venue
+*/
+func GetAccVenue(account string) (string, string) {
+	acc, ok := Accounts[account]
+	if !ok {
+		acc, _ = BakAccounts[account]
+	}
+	return acc.Venue()
+}
+
+// AccVenueKey key of the symbol on the exchange and market of the account, see core.VenueKey 账户所在交易所和市场上品种的键
+func AccVenueKey(account, symbol string) string {
+	exgName, market := GetAccVenue(account)
+	return core.VenueKey(exgName, market, symbol)
+}
+
+/*
+GetVenueAccount
+Return the first enabled account (sorted by name) trading on the exchange and market, empty if not found
+返回在此交易所和市场交易的第一个启用账户（按名称排序），未找到返回空
+*/
+func GetVenueAccount(exgName, market string) string {
+	names := utils2.KeysOfMap(Accounts)
+	slices.Sort(names)
+	for _, name := range names {
+		accExg, accMarket := Accounts[name].Venue()
+		if accExg == exgName && accMarket == market {
+			return name
+		}
+	}
+	return ""
+}
+
 func LoadPerfs(inDir string) {
 	if StratPerf == nil || !StratPerf.Enable {
 		return
@@ -2,6 +2,7 @@ package config
 
 import (
 	"fmt"
+	"github.com/banbox/banbot/core"
 	"gopkg.in/yaml.v3"
 	"testing"
 )
@@ -20,3 +21,34 @@ func TestLoadConfig(t *testing.T) {
 	}
 	fmt.Println("result: \n", string(data))
 }
+
+func TestAccVenueKey(t *testing.T) {
+	oldExg, oldMarket, oldReal := core.ExgName, core.Market, core.EnvReal
+	oldExgCfg, oldAccs := Exchange, Accounts
+	core.ExgName, core.Market, core.EnvReal = "binance", "linear", true
+	Exchange = &ExchangeConfig{Name: "binance"}
+	Accounts = map[string]*AccountConfig{
+		"main": {},
+		"sub":  {Exchange: "okx", Market: "spot"},
+	}
+	defer func() {
+		core.ExgName, core.Market, core.EnvReal = oldExg, oldMarket, oldReal
+		Exchange, Accounts = oldExgCfg, oldAccs
+	}()
+	cases := []struct {
+		account, symbol, key string
+	}{
+		{"main", "BTC/USDT:USDT", "BTC/USDT:USDT"},
+		{"sub", "BTC/USDT", "okx:spot:BTC/USDT"},
+	}
+	for _, c := range cases {
+		if key := AccVenueKey(c.account, c.symbol); key != c.key {
+			t.Errorf("AccVenueKey %s %s expect %s, got %s", c.account, c.symbol, c.key, key)
+		}
+	}
+	// other accounts trade on the default exchange out of production 非生产环境所有账户均使用默认交易所
+	core.EnvReal = false
+	if key := AccVenueKey("sub", "BTC/USDT:USDT"); key != "BTC/USDT:USDT" {
+		t.Errorf("AccVenueKey in dry run expect default venue, got %s", key)
+	}
+}
@@ -0,0 +1,492 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/banbox/banbot/core"
+	"github.com/banbox/banexg/errs"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	IssueError = "error"
+	IssueWarn  = "warn"
+)
+
+var reTimeframe = regexp.MustCompile(`^[1-9]\d*[smhdwM]$`)
+
+// CfgIssue a problem found in yaml config, Line is 0 if not located 配置中发现的问题，未定位时Line为0
+type CfgIssue struct {
+	File  string
+	Line  int
+	Path  string
+	Level string
+	Msg   string
+}
+
+func (i *CfgIssue) String() string {
+	loc := i.File
+	if i.Line > 0 {
+		loc = fmt.Sprintf("%s:%d", i.File, i.Line)
+	}
+	if loc == "" {
+		loc = "<merged>"
+	}
+	return fmt.Sprintf("%s: [%s] %s: %s", loc, i.Level, i.Path, i.Msg)
+}
+
+type cfgFile struct {
+	Path string
+	Root *yaml.Node
+}
+
+/*
+CfgChecker
+Validate yaml config files against the schema of Config, and locate problems of the merged config to file and line.
+Unknown keys swallowed by `remain`/interface{} fields are reported here instead of silently ignored.
+按Config的结构校验yaml配置文件，并将合并后配置的问题定位到文件和行号。被remain/interface{}字段吞掉的未知键会在这里报告
+*/
+type CfgChecker struct {
+	files  []*cfgFile
+	Issues []*CfgIssue
+}
+
+func NewCfgChecker(paths []string) (*CfgChecker, *errs.Error) {
+	res := &CfgChecker{}
+	for _, path := range paths {
+		data, err_ := os.ReadFile(ParsePath(path))
+		if err_ != nil {
+			return nil, errs.NewFull(core.ErrIOReadFail, err_, "Read %s Fail", path)
+		}
+		var root yaml.Node
+		if err_ = yaml.Unmarshal(data, &root); err_ != nil {
+			res.Issues = append(res.Issues, &CfgIssue{File: path, Level: IssueError, Path: "$", Msg: err_.Error()})
+			continue
+		}
+		res.files = append(res.files, &cfgFile{Path: path, Root: &root})
+	}
+	return res, nil
+}
+
+/*
+GetConfigPaths
+Paths of config files in the order of merging, same as GetConfig
+与GetConfig相同合并顺序的配置文件路径
+*/
+func GetConfigPaths(args *CmdArgs) []string {
+	var paths []string
+	if !args.NoDefault {
+		dataDir := GetDataDir()
+		for _, name := range []string{"config.yml", "config.local.yml"} {
+			path := filepath.Join(dataDir, name)
+			if _, err := os.Stat(path); err == nil {
+				paths = append(paths, path)
+			}
+		}
+	}
+	return append(paths, args.Configs...)
+}
+
+/*
+LoadMerged
+Merge config files and apply args like GetConfig, without resolving secrets
+与GetConfig相同地合并配置文件并应用参数，但不解析密钥
+*/
+func (c *CfgChecker) LoadMerged(args *CmdArgs) (*Config, *errs.Error) {
+	paths := make([]string, 0, len(c.files))
+	for _, f := range c.files {
+		paths = append(paths, f.Path)
+	}
+	res, err := mergeConfigFiles(paths, false)
+	if err != nil {
+		return nil, err
+	}
+	if err_ := res.Apply(args); err_ != nil {
+		return nil, errs.New(errs.CodeRunTime, err_)
+	}
+	return res, nil
+}
+
+// Add an issue located by the path in merged config 添加按合并配置中路径定位的问题
+func (c *CfgChecker) Add(level string, path []string, msg string, args ...interface{}) {
+	if len(args) > 0 {
+		msg = fmt.Sprintf(msg, args...)
+	}
+	file, line := c.Locate(path...)
+	c.Issues = append(c.Issues, &CfgIssue{
+		File:  file,
+		Line:  line,
+		Path:  strings.Join(path, "."),
+		Level: level,
+		Msg:   msg,
+	})
+}
+
+/*
+Locate
+Find file and line of the path, the last file defining it wins since later files override former ones.
+Sequence items are indexed by number in path.
+查找路径所在的文件和行号，后面的文件覆盖前面的，所以最后定义的文件优先。路径中序列项使用数字索引
+*/
+func (c *CfgChecker) Locate(path ...string) (string, int) {
+	for i := len(c.files) - 1; i >= 0; i-- {
+		file := c.files[i]
+		if node := findNode(file.Root, path); node != nil {
+			return file.Path, node.Line
+		}
+	}
+	// fallback to the deepest parent 回退到最深的父节点
+	if len(path) > 1 {
+		return c.Locate(path[:len(path)-1]...)
+	}
+	return "", 0
+}
+
+func findNode(node *yaml.Node, path []string) *yaml.Node {
+	node = unwrapNode(node)
+	for depth, key := range path {
+		if node == nil {
+			return nil
+		}
+		var next *yaml.Node
+		if node.Kind == yaml.MappingNode {
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == key {
+					// return key node for its line, value is used to go deeper 返回键节点的行号，值节点用于继续查找
+					if depth == len(path)-1 {
+						return node.Content[i]
+					}
+					next = node.Content[i+1]
+					break
+				}
+			}
+		} else if node.Kind == yaml.SequenceNode {
+			idx, err := strconv.Atoi(key)
+			if err == nil && idx >= 0 && idx < len(node.Content) {
+				next = node.Content[idx]
+			}
+		}
+		node = unwrapNode(next)
+	}
+	return node
+}
+
+func unwrapNode(node *yaml.Node) *yaml.Node {
+	for node != nil {
+		if node.Kind == yaml.DocumentNode && len(node.Content) > 0 {
+			node = node.Content[0]
+		} else if node.Kind == yaml.AliasNode {
+			node = node.Alias
+		} else {
+			break
+		}
+	}
+	return node
+}
+
+// CheckSchema validate every file against Config by yaml tags 按yaml标签校验每个文件
+func (c *CfgChecker) CheckSchema() {
+	tp := reflect.TypeOf(Config{})
+	for _, file := range c.files {
+		c.walk(file.Path, unwrapNode(file.Root), tp, nil)
+	}
+}
+
+func (c *CfgChecker) addAt(file string, node *yaml.Node, level string, path []string, msg string) {
+	c.Issues = append(c.Issues, &CfgIssue{
+		File:  file,
+		Line:  node.Line,
+		Path:  strings.Join(path, "."),
+		Level: level,
+		Msg:   msg,
+	})
+}
+
+func (c *CfgChecker) walk(file string, node *yaml.Node, tp reflect.Type, path []string) {
+	node = unwrapNode(node)
+	if node == nil || node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return
+	}
+	for tp.Kind() == reflect.Pointer {
+		tp = tp.Elem()
+	}
+	switch tp.Kind() {
+	case reflect.Interface:
+		return
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			c.addAt(file, node, IssueError, path, "expect a mapping")
+			return
+		}
+		fields, inline := yamlFields(tp)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			keyNode, valNode := node.Content[i], node.Content[i+1]
+			subPath := append(slices.Clone(path), keyNode.Value)
+			if ft, ok := fields[keyNode.Value]; ok {
+				c.walk(file, valNode, ft, subPath)
+			} else if inline != nil && !isTypoOfInline(valNode, inline) {
+				c.walk(file, valNode, inline, subPath)
+			} else {
+				msg := "unknown key"
+				if guess := ClosestKey(keyNode.Value, fields); guess != "" {
+					msg += fmt.Sprintf(", did you mean `%s`?", guess)
+				}
+				c.addAt(file, keyNode, IssueError, subPath, msg)
+			}
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			c.addAt(file, node, IssueError, path, "expect a mapping")
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			subPath := append(slices.Clone(path), node.Content[i].Value)
+			c.walk(file, node.Content[i+1], tp.Elem(), subPath)
+		}
+	case reflect.Slice, reflect.Array:
+		if node.Kind != yaml.SequenceNode {
+			c.addAt(file, node, IssueError, path, "expect a list")
+			return
+		}
+		for i, item := range node.Content {
+			c.walk(file, item, tp.Elem(), append(slices.Clone(path), strconv.Itoa(i)))
+		}
+	default:
+		if node.Kind != yaml.ScalarNode {
+			c.addAt(file, node, IssueError, path, "expect a "+tp.Kind().String())
+			return
+		}
+		val := reflect.New(tp)
+		if err := node.Decode(val.Interface()); err != nil {
+			c.addAt(file, node, IssueError, path, fmt.Sprintf("invalid %s: %s", tp.Kind(), node.Value))
+		}
+	}
+}
+
+// isTypoOfInline a scalar for inline struct/map is more likely a typo of known keys 内联结构体/map收到标量时更可能是已知键的拼写错误
+func isTypoOfInline(node *yaml.Node, inline reflect.Type) bool {
+	node = unwrapNode(node)
+	if node == nil || node.Kind != yaml.ScalarNode || node.Tag == "!!null" {
+		return false
+	}
+	for inline.Kind() == reflect.Pointer {
+		inline = inline.Elem()
+	}
+	return inline.Kind() == reflect.Struct || inline.Kind() == reflect.Map
+}
+
+/*
+yamlFields
+Fields of struct by yaml names, embedded and `,inline` structs are merged. For `,inline` maps, the element type is
+returned as inline, which accepts any other keys.
+按yaml名称返回结构体字段，合并嵌入和inline结构体。对于inline的map，返回其元素类型作为inline，接受其他任意键
+*/
+func yamlFields(tp reflect.Type) (map[string]reflect.Type, reflect.Type) {
+	res := make(map[string]reflect.Type)
+	var inline reflect.Type
+	for i := 0; i < tp.NumField(); i++ {
+		f := tp.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		tag := f.Tag.Get("yaml")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		ft := f.Type
+		for ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if strings.Contains(opts, "inline") || f.Anonymous && name == "" {
+			if ft.Kind() == reflect.Map {
+				inline = ft.Elem()
+			} else if ft.Kind() == reflect.Struct {
+				subs, subInline := yamlFields(ft)
+				for k, v := range subs {
+					res[k] = v
+				}
+				if subInline != nil {
+					inline = subInline
+				}
+			}
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(f.Name)
+		}
+		res[name] = f.Type
+	}
+	return res, inline
+}
+
+// ClosestKey the most similar key for typos, empty if none is close enough 最相似的键用于提示拼写错误，都不够接近时为空
+func ClosestKey[T any](key string, items map[string]T) string {
+	best, bestDist := "", len(key)/3+2
+	for k := range items {
+		dist := editDistance(strings.ToLower(key), strings.ToLower(k))
+		if dist < bestDist || dist == bestDist && best != "" && k < best {
+			best, bestDist = k, dist
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+// ValidTimeframe check timeframe like 1m, 15m, 4h, 1d 检查时间周期是否有效
+func ValidTimeframe(tf string) bool {
+	return reTimeframe.MatchString(tf)
+}
+
+/*
+CheckConfig
+Semantic checks of the merged config: timeframes, directions, stop loss and account references
+合并后配置的语义检查：时间周期、方向、止损和账户引用
+*/
+func (c *CfgChecker) CheckConfig(cfg *Config) {
+	for i, tf := range cfg.RunTimeframes {
+		if !ValidTimeframe(tf) {
+			c.Add(IssueError, []string{"run_timeframes", strconv.Itoa(i)}, "invalid timeframe: %s", tf)
+		}
+	}
+	if cfg.BTIntraTF != "" && !ValidTimeframe(cfg.BTIntraTF) {
+		c.Add(IssueError, []string{"bt_intra_tf"}, "invalid timeframe: %s", cfg.BTIntraTF)
+	}
+	for tf := range cfg.WatchJobs {
+		if !ValidTimeframe(tf) {
+			c.Add(IssueError, []string{"watch_jobs", tf}, "invalid timeframe: %s", tf)
+		}
+	}
+	for i, pol := range cfg.RunPolicy {
+		idx := strconv.Itoa(i)
+		if pol.Name == "" {
+			c.Add(IssueError, []string{"run_policy", idx}, "name is required")
+		}
+		for j, tf := range pol.RunTimeframes {
+			if !ValidTimeframe(tf) {
+				c.Add(IssueError, []string{"run_policy", idx, "run_timeframes", strconv.Itoa(j)}, "invalid timeframe: %s", tf)
+			}
+		}
+		switch strings.TrimSpace(pol.Dirt) {
+		case "", "long", "short", "any":
+		default:
+			c.Add(IssueError, []string{"run_policy", idx, "dirt"}, "invalid dirt: %s, expect long/short/any", pol.Dirt)
+		}
+		if pol.StopLoss != nil {
+			switch val := pol.StopLoss.(type) {
+			case float64:
+				if val < 0 {
+					c.Add(IssueError, []string{"run_policy", idx, "stop_loss"}, "stop_loss should >= 0")
+				}
+			case string:
+				text := strings.TrimSuffix(strings.TrimSpace(val), "%")
+				if _, err := strconv.ParseFloat(text, 64); text != "" && err != nil {
+					c.Add(IssueError, []string{"run_policy", idx, "stop_loss"}, "invalid stop_loss: %s, expect e.g. 5%% or 0.05", val)
+				}
+			case int:
+				if val != 0 {
+					c.Add(IssueError, []string{"run_policy", idx, "stop_loss"}, "invalid stop_loss: %v, expect e.g. 5%% or 0.05", val)
+				}
+			default:
+				c.Add(IssueError, []string{"run_policy", idx, "stop_loss"}, "invalid stop_loss type: %T", val)
+			}
+		}
+	}
+	c.checkAccounts(cfg)
+}
+
+func (c *CfgChecker) checkAccounts(cfg *Config) {
+	exgNames := make(map[string]bool)
+	if cfg.Exchange != nil {
+		if cfg.Exchange.Name != "" {
+			exgNames[cfg.Exchange.Name] = true
+		}
+		for name := range cfg.Exchange.Items {
+			exgNames[name] = true
+		}
+	}
+	for accName, acc := range cfg.Accounts {
+		if acc == nil {
+			continue
+		}
+		if acc.Exchange != "" && !exgNames[acc.Exchange] {
+			c.Add(IssueError, []string{"accounts", accName, "exchange"}, "exchange not configured: %s", acc.Exchange)
+		}
+		// keys swallowed by Exchanges should be exchange names 被Exchanges吞掉的键应为交易所名称
+		for name := range acc.Exchanges {
+			if !exgNames[name] {
+				msg := "unknown key or exchange"
+				if guess := ClosestKey(name, exgNames); guess != "" {
+					msg += fmt.Sprintf(", did you mean `%s`?", guess)
+				}
+				c.Add(IssueError, []string{"accounts", accName, name}, msg)
+			}
+		}
+	}
+	if cfg.APIServer == nil {
+		return
+	}
+	for i, u := range cfg.APIServer.Users {
+		for acc := range u.AccRoles {
+			if _, ok := cfg.Accounts[acc]; !ok {
+				c.Add(IssueError, []string{"api_server", "users", strconv.Itoa(i), "acc_roles", acc},
+					"account not found: %s", acc)
+			}
+		}
+	}
+	for i, t := range cfg.APIServer.Tokens {
+		for j, acc := range t.Accounts {
+			if _, ok := cfg.Accounts[acc]; !ok {
+				c.Add(IssueError, []string{"api_server", "tokens", strconv.Itoa(i), "accounts", strconv.Itoa(j)},
+					"account not found: %s", acc)
+			}
+		}
+	}
+}
+
+// ErrorNum count of issues with error level 错误级别的问题数量
+func (c *CfgChecker) ErrorNum() int {
+	num := 0
+	for _, it := range c.Issues {
+		if it.Level == IssueError {
+			num += 1
+		}
+	}
+	return num
+}
+
+// SortIssues sort issues by file and line 按文件和行号排序
+func (c *CfgChecker) SortIssues() {
+	slices.SortStableFunc(c.Issues, func(a, b *CfgIssue) int {
+		if a.File != b.File {
+			return strings.Compare(a.File, b.File)
+		}
+		return a.Line - b.Line
+	})
+}
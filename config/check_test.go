@@ -0,0 +1,60 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCfgCheckerSchema(t *testing.T) {
+	content := `name: demo
+stake_amout: 100
+leverage: abc
+run_policy:
+  - name: ma:demo
+    run_timeframes: [5m, 7x]
+    dirt: up
+accounts:
+  user1:
+    max_open_orders: 3
+    binanse:
+      prod:
+        api_key: xx
+exchange:
+  name: binance
+  binance: {}
+`
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	checker, err := NewCfgChecker([]string{path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checker.CheckSchema()
+	cfg, err := checker.LoadMerged(&CmdArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checker.CheckConfig(cfg)
+	checker.SortIssues()
+	var lines []string
+	for _, it := range checker.Issues {
+		lines = append(lines, it.String())
+	}
+	text := strings.Join(lines, "\n")
+	expects := []string{
+		":2: [error] stake_amout: unknown key, did you mean `stake_amount`?",
+		":3: [error] leverage: invalid float64: abc",
+		":6: [error] run_policy.0.run_timeframes.1: invalid timeframe: 7x",
+		":7: [error] run_policy.0.dirt: invalid dirt: up",
+		":11: [error] accounts.user1.binanse: unknown key or exchange, did you mean `binance`?",
+	}
+	for _, exp := range expects {
+		if !strings.Contains(text, exp) {
+			t.Errorf("missing issue %q in:\n%s", exp, text)
+		}
+	}
+}
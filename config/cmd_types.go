@@ -55,4 +55,5 @@ type CmdArgs struct {
 	Separate      bool // Used for backtesting. When true, the strategy combination is tested separately. 用于回测，true时策略组合单独测试
 	Inited        bool
 	DeadLock      bool
+	SafeStart     int // bars of the smallest timeframe to observe without entries in live trade 实盘启动后不入场仅观察的最小周期bar数
 }
@@ -0,0 +1,404 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/banbox/banbot/core"
+	"github.com/banbox/banexg/errs"
+	"github.com/banbox/banexg/log"
+	"github.com/sasha-s/go-deadlock"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/scrypt"
+	"golang.org/x/term"
+)
+
+const (
+	SecretEnv  = "env:"  // read from environment variable 从环境变量读取
+	SecretFile = "file:" // read from file, trailing spaces are trimmed 从文件读取，去除尾部空白
+	SecretEnc  = "enc:"  // read from the encrypted keystore 从加密密钥库读取
+
+	TokenHashPrefix = "sha256:" // prefix of hashed api token 哈希后API令牌的前缀
+
+	EnvKeyPass  = "BanKeyPass"  // passphrase of keystore 密钥库的密码
+	EnvKeystore = "BanKeystore" // path of keystore, default: [datadir]/secrets.enc 密钥库路径
+
+	keystoreVer = 1
+	scryptN     = 1 << 15
+)
+
+var (
+	keystore     map[string]string
+	keystoreLock deadlock.Mutex
+)
+
+type keystoreFile struct {
+	Version int    `json:"version"`
+	Salt    string `json:"salt"`
+	Nonce   string `json:"nonce"`
+	Data    string `json:"data"`
+}
+
+// KeystorePath path of encrypted keystore 加密密钥库路径
+func KeystorePath() string {
+	if path := os.Getenv(EnvKeystore); path != "" {
+		return ParsePath(path)
+	}
+	return filepath.Join(GetDataDir(), "secrets.enc")
+}
+
+func keystoreKey(pass string, salt []byte) ([]byte, error) {
+	return scrypt.Key([]byte(pass), salt, scryptN, 8, 1, 32)
+}
+
+/*
+LoadKeystore
+Decrypt keystore file with passphrase, it's AES-256-GCM encrypted with key derived by scrypt. Empty map if file not exist.
+使用密码解密密钥库文件，采用scrypt派生密钥的AES-256-GCM加密。文件不存在时返回空map
+*/
+func LoadKeystore(path, pass string) (map[string]string, *errs.Error) {
+	data, err_ := os.ReadFile(path)
+	if err_ != nil {
+		if errors.Is(err_, os.ErrNotExist) {
+			return make(map[string]string), nil
+		}
+		return nil, errs.New(core.ErrIOReadFail, err_)
+	}
+	var file keystoreFile
+	if err_ = json.Unmarshal(data, &file); err_ != nil {
+		return nil, errs.NewFull(errs.CodeUnmarshalFail, err_, "invalid keystore: %s", path)
+	}
+	if file.Version != keystoreVer {
+		return nil, errs.NewMsg(core.ErrBadConfig, "unsupported keystore version: %v", file.Version)
+	}
+	salt, err1 := base64.StdEncoding.DecodeString(file.Salt)
+	nonce, err2 := base64.StdEncoding.DecodeString(file.Nonce)
+	cipherText, err3 := base64.StdEncoding.DecodeString(file.Data)
+	if err_ = errors.Join(err1, err2, err3); err_ != nil {
+		return nil, errs.NewFull(errs.CodeUnmarshalFail, err_, "invalid keystore: %s", path)
+	}
+	gcm, err_ := newKeystoreGCM(pass, salt)
+	if err_ != nil {
+		return nil, errs.New(errs.CodeRunTime, err_)
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, errs.NewMsg(errs.CodeUnmarshalFail, "invalid keystore nonce: %s", path)
+	}
+	plain, err_ := gcm.Open(nil, nonce, cipherText, nil)
+	if err_ != nil {
+		return nil, errs.NewMsg(errs.CodeParamInvalid, "decrypt keystore fail, wrong passphrase?")
+	}
+	var res = make(map[string]string)
+	if err_ = json.Unmarshal(plain, &res); err_ != nil {
+		return nil, errs.NewFull(errs.CodeUnmarshalFail, err_, "invalid keystore content")
+	}
+	return res, nil
+}
+
+// SaveKeystore encrypt items with a new salt and nonce, and write to path with mode 0600 使用新的盐和随机数加密并以0600权限写入
+func SaveKeystore(path, pass string, items map[string]string) *errs.Error {
+	plain, err_ := json.Marshal(items)
+	if err_ != nil {
+		return errs.New(errs.CodeMarshalFail, err_)
+	}
+	salt := make([]byte, 16)
+	if _, err_ = rand.Read(salt); err_ != nil {
+		return errs.New(errs.CodeRunTime, err_)
+	}
+	gcm, err_ := newKeystoreGCM(pass, salt)
+	if err_ != nil {
+		return errs.New(errs.CodeRunTime, err_)
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err_ = rand.Read(nonce); err_ != nil {
+		return errs.New(errs.CodeRunTime, err_)
+	}
+	data, err_ := json.MarshalIndent(keystoreFile{
+		Version: keystoreVer,
+		Salt:    base64.StdEncoding.EncodeToString(salt),
+		Nonce:   base64.StdEncoding.EncodeToString(nonce),
+		Data:    base64.StdEncoding.EncodeToString(gcm.Seal(nil, nonce, plain, nil)),
+	}, "", "  ")
+	if err_ != nil {
+		return errs.New(errs.CodeMarshalFail, err_)
+	}
+	if err_ = os.MkdirAll(filepath.Dir(path), 0700); err_ != nil {
+		return errs.New(core.ErrIOWriteFail, err_)
+	}
+	if err_ = os.WriteFile(path, data, 0600); err_ != nil {
+		return errs.New(core.ErrIOWriteFail, err_)
+	}
+	return nil
+}
+
+func newKeystoreGCM(pass string, salt []byte) (cipher.AEAD, error) {
+	key, err := keystoreKey(pass, salt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// getKeystore unlock the keystore once by env BanKeyPass or prompt from terminal 通过环境变量BanKeyPass或终端输入解锁一次密钥库
+func getKeystore() (map[string]string, *errs.Error) {
+	keystoreLock.Lock()
+	defer keystoreLock.Unlock()
+	if keystore != nil {
+		return keystore, nil
+	}
+	path := KeystorePath()
+	if _, err_ := os.Stat(path); err_ != nil {
+		return nil, errs.NewMsg(core.ErrBadConfig, "keystore not found: %s", path)
+	}
+	pass, err := readKeyPass(false)
+	if err != nil {
+		return nil, err
+	}
+	items, err := LoadKeystore(path, pass)
+	if err != nil {
+		return nil, err
+	}
+	keystore = items
+	return keystore, nil
+}
+
+func readKeyPass(confirm bool) (string, *errs.Error) {
+	if pass := os.Getenv(EnvKeyPass); pass != "" {
+		return pass, nil
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", errs.NewMsg(core.ErrBadConfig, "keystore locked, env `%s` is required", EnvKeyPass)
+	}
+	pass, err := readHidden(fd, "Keystore passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if confirm {
+		again, err := readHidden(fd, "Repeat passphrase: ")
+		if err != nil {
+			return "", err
+		}
+		if again != pass {
+			return "", errs.NewMsg(errs.CodeParamInvalid, "passphrases not match")
+		}
+	}
+	if pass == "" {
+		return "", errs.NewMsg(errs.CodeParamRequired, "passphrase is required")
+	}
+	return pass, nil
+}
+
+func readHidden(fd int, prompt string) (string, *errs.Error) {
+	fmt.Fprint(os.Stderr, prompt)
+	data, err_ := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err_ != nil {
+		return "", errs.New(core.ErrIOReadFail, err_)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+/*
+ResolveSecret
+Resolve secret reference like `env:NAME`, `file:path` and `enc:NAME`, other values are returned as is.
+解析`env:NAME`、`file:path`和`enc:NAME`形式的密钥引用，其他值原样返回
+*/
+func ResolveSecret(val string) (string, *errs.Error) {
+	if strings.HasPrefix(val, SecretEnv) {
+		name := strings.TrimPrefix(val, SecretEnv)
+		res, ok := os.LookupEnv(name)
+		if !ok {
+			return "", errs.NewMsg(core.ErrBadConfig, "env for secret not found: %s", name)
+		}
+		return res, nil
+	} else if strings.HasPrefix(val, SecretFile) {
+		path := ParsePath(strings.TrimPrefix(val, SecretFile))
+		data, err_ := os.ReadFile(path)
+		if err_ != nil {
+			return "", errs.NewFull(core.ErrIOReadFail, err_, "read secret file fail: %s", path)
+		}
+		return strings.TrimRight(string(data), " \t\r\n"), nil
+	} else if strings.HasPrefix(val, SecretEnc) {
+		name := strings.TrimPrefix(val, SecretEnc)
+		items, err := getKeystore()
+		if err != nil {
+			return "", err
+		}
+		res, ok := items[name]
+		if !ok {
+			return "", errs.NewMsg(core.ErrBadConfig, "secret not found in keystore: %s", name)
+		}
+		return res, nil
+	}
+	return val, nil
+}
+
+/*
+HashApiToken
+Hex encoded sha256 of the api token with prefix "sha256:", used to store and compare api tokens
+API令牌的sha256十六进制哈希，带"sha256:"前缀，用于保存和比较API令牌
+*/
+func HashApiToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return TokenHashPrefix + hex.EncodeToString(sum[:])
+}
+
+/*
+resolveSecrets
+Resolve secret references of exchange api keys, passwords, jwt/signal secrets, tokens, database url and rpc channels.
+Failures are errors in live mode; otherwise the secret is cleared with a warning, since backtests don't need it.
+解析交易所api密钥、密码、jwt/信号密钥、令牌、数据库url和rpc渠道中的密钥引用。
+实盘模式下解析失败返回错误；否则清空并警告，因回测不需要这些密钥
+*/
+func (c *Config) resolveSecrets() *errs.Error {
+	var fails []string
+	resolve := func(key string, val *string) {
+		res, err := ResolveSecret(*val)
+		if err != nil {
+			fails = append(fails, key+": "+err.Short())
+			res = ""
+		}
+		*val = res
+	}
+	for accName, acc := range c.Accounts {
+		for exgName, sec := range acc.Exchanges {
+			if sec == nil {
+				continue
+			}
+			for env, item := range map[string]*ApiSecretConfig{"prod": sec.Prod, "test": sec.Test} {
+				if item == nil {
+					continue
+				}
+				prefix := fmt.Sprintf("accounts.%s.%s.%s.", accName, exgName, env)
+				resolve(prefix+"api_key", &item.APIKey)
+				resolve(prefix+"api_secret", &item.APISecret)
+			}
+		}
+		if acc.APIServer != nil {
+			resolve(fmt.Sprintf("accounts.%s.api_server.pwd", accName), &acc.APIServer.Pwd)
+		}
+	}
+	if c.Database != nil {
+		resolve("database.url", &c.Database.Url)
+	}
+	if c.APIServer != nil {
+		resolve("api_server.jwt_secret_key", &c.APIServer.JWTSecretKey)
+		resolve("api_server.signal_secret", &c.APIServer.SignalSecret)
+		resolve("api_server.metrics_token", &c.APIServer.MetricsToken)
+		for _, u := range c.APIServer.Users {
+			resolve(fmt.Sprintf("api_server.users.%s.pwd", u.Username), &u.Password)
+		}
+		for _, t := range c.APIServer.Tokens {
+			resolve(fmt.Sprintf("api_server.tokens.%s.token", t.Name), &t.Token)
+			// only the hash of api token is kept in memory 内存中仅保留API令牌的哈希
+			if t.Token != "" && !strings.HasPrefix(t.Token, TokenHashPrefix) {
+				t.Token = HashApiToken(t.Token)
+			}
+		}
+	}
+	for name, chl := range c.RPCChannels {
+		for k, v := range chl {
+			if text, ok := v.(string); ok {
+				resolve(fmt.Sprintf("rpc_channels.%s.%s", name, k), &text)
+				chl[k] = text
+			}
+		}
+	}
+	if len(fails) == 0 {
+		return nil
+	}
+	slices.Sort(fails)
+	if core.LiveMode {
+		return errs.NewMsg(core.ErrBadConfig, "resolve secrets fail:\n%s", strings.Join(fails, "\n"))
+	}
+	log.Warn("resolve secrets fail, cleared", zap.Strings("items", fails))
+	return nil
+}
+
+/*
+RunSecretTool
+Manage the encrypted keystore: list names, set or delete a secret. Values are read from terminal if not given.
+管理加密密钥库：列出名称、设置或删除密钥。未传入值时从终端读取
+*/
+func RunSecretTool(args []string) error {
+	parser := flag.NewFlagSet("", flag.ExitOnError)
+	var setName, value, delName, dataDir string
+	var list bool
+	parser.StringVar(&setName, "set", "", "name of secret to set, use in config as enc:NAME")
+	parser.StringVar(&value, "value", "", "value of secret, read from terminal if empty")
+	parser.StringVar(&delName, "del", "", "name of secret to delete")
+	parser.BoolVar(&list, "list", false, "list names of secrets")
+	parser.StringVar(&dataDir, "datadir", "", "Path to data dir.")
+	err_ := parser.Parse(args)
+	if err_ != nil {
+		return err_
+	}
+	if dataDir != "" {
+		DataDir = dataDir
+	}
+	path := KeystorePath()
+	_, statErr := os.Stat(path)
+	isNew := statErr != nil
+	pass, err := readKeyPass(isNew)
+	if err != nil {
+		return err
+	}
+	items, err := LoadKeystore(path, pass)
+	if err != nil {
+		return err
+	}
+	if list || setName == "" && delName == "" {
+		names := make([]string, 0, len(items))
+		for name := range items {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		fmt.Printf("%d secrets in %s\n", len(names), path)
+		for _, name := range names {
+			fmt.Println(name)
+		}
+		return nil
+	}
+	if delName != "" {
+		if _, ok := items[delName]; !ok {
+			return fmt.Errorf("secret not found: %s", delName)
+		}
+		delete(items, delName)
+	}
+	if setName != "" {
+		if value == "" {
+			fd := int(os.Stdin.Fd())
+			if !term.IsTerminal(fd) {
+				return errors.New("-value is required when stdin is not a terminal")
+			}
+			value, err = readHidden(fd, fmt.Sprintf("Value of %s: ", setName))
+			if err != nil {
+				return err
+			}
+		}
+		items[setName] = value
+	}
+	err = SaveKeystore(path, pass, items)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("keystore saved: %s\n", path)
+	return nil
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKeystore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.enc")
+	items := map[string]string{"binance_key": "abc", "binance_secret": "def"}
+	if err := SaveKeystore(path, "pass123", items); err != nil {
+		t.Fatal(err)
+	}
+	res, err := LoadKeystore(path, "pass123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res) != 2 || res["binance_secret"] != "def" {
+		t.Errorf("bad keystore: %v", res)
+	}
+	if _, err = LoadKeystore(path, "wrong"); err == nil {
+		t.Error("wrong passphrase should fail")
+	}
+}
+
+func TestResolveSecret(t *testing.T) {
+	t.Setenv("BAN_TEST_SECRET", "from_env")
+	path := filepath.Join(t.TempDir(), "key.txt")
+	if err := os.WriteFile(path, []byte("from_file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]string{
+		"plain":               "plain",
+		"env:BAN_TEST_SECRET": "from_env",
+		"file:" + path:        "from_file",
+	}
+	for input, expect := range cases {
+		res, err := ResolveSecret(input)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res != expect {
+			t.Errorf("%s: expect %s, got %s", input, expect, res)
+		}
+	}
+	if _, err := ResolveSecret("env:BAN_TEST_MISSING"); err == nil {
+		t.Error("missing env should fail")
+	}
+}
+
+func TestApiTokenHashed(t *testing.T) {
+	hashed := HashApiToken("other")
+	c := &Config{APIServer: &APIServerConfig{Tokens: []*APITokenConfig{
+		{Name: "plain", Token: "abc"},
+		{Name: "hashed", Token: hashed},
+	}}}
+	if err := c.resolveSecrets(); err != nil {
+		t.Fatal(err)
+	}
+	// echo -n abc | sha256sum
+	expect := "sha256:ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"
+	if c.APIServer.Tokens[0].Token != expect {
+		t.Errorf("plain token should be hashed, got %s", c.APIServer.Tokens[0].Token)
+	}
+	if c.APIServer.Tokens[1].Token != hashed {
+		t.Errorf("hashed token should be kept, got %s", c.APIServer.Tokens[1].Token)
+	}
+}
@@ -25,22 +25,26 @@ var (
 	ChargeOnBomb     bool
 	TakeOverStrat    string
 	CloseOnStuck     int
-	StakeAmount      float64 // The amount of a single order, the priority is lower than StakePct 单笔开单金额，优先级低于StakePct
-	StakePct         float64 // Percentage of single bill amount 单笔开单金额百分比
-	MaxStakeAmt      float64 // Maximum bill amount for a single transaction 单笔最大开单金额
-	OpenVolRate      float64 // When opening an order without specifying a quantity, the multiple of the maximum allowed order quantity/average candle trading volume, defaults to 1 未指定数量开单时，最大允许开单数量/平均蜡烛成交量的倍数，默认1
-	MinOpenRate      float64 // When the wallet balance is less than the single amount, orders are allowed to be issued when it reaches this ratio of the single amount. 钱包余额不足单笔金额时，达到单笔金额的此比例则允许开单
-	LowCostAction    string  // Actions taken when stake amount less than the minimum amount 花费不足最小金额时的动作：ignore, keep
-	BTNetCost        float64 // Order placement delay during backtesting, simulated slippage, unit seconds 回测时下单延迟，模拟滑点，单位秒
-	RelaySimUnFinish bool    // 交易新品种时(回测/实盘)，是否从开始时间未平仓订单接力开始交易
-	NTPLangCode      string  // NTP真实时间同步所用langCode，默认none不启用
-	OrderBarMax      int     // 查找开始时间未平仓订单向前模拟最大bar数量
+	StakeAmount      float64  // The amount of a single order, the priority is lower than StakePct 单笔开单金额，优先级低于StakePct
+	StakePct         float64  // Percentage of single bill amount 单笔开单金额百分比
+	MaxStakeAmt      float64  // Maximum bill amount for a single transaction 单笔最大开单金额
+	OpenVolRate      float64  // When opening an order without specifying a quantity, the multiple of the maximum allowed order quantity/average candle trading volume, defaults to 1 未指定数量开单时，最大允许开单数量/平均蜡烛成交量的倍数，默认1
+	MinOpenRate      float64  // When the wallet balance is less than the single amount, orders are allowed to be issued when it reaches this ratio of the single amount. 钱包余额不足单笔金额时，达到单笔金额的此比例则允许开单
+	LowCostAction    string   // Actions taken when stake amount less than the minimum amount 花费不足最小金额时的动作：ignore, keep
+	BTNetCost        float64  // Order placement delay during backtesting, simulated slippage, unit seconds 回测时下单延迟，模拟滑点，单位秒
+	BTIntraTF        string   // Lower timeframe replayed to resolve fills and triggers within a bar in backtest 回测时用于解析bar内成交和触发的更小周期
+	RelaySimUnFinish bool     // 交易新品种时(回测/实盘)，是否从开始时间未平仓订单接力开始交易
+	RegimeSymbol     string   // Benchmark symbol for market regime labels in backtest reports 回测报告中市场状态标签的基准品种
+	Benchmarks       []string // Symbols or "basket" compared with in backtest reports 回测报告中对比的品种或"basket"
+	NTPLangCode      string   // NTP真实时间同步所用langCode，默认none不启用
+	OrderBarMax      int      // 查找开始时间未平仓订单向前模拟最大bar数量
 	MaxOpenOrders    int
 	MaxSimulOpen     int
 	WalletAmounts    map[string]float64
 	DrawBalanceOver  float64
 	StakeCurrency    []string
 	StakeCurrencyMap map[string]bool
+	ReportCurrency   string // Currency of stake amounts and reports, default the first of StakeCurrency 开单金额和报告的计价币，默认StakeCurrency第一个
 	FatalStop        map[int]float64
 	FatalStopHours   int
 	TimeRange        *TimeTuple
@@ -49,6 +53,9 @@ var (
 	WatchJobs        map[string][]string
 	RunPolicy        []*RunPolicyConfig
 	StratPerf        *StratPerfConfig
+	Margin           *MarginConfig
+	Shutdown         *ShutdownConfig
+	HA               *HAConfig
 	Pairs            []string
 	PairMgr          *PairMgrConfig
 	PairFilters      []*CommonPairFilter
@@ -103,7 +110,10 @@ type Config struct {
 	MinOpenRate      float64                           `yaml:"min_open_rate,omitempty" mapstructure:"min_open_rate"`
 	LowCostAction    string                            `yaml:"low_cost_action,omitempty" mapstructure:"low_cost_action"`
 	BTNetCost        float64                           `yaml:"bt_net_cost,omitempty" mapstructure:"bt_net_cost"`
+	BTIntraTF        string                            `yaml:"bt_intra_tf,omitempty" mapstructure:"bt_intra_tf"`
 	RelaySimUnFinish bool                              `yaml:"relay_sim_unfinish,omitempty" mapstructure:"relay_sim_unfinish"`
+	RegimeSymbol     string                            `yaml:"regime_symbol,omitempty" mapstructure:"regime_symbol"`
+	Benchmarks       []string                          `yaml:"benchmarks,omitempty,flow" mapstructure:"benchmarks"`
 	NTPLangCode      string                            `yaml:"ntp_lang_code,omitempty" mapstructure:"ntp_lang_code"`
 	OrderBarMax      int                               `yaml:"order_bar_max,omitempty" mapstructure:"order_bar_max"`
 	MaxOpenOrders    int                               `yaml:"max_open_orders,omitempty" mapstructure:"max_open_orders"`
@@ -111,6 +121,7 @@ type Config struct {
 	WalletAmounts    map[string]float64                `yaml:"wallet_amounts,omitempty" mapstructure:"wallet_amounts"`
 	DrawBalanceOver  float64                           `yaml:"draw_balance_over,omitempty" mapstructure:"draw_balance_over"`
 	StakeCurrency    []string                          `yaml:"stake_currency,omitempty,flow" mapstructure:"stake_currency"`
+	ReportCurrency   string                            `yaml:"report_currency,omitempty" mapstructure:"report_currency"`
 	FatalStop        map[string]float64                `yaml:"fatal_stop,omitempty" mapstructure:"fatal_stop"`
 	FatalStopHours   int                               `yaml:"fatal_stop_hours,omitempty" mapstructure:"fatal_stop_hours"`
 	TimeRangeRaw     string                            `yaml:"timerange,omitempty" mapstructure:"timerange"`
@@ -122,6 +133,9 @@ type Config struct {
 	WatchJobs        map[string][]string               `yaml:"watch_jobs,omitempty" mapstructure:"watch_jobs"`
 	RunPolicy        []*RunPolicyConfig                `yaml:"run_policy,omitempty" mapstructure:"run_policy"`
 	StratPerf        *StratPerfConfig                  `yaml:"strat_perf,omitempty" mapstructure:"strat_perf"`
+	Margin           *MarginConfig                     `yaml:"margin,omitempty" mapstructure:"margin"`
+	Shutdown         *ShutdownConfig                   `yaml:"shutdown,omitempty" mapstructure:"shutdown"`
+	HA               *HAConfig                         `yaml:"ha,omitempty" mapstructure:"ha"`
 	Pairs            []string                          `yaml:"pairs,omitempty,flow" mapstructure:"pairs"`
 	PairMgr          *PairMgrConfig                    `yaml:"pairmgr,omitempty" mapstructure:"pairmgr"`
 	PairFilters      []*CommonPairFilter               `yaml:"pairlists,omitempty" mapstructure:"pairlists"`
@@ -151,6 +165,7 @@ type RunPolicyConfig struct {
 	Params        map[string]float64            `yaml:"params,omitempty" mapstructure:"params"`
 	PairParams    map[string]map[string]float64 `yaml:"pair_params,omitempty" mapstructure:"pair_params"`
 	defs          map[string]*core.Param
+	usedKeys      map[string]bool // names of params read by strategy 策略读取过的参数名
 	Score         float64
 	Index         int // index in run_policy array
 }
@@ -164,6 +179,50 @@ type StratPerfConfig struct {
 	BadWeight float64 `yaml:"bad_weight,omitempty" mapstructure:"bad_weight"`
 }
 
+/*
+MarginConfig
+Margin and liquidation model of contracts in backtest
+回测中合约的保证金和强平模型
+*/
+type MarginConfig struct {
+	Mode       string        `yaml:"mode,omitempty" mapstructure:"mode"`                 // cross/isolated, default cross
+	LiqTrigger string        `yaml:"liq_trigger,omitempty" mapstructure:"liq_trigger"`   // mark/last, default mark
+	LiqFeeRate float64       `yaml:"liq_fee_rate,omitempty" mapstructure:"liq_fee_rate"` // Fee rate of notional charged on liquidation 强平时按名义价值收取的费率
+	Tiers      []*MarginTier `yaml:"tiers,omitempty" mapstructure:"tiers"`               // Use maintenance tiers of the exchange if empty 为空时使用交易所的维持保证金档位
+}
+
+/*
+ShutdownConfig
+How positions are handled when live trading exits
+实盘退出时如何处理持仓
+*/
+type ShutdownConfig struct {
+	Policy      string `yaml:"policy,omitempty" mapstructure:"policy"`             // keep/close_all/close_unprotected, default keep
+	TimeoutSecs int    `yaml:"timeout_secs,omitempty" mapstructure:"timeout_secs"` // max seconds to wait, default 60 最长等待秒数
+}
+
+/*
+HAConfig
+Active/standby mode of live trading, instances with the same lease name compete for the leader lease in postgres
+实盘主备模式，相同租约名称的实例在postgres中竞争主节点租约
+*/
+type HAConfig struct {
+	Enable        bool   `yaml:"enable" mapstructure:"enable"`
+	Name          string `yaml:"name,omitempty" mapstructure:"name"`                     // lease name, default `name` of config 租约名称，默认为配置的name
+	LeaseSecs     int    `yaml:"lease_secs,omitempty" mapstructure:"lease_secs"`         // leader is replaced if not renewed in this time, default 30 超过此时间未续约时被替换，默认30
+	HeartbeatSecs int    `yaml:"heartbeat_secs,omitempty" mapstructure:"heartbeat_secs"` // interval to renew or acquire the lease, default 1/3 of lease_secs 续约或抢占租约的间隔，默认lease_secs的1/3
+}
+
+/*
+MarginTier
+Maintenance margin rate for positions with notional value up to MaxNotional, 0 means no upper limit
+名义价值不超过MaxNotional的仓位的维持保证金率，0表示无上限
+*/
+type MarginTier struct {
+	MaxNotional float64 `yaml:"max_notional" mapstructure:"max_notional"`
+	MaintRate   float64 `yaml:"maint_rate" mapstructure:"maint_rate"`
+}
+
 type DatabaseConfig struct {
 	Url         string `yaml:"url,omitempty" mapstructure:"url"`
 	Retention   string `yaml:"retention,omitempty" mapstructure:"retention"`
@@ -172,13 +231,17 @@ type DatabaseConfig struct {
 }
 
 type APIServerConfig struct {
-	Enable       bool          `yaml:"enable" mapstructure:"enable"`                           // Whether to enable 是否启用
-	BindIPAddr   string        `yaml:"bind_ip" mapstructure:"bind_ip"`                         // Binding address, 0.0.0.0 means exposed to the public network 绑定地址，0.0.0.0表示暴露到公网
-	Port         int           `yaml:"port" mapstructure:"port"`                               // LOCAL LISTENING PORT 本地监听端口
-	Verbosity    string        `yaml:"verbosity" mapstructure:"verbosity"`                     // Detail level 详细程度
-	JWTSecretKey string        `yaml:"jwt_secret_key,omitempty" mapstructure:"jwt_secret_key"` // Key used for password encryption 用于密码加密的密钥
-	CORSOrigins  []string      `yaml:"CORS_origins,flow" mapstructure:"CORS_origins"`          // When accessing banweb, you need to add the address of banweb here to allow access. banweb访问时，要这里添加banweb的地址放行
-	Users        []*UserConfig `yaml:"users" mapstructure:"users"`                             // Login user 登录用户
+	Enable       bool              `yaml:"enable" mapstructure:"enable"`                           // Whether to enable 是否启用
+	BindIPAddr   string            `yaml:"bind_ip" mapstructure:"bind_ip"`                         // Binding address, 0.0.0.0 means exposed to the public network 绑定地址，0.0.0.0表示暴露到公网
+	Port         int               `yaml:"port" mapstructure:"port"`                               // LOCAL LISTENING PORT 本地监听端口
+	Verbosity    string            `yaml:"verbosity" mapstructure:"verbosity"`                     // Detail level 详细程度
+	JWTSecretKey string            `yaml:"jwt_secret_key,omitempty" mapstructure:"jwt_secret_key"` // Key used for password encryption 用于密码加密的密钥
+	CORSOrigins  []string          `yaml:"CORS_origins,flow" mapstructure:"CORS_origins"`          // When accessing banweb, you need to add the address of banweb here to allow access. banweb访问时，要这里添加banweb的地址放行
+	Users        []*UserConfig     `yaml:"users" mapstructure:"users"`                             // Login user 登录用户
+	Tokens       []*APITokenConfig `yaml:"tokens,omitempty" mapstructure:"tokens"`                 // API tokens for programs, separated from login users 供程序使用的API令牌，与登录用户分离
+	SignalSecret string            `yaml:"signal_secret,omitempty" mapstructure:"signal_secret"`   // HMAC key of signal webhook, disabled when empty 信号webhook的HMAC密钥，为空时禁用
+	SignalWindow int               `yaml:"signal_window,omitempty" mapstructure:"signal_window"`   // Allowed seconds between signal timestamp and now, default 300 信号时间戳与当前允许相差的秒数，默认300
+	MetricsToken string            `yaml:"metrics_token,omitempty" mapstructure:"metrics_token"`   // Bearer token of /metrics, disabled when empty /metrics的Bearer令牌，为空时禁用
 }
 
 type UserConfig struct {
@@ -250,6 +313,11 @@ type AccountConfig struct {
 	RPCChannels   []map[string]interface{}  `yaml:"rpc_channels,omitempty" mapstructure:"rpc_channels"`
 	APIServer     *AccPwdRole               `yaml:"api_server,omitempty" mapstructure:"api_server"`
 	Exchanges     map[string]*ExgApiSecrets `yaml:",inline" mapstructure:",remain"`
+
+	// Exchange and market this account trades on, default: exchange.name and market_type
+	// 此账户交易的交易所和市场，默认：exchange.name和market_type
+	Exchange string `yaml:"exchange,omitempty" mapstructure:"exchange"`
+	Market   string `yaml:"market,omitempty" mapstructure:"market"`
 }
 
 type ExgApiSecrets struct {
@@ -262,6 +330,20 @@ type ApiSecretConfig struct {
 	APISecret string `yaml:"api_secret,omitempty" mapstructure:"api_secret"`
 }
 
+/*
+APITokenConfig
+Token for programs, authorized by header X-Api-Token. Scopes are read/trade/admin, Accounts empty means all accounts.
+供程序使用的令牌，通过请求头X-Api-Token验证。scopes可为read/trade/admin，accounts为空表示所有账户
+*/
+type APITokenConfig struct {
+	Name     string   `yaml:"name" mapstructure:"name"`
+	Token    string   `yaml:"token,omitempty" mapstructure:"token"` // plain token or its hash like sha256:<hex>, hashed when loaded 明文令牌或其哈希如sha256:<hex>，加载时哈希
+	Scopes   []string `yaml:"scopes,flow" mapstructure:"scopes"`
+	Accounts []string `yaml:"accounts,flow" mapstructure:"accounts"`
+	AllowIPs []string `yaml:"allow_ips,flow" mapstructure:"allow_ips"`
+	ExpireAt string   `yaml:"expire_at,omitempty" mapstructure:"expire_at"` // Expiration time like 2025-12-31, never expires when empty 过期时间，为空时永不过期
+}
+
 type AccPwdRole struct {
 	Pwd  string `yaml:"pwd,omitempty" mapstructure:"pwd"`
 	Role string `yaml:"role,omitempty" mapstructure:"role"`
@@ -11,11 +11,13 @@ import (
 	"math"
 	"os"
 	"strings"
+	"sync"
 	"unicode"
 )
 
 var (
-	Cache *ristretto.Cache
+	Cache    *ristretto.Cache
+	exitLock sync.Mutex
 )
 
 func Setup() *errs.Error {
@@ -41,13 +43,33 @@ func GetCacheVal[T any](key interface{}, defVal T) T {
 	return defVal
 }
 
+/*
+RunExitCalls
+Run exit callbacks once, concurrent callers wait until the running one finishes
+执行一次退出回调，并发调用者会等待正在执行的完成
+*/
 func RunExitCalls() {
+	exitLock.Lock()
+	defer exitLock.Unlock()
 	for _, method := range ExitCalls {
 		method()
 	}
 	ExitCalls = nil
 }
 
+/*
+ExitProcess
+Stop all threads, run exit callbacks and exit, used by SIGTERM and the shutdown api
+停止所有线程，执行退出回调后退出进程，用于SIGTERM和退出接口
+*/
+func ExitProcess(code int) {
+	if StopAll != nil {
+		StopAll()
+	}
+	RunExitCalls()
+	os.Exit(code)
+}
+
 func KeyStratPairTf(stagy, pair, tf string) string {
 	var b strings.Builder
 	b.Grow(len(pair) + len(tf) + len(stagy) + 2)
@@ -117,11 +117,14 @@ const (
 	ExitTagSLTake      = "sl_take"
 	ExitTagTakeProfit  = "take_profit"
 	ExitTagDrawDown    = "draw_down"
+	ExitTagTrailStop   = "trail_stop"
 	ExitTagDataStuck   = "data_stuck"
 	ExitTagLiquidation = "liquidation"
 	ExitTagEnvEnd      = "env_end"
 	ExitTagEntExp      = "ent_expire" // enter limit expired
 	ExitTagExitDelay   = "exit_delay"
+	ExitTagGroupExit   = "group_exit" // other legs of the group exited
+	ExitTagDelist      = "delist"     // symbol delisted in backtest
 )
 
 var (
@@ -130,6 +133,7 @@ var (
 	lockPrices     deadlock.RWMutex
 	lockBarPrices  deadlock.RWMutex
 	TfPairHitsLock deadlock.RWMutex
+	PairCopiedLock deadlock.RWMutex
 	Ctx            context.Context // Used to stop all goroutines at the same time 用于全部goroutine同时停止
 	StopAll        func()          // Stop all robot threads 停止全部机器人线程
 	BotRunning     bool            // Is the robot running? 机器人是否正在运行
@@ -170,6 +174,22 @@ const (
 	LowCostKeepAll = "keepAll"
 )
 
+const (
+	MarginCross    = "cross"    // all positions of a settle currency share the wallet balance 同一结算币的所有仓位共享钱包余额
+	MarginIsolated = "isolated" // each position can only lose its own margin 每个仓位最多亏损自己的保证金
+)
+
+const (
+	ShutKeep             = "keep"              // keep positions and ensure stop loss on exchange 保留持仓并确保交易所止损单
+	ShutCloseAll         = "close_all"         // close all positions 全部平仓
+	ShutCloseUnprotected = "close_unprotected" // close positions without stop loss on exchange 平掉没有交易所止损单的仓位
+)
+
+const (
+	LiqByMark = "mark" // liquidation triggered by mark price, bar close in backtest 按标记价格触发强平，回测中为bar收盘价
+	LiqByLast = "last" // liquidation triggered by last price, including wicks of the bar 按最新价触发强平，包含bar的影线
+)
+
 var LowCostVals = map[string]int{
 	LowCostIgnore:  0,
 	LowCostKeepBig: 1,
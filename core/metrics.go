@@ -0,0 +1,217 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/sasha-s/go-deadlock"
+)
+
+const (
+	MetricBarsReceived   = "banbot_bars_received_total"
+	MetricKlineLag       = "banbot_kline_lag_seconds"
+	MetricOpenOrders     = "banbot_open_orders"
+	MetricWalletEquity   = "banbot_wallet_equity"
+	MetricUnrealizedPnl  = "banbot_unrealized_pnl"
+	MetricSubmitSeconds  = "banbot_order_submit_seconds"
+	MetricSubmitFailures = "banbot_order_submit_failures_total"
+	MetricWsReconnects   = "banbot_ws_reconnects_total"
+	MetricSignalRejects  = "banbot_signal_rejects_total"
+)
+
+const (
+	MetricCounter   = "counter"
+	MetricGauge     = "gauge"
+	MetricHistogram = "histogram"
+)
+
+var (
+	// MetricBuckets upper bounds(seconds) of histogram buckets 直方图分桶的上限(秒)
+	MetricBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+	metricHelps   = map[string]string{
+		MetricBarsReceived:   "Closed bars received from spider by timeframe",
+		MetricKlineLag:       "Seconds since the latest bar of the pair was received from spider",
+		MetricOpenOrders:     "Open orders by account and strategy",
+		MetricWalletEquity:   "Wallet equity in legal currency by account",
+		MetricUnrealizedPnl:  "Unrealized profit of open positions in legal currency by account",
+		MetricSubmitSeconds:  "Latency of submitting orders to exchange",
+		MetricSubmitFailures: "Failed order submits by error code",
+		MetricWsReconnects:   "Websocket watch failures in spider which trigger a reconnect",
+		MetricSignalRejects:  "Rejected signals of webhook by reason",
+	}
+	metrics     = make(map[string]*metricFamily)
+	lockMetrics deadlock.Mutex
+)
+
+type metricFamily struct {
+	kind   string
+	series map[string]*metricSeries // labels text: series 标签文本：序列
+}
+
+type metricSeries struct {
+	value   float64  // value for counter and gauge, sum for histogram 计数器和仪表的值，直方图的总和
+	count   uint64   // observation count of histogram 直方图的观测次数
+	buckets []uint64 // cumulative count of each bucket 各分桶的累计次数
+}
+
+/*
+AddMetric
+Add val to a counter with labels in key-value pairs, thread safe
+给计数器增加val，labels为键值对，线程安全
+*/
+func AddMetric(name string, val float64, labels ...string) {
+	lockMetrics.Lock()
+	getMetricSeries(name, MetricCounter, labels).value += val
+	lockMetrics.Unlock()
+}
+
+/*
+ObserveMetric
+Record an observation of a histogram with labels in key-value pairs, thread safe
+记录直方图的一次观测，labels为键值对，线程安全
+*/
+func ObserveMetric(name string, val float64, labels ...string) {
+	lockMetrics.Lock()
+	s := getMetricSeries(name, MetricHistogram, labels)
+	s.value += val
+	s.count += 1
+	for i, bound := range MetricBuckets {
+		if val <= bound {
+			s.buckets[i] += 1
+		}
+	}
+	lockMetrics.Unlock()
+}
+
+func getMetricSeries(name, kind string, labels []string) *metricSeries {
+	fam, ok := metrics[name]
+	if !ok {
+		fam = &metricFamily{kind: kind, series: make(map[string]*metricSeries)}
+		metrics[name] = fam
+	}
+	key := MetricLabels(labels...)
+	s, ok := fam.series[key]
+	if !ok {
+		s = &metricSeries{}
+		if kind == MetricHistogram {
+			s.buckets = make([]uint64, len(MetricBuckets))
+		}
+		fam.series[key] = s
+	}
+	return s
+}
+
+/*
+MetricLabels
+Format key-value pairs as prometheus labels like {k1="v1",k2="v2"}, empty for no labels
+将键值对格式化为prometheus标签，如{k1="v1",k2="v2"}，无标签时为空
+*/
+func MetricLabels(labels ...string) string {
+	if len(labels) < 2 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("{")
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			b.WriteString(",")
+		}
+		b.WriteString(labels[i])
+		b.WriteString(`="`)
+		b.WriteString(escapeLabel(labels[i+1]))
+		b.WriteString(`"`)
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+func escapeLabel(val string) string {
+	if !strings.ContainsAny(val, "\\\"\n") {
+		return val
+	}
+	val = strings.ReplaceAll(val, `\`, `\\`)
+	val = strings.ReplaceAll(val, `"`, `\"`)
+	return strings.ReplaceAll(val, "\n", `\n`)
+}
+
+func fmtMetricVal(val float64) string {
+	if math.IsInf(val, 1) {
+		return "+Inf"
+	} else if math.IsInf(val, -1) {
+		return "-Inf"
+	}
+	return strconv.FormatFloat(val, 'g', -1, 64)
+}
+
+/*
+WriteMetricHead
+Write HELP and TYPE lines of a metric in prometheus text format
+以prometheus文本格式写入指标的HELP和TYPE行
+*/
+func WriteMetricHead(w io.Writer, name, kind string) {
+	if help, ok := metricHelps[name]; ok {
+		_, _ = fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	}
+	_, _ = fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+}
+
+// WriteMetric write a sample line in prometheus text format 以prometheus文本格式写入一行样本
+func WriteMetric(w io.Writer, name string, val float64, labels ...string) {
+	_, _ = fmt.Fprintf(w, "%s%s %s\n", name, MetricLabels(labels...), fmtMetricVal(val))
+}
+
+/*
+WriteMetrics
+Write all counters and histograms recorded by AddMetric/ObserveMetric in prometheus text format, sorted by name
+以prometheus文本格式写入AddMetric/ObserveMetric记录的所有计数器和直方图，按名称排序
+*/
+func WriteMetrics(w io.Writer) {
+	lockMetrics.Lock()
+	defer lockMetrics.Unlock()
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		fam := metrics[name]
+		WriteMetricHead(w, name, fam.kind)
+		keys := make([]string, 0, len(fam.series))
+		for key := range fam.series {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			s := fam.series[key]
+			if fam.kind != MetricHistogram {
+				_, _ = fmt.Fprintf(w, "%s%s %s\n", name, key, fmtMetricVal(s.value))
+				continue
+			}
+			for i, bound := range MetricBuckets {
+				_, _ = fmt.Fprintf(w, "%s_bucket%s %d\n", name, withLe(key, fmtMetricVal(bound)), s.buckets[i])
+			}
+			_, _ = fmt.Fprintf(w, "%s_bucket%s %d\n", name, withLe(key, "+Inf"), s.count)
+			_, _ = fmt.Fprintf(w, "%s_sum%s %s\n", name, key, fmtMetricVal(s.value))
+			_, _ = fmt.Fprintf(w, "%s_count%s %d\n", name, key, s.count)
+		}
+	}
+}
+
+// withLe append the le label of histogram bucket to labels text 将直方图分桶的le标签追加到标签文本
+func withLe(key, le string) string {
+	if key == "" {
+		return `{le="` + le + `"}`
+	}
+	return key[:len(key)-1] + `,le="` + le + `"}`
+}
+
+// ResetMetrics clear all recorded counters and histograms 清空已记录的计数器和直方图
+func ResetMetrics() {
+	lockMetrics.Lock()
+	metrics = make(map[string]*metricFamily)
+	lockMetrics.Unlock()
+}
@@ -0,0 +1,35 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	ResetMetrics()
+	defer ResetMetrics()
+	AddMetric(MetricSubmitFailures, 1, "account", "user1", "code", "-1")
+	AddMetric(MetricSubmitFailures, 2, "account", "user1", "code", "-1")
+	ObserveMetric(MetricSubmitSeconds, 0.3, "account", "user1")
+	ObserveMetric(MetricSubmitSeconds, 20, "account", "user1")
+	AddMetric(MetricWsReconnects, 1, "watch", "a\"b")
+	var b strings.Builder
+	WriteMetrics(&b)
+	text := b.String()
+	lines := []string{
+		`# TYPE banbot_order_submit_failures_total counter`,
+		`banbot_order_submit_failures_total{account="user1",code="-1"} 3`,
+		`# TYPE banbot_order_submit_seconds histogram`,
+		`banbot_order_submit_seconds_bucket{account="user1",le="0.25"} 0`,
+		`banbot_order_submit_seconds_bucket{account="user1",le="0.5"} 1`,
+		`banbot_order_submit_seconds_bucket{account="user1",le="+Inf"} 2`,
+		`banbot_order_submit_seconds_sum{account="user1"} 20.3`,
+		`banbot_order_submit_seconds_count{account="user1"} 2`,
+		`banbot_ws_reconnects_total{watch="a\"b"} 1`,
+	}
+	for _, line := range lines {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("missing line: %s\n%s", line, text)
+		}
+	}
+}
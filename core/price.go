@@ -3,6 +3,7 @@ package core
 import (
 	"fmt"
 	"github.com/banbox/banexg"
+	"github.com/banbox/banexg/errs"
 	"strings"
 )
 
@@ -33,11 +34,56 @@ func GetPrice(symbol string) float64 {
 	return price
 }
 
-func setDataPrice(data map[string]float64, pair string, price float64) {
-	data[pair] = price
+/*
+LegalRate
+Price of a currency in legal currency, 1 for fiat, -1 if not loaded, used to convert amounts of non-fiat quotes
+币种的法币价格，法币返回1，未加载返回-1，用于换算非法币定价的金额
+*/
+func LegalRate(code string) float64 {
+	price := GetPriceSafe(code)
+	if price <= 0 {
+		return -1
+	}
+	return price
+}
+
+/*
+GetLegalRate
+Same as LegalRate, but return an error if the rate is not loaded, used where an unknown rate must block the operation
+同LegalRate，但汇率未加载时返回错误，用于汇率未知时必须阻止的操作
+*/
+func GetLegalRate(code string) (float64, *errs.Error) {
+	price := LegalRate(code)
+	if price <= 0 {
+		return 0, errs.NewMsg(ErrRunTime, "legal rate of %s not loaded", code)
+	}
+	return price, nil
+}
+
+/*
+QuoteCode
+Currency in which the profit of pair is settled: the settle currency for contracts, otherwise the quote
+交易对利润的结算币种：合约为结算币，否则为定价币
+*/
+func QuoteCode(pair string) string {
+	_, _, pair = ParseVenueKey(pair)
+	_, quote, settle, _ := SplitSymbol(pair)
+	if settle != "" {
+		return settle
+	}
+	return quote
+}
+
+func setDataPrice(data map[string]float64, key string, price float64) {
+	data[key] = price
+	exgName, _, pair := ParseVenueKey(key)
 	base, quote, settle, _ := SplitSymbol(pair)
 	if IsFiat(quote) && (settle == "" || settle == quote) {
-		data[base] = price
+		// coin prices from other exchanges are only used when missing on the default one
+		// 其他交易所的币种价格仅在默认交易所缺失时使用
+		if _, ok := data[base]; exgName == "" || !ok {
+			data[base] = price
+		}
 	}
 }
 
@@ -57,19 +103,27 @@ func IsPriceEmpty() bool {
 }
 
 func SetPrices(data map[string]float64) {
+	SetVenuePrices(ExgName, Market, data)
+}
+
+/*
+SetVenuePrices
+Set latest prices of symbols on the exchange and market, they are stored by VenueKey
+设置交易所和市场上品种的最新价格，按VenueKey存储
+*/
+func SetVenuePrices(exgName, market string, data map[string]float64) {
 	lockPrices.Lock()
 	for pair, price := range data {
-		prices[pair] = price
-		base, quote, settle, _ := SplitSymbol(pair)
-		if IsFiat(quote) && (settle == "" || settle == quote) {
-			prices[base] = price
-		}
+		setDataPrice(prices, VenueKey(exgName, market, pair), price)
 	}
 	lockPrices.Unlock()
 }
 
 func IsMaker(pair, side string, price float64) bool {
-	curPrice := GetPrice(pair)
+	curPrice := GetPriceSafe(pair)
+	if curPrice <= 0 {
+		return false
+	}
 	isBuy := side == banexg.OdSideBuy
 	isLow := price < curPrice
 	return isBuy == isLow
@@ -0,0 +1,101 @@
+package core
+
+import "testing"
+
+func TestVenuePrices(t *testing.T) {
+	oldExg, oldMarket := ExgName, Market
+	ExgName, Market = "binance", "linear"
+	lockPrices.Lock()
+	oldPrices := prices
+	prices = make(map[string]float64)
+	lockPrices.Unlock()
+	defer func() {
+		ExgName, Market = oldExg, oldMarket
+		lockPrices.Lock()
+		prices = oldPrices
+		lockPrices.Unlock()
+	}()
+	SetPrices(map[string]float64{"BTC/USDT:USDT": 101})
+	SetVenuePrices("okx", "linear", map[string]float64{"BTC/USDT:USDT": 99, "ETH/USDT:USDT": 5})
+	cases := []struct {
+		key   string
+		price float64
+	}{
+		{"BTC/USDT:USDT", 101},
+		{"okx:linear:BTC/USDT:USDT", 99},
+		{"BTC", 101},
+		{"ETH", 5},
+		{"ETH/USDT:USDT", -1},
+		{"okx:spot:BTC/USDT", -1},
+		{"USDT", 1},
+	}
+	for _, c := range cases {
+		if res := GetPriceSafe(c.key); res != c.price {
+			t.Errorf("price of %s expect %v, got %v", c.key, c.price, res)
+		}
+	}
+}
+
+func TestVenueKey(t *testing.T) {
+	oldExg, oldMarket := ExgName, Market
+	ExgName, Market = "binance", "linear"
+	defer func() {
+		ExgName, Market = oldExg, oldMarket
+	}()
+	cases := []struct {
+		exgName, market, symbol, key string
+	}{
+		{"binance", "linear", "BTC/USDT:USDT", "BTC/USDT:USDT"},
+		{"binance", "spot", "BTC/USDT", "binance:spot:BTC/USDT"},
+		{"okx", "linear", "BTC/USDT:USDT", "okx:linear:BTC/USDT:USDT"},
+	}
+	for _, c := range cases {
+		key := VenueKey(c.exgName, c.market, c.symbol)
+		if key != c.key {
+			t.Errorf("VenueKey %s %s %s expect %s, got %s", c.exgName, c.market, c.symbol, c.key, key)
+			continue
+		}
+		exgName, market, symbol := ParseVenueKey(key)
+		if symbol != c.symbol || (exgName != "" && (exgName != c.exgName || market != c.market)) {
+			t.Errorf("ParseVenueKey %s got %s %s %s", key, exgName, market, symbol)
+		}
+	}
+	if code := QuoteCode("okx:linear:BTC/USDT:USDT"); code != "USDT" {
+		t.Errorf("QuoteCode expect USDT, got %s", code)
+	}
+}
+
+func TestLegalRate(t *testing.T) {
+	lockPrices.Lock()
+	oldPrices := prices
+	prices = make(map[string]float64)
+	lockPrices.Unlock()
+	defer func() {
+		lockPrices.Lock()
+		prices = oldPrices
+		lockPrices.Unlock()
+	}()
+	SetPrices(map[string]float64{"BTC": 50000})
+	cases := []struct {
+		code string
+		rate float64
+	}{
+		{"USDT", 1},
+		{"USD", 1},
+		{"BTC", 50000},
+		{"ETH", -1},
+	}
+	for _, c := range cases {
+		if rate := LegalRate(c.code); rate != c.rate {
+			t.Errorf("LegalRate(%s) = %v, want %v", c.code, rate, c.rate)
+		}
+		rate, err := GetLegalRate(c.code)
+		if c.rate < 0 {
+			if err == nil {
+				t.Errorf("GetLegalRate(%s) should fail for unknown rate, got %v", c.code, rate)
+			}
+		} else if err != nil || rate != c.rate {
+			t.Errorf("GetLegalRate(%s) = %v, %v, want %v", c.code, rate, err, c.rate)
+		}
+	}
+}
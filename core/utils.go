@@ -79,3 +79,29 @@ func SplitSymbol(pair string) (string, string, string, string) {
 	cache, _ := splitCache[pair]
 	return cache[0], cache[1], cache[2], cache[3]
 }
+
+/*
+VenueKey
+Key of the symbol used by data feeds and strategy jobs. It's the symbol itself for the default exchange and market,
+and exchange:market:symbol for others, so the same symbol on different exchanges won't conflict.
+数据源和策略任务使用的品种键。默认交易所和市场为品种本身，其他为exchange:market:symbol，避免不同交易所的相同品种冲突
+*/
+func VenueKey(exgName, market, symbol string) string {
+	if exgName == ExgName && market == Market {
+		return symbol
+	}
+	return fmt.Sprintf("%s:%s:%s", exgName, market, symbol)
+}
+
+/*
+ParseVenueKey
+Parse the key from VenueKey, exgName is empty for symbols of the default exchange
+解析VenueKey返回的键，默认交易所的品种exgName为空
+*/
+func ParseVenueKey(key string) (string, string, string) {
+	arr := strings.SplitN(key, ":", 3)
+	if len(arr) == 3 && !strings.Contains(arr[0], "/") {
+		return arr[0], arr[1], arr[2]
+	}
+	return "", "", key
+}
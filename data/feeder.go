@@ -70,7 +70,7 @@ func (f *Feeder) getStates() []*PairTFCache {
 }
 
 func (f *Feeder) getSymbol() string {
-	return f.Symbol
+	return f.ExSymbol.VenueKey()
 }
 
 func (f *Feeder) getWaitBar() *banexg.Kline {
@@ -257,7 +257,7 @@ func (f *Feeder) addTfKlines(tf string, bars []*banexg.Kline) {
 
 func (f *Feeder) fireCallBacks(timeFrame string, tfMSecs int64, bars []*banexg.Kline, adj *orm.AdjInfo) {
 	isLive := core.LiveMode
-	pair := f.Symbol
+	pair := f.getSymbol()
 	for _, bar := range bars {
 		if !isLive || f.isWarmUp {
 			btime.CurTimeMS = bar.Time + tfMSecs
@@ -370,11 +370,11 @@ func (f *KlineFeeder) WarmTfs(curMS int64, tfNums map[string]int, pBar *utils.Pr
 			return 0, nil, err
 		}
 		if len(bars) == 0 && f.showLog {
-			skips[fmt.Sprintf("%s_%s", f.Symbol, tf)] = [2]int{warmNum, 0}
+			skips[fmt.Sprintf("%s_%s", f.getSymbol(), tf)] = [2]int{warmNum, 0}
 			continue
 		}
 		if warmNum != len(bars) && f.showLog {
-			skips[fmt.Sprintf("%s_%s", f.Symbol, tf)] = [2]int{warmNum, len(bars)}
+			skips[fmt.Sprintf("%s_%s", f.getSymbol(), tf)] = [2]int{warmNum, len(bars)}
 		}
 		curEnd := f.warmTf(tf, bars)
 		if !hourDone && tfMSecs == 3600000 {
@@ -418,7 +418,7 @@ func (f *KlineFeeder) warmTf(tf string, bars []*banexg.Kline) int64 {
 	f.isWarmUp = true
 	tfMSecs := int64(utils2.TFToSecs(tf) * 1000)
 	lastMS := bars[len(bars)-1].Time + tfMSecs
-	envKey := strings.Join([]string{f.Symbol, tf}, "_")
+	envKey := strings.Join([]string{f.getSymbol(), tf}, "_")
 	if env, ok := strat.Envs[envKey]; ok {
 		env.Reset()
 	}
@@ -643,7 +643,7 @@ func (f *DBKlineFeeder) CallNext() {
 			old := f.caches[f.rowIdx-1]
 			tf := f.Timeframe
 			f.OnEnvEnd(&banexg.PairTFKline{
-				Symbol:    f.Symbol,
+				Symbol:    f.getSymbol(),
 				TimeFrame: tf,
 				Kline:     *old,
 			}, f.adj)
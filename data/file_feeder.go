@@ -0,0 +1,228 @@
+package data
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/banbox/banbot/config"
+	"github.com/banbox/banbot/core"
+	"github.com/banbox/banbot/orm"
+	"github.com/banbox/banbot/utils"
+	"github.com/banbox/banexg"
+	"github.com/banbox/banexg/errs"
+	utils2 "github.com/banbox/banexg/utils"
+)
+
+/*
+FileKlines
+K-lines of a symbol loaded from a local json file, used to backtest without the database
+从本地json文件加载的某个品种的K线，用于无数据库回测
+*/
+type FileKlines struct {
+	Pair      string
+	TimeFrame string
+	TFMSecs   int64
+	Bars      []*banexg.Kline
+}
+
+var fileKlines = make(map[string]*FileKlines)
+
+/*
+AddKlineFile
+Register a json file of K-lines for pair, HistProvider will feed the pair from it instead of the database.
+The file is an array of {"Time","Open","High","Low","Close","Volume"}, sorted by Time, same as data/testdata/btc_1m.json
+为品种注册K线json文件，HistProvider将从此文件而不是数据库读取该品种。
+文件为{"Time","Open","High","Low","Close","Volume"}数组，按Time排序，同data/testdata/btc_1m.json
+*/
+func AddKlineFile(pair, tf, path string) *errs.Error {
+	var bars []*banexg.Kline
+	err_ := utils2.ReadJsonFile(path, &bars, utils2.JsonNumDefault)
+	if err_ != nil {
+		return errs.New(errs.CodeIOReadFail, err_)
+	}
+	if len(bars) == 0 {
+		return errs.NewMsg(errs.CodeParamInvalid, "no klines in %s", path)
+	}
+	tfMSecs := int64(utils2.TFToSecs(tf) * 1000)
+	for i := 1; i < len(bars); i++ {
+		if bars[i].Time <= bars[i-1].Time || (bars[i].Time-bars[i-1].Time)%tfMSecs != 0 {
+			return errs.NewMsg(errs.CodeParamInvalid, "klines of %s not sorted or not aligned to %s at %v",
+				path, tf, bars[i].Time)
+		}
+	}
+	fileKlines[pair] = &FileKlines{
+		Pair:      pair,
+		TimeFrame: tf,
+		TFMSecs:   tfMSecs,
+		Bars:      bars,
+	}
+	return nil
+}
+
+// ResetKlineFiles remove all registered K-line files 移除所有已注册的K线文件
+func ResetKlineFiles() {
+	fileKlines = make(map[string]*FileKlines)
+}
+
+func getFileKlines(pair string) *FileKlines {
+	return fileKlines[pair]
+}
+
+/*
+FileKlineFeeder
+Historical data feeder reading K-lines from FileKlines. Larger timeframes are all built from the file K-lines.
+从FileKlines读取K线的历史数据反馈器。更大周期全部从文件K线归集。
+*/
+type FileKlineFeeder struct {
+	KlineFeeder
+	src    *FileKlines
+	rowIdx int   // index of the next bar, -1 means ended 下一个bar的索引，-1表示已结束
+	nextMS int64 // end timestamp of the next bar, math.MaxInt64 means ended 下一个bar的结束时间戳，math.MaxInt64表示结束
+	endMS  int64
+}
+
+func NewFileKlineFeeder(exs *orm.ExSymbol, src *FileKlines, callBack FnPairKline, showLog bool) *FileKlineFeeder {
+	return &FileKlineFeeder{
+		KlineFeeder: KlineFeeder{
+			Feeder: Feeder{
+				ExSymbol: exs,
+				CallBack: callBack,
+				tfBars:   make(map[string][]*banexg.Kline),
+			},
+			PreFire: config.PreFire,
+			showLog: showLog,
+		},
+		src:   src,
+		endMS: config.TimeRange.EndMS,
+	}
+}
+
+func (f *FileKlineFeeder) getNextMS() int64 {
+	return f.nextMS
+}
+
+// DownIfNeed nothing to download for local files 本地文件无需下载
+func (f *FileKlineFeeder) DownIfNeed(sess *orm.Queries, exchange banexg.BanExchange, pBar *utils.PrgBar) *errs.Error {
+	if pBar != nil {
+		pBar.Add(core.StepTotal)
+	}
+	return nil
+}
+
+func (f *FileKlineFeeder) SubTfs(timeFrames []string, delOther bool) []string {
+	arr := f.Feeder.SubTfs(timeFrames, delOther)
+	// all timeframes are built from file klines, no hour loader from database
+	// 所有周期从文件K线归集，不从数据库加载1h
+	f.hour = nil
+	return arr
+}
+
+func (f *FileKlineFeeder) SetSeek(since int64) {
+	bars := f.src.Bars
+	f.rowIdx = sort.Search(len(bars), func(i int) bool {
+		return bars[i].Time >= since
+	})
+	f.setNextMS()
+}
+
+func (f *FileKlineFeeder) SetEndMS(ms int64) {
+	f.endMS = ms
+	if f.rowIdx >= 0 {
+		f.setNextMS()
+	}
+}
+
+func (f *FileKlineFeeder) GetBar() *banexg.Kline {
+	if f.rowIdx < 0 || f.nextMS == math.MaxInt64 {
+		return nil
+	}
+	return f.src.Bars[f.rowIdx]
+}
+
+func (f *FileKlineFeeder) RunBar(bar *banexg.Kline) *errs.Error {
+	_, err := f.onNewBars(f.src.TFMSecs, []*banexg.Kline{bar})
+	return err
+}
+
+func (f *FileKlineFeeder) CallNext() {
+	if f.rowIdx < 0 {
+		return
+	}
+	f.rowIdx += 1
+	f.setNextMS()
+}
+
+func (f *FileKlineFeeder) setNextMS() {
+	if f.rowIdx >= len(f.src.Bars) {
+		f.rowIdx = -1
+		f.nextMS = math.MaxInt64
+		return
+	}
+	nextMS := f.src.Bars[f.rowIdx].Time + f.src.TFMSecs
+	if f.endMS > 0 && nextMS > f.endMS {
+		f.nextMS = math.MaxInt64
+		return
+	}
+	f.nextMS = nextMS
+}
+
+/*
+WarmTfs
+Warm up each timeframe with file klines before curMS, aggregating when the timeframe is larger than the file.
+使用curMS之前的文件K线预热各周期，周期大于文件周期时进行归集。
+*/
+func (f *FileKlineFeeder) WarmTfs(curMS int64, tfNums map[string]int, pBar *utils.PrgBar) (int64, map[string][2]int, *errs.Error) {
+	if len(tfNums) == 0 {
+		tfNums = f.warmNums
+		if len(tfNums) == 0 {
+			return 0, nil, nil
+		}
+	} else {
+		f.warmNums = tfNums
+	}
+	maxEndMs := int64(0)
+	skips := make(map[string][2]int)
+	bars := f.src.Bars
+	for tf, warmNum := range tfNums {
+		tfMSecs := int64(utils2.TFToSecs(tf) * 1000)
+		endMS := utils2.AlignTfMSecs(curMS, tfMSecs)
+		if warmNum <= 0 {
+			maxEndMs = max(maxEndMs, endMS)
+			continue
+		}
+		if tfMSecs < f.src.TFMSecs || tfMSecs%f.src.TFMSecs != 0 {
+			return 0, nil, errs.NewMsg(errs.CodeParamInvalid, "%s: can't build %s from file klines of %s",
+				f.getSymbol(), tf, f.src.TimeFrame)
+		}
+		stop := sort.Search(len(bars), func(i int) bool {
+			return bars[i].Time+f.src.TFMSecs > endMS
+		})
+		tfBars := bars[:stop]
+		if tfMSecs > f.src.TFMSecs {
+			var alignOff int64
+			for _, sta := range f.States {
+				if sta.TimeFrame == tf {
+					alignOff = sta.AlignOffMS
+					break
+				}
+			}
+			var lastOk bool
+			tfBars, lastOk = utils.BuildOHLCV(tfBars, tfMSecs, 0, nil, f.src.TFMSecs, alignOff, f.InfoBy())
+			if !lastOk && len(tfBars) > 0 {
+				tfBars = tfBars[:len(tfBars)-1]
+			}
+		}
+		if len(tfBars) > warmNum {
+			tfBars = tfBars[len(tfBars)-warmNum:]
+		}
+		if len(tfBars) < warmNum && f.showLog {
+			skips[fmt.Sprintf("%s_%s", f.getSymbol(), tf)] = [2]int{warmNum, len(tfBars)}
+		}
+		if len(tfBars) == 0 {
+			continue
+		}
+		maxEndMs = max(maxEndMs, f.warmTf(tf, tfBars))
+	}
+	return maxEndMs, skips, nil
+}
@@ -5,7 +5,9 @@ import (
 	"github.com/banbox/banbot/strat"
 	"github.com/sasha-s/go-deadlock"
 	"math"
+	"slices"
 	"sort"
+	"strings"
 
 	"github.com/banbox/banbot/btime"
 	"github.com/banbox/banbot/config"
@@ -177,6 +179,12 @@ func NewHistProvider(callBack FnPairKline, envEnd FuncEnvEnd, getEnd FnGetInt64,
 				if err != nil {
 					return nil, err
 				}
+				if src := getFileKlines(pair); src != nil {
+					feeder := NewFileKlineFeeder(exs, src, callBack, showLog)
+					feeder.OnEnvEnd = envEnd
+					feeder.SubTfs(tfs, false)
+					return feeder, nil
+				}
 				feeder, err := NewDBKlineFeeder(exs, callBack, showLog)
 				if err != nil {
 					return nil, err
@@ -426,7 +434,7 @@ func NewLiveProvider(callBack FnPairKline, envEnd FuncEnvEnd) (*LiveProvider, *e
 		Provider: Provider[IKlineFeeder]{
 			holders: make(map[string]IKlineFeeder),
 			newFeeder: func(pair string, tfs []string) (IKlineFeeder, *errs.Error) {
-				exs, err := orm.GetExSymbol(exg.Default, pair)
+				exs, err := orm.GetExSymbolCur(pair)
 				if err != nil {
 					return nil, err
 				}
@@ -445,10 +453,17 @@ func NewLiveProvider(callBack FnPairKline, envEnd FuncEnvEnd) (*LiveProvider, *e
 	watcher.OnKLineMsg = makeOnKlineMsg(provider)
 	watcher.OnTrades = makeOnTrade(provider)
 	watcher.OnDepth = makeOnDepth(provider)
-	// 立刻订阅实时价格
-	err = watcher.SendMsg("subscribe", []string{
-		fmt.Sprintf("price_%s_%s", core.ExgName, core.Market),
-	})
+	// Subscribe real-time prices of the default exchange and all accounts' exchanges at once
+	// 立刻订阅默认交易所和所有账户所在交易所的实时价格
+	priceKeys := []string{fmt.Sprintf("price_%s_%s", core.ExgName, core.Market)}
+	for account := range config.Accounts {
+		exgName, market := config.GetAccVenue(account)
+		key := fmt.Sprintf("price_%s_%s", exgName, market)
+		if !slices.Contains(priceKeys, key) {
+			priceKeys = append(priceKeys, key)
+		}
+	}
+	err = watcher.SendMsg("subscribe", priceKeys)
 	if err != nil {
 		return nil, err
 	}
@@ -461,21 +476,29 @@ func (p *LiveProvider) SubWarmPairs(items map[string]map[string]int, delOther bo
 		return err
 	}
 	if len(newHolds) > 0 {
-		var jobs []WatchJob
+		// Group by exchange and market, symbols on other exchanges are subscribed with their venue
+		// 按交易所和市场分组，其他交易所的品种在其所在场所订阅
+		venueJobs := make(map[string][]WatchJob)
 		for _, h := range newHolds {
-			symbol, timeFrame := h.getSymbol(), h.getStates()[0].TimeFrame
-			if since, ok := sinceMap[symbol]; ok {
-				jobs = append(jobs, WatchJob{
+			key, timeFrame := h.getSymbol(), h.getStates()[0].TimeFrame
+			if since, ok := sinceMap[key]; ok {
+				exgName, market, symbol := parseVenue(key)
+				venue := exgName + ":" + market
+				venueJobs[venue] = append(venueJobs[venue], WatchJob{
 					Symbol:    symbol,
 					TimeFrame: timeFrame,
 					Since:     since,
 				})
 			}
 		}
-		err = p.WatchJobs(core.ExgName, core.Market, "ohlcv", jobs...)
-		if err != nil {
-			return err
+		for venue, jobs := range venueJobs {
+			exgName, market, _ := strings.Cut(venue, ":")
+			err = p.WatchJobs(exgName, market, "ohlcv", jobs...)
+			if err != nil {
+				return err
+			}
 		}
+		var jobs []WatchJob
 		for msgType, pairMap := range strat.WsSubJobs {
 			jobs = make([]WatchJob, 0, len(pairMap))
 			for pair := range pairMap {
@@ -488,10 +511,7 @@ func (p *LiveProvider) SubWarmPairs(items map[string]map[string]int, delOther bo
 		}
 	}
 	if len(delPairs) > 0 {
-		err = p.UnWatchJobs(core.ExgName, core.Market, "ohlcv", delPairs)
-		if err != nil {
-			return err
-		}
+		return p.unWatchVenues(delPairs)
 	}
 	return nil
 }
@@ -501,7 +521,37 @@ func (p *LiveProvider) UnSubPairs(pairs ...string) *errs.Error {
 	if len(removed) == 0 {
 		return nil
 	}
-	return p.UnWatchJobs(core.ExgName, core.Market, "ohlcv", pairs)
+	return p.unWatchVenues(pairs)
+}
+
+func (p *LiveProvider) unWatchVenues(keys []string) *errs.Error {
+	venuePairs := make(map[string][]string)
+	for _, key := range keys {
+		exgName, market, symbol := parseVenue(key)
+		venue := exgName + ":" + market
+		venuePairs[venue] = append(venuePairs[venue], symbol)
+	}
+	for venue, pairs := range venuePairs {
+		exgName, market, _ := strings.Cut(venue, ":")
+		err := p.UnWatchJobs(exgName, market, "ohlcv", pairs)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+parseVenue
+Parse the holder key into exchange, market and symbol, see orm.VenueKey
+将数据源的键解析为交易所、市场和品种，见orm.VenueKey
+*/
+func parseVenue(key string) (string, string, string) {
+	exgName, market, symbol := core.ParseVenueKey(key)
+	if exgName == "" {
+		return core.ExgName, core.Market, symbol
+	}
+	return exgName, market, symbol
 }
 
 func (p *LiveProvider) LoopMain() *errs.Error {
@@ -510,13 +560,11 @@ func (p *LiveProvider) LoopMain() *errs.Error {
 
 func makeOnKlineMsg(p *LiveProvider) func(msg *KLineMsg) {
 	return func(msg *KLineMsg) {
-		if msg.ExgName != core.ExgName || msg.Market != core.Market {
-			return
-		}
-		if msg.Interval < msg.TFSecs {
+		key := core.VenueKey(msg.ExgName, msg.Market, msg.Pair)
+		if msg.Interval < msg.TFSecs && key == msg.Pair {
 			fireWsKlines(msg)
 		}
-		hold, ok := p.holders[msg.Pair]
+		hold, ok := p.holders[key]
 		if !ok {
 			return
 		}
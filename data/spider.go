@@ -373,6 +373,16 @@ func (m *Miner) UnSubPairs(jobType string, pairs ...string) *errs.Error {
 	return nil
 }
 
+/*
+setWatchFail
+Delay the next retry of a failed or stopped websocket watch, and count it as a reconnect
+推迟失败或停止的websocket监听的下次重试，并计为一次重连
+*/
+func (m *Miner) setWatchFail(key string) {
+	retryWaits.SetFail(key)
+	core.AddMetric(core.MetricWsReconnects, 1, "exchange", m.ExgName, "market", m.Market, "watch", key)
+}
+
 func (m *Miner) watchTrades(pairs []string) {
 	pairs = m.Trades.GetNewSubs(pairs)
 	if len(pairs) == 0 {
@@ -381,7 +391,7 @@ func (m *Miner) watchTrades(pairs []string) {
 	out, err := m.exchange.WatchTrades(pairs, nil)
 	if err != nil {
 		m.Trades.Status = 0
-		retryWaits.SetFail("watchTrades")
+		m.setWatchFail("watchTrades")
 		log.Error("watch trades fail", zap.String("exg", m.ExgName), zap.Error(err))
 		return
 	}
@@ -396,7 +406,7 @@ func (m *Miner) watchTrades(pairs []string) {
 	go func() {
 		defer func() {
 			m.Trades.Status = 0
-			retryWaits.SetFail("watchTrades")
+			m.setWatchFail("watchTrades")
 			log.Info("watch trades stopped", zap.String("exg", m.ExgName))
 		}()
 		for {
@@ -428,7 +438,7 @@ func (m *Miner) watchPrices() {
 	})
 	if err != nil {
 		m.IsWatchPrice = false
-		retryWaits.SetFail("watchPrices")
+		m.setWatchFail("watchPrices")
 		log.Error("watch prices fail", zap.String("exg", m.ExgName), zap.Error(err))
 		return
 	}
@@ -440,7 +450,7 @@ func (m *Miner) watchPrices() {
 	go func() {
 		defer func() {
 			m.IsWatchPrice = false
-			retryWaits.SetFail("watchPrices")
+			m.setWatchFail("watchPrices")
 			log.Info("watch prices stopped", zap.String("exg", m.ExgName))
 		}()
 		for item := range out {
@@ -463,7 +473,7 @@ func (m *Miner) watchOdBooks(pairs []string) {
 	out, err := m.exchange.WatchOrderBooks(pairs, 0, nil)
 	if err != nil {
 		m.Depths.Status = 0
-		retryWaits.SetFail("watchOdBooks")
+		m.setWatchFail("watchOdBooks")
 		log.Error("watch odBook fail", zap.String("exg", m.ExgName), zap.Error(err))
 		return
 	}
@@ -478,7 +488,7 @@ func (m *Miner) watchOdBooks(pairs []string) {
 	go func() {
 		defer func() {
 			m.Depths.Status = 0
-			retryWaits.SetFail("watchOdBooks")
+			m.setWatchFail("watchOdBooks")
 			log.Info("watch odBook stopped", zap.String("exg", m.ExgName))
 		}()
 		for {
@@ -542,7 +552,7 @@ func (m *Miner) watchKLines(pairs []string) {
 	out, err := m.exchange.WatchOHLCVs(jobs, nil)
 	if err != nil {
 		m.KLines.Status = 0
-		retryWaits.SetFail("watchKLines")
+		m.setWatchFail("watchKLines")
 		log.Error("watch kline fail", zap.String("exg", m.ExgName),
 			zap.Strings("pairs", pairs), zap.Error(err))
 		return
@@ -635,7 +645,7 @@ func (m *Miner) watchKLines(pairs []string) {
 	go func() {
 		defer func() {
 			m.KLines.Status = 0
-			retryWaits.SetFail("watchKLines")
+			m.setWatchFail("watchKLines")
 			log.Info("watch kline stopped", zap.String("exg", m.ExgName))
 		}()
 		for {
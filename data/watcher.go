@@ -149,7 +149,9 @@ func (w *KLineWatcher) UnWatchJobs(exgName, marketType, jobType string, pairs []
 		}
 		jobKey := fmt.Sprintf("%s_%s", pair, jobType)
 		delete(w.jobs, jobKey)
+		core.PairCopiedLock.Lock()
 		delete(core.PairCopiedMs, pair)
+		core.PairCopiedLock.Unlock()
 	}
 	if len(tags) == 0 {
 		return nil
@@ -210,6 +212,7 @@ func (w *KLineWatcher) onSpiderBar(key string, data []byte) {
 	num, _ := hits[pair]
 	hits[pair] = num + len(bars.Arr)
 	core.TfPairHitsLock.Unlock()
+	core.AddMetric(core.MetricBarsReceived, float64(len(bars.Arr)), "timeframe", timeFrame)
 	// 检测并填充缺失的K线
 	olds, err := job.fillLacks(pair, bars.TFSecs, bars.Arr[0].Time, nextBarMS)
 	if err != nil {
@@ -240,16 +243,13 @@ func (w *KLineWatcher) onSpiderBar(key string, data []byte) {
 func (w *KLineWatcher) onPriceUpdate(key string, data []byte) {
 	parts := strings.Split(key, "_")
 	exgName, market := parts[1], parts[2]
-	if exgName != core.ExgName || market != core.Market {
-		return
-	}
 	var msg map[string]float64
 	err := utils2.Unmarshal(data, &msg, utils2.JsonNumDefault)
 	if err != nil {
 		log.Warn("onPriceUpdate receive invalid msg", zap.String("raw", string(data)), zap.Error(err))
 		return
 	}
-	core.SetPrices(msg)
+	core.SetVenuePrices(exgName, market, msg)
 }
 
 func (w *KLineWatcher) onTrades(key string, data []byte) {
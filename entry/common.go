@@ -63,12 +63,14 @@ func init() {
 	AddGroup("tick", "run tick commands")
 	AddGroup("tool", "run tools commands")
 	AddGroup("live", "run live order manager commands")
+	AddGroup("bt", "run backtest result commands")
+	AddGroup("config", "run config commands")
 
 	// Root command group
 	AddCmdJob(&CmdJob{
 		Name:    "trade",
 		Run:     RunTrade,
-		Options: []string{"stake_amount", "pairs", "with_spider", "out"},
+		Options: []string{"stake_amount", "pairs", "with_spider", "out", "force", "safe_start"},
 		Help:    "live trade",
 	})
 	AddCmdJob(&CmdJob{
@@ -279,6 +281,28 @@ func init() {
 		Options: []string{"in", "out"},
 		Help:    "build backtest result from orders.gob and config",
 	})
+	AddCmdJob(&CmdJob{
+		Name:   "secret",
+		Parent: "tool",
+		RunRaw: config.RunSecretTool,
+		Help:   "manage encrypted keystore for secrets referenced by enc:NAME in config",
+	})
+
+	// bt command group
+	AddCmdJob(&CmdJob{
+		Name:   "diff",
+		Parent: "bt",
+		RunRaw: opt.RunBtDiff,
+		Help:   "compare two backtest results, exit non-zero on regression",
+	})
+
+	// config command group
+	AddCmdJob(&CmdJob{
+		Name:   "check",
+		Parent: "config",
+		RunRaw: biz.RunConfigCheck,
+		Help:   "validate merged yaml configs, report problems with file and line",
+	})
 
 	AddCmdJob(&CmdJob{
 		Name:   "down_order",
@@ -88,7 +88,7 @@ func RunTrade(args *config.CmdArgs) *errs.Error {
 	}
 	core.BotRunning = true
 	core.StartAt = btime.UTCStamp()
-	t := live.NewCryptoTrader()
+	t := live.NewCryptoTrader(args)
 	return t.Run()
 }
 
@@ -43,11 +43,7 @@ func RunCmd() {
 	// 在goroutine中等待信号
 	go func() {
 		<-sigChan
-		if core.StopAll != nil {
-			core.StopAll()
-		}
-		core.RunExitCalls()
-		os.Exit(0)
+		core.ExitProcess(0)
 	}()
 
 	// disable deadlock by default
@@ -270,6 +266,8 @@ func bindSubFlags(args *config.CmdArgs, cmd *flag.FlagSet, opts ...string) error
 			cmd.StringVar(&args.RunEveryTF, "run-every", "", "run every ? timerange")
 		case "out_type":
 			cmd.StringVar(&args.OutType, "out-type", "", "output data type")
+		case "safe_start":
+			cmd.IntVar(&args.SafeStart, "safe-start", 0, "observe n bars without entries after pre-flight checks")
 		case "separate":
 			cmd.BoolVar(&args.Separate, "separate", false, "run policy separately for backtest")
 		default:
@@ -10,6 +10,7 @@ import (
 	"github.com/banbox/banexg/bntp"
 	"github.com/banbox/banexg/errs"
 	"github.com/go-viper/mapstructure/v2"
+	"slices"
 	"time"
 )
 
@@ -27,11 +28,77 @@ func Setup() *errs.Error {
 	var err *errs.Error
 	Default, err = GetWith(exgCfg.Name, core.Market, core.ContractType)
 	core.IsContract = banexg.IsContract(core.Market)
-	return err
+	if err != nil {
+		return err
+	}
+	return setupAccExgs()
+}
+
+/*
+setupAccExgs
+Create exchange clients for accounts trading on other exchanges or markets
+为在其他交易所或市场交易的账户创建交易所客户端
+*/
+func setupAccExgs() *errs.Error {
+	for account := range config.Accounts {
+		exgName, market := config.GetAccVenue(account)
+		if exgName == config.Exchange.Name && market == core.Market {
+			accExgs[account] = Default
+			continue
+		}
+		if _, ok := AllowExgIds[exgName]; !ok {
+			return errs.NewMsg(core.ErrBadConfig, "unsupported exchange for account %s: %s", account, exgName)
+		}
+		exchange, err := GetWith(exgName, market, "")
+		if err != nil {
+			return err
+		}
+		accExgs[account] = exchange
+	}
+	return nil
+}
+
+/*
+Get
+Return the exchange client of the account, Default is returned for unknown accounts
+返回账户的交易所客户端，未知账户返回Default
+*/
+func Get(account string) banexg.BanExchange {
+	if exchange, ok := accExgs[account]; ok {
+		return exchange
+	}
+	return Default
+}
+
+/*
+GetVenue
+Return the exchange client of the exchange and market, Default for the default exchange and market
+返回交易所和市场的交易所客户端，默认交易所和市场返回Default
+*/
+func GetVenue(exgName, market string) (banexg.BanExchange, *errs.Error) {
+	if exgName == core.ExgName && market == core.Market && Default != nil {
+		return Default, nil
+	}
+	return GetWith(exgName, market, "")
+}
+
+/*
+AllExchanges
+All distinct exchange clients used by the bot, Default is the first one
+机器人使用的所有不同交易所客户端，Default为第一个
+*/
+func AllExchanges() []banexg.BanExchange {
+	res := []banexg.BanExchange{Default}
+	for _, exchange := range accExgs {
+		if !slices.Contains(res, exchange) {
+			res = append(res, exchange)
+		}
+	}
+	return res
 }
 
 func create(name, market, contractType string) (banexg.BanExchange, *errs.Error) {
-	var exgOpts, _ = config.Exchange.Items[config.Exchange.Name]
+	var exgOpts, _ = config.Exchange.Items[name]
 	var options = map[string]interface{}{}
 	for key, val := range exgOpts {
 		key = utils.SnakeToCamel(key)
@@ -49,6 +116,9 @@ func create(name, market, contractType string) (banexg.BanExchange, *errs.Error)
 	accs := map[string]map[string]interface{}{}
 	var defAcc string
 	for key, acc := range config.Accounts {
+		if accExg, _ := acc.Venue(); accExg != name {
+			continue
+		}
 		sec := acc.GetApiSecret()
 		accs[key] = map[string]interface{}{
 			banexg.OptApiKey:    sec.APIKey,
@@ -57,6 +127,9 @@ func create(name, market, contractType string) (banexg.BanExchange, *errs.Error)
 		defAcc = key
 	}
 	for key, acc := range config.BakAccounts {
+		if accExg, _ := acc.Venue(); accExg != name {
+			continue
+		}
 		sec := acc.GetApiSecret()
 		accs[key] = map[string]interface{}{
 			banexg.OptApiKey:    sec.APIKey,
@@ -142,28 +215,56 @@ func PrecAmount(exchange banexg.BanExchange, symbol string, amount float64) (flo
 }
 
 func GetLeverage(symbol string, notional float64, account string) (float64, float64) {
-	return Default.GetLeverage(symbol, notional, account)
+	return Get(account).GetLeverage(symbol, notional, account)
+}
+
+/*
+GetOdBook
+Order book of the symbol on the exchange and market of the account, see GetVenueOdBook
+账户所在交易所和市场上品种的订单簿，见GetVenueOdBook
+*/
+func GetOdBook(account, pair string) (*banexg.OrderBook, *errs.Error) {
+	return GetVenueOdBook(config.AccVenueKey(account, pair))
 }
 
-func GetOdBook(pair string) (*banexg.OrderBook, *errs.Error) {
-	book, ok := core.OdBooks[pair]
+/*
+GetVenueOdBook
+Order book of a venue key (see core.VenueKey), cached in core.OdBooks by the key
+场所键（见core.VenueKey）对应的订单簿，按键缓存在core.OdBooks
+*/
+func GetVenueOdBook(key string) (*banexg.OrderBook, *errs.Error) {
+	book, ok := core.OdBooks[key]
 	if !ok || book == nil || book.TimeStamp+config.OdBookTtl < btime.TimeMS() {
-		var err *errs.Error
-		book, err = Default.FetchOrderBook(pair, 1000, nil)
+		exgName, market, pair := core.ParseVenueKey(key)
+		if exgName == "" {
+			exgName, market = core.ExgName, core.Market
+		}
+		exchange, err := GetVenue(exgName, market)
 		if err != nil {
 			return nil, err
 		}
-		core.OdBooks[pair] = book
+		book, err = exchange.FetchOrderBook(pair, 1000, nil)
+		if err != nil {
+			return nil, err
+		}
+		core.OdBooks[key] = book
 	}
 	return book, nil
 }
 
-func GetTickers() (map[string]*banexg.Ticker, *errs.Error) {
-	tickersMap := core.GetCacheVal("tickers", map[string]*banexg.Ticker{})
+/*
+GetTickers
+Tickers on the exchange and market of the account, cached for an hour
+账户所在交易所和市场的行情，缓存一小时
+*/
+func GetTickers(account string) (map[string]*banexg.Ticker, *errs.Error) {
+	exgName, market := config.GetAccVenue(account)
+	cacheKey := "tickers_" + exgName + "_" + market
+	tickersMap := core.GetCacheVal(cacheKey, map[string]*banexg.Ticker{})
 	if len(tickersMap) > 0 {
 		return tickersMap, nil
 	}
-	tickers, err := Default.FetchTickers(nil, nil)
+	tickers, err := Get(account).FetchTickers(nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -171,7 +272,7 @@ func GetTickers() (map[string]*banexg.Ticker, *errs.Error) {
 		tickersMap[t.Symbol] = t
 	}
 	expires := time.Second * 3600
-	core.Cache.SetWithTTL("tickers", tickersMap, 1, expires)
+	core.Cache.SetWithTTL(cacheKey, tickersMap, 1, expires)
 	return tickersMap, nil
 }
 
@@ -7,6 +7,7 @@ import (
 )
 
 var Default banexg.BanExchange
+var accExgs = map[string]banexg.BanExchange{} // exchange clients of accounts 账户的交易所客户端
 var exgMap = map[string]banexg.BanExchange{}
 var exgMapLock deadlock.Mutex
 var AllowExgIds = map[string]bool{
@@ -14,8 +14,37 @@ var (
 	pairProducer IProducer
 	filters      = make([]IFilter, 0, 10)
 	ShowLog      = true
+
+	// FilterMake Makers of pair filters by name, custom filters can be added by AddFilter 按名称的品种过滤器构造函数，可通过AddFilter添加自定义过滤器
+	FilterMake = map[string]FuncMakeFilter{
+		"AgeFilter":          func(b BaseFilter) IFilter { return &AgeFilter{BaseFilter: b} },
+		"VolumePairList":     func(b BaseFilter) IFilter { return &VolumePairFilter{BaseFilter: b} },
+		"PriceFilter":        func(b BaseFilter) IFilter { return &PriceFilter{BaseFilter: b} },
+		"RateOfChangeFilter": func(b BaseFilter) IFilter { return &RateOfChangeFilter{BaseFilter: b} },
+		"VolatilityFilter":   func(b BaseFilter) IFilter { return &VolatilityFilter{BaseFilter: b} },
+		"SpreadFilter":       func(b BaseFilter) IFilter { return &SpreadFilter{BaseFilter: b} },
+		"LiquidityFilter":    func(b BaseFilter) IFilter { return &LiquidityFilter{BaseFilter: b} },
+		"OpenInterestFilter": func(b BaseFilter) IFilter { return &OpenInterestFilter{BaseFilter: b} },
+		"FundingFilter":      func(b BaseFilter) IFilter { return &FundingFilter{BaseFilter: b} },
+		"MarketCapList":      func(b BaseFilter) IFilter { return &MarketCapList{BaseFilter: b} },
+		"IndicatorFilter":    func(b BaseFilter) IFilter { return &IndicatorFilter{BaseFilter: b} },
+		"OffsetFilter":       func(b BaseFilter) IFilter { return &OffsetFilter{BaseFilter: b} },
+		"ShuffleFilter":      func(b BaseFilter) IFilter { return &ShuffleFilter{BaseFilter: b} },
+		"CorrelationFilter":  func(b BaseFilter) IFilter { return &CorrelationFilter{BaseFilter: b} },
+	}
 )
 
+/*
+AddFilter
+Register a custom IFilter or IProducer by name, which can be used in `pairlists` of yaml config.
+The filter should embed BaseFilter, its fields are decoded from the yaml by `mapstructure` tags.
+按名称注册自定义的IFilter或IProducer，可在yaml配置的`pairlists`中使用。
+过滤器应嵌入BaseFilter，其字段按`mapstructure`标签从yaml解码
+*/
+func AddFilter(name string, maker FuncMakeFilter) {
+	FilterMake[name] = maker
+}
+
 func Setup() *errs.Error {
 	if len(config.PairFilters) == 0 {
 		return nil
@@ -38,30 +67,12 @@ func GetPairFilters(items []*config.CommonPairFilter, withInvalid bool) ([]IFilt
 	// 未启用定期刷新，则允许成交量为空的品种
 	allowEmpty := config.PairMgr.Cron == ""
 	for _, cfg := range items {
-		var output IFilter
 		var base = BaseFilter{Name: cfg.Name, AllowEmpty: allowEmpty}
-		switch cfg.Name {
-		case "AgeFilter":
-			output = &AgeFilter{BaseFilter: base}
-		case "VolumePairList":
-			output = &VolumePairFilter{BaseFilter: base}
-		case "PriceFilter":
-			output = &PriceFilter{BaseFilter: base}
-		case "RateOfChangeFilter":
-			output = &RateOfChangeFilter{BaseFilter: base}
-		case "VolatilityFilter":
-			output = &VolatilityFilter{BaseFilter: base}
-		case "SpreadFilter":
-			output = &SpreadFilter{BaseFilter: base}
-		case "OffsetFilter":
-			output = &OffsetFilter{BaseFilter: base}
-		case "ShuffleFilter":
-			output = &ShuffleFilter{BaseFilter: base}
-		case "CorrelationFilter":
-			output = &CorrelationFilter{BaseFilter: base}
-		default:
+		maker, ok := FilterMake[cfg.Name]
+		if !ok {
 			return nil, errs.NewMsg(errs.CodeParamInvalid, "unknown symbol filter: %s", cfg.Name)
 		}
+		output := maker(base)
 		err_ := mapstructure.Decode(cfg.Items, &output)
 		if err_ != nil {
 			return nil, errs.New(errs.CodeUnmarshalFail, err_)
@@ -0,0 +1,465 @@
+package goods
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"math"
+	"strconv"
+
+	"github.com/banbox/banbot/core"
+	"github.com/banbox/banexg"
+	"github.com/banbox/banexg/errs"
+	"github.com/banbox/banexg/log"
+	"go.uber.org/zap"
+)
+
+/*
+exprEnv
+Kline series an indicator expression is evaluated on.
+Every value is a series aligned at the end, numbers are series of length 1, booleans are 1 or 0.
+计算指标表达式的K线序列。
+所有值都是末尾对齐的序列，数字是长度为1的序列，布尔值为1或0
+*/
+type exprEnv struct {
+	cols map[string][]float64
+}
+
+type exprFunc func(env *exprEnv, args []ast.Expr) ([]float64, *errs.Error)
+
+var exprFuncs map[string]exprFunc
+
+func init() {
+	exprFuncs = map[string]exprFunc{
+		"sma":     seriesFunc(calcSMA),
+		"ema":     seriesFunc(calcEMA),
+		"rsi":     seriesFunc(calcRSI),
+		"std":     seriesFunc(calcStd),
+		"highest": seriesFunc(calcHighest),
+		"lowest":  seriesFunc(calcLowest),
+		"roc":     seriesFunc(calcROC),
+		"ref":     seriesFunc(calcRef),
+		"abs":     exprAbs,
+		"atr":     exprATR,
+	}
+}
+
+func newExprEnv(klines []*banexg.Kline) *exprEnv {
+	names := []string{"open", "high", "low", "close", "volume"}
+	cols := make(map[string][]float64, len(names))
+	for _, name := range names {
+		cols[name] = make([]float64, len(klines))
+	}
+	for i, k := range klines {
+		cols["open"][i] = k.Open
+		cols["high"][i] = k.High
+		cols["low"][i] = k.Low
+		cols["close"][i] = k.Close
+		cols["volume"][i] = k.Volume
+	}
+	return &exprEnv{cols: cols}
+}
+
+/*
+ParseFilterExpr
+Parse an indicator expression and check identifiers and functions used
+解析指标表达式，并检查使用的标识符和函数
+*/
+func ParseFilterExpr(text string) (ast.Expr, *errs.Error) {
+	expr, err_ := parser.ParseExpr(text)
+	if err_ != nil {
+		return nil, errs.NewMsg(errs.CodeParamInvalid, "invalid expr %s: %v", text, err_)
+	}
+	var err *errs.Error
+	ast.Inspect(expr, func(n ast.Node) bool {
+		if err != nil {
+			return false
+		}
+		switch v := n.(type) {
+		case *ast.Ident:
+			if _, ok := newExprEnv(nil).cols[v.Name]; !ok {
+				if _, ok = exprFuncs[v.Name]; !ok {
+					err = errs.NewMsg(errs.CodeParamInvalid, "unknown name in expr: %s", v.Name)
+				}
+			}
+		case *ast.CallExpr:
+			if _, ok := v.Fun.(*ast.Ident); !ok {
+				err = errs.NewMsg(errs.CodeParamInvalid, "unsupported call in expr: %s", text)
+			}
+		case *ast.BasicLit, *ast.BinaryExpr, *ast.UnaryExpr, *ast.ParenExpr, nil:
+		default:
+			err = errs.NewMsg(errs.CodeParamInvalid, "unsupported syntax in expr: %s", text)
+		}
+		return err == nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return expr, nil
+}
+
+/*
+EvalFilterExpr
+Evaluate the expression on klines, return true if the last value is non-zero
+在K线上计算表达式，最后一个值非0时返回true
+*/
+func EvalFilterExpr(expr ast.Expr, klines []*banexg.Kline) (bool, *errs.Error) {
+	res, err := newExprEnv(klines).eval(expr)
+	if err != nil {
+		return false, err
+	}
+	if len(res) == 0 {
+		return false, nil
+	}
+	val := res[len(res)-1]
+	return !math.IsNaN(val) && val != 0, nil
+}
+
+func (e *exprEnv) eval(expr ast.Expr) ([]float64, *errs.Error) {
+	switch v := expr.(type) {
+	case *ast.ParenExpr:
+		return e.eval(v.X)
+	case *ast.BasicLit:
+		if v.Kind != token.INT && v.Kind != token.FLOAT {
+			return nil, errs.NewMsg(errs.CodeParamInvalid, "unsupported literal: %s", v.Value)
+		}
+		num, err_ := strconv.ParseFloat(v.Value, 64)
+		if err_ != nil {
+			return nil, errs.New(errs.CodeParamInvalid, err_)
+		}
+		return []float64{num}, nil
+	case *ast.Ident:
+		col, ok := e.cols[v.Name]
+		if !ok {
+			return nil, errs.NewMsg(errs.CodeParamInvalid, "unknown series: %s", v.Name)
+		}
+		return col, nil
+	case *ast.UnaryExpr:
+		arr, err := e.eval(v.X)
+		if err != nil {
+			return nil, err
+		}
+		res := make([]float64, len(arr))
+		for i, val := range arr {
+			switch v.Op {
+			case token.SUB:
+				res[i] = -val
+			case token.ADD:
+				res[i] = val
+			case token.NOT:
+				res[i] = boolNum(val == 0)
+			default:
+				return nil, errs.NewMsg(errs.CodeParamInvalid, "unsupported operator: %s", v.Op)
+			}
+		}
+		return res, nil
+	case *ast.BinaryExpr:
+		left, err := e.eval(v.X)
+		if err != nil {
+			return nil, err
+		}
+		right, err := e.eval(v.Y)
+		if err != nil {
+			return nil, err
+		}
+		return binaryOp(v.Op, left, right)
+	case *ast.CallExpr:
+		name := v.Fun.(*ast.Ident).Name
+		fn, ok := exprFuncs[name]
+		if !ok {
+			return nil, errs.NewMsg(errs.CodeParamInvalid, "unknown function: %s", name)
+		}
+		return fn(e, v.Args)
+	default:
+		return nil, errs.NewMsg(errs.CodeParamInvalid, "unsupported expr type: %T", expr)
+	}
+}
+
+func boolNum(v bool) float64 {
+	if v {
+		return 1
+	}
+	return 0
+}
+
+/*
+binaryOp
+Apply the operator elementwise with both series aligned at the end, a number is broadcast to the other series
+以末尾对齐逐元素计算，数字会广播到另一个序列
+*/
+func binaryOp(op token.Token, left, right []float64) ([]float64, *errs.Error) {
+	size := min(len(left), len(right))
+	if len(left) == 1 || len(right) == 1 {
+		size = max(len(left), len(right))
+	}
+	res := make([]float64, size)
+	for i := range res {
+		a := left[max(0, len(left)-size+i)]
+		b := right[max(0, len(right)-size+i)]
+		switch op {
+		case token.ADD:
+			res[i] = a + b
+		case token.SUB:
+			res[i] = a - b
+		case token.MUL:
+			res[i] = a * b
+		case token.QUO:
+			res[i] = a / b
+		case token.GTR:
+			res[i] = boolNum(a > b)
+		case token.GEQ:
+			res[i] = boolNum(a >= b)
+		case token.LSS:
+			res[i] = boolNum(a < b)
+		case token.LEQ:
+			res[i] = boolNum(a <= b)
+		case token.EQL:
+			res[i] = boolNum(a == b)
+		case token.NEQ:
+			res[i] = boolNum(a != b)
+		case token.LAND:
+			res[i] = boolNum(a != 0 && b != 0 && !math.IsNaN(a) && !math.IsNaN(b))
+		case token.LOR:
+			res[i] = boolNum(a != 0 && !math.IsNaN(a) || b != 0 && !math.IsNaN(b))
+		default:
+			return nil, errs.NewMsg(errs.CodeParamInvalid, "unsupported operator: %s", op)
+		}
+	}
+	return res, nil
+}
+
+func (e *exprEnv) periodArg(expr ast.Expr) (int, *errs.Error) {
+	arr, err := e.eval(expr)
+	if err != nil {
+		return 0, err
+	}
+	if len(arr) != 1 || arr[0] < 1 {
+		return 0, errs.NewMsg(errs.CodeParamInvalid, "period should be a positive number")
+	}
+	return int(arr[0]), nil
+}
+
+/*
+seriesFunc
+Wrap a function of (series, period) for the expression
+将(序列, 周期)函数包装为表达式函数
+*/
+func seriesFunc(calc func(arr []float64, period int) []float64) exprFunc {
+	return func(env *exprEnv, args []ast.Expr) ([]float64, *errs.Error) {
+		if len(args) != 2 {
+			return nil, errs.NewMsg(errs.CodeParamInvalid, "2 args required: (series, period)")
+		}
+		arr, err := env.eval(args[0])
+		if err != nil {
+			return nil, err
+		}
+		period, err := env.periodArg(args[1])
+		if err != nil {
+			return nil, err
+		}
+		return calc(arr, period), nil
+	}
+}
+
+func exprAbs(env *exprEnv, args []ast.Expr) ([]float64, *errs.Error) {
+	if len(args) != 1 {
+		return nil, errs.NewMsg(errs.CodeParamInvalid, "abs requires 1 arg")
+	}
+	arr, err := env.eval(args[0])
+	if err != nil {
+		return nil, err
+	}
+	res := make([]float64, len(arr))
+	for i, v := range arr {
+		res[i] = math.Abs(v)
+	}
+	return res, nil
+}
+
+func exprATR(env *exprEnv, args []ast.Expr) ([]float64, *errs.Error) {
+	if len(args) != 1 {
+		return nil, errs.NewMsg(errs.CodeParamInvalid, "atr requires 1 arg: (period)")
+	}
+	period, err := env.periodArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+	high, low, closes := env.cols["high"], env.cols["low"], env.cols["close"]
+	trs := make([]float64, len(closes))
+	for i := range closes {
+		trs[i] = high[i] - low[i]
+		if i > 0 {
+			trs[i] = max(trs[i], math.Abs(high[i]-closes[i-1]), math.Abs(low[i]-closes[i-1]))
+		}
+	}
+	return calcRMA(trs, period), nil
+}
+
+func nanSeries(size int) []float64 {
+	res := make([]float64, size)
+	for i := range res {
+		res[i] = math.NaN()
+	}
+	return res
+}
+
+/*
+warmUpLen
+Number of leading NaN values, which are the warm-up of a nested indicator
+开头NaN值的数量，即嵌套指标的预热部分
+*/
+func warmUpLen(arr []float64) int {
+	for i, v := range arr {
+		if !math.IsNaN(v) {
+			return i
+		}
+	}
+	return len(arr)
+}
+
+func calcSMA(arr []float64, period int) []float64 {
+	res := nanSeries(len(arr))
+	start := warmUpLen(arr)
+	var sum float64
+	for i := start; i < len(arr); i++ {
+		sum += arr[i]
+		if i-start >= period {
+			sum -= arr[i-period]
+		}
+		if i-start+1 >= period {
+			res[i] = sum / float64(period)
+		}
+	}
+	return res
+}
+
+func calcEMA(arr []float64, period int) []float64 {
+	return calcEMABy(arr, period, 2/float64(period+1))
+}
+
+/*
+calcRMA
+Wilder's moving average, used by rsi and atr
+Wilder平滑移动平均，用于rsi和atr
+*/
+func calcRMA(arr []float64, period int) []float64 {
+	return calcEMABy(arr, period, 1/float64(period))
+}
+
+func calcEMABy(arr []float64, period int, alpha float64) []float64 {
+	res := calcSMA(arr, period)
+	for i := warmUpLen(arr) + period; i < len(arr); i++ {
+		res[i] = alpha*arr[i] + (1-alpha)*res[i-1]
+	}
+	return res
+}
+
+func calcRSI(arr []float64, period int) []float64 {
+	if len(arr) < 2 {
+		return nanSeries(len(arr))
+	}
+	gains := make([]float64, len(arr)-1)
+	losses := make([]float64, len(arr)-1)
+	for i := 1; i < len(arr); i++ {
+		chg := arr[i] - arr[i-1]
+		gains[i-1] = max(chg, 0)
+		losses[i-1] = max(-chg, 0)
+	}
+	avgGain, avgLoss := calcRMA(gains, period), calcRMA(losses, period)
+	res := nanSeries(len(arr))
+	for i := range avgGain {
+		if math.IsNaN(avgGain[i]) {
+			continue
+		}
+		if avgLoss[i] == 0 {
+			res[i+1] = 100
+		} else {
+			res[i+1] = 100 - 100/(1+avgGain[i]/avgLoss[i])
+		}
+	}
+	return res
+}
+
+func calcStd(arr []float64, period int) []float64 {
+	res := nanSeries(len(arr))
+	means := calcSMA(arr, period)
+	for i := period - 1; i < len(arr); i++ {
+		var sum float64
+		for _, v := range arr[i+1-period : i+1] {
+			sum += (v - means[i]) * (v - means[i])
+		}
+		res[i] = math.Sqrt(sum / float64(period))
+	}
+	return res
+}
+
+func calcHighest(arr []float64, period int) []float64 {
+	res := nanSeries(len(arr))
+	for i := period - 1; i < len(arr); i++ {
+		res[i] = arr[i]
+		for _, v := range arr[i+1-period : i] {
+			res[i] = max(res[i], v)
+		}
+	}
+	return res
+}
+
+func calcLowest(arr []float64, period int) []float64 {
+	res := nanSeries(len(arr))
+	for i := period - 1; i < len(arr); i++ {
+		res[i] = arr[i]
+		for _, v := range arr[i+1-period : i] {
+			res[i] = min(res[i], v)
+		}
+	}
+	return res
+}
+
+func calcROC(arr []float64, period int) []float64 {
+	res := nanSeries(len(arr))
+	for i := period; i < len(arr); i++ {
+		res[i] = (arr[i] - arr[i-period]) / arr[i-period] * 100
+	}
+	return res
+}
+
+func calcRef(arr []float64, period int) []float64 {
+	res := nanSeries(len(arr))
+	for i := period; i < len(arr); i++ {
+		res[i] = arr[i-period]
+	}
+	return res
+}
+
+func (f *IndicatorFilter) Filter(symbols []string, timeMS int64) ([]string, *errs.Error) {
+	if f.Expr == "" {
+		return symbols, nil
+	}
+	if f.expr == nil {
+		expr, err := ParseFilterExpr(f.Expr)
+		if err != nil {
+			return nil, err
+		}
+		f.expr = expr
+	}
+	if f.Timeframe == "" {
+		f.Timeframe = "1d"
+	}
+	if f.BackNum <= 0 {
+		f.BackNum = 100
+	}
+	return filterByOHLCV(symbols, f.Timeframe, timeMS, f.BackNum, core.AdjFront, func(s string, klines []*banexg.Kline) bool {
+		if len(klines) == 0 {
+			return f.AllowEmpty
+		}
+		ok, err := EvalFilterExpr(f.expr, klines)
+		if err != nil {
+			log.Warn("IndicatorFilter eval fail", zap.String("pair", s), zap.Error(err))
+			return false
+		}
+		if !ok {
+			log.Info("IndicatorFilter drop", zap.String("pair", s))
+		}
+		return ok
+	})
+}
@@ -0,0 +1,118 @@
+package goods
+
+import (
+	"math"
+	"testing"
+
+	"github.com/banbox/banexg"
+)
+
+func makeKlines(closes ...float64) []*banexg.Kline {
+	res := make([]*banexg.Kline, len(closes))
+	for i, c := range closes {
+		res[i] = &banexg.Kline{Time: int64(i) * 60000, Open: c, High: c + 1, Low: c - 1, Close: c, Volume: 10}
+	}
+	return res
+}
+
+func TestParseFilterExpr(t *testing.T) {
+	cases := []struct {
+		text  string
+		valid bool
+	}{
+		{"close > sma(close, 20) && rsi(close, 14) < 70", true},
+		{"!(close < open) || abs(roc(close, 1)) > 5", true},
+		{"ema(sma(close, 20), 10) > atr(14)", true},
+		{"close >", false},
+		{"foo > 1", false},
+		{"bar(close, 3) > 1", false},
+		{"close[1] > 1", false},
+		{"x.y(close) > 1", false},
+		{`close > "a"`, true},
+	}
+	for _, c := range cases {
+		_, err := ParseFilterExpr(c.text)
+		if (err == nil) != c.valid {
+			t.Errorf("parse %s expect valid=%v, got err: %v", c.text, c.valid, err)
+		}
+	}
+	// string literal passes syntax check but fails on eval 字符串字面量通过语法检查，但计算时失败
+	expr, _ := ParseFilterExpr(`close > "a"`)
+	if _, err := EvalFilterExpr(expr, makeKlines(1, 2)); err == nil {
+		t.Errorf("string literal should fail on eval")
+	}
+}
+
+func TestEvalFilterExpr(t *testing.T) {
+	klines := makeKlines(1, 2, 3, 4, 5, 6, 7, 8, 9, 10)
+	cases := []struct {
+		text string
+		res  bool
+	}{
+		// operator precedence: * before +, comparison before && before || 运算符优先级
+		{"1 + 2 * 3 == 7", true},
+		{"(1 + 2) * 3 == 9", true},
+		{"10 - 4 / 2 == 8", true},
+		{"1 > 2 || 3 > 2 && 2 > 1", true},
+		{"(1 > 2 || 3 > 2) && 2 > 3", false},
+		{"!(close > 5)", false},
+		{"-close < 0", true},
+		{"close == 10", true},
+		{"sma(close, 3) == 9", true},
+		{"ref(close, 2) == 8", true},
+		{"highest(high, 3) == 11 && lowest(low, 3) == 7", true},
+		{"roc(close, 5) == 100", true},
+		{"sma(close, 20) > 0", false},
+		{"std(close, 1) == 0", true},
+	}
+	for _, c := range cases {
+		expr, err := ParseFilterExpr(c.text)
+		if err != nil {
+			t.Fatalf("parse %s fail: %v", c.text, err)
+		}
+		res, err := EvalFilterExpr(expr, klines)
+		if err != nil {
+			t.Fatalf("eval %s fail: %v", c.text, err)
+		}
+		if res != c.res {
+			t.Errorf("eval %s expect %v, got %v", c.text, c.res, res)
+		}
+	}
+}
+
+func TestNestedIndicators(t *testing.T) {
+	arr := make([]float64, 40)
+	for i := range arr {
+		arr[i] = float64(i + 1)
+	}
+	// sma of sma: inner is valid from index 19, outer from 28 内层从19开始有效，外层从28开始
+	res := calcSMA(calcSMA(arr, 20), 10)
+	if !math.IsNaN(res[27]) {
+		t.Errorf("sma(sma) should be NaN in warm-up, got %v", res[27])
+	}
+	// sma(close,20) at i is i-8.5, the average of i in 30..39 is 34.5 sma(close,20)在i处为i-8.5
+	if want := 26.0; math.Abs(res[39]-want) > 1e-9 {
+		t.Errorf("sma(sma) expect %v, got %v", want, res[39])
+	}
+	ema := calcEMA(calcSMA(arr, 20), 10)
+	if !math.IsNaN(ema[27]) || math.IsNaN(ema[28]) || math.IsNaN(ema[39]) {
+		t.Errorf("ema(sma) invalid warm-up: %v, %v, %v", ema[27], ema[28], ema[39])
+	}
+	// linear input, ema lags sma by (period-1)/2 线性输入时ema落后(period-1)/2
+	if math.Abs(ema[39]-26) > 0.5 {
+		t.Errorf("ema(sma) expect about 26, got %v", ema[39])
+	}
+	closes := make([]float64, 40)
+	for i := range closes {
+		closes[i] = 100 + float64(i%4)
+	}
+	rsiSma := calcSMA(calcRSI(closes, 14), 5)
+	if math.IsNaN(rsiSma[len(rsiSma)-1]) {
+		t.Errorf("sma(rsi) should be valid after warm-up")
+	}
+	for i := 0; i < 18; i++ {
+		if !math.IsNaN(rsiSma[i]) {
+			t.Errorf("sma(rsi) at %d should be NaN, got %v", i, rsiSma[i])
+		}
+	}
+}
@@ -1,13 +1,18 @@
 package goods
 
 import (
+	"cmp"
+	"encoding/csv"
 	"fmt"
 	"math"
 	"math/rand"
+	"os"
 	"slices"
 	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/banbox/banbot/btime"
 	"github.com/banbox/banbot/config"
 	"github.com/banbox/banbot/core"
 	"github.com/banbox/banbot/exg"
@@ -202,9 +207,42 @@ func filterByMinCost(symbols []SymbolVol) ([]string, map[string]float64) {
 }
 
 func (f *VolumePairFilter) GenSymbols(timeMS int64) ([]string, *errs.Error) {
-	symbols := make([]string, 0)
-	markets := exg.Default.GetCurMarkets()
-	symbols = utils.KeysOfMap(markets)
+	symbols := getUniverse(timeMS)
+	if len(symbols) == 0 {
+		return nil, errs.NewMsg(errs.CodeRunTime, "no symbols generate from VolumePairFilter")
+	}
+	return f.Filter(symbols, timeMS)
+}
+
+/*
+getUniverse
+Symbols of stake currencies to produce pairs from. In backtest, symbols delisted later are included
+and those not listed at timeMS are excluded, to avoid survivorship bias.
+用于生成品种的质押币种交易对。回测时包含之后退市的品种，并排除timeMS时未上市的品种，避免幸存者偏差
+*/
+func getUniverse(timeMS int64) []string {
+	exchange := exg.Default
+	markets := exchange.GetCurMarkets()
+	var symbols []string
+	if core.LiveMode {
+		symbols = utils.KeysOfMap(markets)
+	} else {
+		exInfo := exchange.Info()
+		skipNum := 0
+		for symbol := range orm.GetTradableSymbols(exInfo.ID, exInfo.MarketType, timeMS) {
+			if _, ok := markets[symbol]; !ok {
+				if _, err := exchange.GetMarket(symbol); err != nil {
+					// unable to simulate orders without market info 缺少市场信息时无法模拟订单
+					skipNum += 1
+					continue
+				}
+			}
+			symbols = append(symbols, symbol)
+		}
+		if skipNum > 0 && ShowLog {
+			log.Info("skip delisted symbols without market info", zap.Int("num", skipNum))
+		}
+	}
 	pairs := make([]string, 0, len(symbols))
 	for _, pair := range symbols {
 		_, quote, _, _ := core.SplitSymbol(pair)
@@ -212,11 +250,8 @@ func (f *VolumePairFilter) GenSymbols(timeMS int64) ([]string, *errs.Error) {
 			pairs = append(pairs, pair)
 		}
 	}
-	symbols = pairs
-	if len(symbols) == 0 {
-		return nil, errs.NewMsg(errs.CodeRunTime, "no symbols generate from VolumePairFilter")
-	}
-	return f.Filter(symbols, timeMS)
+	slices.Sort(pairs)
+	return pairs
 }
 
 func (f *PriceFilter) Filter(symbols []string, timeMS int64) ([]string, *errs.Error) {
@@ -468,7 +503,299 @@ func (f *VolatilityFilter) Filter(symbols []string, timeMS int64) ([]string, *er
 }
 
 func (f *SpreadFilter) Filter(symbols []string, timeMS int64) ([]string, *errs.Error) {
-	return symbols, nil
+	if f.MaxRatio <= 0 {
+		return symbols, nil
+	}
+	return filterByOdBook(f.Name, symbols, func(pair string, book *banexg.OrderBook) bool {
+		ratio := 1 - book.Bids.Price[0]/book.Asks.Price[0]
+		if ratio > float64(f.MaxRatio) {
+			log.Info("SpreadFilter drop", zap.String("pair", pair), zap.Float64("spread", ratio))
+			return false
+		}
+		return true
+	})
+}
+
+func (f *LiquidityFilter) Filter(symbols []string, timeMS int64) ([]string, *errs.Error) {
+	if f.MinValue <= 0 {
+		return symbols, nil
+	}
+	if f.DepthRate <= 0 {
+		f.DepthRate = 0.01
+	}
+	return filterByOdBook(f.Name, symbols, func(pair string, book *banexg.OrderBook) bool {
+		midPrice := (book.Bids.Price[0] + book.Asks.Price[0]) / 2
+		var bidVal, askVal float64
+		for i, price := range book.Bids.Price {
+			if price < midPrice*(1-f.DepthRate) {
+				break
+			}
+			bidVal += price * book.Bids.Size[i]
+		}
+		for i, price := range book.Asks.Price {
+			if price > midPrice*(1+f.DepthRate) {
+				break
+			}
+			askVal += price * book.Asks.Size[i]
+		}
+		value := min(bidVal, askVal)
+		if value < f.MinValue {
+			log.Info("LiquidityFilter drop", zap.String("pair", pair), zap.Float64("depth", value))
+			return false
+		}
+		return true
+	})
+}
+
+/*
+filterByOdBook
+Filter symbols by the latest order books, keep all symbols in backtest as history order books are unavailable
+按最新订单簿过滤品种，回测时没有历史订单簿，保留所有品种
+*/
+func filterByOdBook(name string, symbols []string, cb func(string, *banexg.OrderBook) bool) ([]string, *errs.Error) {
+	if !core.LiveMode {
+		if ShowLog {
+			log.Warn(name + " requires order books, skip in backtest")
+		}
+		return symbols, nil
+	}
+	res := make([]string, 0, len(symbols))
+	for _, pair := range symbols {
+		book, err := exg.GetVenueOdBook(pair)
+		if err != nil {
+			log.Warn("fetch order book fail", zap.String("pair", pair), zap.Error(err))
+			continue
+		}
+		if book == nil || book.Bids == nil || book.Asks == nil || len(book.Bids.Price) == 0 || len(book.Asks.Price) == 0 {
+			continue
+		}
+		if cb(pair, book) {
+			res = append(res, pair)
+		}
+	}
+	return res, nil
+}
+
+/*
+getPairExg
+Exchange client and raw symbol of the key, keys without venue are on the default exchange, see core.VenueKey
+返回键对应的交易所客户端和原始品种，无交易所的键属于默认交易所，见core.VenueKey
+*/
+func getPairExg(key string) (banexg.BanExchange, string, *errs.Error) {
+	exgName, market, pair := core.ParseVenueKey(key)
+	if exgName == "" {
+		exgName, market = core.ExgName, core.Market
+	}
+	exchange, err := exg.GetVenue(exgName, market)
+	return exchange, pair, err
+}
+
+// isContractPair whether the symbol (venue key supported) is a futures contract 品种（支持venue键）是否为合约
+func isContractPair(key string) bool {
+	exgName, market, _ := core.ParseVenueKey(key)
+	if exgName == "" {
+		return core.IsContract
+	}
+	return banexg.IsContract(market)
+}
+
+func (f *OpenInterestFilter) Filter(symbols []string, timeMS int64) ([]string, *errs.Error) {
+	if f.MinValue <= 0 && f.Limit <= 0 {
+		return symbols, nil
+	}
+	if f.Period == "" {
+		f.Period = "1h"
+	}
+	periodMS := int64(utils2.TFToSecs(f.Period) * 1000)
+	items := make([]*SymbolVol, 0, len(symbols))
+	for _, key := range symbols {
+		if !isContractPair(key) {
+			log.Warn("OpenInterestFilter is only available for futures, skip", zap.String("pair", key))
+			items = append(items, &SymbolVol{Symbol: key})
+			continue
+		}
+		exchange, pair, err := getPairExg(key)
+		if err != nil {
+			return nil, err
+		}
+		arr, err := exchange.FetchOpenInterestHistory(pair, f.Period, timeMS-periodMS*3, 3, nil)
+		if err != nil {
+			return nil, err
+		}
+		var value float64
+		for _, it := range arr {
+			if it.Timestamp <= timeMS {
+				value = it.OpenInterestValue
+			}
+		}
+		if value == 0 && !f.AllowEmpty || f.MinValue > 0 && value > 0 && value < f.MinValue {
+			log.Info("OpenInterestFilter drop", zap.String("pair", key), zap.Float64("oi", value))
+			continue
+		}
+		items = append(items, &SymbolVol{Symbol: key, Vol: value})
+	}
+	if f.Limit > 0 {
+		slices.SortStableFunc(items, func(a, b *SymbolVol) int {
+			return cmp.Compare(b.Vol, a.Vol)
+		})
+		if f.Limit < len(items) {
+			items = items[:f.Limit]
+		}
+	}
+	res := make([]string, 0, len(items))
+	for _, it := range items {
+		res = append(res, it.Symbol)
+	}
+	return res, nil
+}
+
+func (f *FundingFilter) Filter(symbols []string, timeMS int64) ([]string, *errs.Error) {
+	if f.Min == 0 && f.Max == 0 && f.MaxAbs == 0 {
+		return symbols, nil
+	}
+	if f.BackNum <= 0 {
+		f.BackNum = 3
+	}
+	// Funding intervals are 8h at most, fetch a bit more 资金费率间隔最长8h，多获取一些
+	sinceMS := timeMS - int64(f.BackNum+1)*8*3600*1000
+	res := make([]string, 0, len(symbols))
+	for _, key := range symbols {
+		if !isContractPair(key) {
+			log.Warn("FundingFilter is only available for futures, skip", zap.String("pair", key))
+			res = append(res, key)
+			continue
+		}
+		exchange, pair, err := getPairExg(key)
+		if err != nil {
+			return nil, err
+		}
+		arr, err := exchange.FetchFundingRateHistory(pair, sinceMS, f.BackNum*8, nil)
+		if err != nil {
+			return nil, err
+		}
+		rates := make([]float64, 0, len(arr))
+		for _, it := range arr {
+			if it.Timestamp <= timeMS {
+				rates = append(rates, it.FundingRate)
+			}
+		}
+		if len(rates) == 0 {
+			if f.AllowEmpty {
+				res = append(res, key)
+			}
+			continue
+		}
+		if len(rates) > f.BackNum {
+			rates = rates[len(rates)-f.BackNum:]
+		}
+		avg := floats.Sum(rates) / float64(len(rates))
+		if f.Min != 0 && avg < f.Min || f.Max != 0 && avg > f.Max || f.MaxAbs > 0 && math.Abs(avg) > f.MaxAbs {
+			log.Info("FundingFilter drop", zap.String("pair", key), zap.Float64("rate", avg))
+			continue
+		}
+		res = append(res, key)
+	}
+	return res, nil
+}
+
+func (f *MarketCapList) GenSymbols(timeMS int64) ([]string, *errs.Error) {
+	return f.Filter(getUniverse(timeMS), timeMS)
+}
+
+/*
+Filter
+Keep symbols whose base coin meets the market cap requirements, sorted by market cap desc
+保留base币满足市值要求的品种，按市值倒序
+*/
+func (f *MarketCapList) Filter(symbols []string, timeMS int64) ([]string, *errs.Error) {
+	if f.caps == nil {
+		err := f.load()
+		if err != nil {
+			return nil, err
+		}
+	}
+	idx, _ := slices.BinarySearchFunc(f.caps, timeMS+1, func(c *coinCaps, t int64) int {
+		return cmp.Compare(c.TimeMS, t)
+	})
+	if idx == 0 {
+		return nil, errs.NewMsg(errs.CodeRunTime, "no market cap before %s in %s", btime.ToDateStr(timeMS, ""), f.Path)
+	}
+	caps := f.caps[idx-1]
+	coinPairs := make(map[string][]string)
+	for _, key := range symbols {
+		_, _, pair := core.ParseVenueKey(key)
+		base, _, _, _ := core.SplitSymbol(pair)
+		coinPairs[base] = append(coinPairs[base], key)
+	}
+	res := make([]string, 0, len(symbols))
+	for i, coin := range caps.Coins {
+		if f.MaxRank > 0 && i >= f.MaxRank || f.MinCap > 0 && caps.Caps[coin] < f.MinCap {
+			break
+		}
+		res = append(res, coinPairs[coin]...)
+		if f.Limit > 0 && len(res) >= f.Limit {
+			res = res[:f.Limit]
+			break
+		}
+	}
+	return res, nil
+}
+
+func (f *MarketCapList) load() *errs.Error {
+	if f.Path == "" {
+		return errs.NewMsg(errs.CodeParamRequired, "MarketCapList.path is required")
+	}
+	path := config.ParsePath(f.Path)
+	file, err_ := os.Open(path)
+	if err_ != nil {
+		return errs.New(errs.CodeIOReadFail, err_)
+	}
+	defer file.Close()
+	rows, err_ := csv.NewReader(file).ReadAll()
+	if err_ != nil {
+		return errs.New(errs.CodeIOReadFail, err_)
+	}
+	dateCaps := make(map[int64]*coinCaps)
+	for i, row := range rows {
+		if len(row) < 3 {
+			continue
+		}
+		capVal, err_ := strconv.ParseFloat(strings.TrimSpace(row[2]), 64)
+		if err_ != nil {
+			if i == 0 {
+				// header 表头
+				continue
+			}
+			return errs.NewMsg(errs.CodeParamInvalid, "invalid market cap at line %d: %s", i+1, row[2])
+		}
+		timeMS, err_ := btime.ParseTimeMS(strings.TrimSpace(row[0]))
+		if err_ != nil {
+			return errs.NewMsg(errs.CodeParamInvalid, "invalid date at line %d: %s", i+1, row[0])
+		}
+		item, ok := dateCaps[timeMS]
+		if !ok {
+			item = &coinCaps{TimeMS: timeMS, Caps: make(map[string]float64)}
+			dateCaps[timeMS] = item
+		}
+		coin := strings.ToUpper(strings.TrimSpace(row[1]))
+		if _, ok = item.Caps[coin]; !ok {
+			item.Coins = append(item.Coins, coin)
+		}
+		item.Caps[coin] = capVal
+	}
+	f.caps = utils.ValsOfMap(dateCaps)
+	slices.SortFunc(f.caps, func(a, b *coinCaps) int {
+		return cmp.Compare(a.TimeMS, b.TimeMS)
+	})
+	for _, item := range f.caps {
+		slices.SortStableFunc(item.Coins, func(a, b string) int {
+			return cmp.Compare(item.Caps[b], item.Caps[a])
+		})
+	}
+	if len(f.caps) == 0 {
+		return errs.NewMsg(errs.CodeParamInvalid, "no market cap found in %s", path)
+	}
+	return nil
 }
 
 func (f *OffsetFilter) Filter(symbols []string, timeMS int64) ([]string, *errs.Error) {
@@ -0,0 +1,186 @@
+package goods
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/banbox/banbot/btime"
+	"github.com/banbox/banbot/core"
+	"github.com/banbox/banbot/exg"
+	"github.com/banbox/banexg"
+	"github.com/banbox/banexg/errs"
+)
+
+// fakeExg returns fixed open interest and funding rates 返回固定持仓和资金费率的交易所
+type fakeExg struct {
+	banexg.BanExchange
+	ois   map[string][]*banexg.OpenInterest
+	rates map[string][]*banexg.FundingRate
+}
+
+func (e *fakeExg) FetchOpenInterestHistory(symbol string, period string, since int64, limit int, params map[string]interface{}) ([]*banexg.OpenInterest, *errs.Error) {
+	return e.ois[symbol], nil
+}
+
+func (e *fakeExg) FetchFundingRateHistory(symbol string, since int64, limit int, params map[string]interface{}) ([]*banexg.FundingRate, *errs.Error) {
+	return e.rates[symbol], nil
+}
+
+func useFakeExg(t *testing.T, exchange banexg.BanExchange) {
+	oldExg, oldName, oldMarket, oldContract := exg.Default, core.ExgName, core.Market, core.IsContract
+	exg.Default, core.ExgName, core.Market, core.IsContract = exchange, "binance", banexg.MarketLinear, true
+	t.Cleanup(func() {
+		exg.Default, core.ExgName, core.Market, core.IsContract = oldExg, oldName, oldMarket, oldContract
+	})
+}
+
+func TestLiquidityFilter(t *testing.T) {
+	oldLive := core.LiveMode
+	core.LiveMode = true
+	defer func() {
+		core.LiveMode = oldLive
+	}()
+	nowMS := btime.TimeMS()
+	core.OdBooks["DEEP/USDT:USDT"] = &banexg.OrderBook{
+		TimeStamp: nowMS,
+		Bids:      &banexg.OdBookSide{Price: []float64{99.9, 99.5, 90}, Size: []float64{10, 10, 1000}},
+		Asks:      &banexg.OdBookSide{Price: []float64{100.1, 100.5, 110}, Size: []float64{10, 10, 1000}},
+	}
+	core.OdBooks["THIN/USDT:USDT"] = &banexg.OrderBook{
+		TimeStamp: nowMS,
+		Bids:      &banexg.OdBookSide{Price: []float64{99.9, 90}, Size: []float64{1, 1000}},
+		Asks:      &banexg.OdBookSide{Price: []float64{100.1, 110}, Size: []float64{100, 1000}},
+	}
+	defer func() {
+		delete(core.OdBooks, "DEEP/USDT:USDT")
+		delete(core.OdBooks, "THIN/USDT:USDT")
+	}()
+	f := &LiquidityFilter{MinValue: 1000}
+	res, err := f.Filter([]string{"DEEP/USDT:USDT", "THIN/USDT:USDT"}, nowMS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// depth out of 1% from mid price is excluded 距中间价1%以外的深度不计入
+	if !slices.Equal(res, []string{"DEEP/USDT:USDT"}) {
+		t.Errorf("LiquidityFilter expect [DEEP/USDT:USDT], got %v", res)
+	}
+}
+
+func TestOpenInterestFilter(t *testing.T) {
+	timeMS := int64(10 * 3600 * 1000)
+	useFakeExg(t, &fakeExg{ois: map[string][]*banexg.OpenInterest{
+		"A/USDT:USDT": {{Timestamp: timeMS - 3600000, OpenInterestValue: 500}},
+		"B/USDT:USDT": {{Timestamp: timeMS - 3600000, OpenInterestValue: 3000}, {Timestamp: timeMS + 1, OpenInterestValue: 1}},
+		"C/USDT:USDT": {{Timestamp: timeMS, OpenInterestValue: 2000}},
+	}})
+	symbols := []string{"A/USDT:USDT", "B/USDT:USDT", "C/USDT:USDT", "D/USDT:USDT"}
+	f := &OpenInterestFilter{MinValue: 1000}
+	res, err := f.Filter(symbols, timeMS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// values after timeMS are ignored, D without data is dropped timeMS之后的值忽略，D无数据被丢弃
+	if !slices.Equal(res, []string{"B/USDT:USDT", "C/USDT:USDT"}) {
+		t.Errorf("OpenInterestFilter min_value got %v", res)
+	}
+	f = &OpenInterestFilter{Limit: 2}
+	res, err = f.Filter(symbols, timeMS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(res, []string{"B/USDT:USDT", "C/USDT:USDT"}) {
+		t.Errorf("OpenInterestFilter limit got %v", res)
+	}
+}
+
+func TestFundingFilter(t *testing.T) {
+	timeMS := int64(100 * 3600 * 1000)
+	useFakeExg(t, &fakeExg{rates: map[string][]*banexg.FundingRate{
+		"A/USDT:USDT": {{Timestamp: timeMS - 16*3600000, FundingRate: 0.01}, {Timestamp: timeMS - 8*3600000, FundingRate: 0.0001},
+			{Timestamp: timeMS, FundingRate: 0.0001}},
+		"B/USDT:USDT": {{Timestamp: timeMS - 8*3600000, FundingRate: 0.001}, {Timestamp: timeMS, FundingRate: 0.003}},
+		"C/USDT:USDT": {{Timestamp: timeMS, FundingRate: -0.002}, {Timestamp: timeMS + 1, FundingRate: 0}},
+	}})
+	symbols := []string{"A/USDT:USDT", "B/USDT:USDT", "C/USDT:USDT", "D/USDT:USDT"}
+	// only the latest 2 periods are averaged 仅平均最近2期
+	f := &FundingFilter{BackNum: 2, MaxAbs: 0.0015}
+	res, err := f.Filter(symbols, timeMS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(res, []string{"A/USDT:USDT"}) {
+		t.Errorf("FundingFilter max_abs got %v", res)
+	}
+	f = &FundingFilter{BackNum: 2, Min: -0.001, BaseFilter: BaseFilter{AllowEmpty: true}}
+	res, err = f.Filter(symbols, timeMS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(res, []string{"A/USDT:USDT", "B/USDT:USDT", "D/USDT:USDT"}) {
+		t.Errorf("FundingFilter min got %v", res)
+	}
+}
+
+func TestMarketCapList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "caps.csv")
+	content := "date,coin,market_cap\n" +
+		"2024-01-01,btc,800\n2024-01-01,eth,300\n2024-01-01,sol,20\n" +
+		"2024-02-01,btc,900\n2024-02-01,eth,400\n2024-02-01,sol,500\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	symbols := []string{"BTC/USDT:USDT", "ETH/USDT:USDT", "SOL/USDT:USDT", "DOGE/USDT:USDT"}
+	jan, _ := btime.ParseTimeMS("2024-01-15")
+	feb, _ := btime.ParseTimeMS("2024-02-15")
+	cases := []struct {
+		filter *MarketCapList
+		timeMS int64
+		res    []string
+	}{
+		{&MarketCapList{Path: path}, jan, []string{"BTC/USDT:USDT", "ETH/USDT:USDT", "SOL/USDT:USDT"}},
+		{&MarketCapList{Path: path, MinCap: 100}, jan, []string{"BTC/USDT:USDT", "ETH/USDT:USDT"}},
+		{&MarketCapList{Path: path, MaxRank: 2}, feb, []string{"BTC/USDT:USDT", "SOL/USDT:USDT"}},
+		{&MarketCapList{Path: path, Limit: 1}, feb, []string{"BTC/USDT:USDT"}},
+	}
+	for i, c := range cases {
+		res, err := c.filter.Filter(symbols, c.timeMS)
+		if err != nil {
+			t.Fatalf("case %d fail: %v", i, err)
+		}
+		if !slices.Equal(res, c.res) {
+			t.Errorf("case %d expect %v, got %v", i, c.res, res)
+		}
+	}
+	early, _ := btime.ParseTimeMS("2023-12-01")
+	if _, err := (&MarketCapList{Path: path}).Filter(symbols, early); err == nil {
+		t.Errorf("MarketCapList should fail without caps before time")
+	}
+}
+
+func TestIndicatorFilter(t *testing.T) {
+	f := &IndicatorFilter{}
+	symbols := []string{"A/USDT:USDT"}
+	res, err := f.Filter(symbols, 0)
+	if err != nil || !slices.Equal(res, symbols) {
+		t.Errorf("IndicatorFilter without expr should keep all, got %v, %v", res, err)
+	}
+	f = &IndicatorFilter{Expr: "close >"}
+	if _, err = f.Filter(symbols, 0); err == nil {
+		t.Errorf("IndicatorFilter should fail for invalid expr")
+	}
+	expr, err := ParseFilterExpr("close > sma(close, 3) && rsi(close, 3) < 100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a single drop keeps rsi below 100 一次下跌使rsi低于100
+	ok, err := EvalFilterExpr(expr, makeKlines(5, 4, 5, 6, 7, 8))
+	if err != nil || !ok {
+		t.Errorf("uptrend should pass, got %v, %v", ok, err)
+	}
+	ok, err = EvalFilterExpr(expr, makeKlines(8, 7, 6, 5, 4, 3))
+	if err != nil || ok {
+		t.Errorf("downtrend should fail, got %v, %v", ok, err)
+	}
+}
@@ -1,6 +1,8 @@
 package goods
 
 import (
+	"go/ast"
+
 	"github.com/banbox/banexg/errs"
 )
 
@@ -15,6 +17,13 @@ type IProducer interface {
 	GenSymbols(timeMS int64) ([]string, *errs.Error)
 }
 
+/*
+FuncMakeFilter
+Create a filter with the base config, other fields are decoded from the yaml config after creation
+使用基础配置创建过滤器，其他字段在创建后从yaml配置解码
+*/
+type FuncMakeFilter func(base BaseFilter) IFilter
+
 type BaseFilter struct {
 	Name       string `yaml:"name" mapstructure:"name"`
 	Disable    bool   `yaml:"disable" mapstructure:"disable,omitempty"`
@@ -55,12 +64,69 @@ type RateOfChangeFilter struct {
 	CacheSecs int     `yaml:"cache_secs" mapstructure:"cache_secs,omitempty"` // 缓存时间，秒
 }
 
-// 流动性过滤器。
+// 流动性过滤器。Only available in live mode as order books are required 需要订单簿，仅实盘可用
 type SpreadFilter struct {
 	BaseFilter
 	MaxRatio float32 `yaml:"max_ratio" mapstructure:"max_ratio,omitempty"` // 公式：1-bid/ask，买卖价差占价格的最大比率
 }
 
+// LiquidityFilter Order book depth within depth_rate of the mid price. Only available in live mode 中间价附近深度过滤，仅实盘可用
+type LiquidityFilter struct {
+	BaseFilter
+	DepthRate float64 `yaml:"depth_rate" mapstructure:"depth_rate,omitempty"` // Price range from mid price, default 0.01 距中间价的价格范围，默认0.01
+	MinValue  float64 `yaml:"min_value" mapstructure:"min_value,omitempty"`   // Min value of bids and asks in the range, in quote 范围内买卖盘的最小价值，以计价币计
+}
+
+// OpenInterestFilter Filter futures by open interest value 按持仓价值过滤合约
+type OpenInterestFilter struct {
+	BaseFilter
+	Period   string  `yaml:"period" mapstructure:"period,omitempty"`       // Period of open interest history, default 1h 持仓历史的周期，默认1h
+	MinValue float64 `yaml:"min_value" mapstructure:"min_value,omitempty"` // Min open interest value 最小持仓价值
+	Limit    int     `yaml:"limit" mapstructure:"limit,omitempty"`         // Keep top n by open interest value 按持仓价值保留前n个
+}
+
+// FundingFilter Filter futures by average funding rate of the latest back_num periods 按最近back_num期平均资金费率过滤合约
+type FundingFilter struct {
+	BaseFilter
+	BackNum int     `yaml:"back_num" mapstructure:"back_num,omitempty"` // default 3
+	Min     float64 `yaml:"min" mapstructure:"min,omitempty"`
+	Max     float64 `yaml:"max" mapstructure:"max,omitempty"`
+	MaxAbs  float64 `yaml:"max_abs" mapstructure:"max_abs,omitempty"` // Max absolute funding rate 最大资金费率绝对值
+}
+
+/*
+MarketCapList
+Produce symbols by market cap from a local CSV with header: date,coin,market_cap. The latest date before the time is used.
+从本地CSV按市值生成品种，表头：date,coin,market_cap。使用不晚于当前时间的最新日期
+*/
+type MarketCapList struct {
+	BaseFilter
+	Path    string  `yaml:"path" mapstructure:"path,omitempty"`         // Path of the csv file, relative to data dir CSV文件路径，相对数据目录
+	MinCap  float64 `yaml:"min_cap" mapstructure:"min_cap,omitempty"`   // Minimum market cap 最小市值
+	MaxRank int     `yaml:"max_rank" mapstructure:"max_rank,omitempty"` // Maximum rank by market cap 最大市值排名
+	Limit   int     `yaml:"limit" mapstructure:"limit,omitempty"`
+	caps    []*coinCaps
+}
+
+type coinCaps struct {
+	TimeMS int64
+	Coins  []string // sorted by market cap desc 按市值倒序
+	Caps   map[string]float64
+}
+
+/*
+IndicatorFilter
+Keep symbols whose klines satisfy the expression, like: close > sma(close, 20) && rsi(close, 14) < 70
+保留K线满足表达式的品种，如：close > sma(close, 20) && rsi(close, 14) < 70
+*/
+type IndicatorFilter struct {
+	BaseFilter
+	Timeframe string `yaml:"timeframe" mapstructure:"timeframe,omitempty"` // default 1d
+	BackNum   int    `yaml:"back_num" mapstructure:"back_num,omitempty"`   // default 100
+	Expr      string `yaml:"expr" mapstructure:"expr,omitempty"`
+	expr      ast.Expr
+}
+
 type CorrelationFilter struct {
 	BaseFilter
 	Min       float64 `yaml:"min" mapstructure:"min,omitempty"`
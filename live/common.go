@@ -50,7 +50,9 @@ func CronRefreshPairs(dp data.IProvider) {
 func CronLoadMarkets() {
 	// 2小时更新一次市场行情
 	_, err := core.Cron.AddFunc("30 3 */2 * * *", func() {
-		_, _ = orm.LoadMarkets(exg.Default, true)
+		for _, exchange := range exg.AllExchanges() {
+			_, _ = orm.LoadMarkets(exchange, true)
+		}
 	})
 	if err != nil {
 		log.Error("add CronLoadMarkets fail", zap.Error(err))
@@ -126,6 +128,7 @@ func CronKlineDelays() {
 		}
 		stuckCount = 0
 		var fails = make(map[string][]string)
+		core.PairCopiedLock.RLock()
 		for pair, wait := range core.PairCopiedMs {
 			if wait[0]+wait[1]*2 > curMS {
 				continue
@@ -134,6 +137,7 @@ func CronKlineDelays() {
 			arr, _ := fails[timeoutMin]
 			fails[timeoutMin] = append(arr, pair)
 		}
+		core.PairCopiedLock.RUnlock()
 		if len(fails) > 0 {
 			failText := core.GroupByPairQuotes(fails, false)
 			logDelay("Listen to the spider kline timeout:" + failText)
@@ -171,6 +175,27 @@ func CronKlineSummary() {
 	}
 }
 
+func CronFundingFees() {
+	// Funding fees are settled on the hour, sync at the 2nd minute of every hour
+	// 资金费在整点结算，每小时第2分钟同步
+	_, err_ := core.Cron.AddFunc("20 2 * * * *", biz.SyncFundingFees)
+	if err_ != nil {
+		log.Error("add SyncFundingFees fail", zap.Error(err_))
+	}
+}
+
+func CronFxRates() {
+	_, err_ := core.Cron.AddFunc("25 * * * * *", func() {
+		err := biz.RefreshFxRates()
+		if err != nil {
+			log.Error("refresh fx rates fail", zap.Error(err))
+		}
+	})
+	if err_ != nil {
+		log.Error("add CronFxRates fail", zap.Error(err_))
+	}
+}
+
 func CronCheckTriggerOds() {
 	// Check every minute 15 seconds to see if the limit order submission is triggered
 	// 在每分钟的15s检查是否触发限价单提交
@@ -216,7 +241,7 @@ func updateBalancePos() {
 
 func updateAccBalance(account string) {
 	wallet := biz.GetWallets(account)
-	rsp, err := exg.Default.FetchBalance(map[string]interface{}{
+	rsp, err := exg.Get(account).FetchBalance(map[string]interface{}{
 		banexg.ParamAccount: account,
 	})
 	if err != nil {
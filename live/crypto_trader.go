@@ -5,6 +5,7 @@ import (
 	"github.com/banbox/banbot/opt"
 	"github.com/banbox/banbot/orm/ormo"
 	"github.com/banbox/banexg/utils"
+	"math"
 	"strings"
 	"time"
 
@@ -25,11 +26,16 @@ import (
 
 type CryptoTrader struct {
 	biz.Trader
-	dp *data.LiveProvider
+	dp        *data.LiveProvider
+	safeStart int  // bars to observe without entries 不入场仅观察的bar数
+	force     bool // skip confirm of pre-flight checks 跳过启动前检查的确认
 }
 
-func NewCryptoTrader() *CryptoTrader {
-	return &CryptoTrader{}
+func NewCryptoTrader(args *config.CmdArgs) *CryptoTrader {
+	return &CryptoTrader{
+		safeStart: args.SafeStart,
+		force:     args.Force,
+	}
 }
 
 func (t *CryptoTrader) Init() *errs.Error {
@@ -53,10 +59,18 @@ func (t *CryptoTrader) Init() *errs.Error {
 	if err != nil {
 		return err
 	}
-	err = web.StartApi()
+	// In HA mode only the leader places orders, the standby keeps data feeds warm
+	// 高可用模式下仅主节点下单，备用节点保持数据订阅预热
+	err = haInit()
 	if err != nil {
 		return err
 	}
+	if haIsLeader() {
+		err = web.StartApi()
+		if err != nil {
+			return err
+		}
+	}
 	// Order Manager initialization
 	// 订单管理器初始化
 	err = t.initOdMgr()
@@ -64,7 +78,26 @@ func (t *CryptoTrader) Init() *errs.Error {
 		return err
 	}
 	err = opt.RefreshPairJobs(dp, true, true, nil)
+	if err != nil {
+		return err
+	}
 	lastRefreshMS = btime.TimeMS()
+	// Load legal prices of non-fiat quotes and report currency
+	// 加载非法币定价币和报告币种的法币价格
+	err = biz.InitFxRates()
+	if err != nil {
+		return err
+	}
+	// Verify account and data before enabling entries
+	// 开启入场前检查账户和数据
+	if haIsLeader() {
+		err = t.preFlight()
+		if err != nil {
+			return err
+		}
+	} else {
+		log.Info("run as ha standby, wait for leader lease to expire")
+	}
 	// add exit callback
 	core.ExitCalls = append(core.ExitCalls, exitCleanUp)
 	strat.WsSubUnWatch = func(m map[string][]string) {
@@ -85,6 +118,15 @@ func (t *CryptoTrader) initOdMgr() *errs.Error {
 		return nil
 	}
 	biz.InitLiveOrderMgr(t.orderCB)
+	if !haIsLeader() {
+		// standby restores orders when taking over 备用节点在接管时恢复订单
+		return nil
+	}
+	return syncExgOrders()
+}
+
+// syncExgOrders restore local orders from exchange for all accounts 从交易所恢复所有账户的本地订单
+func syncExgOrders() *errs.Error {
 	for account := range config.Accounts {
 		odMgr := biz.GetLiveOdMgr(account)
 		oldList, newList, delList, err := odMgr.SyncExgOrders()
@@ -104,6 +146,19 @@ func (t *CryptoTrader) initOdMgr() *errs.Error {
 	return nil
 }
 
+func (t *CryptoTrader) preFlight() *errs.Error {
+	if !core.EnvReal {
+		// dry run: no exchange account to check, only observe if required
+		// 模拟运行：无交易所账户需检查，仅按需观察
+		if t.safeStart > 0 {
+			untilMS := blockEntries(t.safeStart)
+			log.Info("safe start, no entry until " + btime.ToDateStr(untilMS, ""))
+		}
+		return nil
+	}
+	return confirmStart(RunPreFlight(), t.safeStart, t.force)
+}
+
 func (t *CryptoTrader) Run() *errs.Error {
 	err := t.Init()
 	if err != nil {
@@ -119,6 +174,10 @@ func (t *CryptoTrader) Run() *errs.Error {
 }
 
 func (t *CryptoTrader) FeedKLine(bar *orm.InfoKline) {
+	if !haIsLeader() {
+		// standby only updates indicators 备用节点仅更新指标
+		bar.IsWarmUp = true
+	}
 	if bar.IsWarmUp {
 		tfMSecs := int64(utils.TFToSecs(bar.TimeFrame) * 1000)
 		barEndMS := bar.Time + tfMSecs
@@ -162,6 +221,21 @@ func (t *CryptoTrader) orderCB(od *ormo.InOutOrder, isEnter bool) {
 }
 
 func (t *CryptoTrader) startJobs() {
+	// Refresh the market regularly
+	// 定时刷新市场行情
+	CronLoadMarkets()
+	// The timer output is executed every 5 minutes: 01:30 06:30 11:30
+	// 定时输出收到K线情况，每5分钟执行：01:30  06:30  11:30
+	CronKlineSummary()
+	if haIsLeader() {
+		t.startTradeJobs()
+	}
+	t.startHA()
+	core.Cron.Start()
+}
+
+// startTradeJobs jobs only run by the instance placing orders 仅由下单实例运行的任务
+func (t *CryptoTrader) startTradeJobs() {
 	if core.EnvReal {
 		// Listen to account order flow, process user orders, and consume order queues
 		// 监听账户订单流、处理用户下单、消费订单队列
@@ -171,27 +245,26 @@ func (t *CryptoTrader) startJobs() {
 	// Refresh trading pairs regularly
 	// 定期刷新交易对
 	CronRefreshPairs(t.dp)
-	// Refresh the market regularly
-	// 定时刷新市场行情
-	CronLoadMarkets()
 	// Check every 5 minutes to see if the global stop loss is triggered
 	// 每5分钟检查是否触发全局止损
 	CronFatalLossCheck()
 	// Regularly check the candlestick timeout, updated every minute
 	// 定期检查K线超时，每分钟更新
 	CronKlineDelays()
-	// The timer output is executed every 5 minutes: 01:30 06:30 11:30
-	// 定时输出收到K线情况，每5分钟执行：01:30  06:30  11:30
-	CronKlineSummary()
+	// Refresh conversion rates of non-fiat currencies every minute
+	// 每分钟刷新非法币币种的汇率
+	CronFxRates()
 	if core.EnvReal {
 		// Check if the limit order submission is triggered at 15th secs of every minute
 		// 每分钟第15s检查是否触发限价单提交
 		CronCheckTriggerOds()
+		// Record funding fees of positions to orders
+		// 记录仓位的资金费到订单
+		CronFundingFees()
 		// Regularly update balance and synchronize exchange positions with local orders
 		// 定期更新余额，同步交易所持仓到本地订单
 		StartLoopBalancePositions()
 	}
-	core.Cron.Start()
 }
 
 func (t *CryptoTrader) markUnWarm() {
@@ -207,27 +280,72 @@ func (t *CryptoTrader) markUnWarm() {
 func exitCleanUp() {
 	orm.FlushDumps()
 	orm.CloseDump()
-	err := biz.CleanUpOdMgr()
-	if err != nil {
-		log.Error("clean odMgr fail", zap.Error(err))
+	core.Cron.Stop()
+	// No new entries during shutdown 退出过程中不再开新仓
+	for account := range config.Accounts {
+		core.NoEnterUntil[account] = math.MaxInt64
 	}
+	// Strategies can update stop loss in OnShutDown before positions are handled by shutdown policy
+	// 策略可在OnShutDown中更新止损，之后再按shutdown策略处理持仓
 	strat.ExitStratJobs()
-	core.Cron.Stop()
-	err = exg.Default.Close()
-	if err != nil {
-		log.Error("close exg fail", zap.Error(err))
+	isLeader := haIsLeader()
+	if isLeader {
+		cleanUpOdMgr()
+	}
+	for _, exchange := range exg.AllExchanges() {
+		err := exchange.Close()
+		if err != nil {
+			log.Error("close exg fail", zap.String("exg", exchange.Info().ID), zap.Error(err))
+		}
+	}
+	if !isLeader {
+		// standby owns no orders 备用节点不持有订单
+		rpc.CleanUp()
+		return
 	}
 	for account := range config.Accounts {
 		openOds, lock := ormo.GetOpenODs(account)
 		lock.Lock()
-		openNum := len(openOds)
+		openNum, unprotected := len(openOds), 0
+		for _, od := range openOds {
+			if !biz.IsProtected(od) {
+				unprotected += 1
+			}
+		}
 		lock.Unlock()
 		msg := fmt.Sprintf("bot stop, %d orders opened", openNum)
+		if core.EnvReal && config.Shutdown != nil {
+			msg += fmt.Sprintf(", %d without stop loss on exchange, policy: %s", unprotected, biz.GetShutdownPolicy())
+		}
 		rpc.SendMsg(map[string]interface{}{
 			"type":    rpc.MsgTypeStatus,
 			"account": account,
 			"status":  msg,
 		})
 	}
+	if haEnabled() {
+		// let the standby take over at once 让备用节点立即接管
+		haRelease()
+	}
 	rpc.CleanUp()
 }
+
+// cleanUpOdMgr handle orders by shutdown policy within timeout 在超时时间内按shutdown策略处理订单
+func cleanUpOdMgr() {
+	timeoutSecs := 60
+	if config.Shutdown != nil && config.Shutdown.TimeoutSecs > 0 {
+		timeoutSecs = config.Shutdown.TimeoutSecs
+	}
+	done := make(chan *errs.Error, 1)
+	go func() {
+		done <- biz.CleanUpOdMgr()
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			log.Error("clean odMgr fail", zap.Error(err))
+		}
+	case <-time.After(time.Duration(timeoutSecs) * time.Second):
+		log.Error("clean odMgr timeout, exit now", zap.Int("secs", timeoutSecs))
+	}
+}
@@ -0,0 +1,260 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"sync/atomic"
+	"time"
+
+	"github.com/banbox/banbot/btime"
+	"github.com/banbox/banbot/config"
+	"github.com/banbox/banbot/core"
+	"github.com/banbox/banbot/orm"
+	"github.com/banbox/banbot/rpc"
+	"github.com/banbox/banbot/web"
+	"github.com/banbox/banexg/errs"
+	"github.com/banbox/banexg/log"
+	"github.com/sasha-s/go-deadlock"
+	"go.uber.org/zap"
+)
+
+const (
+	haActNone     = iota // nothing to do 无需操作
+	haActTakeOver        // the lease is acquired, take over 已获取租约，接管
+	haActStepDown        // the lease is lost, step down 租约已丢失，退位
+)
+
+var (
+	haNodeID string      // holder id of this instance in ha_lease 本实例在ha_lease中的持有者ID
+	haLeader atomic.Bool // whether this instance holds the lease and places orders 本实例是否持有租约并下单
+)
+
+// haEnabled HA only works for real trading 高可用仅对实盘生效
+func haEnabled() bool {
+	return core.EnvReal && config.HA != nil && config.HA.Enable
+}
+
+// haIsLeader always true when HA is disabled 未启用高可用时始终为true
+func haIsLeader() bool {
+	return !haEnabled() || haLeader.Load()
+}
+
+/*
+haInit
+Try to acquire the leader lease on start; instances failing to get it run as standby.
+启动时尝试获取主节点租约，获取失败的实例作为备用节点运行
+*/
+func haInit() *errs.Error {
+	if !haEnabled() {
+		return nil
+	}
+	host, _ := os.Hostname()
+	haNodeID = fmt.Sprintf("%s-%d-%d", host, os.Getpid(), rand.Intn(100000))
+	ok, err := haTryLease()
+	if err != nil {
+		return err
+	}
+	haLeader.Store(ok)
+	role := "standby"
+	if ok {
+		role = "leader"
+	}
+	log.Info("ha init", zap.String("name", config.HA.Name), zap.String("node", haNodeID), zap.String("role", role))
+	return nil
+}
+
+/*
+haCallTimeout
+Deadline of lease calls, half of lease_secs - heartbeat_secs, so a hung call returns before the lease expires
+租约调用的超时，为lease_secs - heartbeat_secs的一半，使卡住的调用在租约过期前返回
+*/
+func haCallTimeout() time.Duration {
+	return time.Duration(config.HA.LeaseSecs-config.HA.HeartbeatSecs) * time.Second / 2
+}
+
+// haTryLease acquire or renew the lease, the expiry is computed by postgres clock 获取或续期租约，过期时间按postgres时钟计算
+func haTryLease() (bool, *errs.Error) {
+	sess, conn, err := orm.Conn(nil)
+	if err != nil {
+		return false, err
+	}
+	defer conn.Release()
+	ctx, cancel := context.WithTimeout(context.Background(), haCallTimeout())
+	defer cancel()
+	num, err_ := sess.TryHaLease(ctx, orm.TryHaLeaseParams{
+		Name:    config.HA.Name,
+		Holder:  haNodeID,
+		LeaseMs: int64(config.HA.LeaseSecs) * 1000,
+	})
+	if errors.Is(err_, context.DeadlineExceeded) {
+		return false, errs.New(core.ErrTimeout, err_)
+	} else if err_ != nil {
+		return false, orm.NewDbErr(core.ErrDbExecFail, err_)
+	}
+	return num > 0, nil
+}
+
+// haRelease expire the lease at once so the standby can take over without waiting 立即使租约过期，备用节点无需等待即可接管
+func haRelease() {
+	sess, conn, err := orm.Conn(nil)
+	if err != nil {
+		log.Error("release ha lease fail", zap.Error(err))
+		return
+	}
+	defer conn.Release()
+	ctx, cancel := context.WithTimeout(context.Background(), haCallTimeout())
+	defer cancel()
+	err_ := sess.ReleaseHaLease(ctx, orm.ReleaseHaLeaseParams{
+		Name:   config.HA.Name,
+		Holder: haNodeID,
+	})
+	if err_ != nil {
+		log.Error("release ha lease fail", zap.Error(err_))
+	}
+}
+
+/*
+haBeat
+State of the heartbeat loop, decides the action for each result of trying the lease. Thread safe.
+心跳循环的状态，根据每次尝试租约的结果决定操作。线程安全
+*/
+type haBeat struct {
+	holding     bool  // the lease is held, maybe still taking over 持有租约，可能仍在接管中
+	lastRenewMS int64 // last time the lease was acquired or renewed 最近获取或续期租约的时间
+	maxFailMS   int64 // holder retries on db errors within this time since last renew 持有者在距上次续期此时间内遇数据库错误时重试
+	lock        deadlock.Mutex
+}
+
+func newHaBeat(holding bool, curMS int64) *haBeat {
+	return &haBeat{
+		holding:     holding,
+		lastRenewMS: curMS,
+		maxFailMS:   int64(config.HA.LeaseSecs-config.HA.HeartbeatSecs) * 1000,
+	}
+}
+
+/*
+next
+The holder renews the lease, retries on db errors until the lease is about to expire, and steps down when the lease is
+taken or the call times out. Others take over once the lease is acquired.
+持有者续期租约，数据库出错时重试直到租约即将过期，租约被抢占或调用超时时退位。其他实例获取租约后接管
+*/
+func (h *haBeat) next(ok bool, err *errs.Error, curMS int64) int {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	if h.holding {
+		if err == nil && ok {
+			h.lastRenewMS = curMS
+			return haActNone
+		}
+		if err != nil && err.Code != core.ErrTimeout && curMS-h.lastRenewMS < h.maxFailMS {
+			log.Warn("renew ha lease fail, retry", zap.Error(err))
+			return haActNone
+		}
+		h.holding = false
+		return haActStepDown
+	}
+	if err != nil {
+		log.Warn("try ha lease fail", zap.Error(err))
+		return haActNone
+	} else if !ok {
+		return haActNone
+	}
+	h.holding = true
+	h.lastRenewMS = curMS
+	return haActTakeOver
+}
+
+// release the lease is given up after a failed take over 接管失败后放弃租约
+func (h *haBeat) release() {
+	h.lock.Lock()
+	h.holding = false
+	h.lock.Unlock()
+}
+
+/*
+startHA
+Heartbeat loop: the holder renews its lease and exits once the lease is lost; the standby keeps trying and takes over
+when the leader's heartbeat expires. Take over runs in its own goroutine, so the lease keeps being renewed meanwhile.
+心跳循环：持有者续期租约，租约丢失时退出；备用节点持续尝试，主节点心跳过期后接管。接管在单独的协程中执行，期间租约持续续期
+*/
+func (t *CryptoTrader) startHA() {
+	if !haEnabled() {
+		return
+	}
+	beat := newHaBeat(haLeader.Load(), btime.UTCStamp())
+	go func() {
+		ticker := time.NewTicker(time.Duration(config.HA.HeartbeatSecs) * time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-core.Ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			ok, err := haTryLease()
+			switch beat.next(ok, err, btime.UTCStamp()) {
+			case haActStepDown:
+				haStepDown(err)
+				return
+			case haActTakeOver:
+				go func() {
+					err := t.takeOver()
+					if err != nil {
+						log.Error("ha take over fail, release lease", zap.Error(err))
+						beat.release()
+						haRelease()
+					}
+				}()
+			}
+		}
+	}()
+}
+
+/*
+haStepDown
+The lease is lost and another instance may be placing orders now, stop trading and exit immediately.
+租约已丢失，其他实例可能正在下单，立即停止交易并退出
+*/
+func haStepDown(err *errs.Error) {
+	haLeader.Store(false)
+	msg := "ha lease lost, exit without handling orders"
+	log.Error(msg, zap.Error(err))
+	rpc.SendMsg(map[string]interface{}{
+		"type":   rpc.MsgTypeException,
+		"status": msg,
+	})
+	go core.ExitProcess(1)
+}
+
+/*
+takeOver
+Become the leader: restore orders from exchange, start api, run pre-flight checks and start trading jobs.
+成为主节点：从交易所恢复订单，启动api，执行启动前检查并开启交易任务
+*/
+func (t *CryptoTrader) takeOver() *errs.Error {
+	log.Warn("leader heartbeat expired, take over", zap.String("node", haNodeID))
+	err := syncExgOrders()
+	if err != nil {
+		return err
+	}
+	err = web.StartApi()
+	if err != nil {
+		log.Error("start api fail", zap.Error(err))
+	}
+	// no one to confirm here, only report 此处无人确认，仅报告
+	p := RunPreFlight()
+	log.Info("pre-flight checks:\n" + p.Report())
+	status := fmt.Sprintf("ha take over, pre-flight: %d items, %d failed", len(p.Items), p.FailNum())
+	if t.safeStart > 0 {
+		untilMS := blockEntries(t.safeStart)
+		status += fmt.Sprintf(", safe start: no entry until %s", btime.ToDateStr(untilMS, ""))
+	}
+	haLeader.Store(true)
+	t.startTradeJobs()
+	rpcPreFlight(status)
+	return nil
+}
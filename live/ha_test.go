@@ -0,0 +1,88 @@
+package live
+
+import (
+	"testing"
+	"time"
+
+	"github.com/banbox/banbot/config"
+	"github.com/banbox/banbot/core"
+	"github.com/banbox/banexg/errs"
+)
+
+func useHaCfg(t *testing.T) {
+	oldCfg := config.HA
+	config.HA = &config.HAConfig{Enable: true, Name: "test", LeaseSecs: 30, HeartbeatSecs: 10}
+	t.Cleanup(func() {
+		config.HA = oldCfg
+	})
+}
+
+func TestHaCallTimeout(t *testing.T) {
+	useHaCfg(t)
+	timeout := haCallTimeout()
+	if timeout <= 0 || timeout >= 20*time.Second {
+		t.Errorf("timeout should be shorter than lease_secs - heartbeat_secs, got %v", timeout)
+	}
+}
+
+func TestHaBeat(t *testing.T) {
+	useHaCfg(t)
+	dbErr := errs.NewMsg(core.ErrDbExecFail, "db fail")
+	timeoutErr := errs.NewMsg(core.ErrTimeout, "timeout")
+	startMS := int64(1735689600000)
+	type step struct {
+		name  string
+		ok    bool
+		err   *errs.Error
+		secs  int64
+		act   int
+		holds bool
+	}
+	cases := []struct {
+		name    string
+		holding bool
+		steps   []step
+	}{
+		{"acquire", false, []step{
+			{"held by leader", false, nil, 10, haActNone, false},
+			{"db error", false, dbErr, 20, haActNone, false},
+			{"leader expired", true, nil, 30, haActTakeOver, true},
+			{"renew", true, nil, 40, haActNone, true},
+		}},
+		{"renew with retry", true, []step{
+			{"renew", true, nil, 10, haActNone, true},
+			{"db error in lease", false, dbErr, 20, haActNone, true},
+			{"renew again", true, nil, 30, haActNone, true},
+		}},
+		{"expiry", true, []step{
+			{"db error", false, dbErr, 10, haActNone, true},
+			// 20 secs since last renew, lease is about to expire 距上次续约20秒，租约即将过期
+			{"db error near expiry", false, dbErr, 20, haActStepDown, false},
+		}},
+		{"taken by other", true, []step{
+			{"lease taken", false, nil, 10, haActStepDown, false},
+		}},
+		{"timeout", true, []step{
+			{"call timeout", false, timeoutErr, 10, haActStepDown, false},
+		}},
+	}
+	for _, c := range cases {
+		beat := newHaBeat(c.holding, startMS)
+		for _, s := range c.steps {
+			act := beat.next(s.ok, s.err, startMS+s.secs*1000)
+			if act != s.act || beat.holding != s.holds {
+				t.Errorf("%s/%s: got action %d holding %v, want %d %v", c.name, s.name, act, beat.holding,
+					s.act, s.holds)
+			}
+		}
+	}
+	// failed take over gives up the lease, and can acquire again 接管失败后放弃租约，可再次获取
+	beat := newHaBeat(false, startMS)
+	if beat.next(true, nil, startMS) != haActTakeOver {
+		t.Fatal("should take over")
+	}
+	beat.release()
+	if beat.next(false, nil, startMS+10000) != haActNone || beat.holding {
+		t.Errorf("released beat should not step down")
+	}
+}
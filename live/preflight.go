@@ -0,0 +1,471 @@
+package live
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/banbox/banbot/btime"
+	"github.com/banbox/banbot/config"
+	"github.com/banbox/banbot/core"
+	"github.com/banbox/banbot/exg"
+	"github.com/banbox/banbot/orm"
+	"github.com/banbox/banbot/orm/ormo"
+	"github.com/banbox/banbot/rpc"
+	"github.com/banbox/banbot/strat"
+	utils2 "github.com/banbox/banbot/utils"
+	"github.com/banbox/banexg"
+	"github.com/banbox/banexg/binance"
+	"github.com/banbox/banexg/errs"
+	"github.com/banbox/banexg/log"
+	"github.com/banbox/banexg/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	CheckOk   = "ok"
+	CheckWarn = "warn"
+	CheckFail = "fail"
+)
+
+const (
+	maxKlineLagMS   = 180000 // spider kline older than this is reported 爬虫K线延迟超过此值时报告
+	warnClockSkewMS = 1000
+	failClockSkewMS = 5000 // binance rejects requests out of recvWindow 超出recvWindow时币安会拒绝请求
+)
+
+/*
+PreCheck
+Result of one pre-flight check item, Account is empty for global items
+一项启动前检查的结果，全局检查项的Account为空
+*/
+type PreCheck struct {
+	Account string
+	Name    string
+	Level   string
+	Msg     string
+}
+
+/*
+PreFlight
+Checks before live trading starts: api key permissions, balance, position mode, leverage and margin mode,
+clock skew, spider kline lag and exchange positions not mapped to orders.
+实盘开始前的检查：API权限、余额、持仓模式、杠杆和保证金模式、时钟偏差、爬虫K线延迟、未对应订单的交易所持仓
+*/
+type PreFlight struct {
+	Items []*PreCheck
+}
+
+func (p *PreFlight) add(acc, name, level, msg string, args ...interface{}) {
+	if len(args) > 0 {
+		msg = fmt.Sprintf(msg, args...)
+	}
+	p.Items = append(p.Items, &PreCheck{Account: acc, Name: name, Level: level, Msg: msg})
+}
+
+// FailNum count of failed items 失败项数量
+func (p *PreFlight) FailNum() int {
+	num := 0
+	for _, it := range p.Items {
+		if it.Level == CheckFail {
+			num += 1
+		}
+	}
+	return num
+}
+
+// Report text of all items, one line each 所有检查项的文本，每项一行
+func (p *PreFlight) Report() string {
+	var b strings.Builder
+	for _, it := range p.Items {
+		acc := it.Account
+		if acc == "" {
+			acc = "*"
+		}
+		b.WriteString(fmt.Sprintf("[%s] %s %s: %s\n", it.Level, acc, it.Name, it.Msg))
+	}
+	return b.String()
+}
+
+/*
+RunPreFlight
+Run all pre-flight checks, should be called after orders are synced with exchange
+执行所有启动前检查，应在订单与交易所同步后调用
+*/
+func RunPreFlight() *PreFlight {
+	p := &PreFlight{}
+	p.checkClock()
+	p.checkKlineLag()
+	accounts := utils2.KeysOfMap(config.Accounts)
+	slices.Sort(accounts)
+	for _, acc := range accounts {
+		exchange := exg.Get(acc)
+		_, market := config.GetAccVenue(acc)
+		p.checkAccount(acc, exchange, exchange.Info().ID, market)
+	}
+	return p
+}
+
+// checkAccount checks of one account, positions and leverage are only for contracts 单个账户的检查，持仓和杠杆仅用于合约
+func (p *PreFlight) checkAccount(acc string, exchange banexg.BanExchange, exgId, market string) {
+	isBnb := exgId == "binance"
+	if isBnb {
+		p.checkApiKey(acc, exchange, market)
+	} else {
+		p.add(acc, "api_key", CheckWarn, "permission check not supported for %s, skip", exgId)
+	}
+	p.checkBalance(acc, exchange)
+	if banexg.IsContract(market) {
+		if isBnb {
+			p.checkPosMode(acc, exchange, market)
+			p.checkPairMargin(acc, exchange, market)
+		}
+		p.checkLeverage(acc, exchange)
+		// spot has no positions, holdings are balances 现货没有持仓，持有的是余额
+		p.checkUnknownPos(acc, exchange)
+	}
+}
+
+// callBnb call binance api and parse json response 调用币安接口并解析json响应
+func callBnb(exchange banexg.BanExchange, method string, params map[string]interface{}, res interface{}) *errs.Error {
+	rsp, err := exchange.Call(method, params)
+	if err != nil {
+		return err
+	}
+	err_ := utils.UnmarshalString(rsp.Content, res, utils.JsonNumDefault)
+	if err_ != nil {
+		return errs.New(core.ErrMarshalFail, err_)
+	}
+	return nil
+}
+
+func bnbPrivArgs(acc string) map[string]interface{} {
+	return map[string]interface{}{
+		banexg.ParamAccount: acc,
+		"timestamp":         btime.UTCStamp(),
+	}
+}
+
+// checkApiKey api key should be able to read and trade on the market, without withdraw and with ip whitelist
+// API密钥应能读取和交易当前市场，不应允许提现且应绑定IP白名单
+func (p *PreFlight) checkApiKey(acc string, exchange banexg.BanExchange, market string) {
+	var res = struct {
+		IpRestrict                 bool `json:"ipRestrict"`
+		EnableReading              bool `json:"enableReading"`
+		EnableSpotAndMarginTrading bool `json:"enableSpotAndMarginTrading"`
+		EnableFutures              bool `json:"enableFutures"`
+		EnableWithdrawals          bool `json:"enableWithdrawals"`
+	}{}
+	err := callBnb(exchange, binance.MethodSapiGetAccountApiRestrictions, bnbPrivArgs(acc), &res)
+	if err != nil {
+		p.add(acc, "api_key", CheckFail, "query api restrictions fail, check api key and ip whitelist: %s", err.Short())
+		return
+	}
+	canTrade := res.EnableSpotAndMarginTrading
+	if banexg.IsContract(market) {
+		canTrade = res.EnableFutures
+	}
+	if !res.EnableReading || !canTrade {
+		p.add(acc, "api_key", CheckFail, "api key can't read or trade %s, read: %v, trade: %v", market,
+			res.EnableReading, canTrade)
+		return
+	}
+	if res.EnableWithdrawals {
+		p.add(acc, "api_key", CheckWarn, "withdraw is enabled for api key, disable it for safety")
+	} else if !res.IpRestrict {
+		p.add(acc, "api_key", CheckWarn, "api key is not restricted to ip whitelist")
+	} else {
+		p.add(acc, "api_key", CheckOk, "read and trade enabled, ip restricted")
+	}
+}
+
+// checkClock local clock skew against each binance exchange 本地时钟与交易所的偏差
+func (p *PreFlight) checkClock() {
+	for _, exchange := range exg.AllExchanges() {
+		if exchange.Info().ID != "binance" {
+			continue
+		}
+		var res = struct {
+			ServerTime int64 `json:"serverTime"`
+		}{}
+		startMS := btime.UTCStamp()
+		err := callBnb(exchange, binance.MethodPublicGetTime, map[string]interface{}{}, &res)
+		if err != nil {
+			p.add("", "clock", CheckWarn, "fetch server time fail: %s", err.Short())
+			continue
+		}
+		endMS := btime.UTCStamp()
+		skewMS := res.ServerTime - (startMS+endMS)/2
+		level := CheckOk
+		if abs := max(skewMS, -skewMS); abs >= failClockSkewMS {
+			level = CheckFail
+		} else if abs >= warnClockSkewMS {
+			level = CheckWarn
+		}
+		p.add("", "clock", level, "skew against binance: %d ms, round trip: %d ms", skewMS, endMS-startMS)
+	}
+}
+
+// checkKlineLag latest 1m kline saved by spider for each pair 爬虫为每个品种保存的最新1m K线
+func (p *PreFlight) checkKlineLag() {
+	if len(core.Pairs) == 0 {
+		return
+	}
+	sess, conn, err := orm.Conn(nil)
+	if err != nil {
+		p.add("", "kline_lag", CheckWarn, "connect db fail: %s", err.Short())
+		return
+	}
+	defer conn.Release()
+	sidMap := make(map[int32]string)
+	sidList := make([]int32, 0, len(core.Pairs))
+	for _, pair := range core.Pairs {
+		exs, err := orm.GetExSymbolCur(pair)
+		if err != nil {
+			continue
+		}
+		sidMap[exs.ID] = pair
+		sidList = append(sidList, exs.ID)
+	}
+	ranges := sess.GetKlineRanges(sidList, "1m")
+	curMS := btime.UTCStamp()
+	lags := make(map[string][]string)
+	for sid, pair := range sidMap {
+		stop := ranges[sid][1]
+		if curMS-stop <= maxKlineLagMS {
+			continue
+		}
+		key := "no data"
+		if stop > 0 {
+			key = fmt.Sprintf("%dmins", (curMS-stop)/60000)
+		}
+		lags[key] = append(lags[key], pair)
+	}
+	if len(lags) > 0 {
+		p.add("", "kline_lag", CheckWarn, "1m klines lag:\n%s", core.GroupByPairQuotes(lags, true))
+	} else {
+		p.add("", "kline_lag", CheckOk, "%d pairs up to date", len(sidMap))
+	}
+}
+
+// checkBalance stake currencies should have free balance 计价币应有可用余额
+func (p *PreFlight) checkBalance(acc string, exchange banexg.BanExchange) {
+	rsp, err := exchange.FetchBalance(map[string]interface{}{
+		banexg.ParamAccount: acc,
+	})
+	if err != nil {
+		p.add(acc, "balance", CheckFail, "fetch balance fail: %s", err.Short())
+		return
+	}
+	var texts []string
+	var total float64
+	for _, code := range config.StakeCurrency {
+		if it, ok := rsp.Assets[code]; ok && it.Free > 0 {
+			total += it.Free
+			texts = append(texts, fmt.Sprintf("%s: %.2f", code, it.Free))
+		}
+	}
+	stakeAmt := config.GetStakeAmount(acc)
+	if total == 0 {
+		p.add(acc, "balance", CheckFail, "no free balance of %v", config.StakeCurrency)
+	} else if total < stakeAmt {
+		p.add(acc, "balance", CheckWarn, "free %s less than stake amount %v", strings.Join(texts, ", "), stakeAmt)
+	} else {
+		p.add(acc, "balance", CheckOk, "free %s", strings.Join(texts, ", "))
+	}
+}
+
+// checkPosMode orders are placed with positionSide, so hedge mode is required 下单时带positionSide，故需要双向持仓模式
+func (p *PreFlight) checkPosMode(acc string, exchange banexg.BanExchange, market string) {
+	method := binance.MethodFapiPrivateGetPositionSideDual
+	if market == banexg.MarketInverse {
+		method = binance.MethodDapiPrivateGetPositionSideDual
+	}
+	var res = struct {
+		DualSidePosition bool `json:"dualSidePosition"`
+	}{}
+	err := callBnb(exchange, method, bnbPrivArgs(acc), &res)
+	if err != nil {
+		p.add(acc, "position_mode", CheckFail, "query position mode fail: %s", err.Short())
+	} else if !res.DualSidePosition {
+		p.add(acc, "position_mode", CheckFail, "one-way mode is not supported, please switch to hedge mode")
+	} else {
+		p.add(acc, "position_mode", CheckOk, "hedge mode")
+	}
+}
+
+// checkPairMargin margin mode of each pair should match `margin.mode` 每个品种的保证金模式应与margin.mode一致
+func (p *PreFlight) checkPairMargin(acc string, exchange banexg.BanExchange, market string) {
+	pairs := accPairs(acc)
+	if len(pairs) == 0 {
+		return
+	}
+	method := binance.MethodFapiPrivateV2GetPositionRisk
+	if market == banexg.MarketInverse {
+		method = binance.MethodDapiPrivateGetPositionRisk
+	}
+	var items []struct {
+		Symbol     string `json:"symbol"`
+		MarginType string `json:"marginType"`
+	}
+	err := callBnb(exchange, method, bnbPrivArgs(acc), &items)
+	if err != nil {
+		p.add(acc, "margin_mode", CheckWarn, "query position risk fail: %s", err.Short())
+		return
+	}
+	modes := make(map[string]string)
+	for _, it := range items {
+		modes[it.Symbol] = strings.ToLower(it.MarginType)
+	}
+	wantMode := core.MarginCross
+	if config.Margin != nil && config.Margin.Mode != "" {
+		wantMode = config.Margin.Mode
+	}
+	var diffs []string
+	for _, pair := range pairs {
+		mar, err := exchange.GetMarket(pair)
+		if err != nil {
+			continue
+		}
+		if mode, ok := modes[mar.ID]; ok && mode != wantMode {
+			diffs = append(diffs, pair)
+		}
+	}
+	if len(diffs) > 0 {
+		p.add(acc, "margin_mode", CheckWarn, "%d pairs not in %s mode: %s", len(diffs), wantMode,
+			strings.Join(diffs, ", "))
+	} else {
+		p.add(acc, "margin_mode", CheckOk, "%d pairs in %s mode", len(pairs), wantMode)
+	}
+}
+
+// checkLeverage leverage of config should not exceed max leverage of pairs 配置的杠杆不应超过品种最大杠杆
+func (p *PreFlight) checkLeverage(acc string, exchange banexg.BanExchange) {
+	pairs := accPairs(acc)
+	if len(pairs) == 0 {
+		return
+	}
+	wantLev := config.GetAccLeverage(acc)
+	var overs, diffs []string
+	for _, pair := range pairs {
+		curLev, maxLev := exchange.GetLeverage(pair, 0, acc)
+		if maxLev > 0 && wantLev > maxLev {
+			overs = append(overs, fmt.Sprintf("%s(%v)", pair, maxLev))
+		} else if curLev != wantLev {
+			diffs = append(diffs, pair)
+		}
+	}
+	if len(overs) > 0 {
+		p.add(acc, "leverage", CheckWarn, "leverage %v exceeds max, reduced on entry: %s", wantLev,
+			strings.Join(overs, ", "))
+	}
+	if len(diffs) > 0 {
+		p.add(acc, "leverage", CheckOk, "%d pairs will be set to leverage %v on entry", len(diffs), wantLev)
+	} else if len(overs) == 0 {
+		p.add(acc, "leverage", CheckOk, "%d pairs with leverage %v", len(pairs), wantLev)
+	}
+}
+
+// checkUnknownPos exchange positions larger than local orders 交易所持仓大于本地订单
+func (p *PreFlight) checkUnknownPos(acc string, exchange banexg.BanExchange) {
+	posList, err := exchange.FetchAccountPositions(nil, map[string]interface{}{
+		banexg.ParamAccount: acc,
+	})
+	if err != nil {
+		p.add(acc, "positions", CheckFail, "fetch positions fail: %s", err.Short())
+		return
+	}
+	localAmts := make(map[string]float64)
+	openOds, lock := ormo.GetOpenODs(acc)
+	lock.Lock()
+	for _, od := range openOds {
+		localAmts[fmt.Sprintf("%s_%v", od.Symbol, od.Short)] += od.HoldAmount()
+	}
+	lock.Unlock()
+	var unknowns []string
+	for _, pos := range posList {
+		if pos.Contracts <= core.AmtDust {
+			continue
+		}
+		isShort := pos.Side == banexg.PosSideShort
+		localAmt := localAmts[fmt.Sprintf("%s_%v", pos.Symbol, isShort)]
+		if pos.Contracts > localAmt*1.02+core.AmtDust {
+			unknowns = append(unknowns, fmt.Sprintf("%s %s %v(local %v)", pos.Symbol, pos.Side,
+				pos.Contracts, localAmt))
+		}
+	}
+	if len(unknowns) > 0 {
+		p.add(acc, "positions", CheckFail, "positions not mapped to orders: %s", strings.Join(unknowns, "; "))
+	} else {
+		p.add(acc, "positions", CheckOk, "%d positions, %d open orders", len(posList), len(openOds))
+	}
+}
+
+// accPairs distinct pairs of running jobs of account 账户运行中任务的品种
+func accPairs(acc string) []string {
+	pairMap := make(map[string]bool)
+	for _, jobs := range strat.AccJobs[acc] {
+		for _, job := range jobs {
+			pairMap[job.Symbol.Symbol] = true
+			break
+		}
+	}
+	res := utils2.KeysOfMap(pairMap)
+	slices.Sort(res)
+	return res
+}
+
+/*
+confirmStart
+Print pre-flight report and require confirmation before enabling entries. When safeStart > 0, run in observe-only
+mode for safeStart bars of the smallest timeframe instead of asking; force skips the confirmation.
+输出启动前检查报告，开启入场前需确认。safeStart>0时不询问，改为仅观察最小周期的safeStart根bar；force跳过确认
+*/
+func confirmStart(p *PreFlight, safeStart int, force bool) *errs.Error {
+	report := p.Report()
+	failNum := p.FailNum()
+	fmt.Print("pre-flight checks:\n" + report)
+	log.Info("pre-flight checks done", zap.Int("items", len(p.Items)), zap.Int("fail", failNum))
+	status := fmt.Sprintf("pre-flight: %d items, %d failed", len(p.Items), failNum)
+	if safeStart > 0 {
+		untilMS := blockEntries(safeStart)
+		status += fmt.Sprintf(", safe start: no entry until %s", btime.ToDateStr(untilMS, ""))
+	} else if !force {
+		tip := "input `y` to start trading, or restart with -safe-start N to observe N bars first"
+		if failNum > 0 {
+			tip = fmt.Sprintf("%d checks failed! ", failNum) + tip
+		}
+		if !utils2.ReadConfirm([]string{tip}, "y", "n", true) {
+			return errs.NewMsg(core.ErrRunTime, "pre-flight not confirmed, use -force to skip confirm")
+		}
+	}
+	rpcPreFlight(status)
+	return nil
+}
+
+// blockEntries forbid entries of all accounts for n bars of the smallest timeframe 禁止所有账户在最小周期的n根bar内入场
+func blockEntries(n int) int64 {
+	minSecs := 0
+	for _, secs := range core.TFSecs {
+		if minSecs == 0 || secs < minSecs {
+			minSecs = secs
+		}
+	}
+	tfMSecs := int64(max(minSecs, 60)) * 1000
+	curMS := btime.TimeMS()
+	untilMS := utils.AlignTfMSecs(curMS, tfMSecs) + tfMSecs*int64(n)
+	for acc := range config.Accounts {
+		core.NoEnterUntil[acc] = max(core.NoEnterUntil[acc], untilMS)
+	}
+	return untilMS
+}
+
+func rpcPreFlight(status string) {
+	for account := range config.Accounts {
+		rpc.SendMsg(map[string]interface{}{
+			"type":    rpc.MsgTypeStatus,
+			"account": account,
+			"status":  status,
+		})
+	}
+}
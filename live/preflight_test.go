@@ -0,0 +1,121 @@
+package live
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/banbox/banbot/config"
+	"github.com/banbox/banbot/core"
+	"github.com/banbox/banbot/orm/ormo"
+	"github.com/banbox/banexg"
+	"github.com/banbox/banexg/errs"
+)
+
+// fakeExg returns fixed balances and positions 返回固定余额和持仓的交易所
+type fakeExg struct {
+	banexg.BanExchange
+	balances  *banexg.Balances
+	positions []*banexg.Position
+	posCalls  int
+}
+
+func (e *fakeExg) FetchBalance(params map[string]interface{}) (*banexg.Balances, *errs.Error) {
+	return e.balances, nil
+}
+
+func (e *fakeExg) FetchAccountPositions(symbols []string, params map[string]interface{}) ([]*banexg.Position, *errs.Error) {
+	e.posCalls += 1
+	return e.positions, nil
+}
+
+func findCheck(p *PreFlight, name string) *PreCheck {
+	for _, it := range p.Items {
+		if it.Name == name {
+			return it
+		}
+	}
+	return nil
+}
+
+func usePreFlightCfg(t *testing.T) {
+	oldStake, oldAmt, oldReal := config.StakeCurrency, config.StakeAmount, core.EnvReal
+	config.StakeCurrency, config.StakeAmount, core.EnvReal = []string{"USDT"}, 100, true
+	t.Cleanup(func() {
+		config.StakeCurrency, config.StakeAmount, core.EnvReal = oldStake, oldAmt, oldReal
+	})
+}
+
+func TestPreFlightSpot(t *testing.T) {
+	usePreFlightCfg(t)
+	exchange := &fakeExg{
+		balances: &banexg.Balances{Assets: map[string]*banexg.Asset{
+			"USDT": {Code: "USDT", Free: 1000},
+			"BTC":  {Code: "BTC", Free: 1},
+		}},
+		positions: []*banexg.Position{{Symbol: "BTC/USDT", Side: banexg.PosSideLong, Contracts: 1}},
+	}
+	p := &PreFlight{}
+	p.checkAccount("pf_spot", exchange, "okx", banexg.MarketSpot)
+	if exchange.posCalls > 0 || findCheck(p, "positions") != nil {
+		t.Errorf("positions should not be checked for spot")
+	}
+	if p.FailNum() != 0 {
+		t.Errorf("spot check should pass, got:\n%s", p.Report())
+	}
+	if it := findCheck(p, "balance"); it == nil || it.Level != CheckOk {
+		t.Errorf("balance should be ok, got %+v", it)
+	}
+}
+
+func TestCheckUnknownPos(t *testing.T) {
+	usePreFlightCfg(t)
+	acc := "pf_linear"
+	openOds, lock := ormo.GetOpenODs(acc)
+	lock.Lock()
+	openOds[1] = &ormo.InOutOrder{
+		IOrder: &ormo.IOrder{ID: 1, Symbol: "ETH/USDT:USDT", Status: ormo.InOutStatusFullEnter},
+		Enter:  &ormo.ExOrder{Filled: 2},
+	}
+	lock.Unlock()
+	defer func() {
+		lock.Lock()
+		delete(openOds, 1)
+		lock.Unlock()
+	}()
+	cases := []struct {
+		name      string
+		positions []*banexg.Position
+		level     string
+	}{
+		{"mapped", []*banexg.Position{{Symbol: "ETH/USDT:USDT", Side: banexg.PosSideLong, Contracts: 2}}, CheckOk},
+		{"dust", []*banexg.Position{{Symbol: "BTC/USDT:USDT", Side: banexg.PosSideLong, Contracts: core.AmtDust / 2}}, CheckOk},
+		{"larger", []*banexg.Position{{Symbol: "ETH/USDT:USDT", Side: banexg.PosSideLong, Contracts: 3}}, CheckFail},
+		{"other side", []*banexg.Position{{Symbol: "ETH/USDT:USDT", Side: banexg.PosSideShort, Contracts: 2}}, CheckFail},
+	}
+	for _, c := range cases {
+		p := &PreFlight{}
+		p.checkAccount(acc, &fakeExg{
+			balances:  &banexg.Balances{Assets: map[string]*banexg.Asset{"USDT": {Code: "USDT", Free: 1000}}},
+			positions: c.positions,
+		}, "okx", banexg.MarketLinear)
+		it := findCheck(p, "positions")
+		if it == nil || it.Level != c.level {
+			t.Errorf("%s: positions check %+v, want %s", c.name, it, c.level)
+		}
+	}
+}
+
+func TestPreFlightReport(t *testing.T) {
+	p := &PreFlight{}
+	p.add("", "clock", CheckOk, "skew: %d ms", 10)
+	p.add("acc1", "balance", CheckFail, "no free balance")
+	if p.FailNum() != 1 {
+		t.Errorf("FailNum = %d, want 1", p.FailNum())
+	}
+	report := p.Report()
+	for _, line := range []string{"[ok] * clock: skew: 10 ms", "[fail] acc1 balance: no free balance"} {
+		if !strings.Contains(report, line) {
+			t.Errorf("report missing %q:\n%s", line, report)
+		}
+	}
+}
@@ -61,7 +61,6 @@ func RunTradeClose(args []string) error {
 }
 
 func closeOrdersByPos(accMap map[string]bool, pairMap map[string]bool) error {
-	exchange := exg.Default
 	odType := banexg.OdTypeMarket
 	closeNum := 0
 	for account := range config.Accounts {
@@ -70,6 +69,8 @@ func closeOrdersByPos(accMap map[string]bool, pairMap map[string]bool) error {
 				continue
 			}
 		}
+		exchange := exg.Get(account)
+		_, market := config.GetAccVenue(account)
 		posList, err := exchange.FetchAccountPositions(nil, map[string]interface{}{
 			banexg.ParamAccount: account,
 		})
@@ -92,7 +93,7 @@ func closeOrdersByPos(accMap map[string]bool, pairMap map[string]bool) error {
 					banexg.ParamAccount:       account,
 					banexg.ParamClientOrderId: fmt.Sprintf("bancli_%v", rand.Intn(1000)),
 				}
-				if core.IsContract {
+				if banexg.IsContract(market) {
 					params[banexg.ParamPositionSide] = "LONG"
 					if isShort {
 						params[banexg.ParamPositionSide] = "SHORT"
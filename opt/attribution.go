@@ -0,0 +1,397 @@
+package opt
+
+import (
+	"bytes"
+	"cmp"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/banbox/banbot/btime"
+	"github.com/banbox/banbot/config"
+	"github.com/banbox/banbot/core"
+	"github.com/banbox/banbot/orm"
+	"github.com/banbox/banbot/orm/ormo"
+	"github.com/banbox/banexg"
+	"github.com/banbox/banexg/log"
+	utils2 "github.com/banbox/banexg/utils"
+	"github.com/olekukonko/tablewriter"
+	"go.uber.org/zap"
+	"gonum.org/v1/gonum/stat"
+)
+
+const (
+	RegimeUpTrend   = "uptrend"
+	RegimeDownTrend = "downtrend"
+	RegimeRange     = "range"
+	RegimeHighVol   = "high-vol"
+	RegimeUnknown   = "unknown"
+)
+
+const (
+	regimeTF       = "1d"
+	regimeLookback = 20  // bars for efficiency ratio 计算效率比的bar数
+	regimeAtrLen   = 14  // bars for average true range 计算平均真实波幅的bar数
+	regimeTrendER  = 0.3 // efficiency ratio above this is trending 效率比高于此值为趋势
+	regimeVolPct   = 0.8 // atr percentile above this is high volatility 波幅分位数高于此值为高波动
+	sideLong       = "long"
+	sideShort      = "short"
+	weekdayLayout  = "Mon"
+	hourLayout     = "15"
+	leverageSuffix = "x"
+)
+
+/*
+PnlParts
+Decomposition of the total profit: Net = Signal - Fee - Slippage - Funding
+总利润分解：Net = Signal - Fee - Slippage - Funding
+*/
+type PnlParts struct {
+	Signal   float64 `json:"signal"`   // Profit if filled at signal prices without costs 按信号价格成交且无成本时的利润
+	Fee      float64 `json:"fee"`      // Commission of enter and exit orders 入场和出场订单的手续费
+	Slippage float64 `json:"slippage"` // Cost of fill prices versus signal prices, negative if filled better 成交价相对信号价的成本，成交更优时为负
+	Funding  float64 `json:"funding"`  // Funding fee paid by positions 仓位支付的资金费
+	Net      float64 `json:"net"`      // Realized profit 实际利润
+}
+
+var holdBuckets = []struct {
+	Secs  int
+	Title string
+}{
+	{3600, "< 1h"},
+	{4 * 3600, "1h ~ 4h"},
+	{12 * 3600, "4h ~ 12h"},
+	{86400, "12h ~ 1d"},
+	{3 * 86400, "1d ~ 3d"},
+	{7 * 86400, "3d ~ 1w"},
+	{math.MaxInt, ">= 1w"},
+}
+
+func (r *BTResult) groupByHours(orders []*ormo.InOutOrder) {
+	groups := groupItems(orders, false, func(od *ormo.InOutOrder, i int) string {
+		return btime.ToDateStrLoc(od.RealEnterMS(), hourLayout)
+	})
+	slices.SortFunc(groups, func(a, b *RowItem) int {
+		return cmp.Compare(a.Title, b.Title)
+	})
+	r.HourGrps = groups
+}
+
+func textGroupHours(r *BTResult) string {
+	return printGroups(r.HourGrps, "Enter Hour", false, nil, nil)
+}
+
+func (r *BTResult) groupByWeekdays(orders []*ormo.InOutOrder) {
+	groups := groupItems(orders, false, func(od *ormo.InOutOrder, i int) string {
+		return btime.ToDateStrLoc(od.RealEnterMS(), weekdayLayout)
+	})
+	// start from monday 从周一开始
+	sortByTitles(groups, []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"})
+	r.WeekdayGrps = groups
+}
+
+func textGroupWeekdays(r *BTResult) string {
+	return printGroups(r.WeekdayGrps, "Enter Weekday", false, nil, nil)
+}
+
+func holdBucket(secs int) string {
+	for _, b := range holdBuckets {
+		if secs < b.Secs {
+			return b.Title
+		}
+	}
+	return holdBuckets[len(holdBuckets)-1].Title
+}
+
+func (r *BTResult) groupByHolds(orders []*ormo.InOutOrder) {
+	groups := groupItems(orders, false, func(od *ormo.InOutOrder, i int) string {
+		return holdBucket(max(0, int((od.RealExitMS()-od.RealEnterMS())/1000)))
+	})
+	titles := make([]string, 0, len(holdBuckets))
+	for _, b := range holdBuckets {
+		titles = append(titles, b.Title)
+	}
+	sortByTitles(groups, titles)
+	r.HoldGrps = groups
+}
+
+func textGroupHolds(r *BTResult) string {
+	return printGroups(r.HoldGrps, "Holding", false, nil, nil)
+}
+
+func (r *BTResult) groupBySides(orders []*ormo.InOutOrder) {
+	groups := groupItems(orders, true, func(od *ormo.InOutOrder, i int) string {
+		if od.Short {
+			return sideShort
+		}
+		return sideLong
+	})
+	sortByTitles(groups, []string{sideLong, sideShort})
+	r.SideGrps = groups
+}
+
+func textGroupSides(r *BTResult) string {
+	return printGroups(r.SideGrps, "Side", true, nil, nil)
+}
+
+func (r *BTResult) groupByLeverages(orders []*ormo.InOutOrder) {
+	groups := groupItems(orders, false, func(od *ormo.InOutOrder, i int) string {
+		return strconv.FormatFloat(od.Leverage, 'f', -1, 64) + leverageSuffix
+	})
+	slices.SortFunc(groups, func(a, b *RowItem) int {
+		return cmp.Compare(a.Orders[0].Leverage, b.Orders[0].Leverage)
+	})
+	r.LeverageGrps = groups
+}
+
+func textGroupLeverages(r *BTResult) string {
+	return printGroups(r.LeverageGrps, "Leverage", false, nil, nil)
+}
+
+/*
+groupByQuotes
+Group by the currency profits are settled in. Sum Profit is in the report currency, Quote Profit is in the quote itself
+按利润结算币种分组。Sum Profit为报告币种计价，Quote Profit为定价币本身计价
+*/
+func (r *BTResult) groupByQuotes(orders []*ormo.InOutOrder) {
+	groups := groupItems(orders, false, func(od *ormo.InOutOrder, i int) string {
+		return core.QuoteCode(od.Symbol)
+	})
+	slices.SortFunc(groups, func(a, b *RowItem) int {
+		return strings.Compare(a.Title, b.Title)
+	})
+	r.QuoteGrps = groups
+}
+
+func textGroupQuotes(r *BTResult) string {
+	return printGroups(r.QuoteGrps, "Quote", false, []string{"Quote Profit"}, makeQuoteProfits)
+}
+
+func makeQuoteProfits(orders []*ormo.InOutOrder) []string {
+	var total float64
+	for _, od := range orders {
+		total += od.Profit
+	}
+	return []string{strconv.FormatFloat(total, 'f', 6, 64)}
+}
+
+/*
+groupByRegimes
+Group orders by the market regime of the benchmark symbol on the last finished day before entering
+按入场前最后一个完成日基准品种的市场状态分组订单
+*/
+func (r *BTResult) groupByRegimes(orders []*ormo.InOutOrder) {
+	startMS, endMS := orders[0].RealEnterMS(), orders[0].RealEnterMS()
+	for _, od := range orders {
+		startMS = min(startMS, od.RealEnterMS())
+		endMS = max(endMS, od.RealEnterMS())
+	}
+	regimes := loadRegimes(startMS, endMS)
+	if len(regimes) == 0 {
+		r.RegimeGrps = nil
+		return
+	}
+	tfMSecs := int64(utils2.TFToSecs(regimeTF) * 1000)
+	groups := groupItems(orders, false, func(od *ormo.InOutOrder, i int) string {
+		label, ok := regimes[utils2.AlignTfMSecs(od.RealEnterMS(), tfMSecs)]
+		if !ok {
+			return RegimeUnknown
+		}
+		return label
+	})
+	sortByTitles(groups, []string{RegimeUpTrend, RegimeDownTrend, RegimeRange, RegimeHighVol, RegimeUnknown})
+	r.RegimeGrps = groups
+}
+
+func textGroupRegimes(r *BTResult) string {
+	if len(r.RegimeGrps) == 0 {
+		return ""
+	}
+	return printGroups(r.RegimeGrps, "Regime", false, []string{"Enter Tags", "Exit Tags"}, makeEnterExits)
+}
+
+/*
+getRegimeSymbol
+The benchmark symbol for market regimes, defaults to BTC priced in the first stake currency
+市场状态的基准品种，默认为以第一个定价币计价的BTC
+*/
+func getRegimeSymbol() string {
+	if config.RegimeSymbol != "" {
+		return config.RegimeSymbol
+	}
+	if len(config.StakeCurrency) == 0 {
+		return ""
+	}
+	quote := config.StakeCurrency[0]
+	if core.Market == banexg.MarketLinear {
+		return "BTC/" + quote + ":" + quote
+	}
+	return "BTC/" + quote
+}
+
+/*
+loadRegimes
+Load daily klines of the benchmark symbol and return regime labels keyed by the start time of the day they apply to
+加载基准品种的日K线，返回市场状态标签，键为标签适用日期的开始时间
+*/
+func loadRegimes(startMS, endMS int64) map[int64]string {
+	symbol := getRegimeSymbol()
+	if symbol == "" {
+		return nil
+	}
+	exs, err := orm.GetExSymbolCur(symbol)
+	if err != nil {
+		log.Warn("regime symbol not found, skip regime report", zap.String("symbol", symbol), zap.Error(err))
+		return nil
+	}
+	tfMSecs := int64(utils2.TFToSecs(regimeTF) * 1000)
+	fetchStart := utils2.AlignTfMSecs(startMS, tfMSecs) - int64(regimeLookback+1)*tfMSecs
+	bars, _, err := getOHLCVNoLack(exs, regimeTF, fetchStart, endMS+tfMSecs, tfMSecs)
+	if err != nil {
+		log.Warn("load regime klines fail", zap.String("symbol", symbol), zap.Error(err))
+		return nil
+	}
+	labels := labelRegimes(bars)
+	res := make(map[int64]string, len(labels))
+	for i, label := range labels {
+		// the label of a finished day applies to the next day 已完成日的标签用于下一日
+		res[bars[i].Time+tfMSecs] = label
+	}
+	return res
+}
+
+/*
+labelRegimes
+Label each bar as high-vol when its ATR ratio is in the top percentile, otherwise uptrend/downtrend
+when the efficiency ratio is high, else range
+当ATR比率位于最高分位时标记为高波动，否则效率比较高时为上升/下降趋势，其余为震荡
+*/
+func labelRegimes(bars []*banexg.Kline) []string {
+	labels := make([]string, len(bars))
+	atrPcts := make([]float64, len(bars))
+	var valid []float64
+	for i, b := range bars {
+		labels[i] = RegimeUnknown
+		atrPcts[i] = math.NaN()
+		if i < regimeAtrLen || b.Close <= 0 {
+			continue
+		}
+		var sumTR float64
+		for j := i - regimeAtrLen + 1; j <= i; j++ {
+			prevClose := bars[j-1].Close
+			sumTR += max(bars[j].High-bars[j].Low, math.Abs(bars[j].High-prevClose), math.Abs(bars[j].Low-prevClose))
+		}
+		atrPcts[i] = sumTR / regimeAtrLen / b.Close
+		valid = append(valid, atrPcts[i])
+	}
+	if len(valid) == 0 {
+		return labels
+	}
+	slices.Sort(valid)
+	volLimit := stat.Quantile(regimeVolPct, stat.Empirical, valid, nil)
+	for i := regimeLookback; i < len(bars); i++ {
+		if math.IsNaN(atrPcts[i]) {
+			continue
+		}
+		if atrPcts[i] > volLimit {
+			labels[i] = RegimeHighVol
+			continue
+		}
+		change := bars[i].Close - bars[i-regimeLookback].Close
+		var path float64
+		for j := i - regimeLookback + 1; j <= i; j++ {
+			path += math.Abs(bars[j].Close - bars[j-1].Close)
+		}
+		if path > 0 && math.Abs(change)/path >= regimeTrendER {
+			if change > 0 {
+				labels[i] = RegimeUpTrend
+			} else {
+				labels[i] = RegimeDownTrend
+			}
+		} else {
+			labels[i] = RegimeRange
+		}
+	}
+	return labels
+}
+
+/*
+calcOdPnlParts
+Fee, slippage and funding of an order. Slippage is measured against InitPrice for entering and the price
+recorded when the exit was requested, positive values are costs
+订单的手续费、滑点和资金费。入场滑点相对InitPrice，出场滑点相对请求平仓时记录的价格，正值为成本
+*/
+func calcOdPnlParts(od *ormo.InOutOrder) (float64, float64, float64) {
+	var fee, slip float64
+	dirt := float64(1)
+	if od.Short {
+		dirt = -1
+	}
+	if od.Enter != nil {
+		fee += od.Enter.Fee
+		if od.InitPrice > 0 && od.Enter.Average > 0 {
+			slip += (od.Enter.Average - od.InitPrice) * od.Enter.Filled * dirt
+		}
+	}
+	if od.Exit != nil {
+		fee += od.Exit.Fee
+		sigPrice := od.GetInfoFloat64(ormo.OdInfoExitSignal)
+		if sigPrice > 0 && od.Exit.Average > 0 {
+			slip += (sigPrice - od.Exit.Average) * od.Exit.Filled * dirt
+		}
+	}
+	return fee, slip, od.GetInfoFloat64(ormo.OdInfoFunding)
+}
+
+func (r *BTResult) calcPnlParts(orders []*ormo.InOutOrder) {
+	res := &PnlParts{}
+	for _, od := range orders {
+		fee, slip, funding := calcOdPnlParts(od)
+		rate := od.ReportRate()
+		res.Fee += fee * rate
+		res.Slippage += slip * rate
+		res.Funding += funding * rate
+		res.Net += od.Profit * rate
+	}
+	res.Signal = res.Net + res.Fee + res.Slippage + res.Funding
+	r.PnlParts = res
+}
+
+func textPnlParts(r *BTResult) string {
+	p := r.PnlParts
+	if p == nil {
+		return ""
+	}
+	var b bytes.Buffer
+	table := tablewriter.NewWriter(&b)
+	table.SetHeader([]string{"Item", "Value", "Of Signal %"})
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	table.SetAlignment(tablewriter.ALIGN_RIGHT)
+	rows := []struct {
+		Title string
+		Val   float64
+	}{
+		{"Signal Profit", p.Signal},
+		{"- Fee", p.Fee},
+		{"- Slippage", p.Slippage},
+		{"- Funding", p.Funding},
+		{"= Net Profit", p.Net},
+	}
+	for _, row := range rows {
+		pct := "-"
+		if p.Signal != 0 {
+			pct = strconv.FormatFloat(row.Val*100/math.Abs(p.Signal), 'f', 1, 64) + "%"
+		}
+		table.Append([]string{row.Title, strconv.FormatFloat(row.Val, 'f', 2, 64), pct})
+	}
+	table.Render()
+	return b.String()
+}
+
+func sortByTitles(groups []*RowItem, titles []string) {
+	slices.SortFunc(groups, func(a, b *RowItem) int {
+		return slices.Index(titles, a.Title) - slices.Index(titles, b.Title)
+	})
+}
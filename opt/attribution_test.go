@@ -0,0 +1,102 @@
+package opt
+
+import (
+	"math"
+	"testing"
+
+	"github.com/banbox/banbot/orm/ormo"
+	"github.com/banbox/banexg"
+)
+
+func TestHoldBucket(t *testing.T) {
+	cases := map[int]string{
+		0:               "< 1h",
+		3599:            "< 1h",
+		3600:            "1h ~ 4h",
+		4*3600 + 1:      "4h ~ 12h",
+		86399:           "12h ~ 1d",
+		86400:           "1d ~ 3d",
+		7*86400 - 1:     "3d ~ 1w",
+		7 * 86400:       ">= 1w",
+		math.MaxInt - 1: ">= 1w",
+	}
+	for secs, want := range cases {
+		if res := holdBucket(secs); res != want {
+			t.Errorf("holdBucket(%d) expect %s, got %s", secs, want, res)
+		}
+	}
+}
+
+func TestLabelRegimes(t *testing.T) {
+	bars := make([]*banexg.Kline, 150)
+	for i := range bars {
+		var c, rng float64
+		switch {
+		case i < 40:
+			c, rng = float64(100+i), 1
+		case i < 80:
+			c, rng = float64(139-(i-39)), 1
+		case i < 120:
+			c, rng = float64(100+i%2), 1
+		default:
+			c, rng = float64(100+i%2), 15
+		}
+		bars[i] = &banexg.Kline{Time: int64(i) * 86400000, Open: c, High: c + rng, Low: c - rng, Close: c}
+	}
+	labels := labelRegimes(bars)
+	cases := map[int]string{
+		5:   RegimeUnknown,
+		19:  RegimeUnknown,
+		35:  RegimeUpTrend,
+		75:  RegimeDownTrend,
+		115: RegimeRange,
+		125: RegimeHighVol,
+		149: RegimeHighVol,
+	}
+	for i, want := range cases {
+		if labels[i] != want {
+			t.Errorf("bar %d expect %s, got %s", i, want, labels[i])
+		}
+	}
+	for _, l := range labelRegimes(bars[:10]) {
+		if l != RegimeUnknown {
+			t.Errorf("too few bars should be unknown, got %s", l)
+		}
+	}
+}
+
+func TestCalcOdPnlParts(t *testing.T) {
+	long := &ormo.InOutOrder{
+		IOrder: &ormo.IOrder{InitPrice: 100},
+		Enter:  &ormo.ExOrder{Average: 101, Filled: 2, Fee: 0.2},
+		Exit:   &ormo.ExOrder{Average: 109, Filled: 2, Fee: 0.3},
+		Info:   map[string]interface{}{ormo.OdInfoExitSignal: 110.0, ormo.OdInfoFunding: 0.5},
+	}
+	short := &ormo.InOutOrder{
+		IOrder: &ormo.IOrder{InitPrice: 100, Short: true},
+		Enter:  &ormo.ExOrder{Average: 99, Filled: 1, Fee: 0.1},
+		Exit:   &ormo.ExOrder{Average: 91, Filled: 1, Fee: 0.1},
+		Info:   map[string]interface{}{ormo.OdInfoExitSignal: 90.0, ormo.OdInfoFunding: -0.2},
+	}
+	// no signal price, slippage of exit is unknown 无信号价格时出场滑点未知
+	noSig := &ormo.InOutOrder{
+		IOrder: &ormo.IOrder{},
+		Enter:  &ormo.ExOrder{Average: 50, Filled: 1, Fee: 0.05},
+		Exit:   &ormo.ExOrder{Average: 55, Filled: 1},
+		Info:   map[string]interface{}{},
+	}
+	cases := []struct {
+		od                 *ormo.InOutOrder
+		fee, slip, funding float64
+	}{
+		{long, 0.5, 4, 0.5},
+		{short, 0.2, 2, -0.2},
+		{noSig, 0.05, 0, 0},
+	}
+	for i, c := range cases {
+		fee, slip, funding := calcOdPnlParts(c.od)
+		if math.Abs(fee-c.fee) > 1e-9 || math.Abs(slip-c.slip) > 1e-9 || math.Abs(funding-c.funding) > 1e-9 {
+			t.Errorf("case %d expect %v %v %v, got %v %v %v", i, c.fee, c.slip, c.funding, fee, slip, funding)
+		}
+	}
+}
@@ -51,7 +51,7 @@ func NewBackTestLite(isOpt bool, onBar data.FnPairKline, getEnd data.FnGetInt64,
 	}
 	biz.InitFakeWallets()
 	wallets := biz.GetWallets(config.DefAcc)
-	b.TotalInvest = wallets.TotalLegal(nil, false)
+	b.TotalInvest = biz.LegalToReport(wallets.TotalLegal(nil, false))
 	if onBar == nil {
 		onBar = func(bar *orm.InfoKline) {
 			b.FeedKLine(bar)
@@ -79,6 +79,7 @@ func (b *BackTestLite) FeedKLine(bar *orm.InfoKline) bool {
 	if curTime > b.lastTime {
 		b.lastTime = curTime
 		b.TimeNum += 1
+		biz.UpdateFxRates(curTime)
 		if !bar.IsWarmUp {
 			core.CheckWallets = true
 		}
@@ -106,7 +107,7 @@ func (b *BackTestLite) onLiquidation(symbol string) {
 		oldVal := wallets.TotalLegal(nil, false)
 		biz.InitFakeWallets(symbol)
 		newVal := wallets.TotalLegal(nil, false)
-		b.TotalInvest += newVal - oldVal
+		b.TotalInvest += biz.LegalToReport(newVal - oldVal)
 		log.Warn(fmt.Sprintf("wallet %s BOMB at %s, reset wallet and continue..", symbol, date))
 	} else {
 		log.Warn(fmt.Sprintf("wallet %s BOMB at %s, exit", symbol, date))
@@ -127,8 +128,9 @@ func (b *BackTestLite) orderCB(order *ormo.InOutOrder, isEnter bool) {
 		wallets.TryUpdateStakePctAmt()
 		if config.DrawBalanceOver > 0 {
 			quoteLegal := wallets.AvaLegal(config.StakeCurrency)
-			if quoteLegal > config.DrawBalanceOver {
-				wallets.WithdrawLegal(quoteLegal-config.DrawBalanceOver, config.StakeCurrency)
+			overLegal := config.DrawBalanceOver * core.LegalRate(config.ReportCurrency)
+			if overLegal > 0 && quoteLegal > overLegal {
+				wallets.WithdrawLegal(quoteLegal-overLegal, config.StakeCurrency)
 			}
 		}
 	}
@@ -184,7 +186,18 @@ func (b *BackTest) Init() *errs.Error {
 	b.PBar.SetProgress("listMs", 1)
 	// 交易对初始化
 	err = RefreshPairJobs(b.dp, !b.isOpt, true, b.PBar)
-	return err
+	if err != nil {
+		return err
+	}
+	// Conversion rates of non-fiat quotes, investment is valued again with them
+	// 非法币定价币的汇率，加载后重新计算投入价值
+	err = biz.InitFxRates()
+	if err != nil {
+		return err
+	}
+	wallets := biz.GetWallets(config.DefAcc)
+	b.TotalInvest = biz.LegalToReport(wallets.TotalLegal(nil, false))
+	return nil
 }
 
 func (b *BackTest) FeedKLine(bar *orm.InfoKline) {
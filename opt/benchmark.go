@@ -0,0 +1,251 @@
+package opt
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/banbox/banbot/btime"
+	"github.com/banbox/banbot/config"
+	"github.com/banbox/banbot/core"
+	"github.com/banbox/banbot/orm"
+	"github.com/banbox/banbot/orm/ormo"
+	"github.com/banbox/banbot/utils"
+	"github.com/banbox/banexg"
+	"github.com/banbox/banexg/errs"
+	"github.com/banbox/banexg/log"
+	utils2 "github.com/banbox/banexg/utils"
+	"github.com/olekukonko/tablewriter"
+	"go.uber.org/zap"
+	"gonum.org/v1/gonum/stat"
+)
+
+// BenchBasket benchmark of equal-weight buy-and-hold of all traded pairs 所有交易过品种的等权买入持有基准
+const BenchBasket = "basket"
+
+/*
+BenchResult
+Buy-and-hold equity of a benchmark aligned with Plots.Labels, and statistics of the strategy relative to it
+与Plots.Labels对齐的基准买入持有权益，及策略相对基准的统计指标
+*/
+type BenchResult struct {
+	Name        string    `json:"name"`
+	Equity      []float64 `json:"equity"`
+	ProfitPct   float64   `json:"profitPct"`   // Return of the benchmark 基准收益率
+	Alpha       float64   `json:"alpha"`       // Annualized excess return not explained by beta 年化的超出beta部分的收益
+	Beta        float64   `json:"beta"`        // Sensitivity to benchmark returns 对基准收益的敏感度
+	InfoRatio   float64   `json:"infoRatio"`   // Annualized active return / tracking error 年化主动收益/跟踪误差
+	TrackError  float64   `json:"trackError"`  // Annualized std of active returns 年化主动收益标准差
+	UpCapture   float64   `json:"upCapture"`   // Avg return / benchmark avg return when benchmark rises 基准上涨时平均收益/基准平均收益
+	DownCapture float64   `json:"downCapture"` // Avg return / benchmark avg return when benchmark falls 基准下跌时平均收益/基准平均收益
+	Correlation float64   `json:"correlation"`
+}
+
+/*
+calcBenchmarks
+Compute equity and relative statistics for each benchmark in config.Benchmarks
+为config.Benchmarks中的每个基准计算权益及相对统计指标
+*/
+func (r *BTResult) calcBenchmarks(orders []*ormo.InOutOrder) {
+	r.Benchmarks = nil
+	if len(config.Benchmarks) == 0 || len(r.Plots.Real) < 3 {
+		return
+	}
+	times := make([]int64, 0, len(r.Plots.Labels))
+	for _, label := range r.Plots.Labels {
+		timeMS, err_ := btime.ParseTimeMSBy(core.DefaultDateFmt, label)
+		if err_ != nil {
+			log.Warn("parse plot label fail, skip benchmarks", zap.String("label", label), zap.Error(err_))
+			return
+		}
+		times = append(times, timeMS)
+	}
+	for _, name := range config.Benchmarks {
+		equity, err := calcBenchEquity(name, orders, times, r.Plots.Real[0])
+		if err != nil {
+			log.Warn("calc benchmark fail", zap.String("name", name), zap.Error(err))
+			continue
+		}
+		res := calcBenchStats(r.Plots.Real, equity, times)
+		res.Name = name
+		r.Benchmarks = append(r.Benchmarks, res)
+	}
+}
+
+/*
+calcBenchEquity
+Buy-and-hold equity of a symbol or the equal-weight basket at each time, starting from baseVal.
+Pairs listed later are held as cash before their first price.
+从baseVal开始，每个时间点的品种或等权组合的买入持有权益。晚上市的品种在首个价格前按现金持有。
+*/
+func calcBenchEquity(name string, orders []*ormo.InOutOrder, times []int64, baseVal float64) ([]float64, *errs.Error) {
+	var symbols []*orm.ExSymbol
+	if name == BenchBasket {
+		sids := make(map[int64]bool)
+		for _, od := range orders {
+			if sids[od.Sid] {
+				continue
+			}
+			sids[od.Sid] = true
+			if exs := orm.GetSymbolByID(int32(od.Sid)); exs != nil {
+				symbols = append(symbols, exs)
+			}
+		}
+		if len(symbols) == 0 {
+			return nil, errs.NewMsg(errs.CodeParamInvalid, "no traded pairs for basket benchmark")
+		}
+	} else {
+		exs, err := orm.GetExSymbolCur(name)
+		if err != nil {
+			return nil, err
+		}
+		symbols = append(symbols, exs)
+	}
+	tf := benchTimeFrame(times)
+	tfMSecs := int64(utils2.TFToSecs(tf) * 1000)
+	startMS := utils2.AlignTfMSecs(times[0], tfMSecs) - tfMSecs
+	endMS := times[len(times)-1] + tfMSecs
+	ratios := make([]float64, len(times))
+	for _, exs := range symbols {
+		bars, _, err := getOHLCVNoLack(exs, tf, startMS, endMS, tfMSecs)
+		if err != nil {
+			return nil, err
+		}
+		var first float64
+		for i, t := range times {
+			price := benchPriceAt(bars, t, tfMSecs)
+			if first == 0 && price > 0 {
+				first = price
+			}
+			if first == 0 || price <= 0 {
+				ratios[i] += 1
+			} else {
+				ratios[i] += price / first
+			}
+		}
+	}
+	equity := make([]float64, len(times))
+	for i, v := range ratios {
+		equity[i] = baseVal * v / float64(len(symbols))
+	}
+	return equity, nil
+}
+
+/*
+benchTimeFrame
+The largest common timeframe not exceeding the interval of plot points
+不超过绘图点间隔的最大常用时间周期
+*/
+func benchTimeFrame(times []int64) string {
+	stepMS := (times[len(times)-1] - times[0]) / int64(len(times)-1)
+	res := "1m"
+	for _, tf := range []string{"5m", "15m", "1h", "1d"} {
+		if int64(utils2.TFToSecs(tf)*1000) <= stepMS {
+			res = tf
+		}
+	}
+	return res
+}
+
+// benchPriceAt close price of the last finished bar at timeMS 在timeMS时最后一个已完成bar的收盘价
+func benchPriceAt(bars []*banexg.Kline, timeMS, tfMSecs int64) float64 {
+	idx := sort.Search(len(bars), func(i int) bool {
+		return bars[i].Time+tfMSecs > timeMS
+	})
+	if idx == 0 {
+		if len(bars) > 0 && bars[0].Time <= timeMS {
+			return bars[0].Open
+		}
+		return 0
+	}
+	return bars[idx-1].Close
+}
+
+/*
+calcBenchStats
+Relative statistics of the strategy equity against the benchmark equity, annualized by the interval of times
+策略权益相对基准权益的统计指标，按时间间隔年化
+*/
+func calcBenchStats(real, bench []float64, times []int64) *BenchResult {
+	res := &BenchResult{Equity: bench}
+	if bench[0] != 0 {
+		res.ProfitPct = (bench[len(bench)-1]/bench[0] - 1) * 100
+	}
+	var rs, rb, active []float64
+	var upS, upB, downS, downB []float64
+	for i := 1; i < len(real) && i < len(bench); i++ {
+		if real[i-1] <= 0 || bench[i-1] <= 0 {
+			continue
+		}
+		s, b := real[i]/real[i-1]-1, bench[i]/bench[i-1]-1
+		rs = append(rs, s)
+		rb = append(rb, b)
+		active = append(active, s-b)
+		if b > 0 {
+			upS, upB = append(upS, s), append(upB, b)
+		} else if b < 0 {
+			downS, downB = append(downS, s), append(downB, b)
+		}
+	}
+	if len(rs) < 2 {
+		return res
+	}
+	stepSecs := float64(times[len(times)-1]-times[0]) / 1000 / float64(len(times)-1)
+	periods := float64(utils2.TFToSecs("1y")) / stepSecs
+	varB := stat.Variance(rb, nil)
+	if varB > 0 {
+		res.Beta = stat.Covariance(rs, rb, nil) / varB
+	}
+	res.Alpha = (stat.Mean(rs, nil) - res.Beta*stat.Mean(rb, nil)) * periods
+	res.TrackError = stat.StdDev(active, nil) * math.Sqrt(periods)
+	if res.TrackError > 0 {
+		res.InfoRatio = stat.Mean(active, nil) * periods / res.TrackError
+	}
+	if len(upB) > 0 {
+		res.UpCapture = stat.Mean(upS, nil) / stat.Mean(upB, nil)
+	}
+	if len(downB) > 0 {
+		res.DownCapture = stat.Mean(downS, nil) / stat.Mean(downB, nil)
+	}
+	res.Correlation = utils.NanInfTo(stat.Correlation(rs, rb, nil), 0)
+	return res
+}
+
+func textBenchmarks(r *BTResult) string {
+	if len(r.Benchmarks) == 0 {
+		return ""
+	}
+	var b bytes.Buffer
+	table := tablewriter.NewWriter(&b)
+	table.SetHeader([]string{"Benchmark", "Return %", "Alpha", "Beta", "Info Ratio", "Tracking Err", "Up/Down Capture", "Corr"})
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	table.SetAlignment(tablewriter.ALIGN_RIGHT)
+	fmtNum := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', 2, 64)
+	}
+	for _, it := range r.Benchmarks {
+		table.Append([]string{
+			it.Name,
+			strconv.FormatFloat(it.ProfitPct, 'f', 1, 64) + "%",
+			fmtNum(it.Alpha),
+			fmtNum(it.Beta),
+			fmtNum(it.InfoRatio),
+			fmtNum(it.TrackError),
+			fmtNum(it.UpCapture) + " / " + fmtNum(it.DownCapture),
+			fmtNum(it.Correlation),
+		})
+	}
+	table.Render()
+	return b.String()
+}
+
+// benchChartDs datasets of benchmark equities for assets.html 用于assets.html的基准权益数据集
+func (r *BTResult) benchChartDs() []*ChartDs {
+	res := make([]*ChartDs, 0, len(r.Benchmarks))
+	for _, it := range r.Benchmarks {
+		res = append(res, &ChartDs{Label: "Bench:" + it.Name, Data: it.Equity})
+	}
+	return res
+}
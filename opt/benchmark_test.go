@@ -0,0 +1,134 @@
+package opt
+
+import (
+	"math"
+	"testing"
+
+	"github.com/banbox/banexg"
+	utils2 "github.com/banbox/banexg/utils"
+	"gonum.org/v1/gonum/stat"
+)
+
+func TestBenchPriceAt(t *testing.T) {
+	tfMSecs := int64(60000)
+	bars := []*banexg.Kline{
+		{Time: 0, Open: 10, Close: 11},
+		{Time: 60000, Open: 11, Close: 12},
+		{Time: 180000, Open: 12, Close: 13},
+	}
+	cases := []struct {
+		timeMS int64
+		price  float64
+	}{
+		{-1, 0},
+		{0, 10},
+		{59999, 10},
+		{60000, 11},
+		{150000, 12},
+		{239999, 12},
+		{240000, 13},
+		{1e9, 13},
+	}
+	for _, c := range cases {
+		if res := benchPriceAt(bars, c.timeMS, tfMSecs); res != c.price {
+			t.Errorf("price at %d expect %v, got %v", c.timeMS, c.price, res)
+		}
+	}
+	if res := benchPriceAt(nil, 0, tfMSecs); res != 0 {
+		t.Errorf("price of empty bars expect 0, got %v", res)
+	}
+}
+
+func equityByReturns(start float64, rets []float64) []float64 {
+	res := []float64{start}
+	for _, r := range rets {
+		res = append(res, res[len(res)-1]*(1+r))
+	}
+	return res
+}
+
+func TestCalcBenchStats(t *testing.T) {
+	dayMS := int64(86400000)
+	benchRets := []float64{0.01, -0.02, 0.03, -0.01, 0.02}
+	times := make([]int64, len(benchRets)+1)
+	for i := range times {
+		times[i] = int64(i) * dayMS
+	}
+	periods := float64(utils2.TFToSecs("1y")) / 86400
+	bench := equityByReturns(100, benchRets)
+	doubleRets := make([]float64, len(benchRets))
+	for i, r := range benchRets {
+		doubleRets[i] = r * 2
+	}
+	benchStd := stat.StdDev(benchRets, nil)
+	cases := []struct {
+		name string
+		real []float64
+		want BenchResult
+	}{
+		{"same returns", bench,
+			BenchResult{Beta: 1, Alpha: 0, TrackError: 0, InfoRatio: 0, UpCapture: 1, DownCapture: 1, Correlation: 1}},
+		{"double returns", equityByReturns(1000, doubleRets),
+			BenchResult{Beta: 2, Alpha: 0, TrackError: benchStd * math.Sqrt(periods),
+				InfoRatio: stat.Mean(benchRets, nil) * periods / (benchStd * math.Sqrt(periods)),
+				UpCapture: 2, DownCapture: 2, Correlation: 1}},
+		{"flat", []float64{1000, 1000, 1000, 1000, 1000, 1000},
+			BenchResult{Beta: 0, Alpha: 0, TrackError: benchStd * math.Sqrt(periods),
+				InfoRatio: -stat.Mean(benchRets, nil) * periods / (benchStd * math.Sqrt(periods)),
+				UpCapture: 0, DownCapture: 0, Correlation: 0}},
+	}
+	for _, c := range cases {
+		res := calcBenchStats(c.real, bench, times)
+		checks := []struct {
+			field     string
+			val, want float64
+		}{
+			{"beta", res.Beta, c.want.Beta},
+			{"alpha", res.Alpha, c.want.Alpha},
+			{"trackError", res.TrackError, c.want.TrackError},
+			{"infoRatio", res.InfoRatio, c.want.InfoRatio},
+			{"upCapture", res.UpCapture, c.want.UpCapture},
+			{"downCapture", res.DownCapture, c.want.DownCapture},
+			{"correlation", res.Correlation, c.want.Correlation},
+		}
+		for _, ck := range checks {
+			if math.Abs(ck.val-ck.want) > 1e-6 {
+				t.Errorf("%s: %s expect %v, got %v", c.name, ck.field, ck.want, ck.val)
+			}
+		}
+	}
+	// constant excess return is all alpha 恒定超额收益全部为alpha
+	excessRets := make([]float64, len(benchRets))
+	for i, r := range benchRets {
+		excessRets[i] = r + 0.001
+	}
+	res := calcBenchStats(equityByReturns(1000, excessRets), bench, times)
+	if math.Abs(res.Beta-1) > 1e-9 || math.Abs(res.Alpha-0.001*periods) > 1e-6 {
+		t.Errorf("excess: beta %v alpha %v, expect 1, %v", res.Beta, res.Alpha, 0.001*periods)
+	}
+	wantPct := (bench[len(bench)-1]/bench[0] - 1) * 100
+	if math.Abs(res.ProfitPct-wantPct) > 1e-9 {
+		t.Errorf("bench profit pct expect %v, got %v", wantPct, res.ProfitPct)
+	}
+}
+
+func TestCalcBenchStatsAlign(t *testing.T) {
+	times := []int64{0, 1, 2, 3, 4}
+	// periods with zero equity before are skipped 前值为0的区间被跳过
+	real := []float64{0, 100, 110, 99, 108.9}
+	bench := []float64{50, 100, 110, 99, 108.9}
+	res := calcBenchStats(real, bench, times)
+	if math.Abs(res.Beta-1) > 1e-9 || math.Abs(res.Correlation-1) > 1e-9 {
+		t.Errorf("aligned returns should match, beta %v corr %v", res.Beta, res.Correlation)
+	}
+	// bench shorter than real, only common periods are used 基准短于策略时仅使用共同区间
+	res = calcBenchStats([]float64{100, 110, 99, 200}, []float64{100, 110, 99}, []int64{0, 1, 2, 3})
+	if math.Abs(res.Beta-1) > 1e-9 {
+		t.Errorf("common periods should match, beta %v", res.Beta)
+	}
+	// less than 2 returns: no statistics 少于2个收益时无统计
+	res = calcBenchStats([]float64{0, 0, 100}, []float64{100, 101, 102}, []int64{0, 1, 2})
+	if res.Beta != 0 || res.Alpha != 0 || math.Abs(res.ProfitPct-2) > 1e-9 {
+		t.Errorf("too few returns expect empty stats, got %+v", res)
+	}
+}
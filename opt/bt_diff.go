@@ -0,0 +1,219 @@
+package opt
+
+import (
+	"bytes"
+	"flag"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/banbox/banbot/config"
+	"github.com/banbox/banexg/errs"
+	"github.com/banbox/banexg/log"
+	"github.com/olekukonko/tablewriter"
+	"go.uber.org/zap"
+)
+
+/*
+diffMetricDirs
+Metrics which can be compared by `bt diff`: 1 means higher is better, -1 lower is better, 0 any change counts
+可被`bt diff`比较的指标：1表示越高越好，-1表示越低越好，0表示任何变化都计入
+*/
+var diffMetricDirs = map[string]int{
+	"totProfit":       1,
+	"totProfitPct":    1,
+	"finBalance":      1,
+	"winRatePct":      1,
+	"sharpeRatio":     1,
+	"sortinoRatio":    1,
+	"score":           1,
+	"maxDrawDownPct":  -1,
+	"showDrawDownPct": -1,
+	"maxDrawDownVal":  -1,
+	"maxFundOccup":    -1,
+	"totFee":          -1,
+	"orderNum":        0,
+}
+
+/*
+DiffRule
+Allowed regression of a metric, parsed from `metric=tolerance` or `metric=tolerance%` (relative to the base value)
+指标允许的退化幅度，从`metric=tolerance`或`metric=tolerance%`（相对基准值）解析
+*/
+type DiffRule struct {
+	Metric    string
+	Tolerance float64
+	Relative  bool
+}
+
+type MetricDiff struct {
+	Metric  string
+	Base    float64
+	Cur     float64
+	Limit   float64 // Allowed regression, NaN if no rule 允许的退化，无规则时为NaN
+	Regress bool
+}
+
+func (m *BtMetrics) values() map[string]float64 {
+	return map[string]float64{
+		"totProfit":       m.TotProfit,
+		"totProfitPct":    m.TotProfitPct,
+		"finBalance":      m.FinBalance,
+		"winRatePct":      m.WinRatePct,
+		"sharpeRatio":     m.SharpeRatio,
+		"sortinoRatio":    m.SortinoRatio,
+		"score":           m.Score,
+		"maxDrawDownPct":  m.MaxDrawDownPct,
+		"showDrawDownPct": m.ShowDrawDownPct,
+		"maxDrawDownVal":  m.MaxDrawDownVal,
+		"maxFundOccup":    m.MaxFundOccup,
+		"totFee":          m.TotFee,
+		"orderNum":        float64(m.OrderNum),
+	}
+}
+
+func ParseDiffRule(text string) (*DiffRule, *errs.Error) {
+	name, tolText, found := strings.Cut(text, "=")
+	name, tolText = strings.TrimSpace(name), strings.TrimSpace(tolText)
+	if !found || name == "" || tolText == "" {
+		return nil, errs.NewMsg(errs.CodeParamInvalid, "invalid rule, should be metric=tolerance: %s", text)
+	}
+	if _, ok := diffMetricDirs[name]; !ok {
+		return nil, errs.NewMsg(errs.CodeParamInvalid, "unsupported metric: %s", name)
+	}
+	res := &DiffRule{Metric: name}
+	if strings.HasSuffix(tolText, "%") {
+		res.Relative = true
+		tolText = strings.TrimSuffix(tolText, "%")
+	}
+	tol, err_ := strconv.ParseFloat(tolText, 64)
+	if err_ != nil || tol < 0 {
+		return nil, errs.NewMsg(errs.CodeParamInvalid, "invalid tolerance: %s", text)
+	}
+	if res.Relative {
+		tol /= 100
+	}
+	res.Tolerance = tol
+	return res, nil
+}
+
+/*
+DiffBtReports
+Compare metrics of two results, a metric regresses when it gets worse than the tolerance of its rule
+比较两个结果的指标，指标变差超过规则的容忍度时视为退化
+*/
+func DiffBtReports(base, cur *BtReport, rules []*DiffRule) ([]*MetricDiff, bool) {
+	ruleMap := make(map[string]*DiffRule)
+	for _, r := range rules {
+		ruleMap[r.Metric] = r
+	}
+	baseVals, curVals := base.Metrics.values(), cur.Metrics.values()
+	names := make([]string, 0, len(diffMetricDirs))
+	for name := range diffMetricDirs {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	res := make([]*MetricDiff, 0, len(names))
+	anyRegress := false
+	for _, name := range names {
+		item := &MetricDiff{Metric: name, Base: baseVals[name], Cur: curVals[name], Limit: math.NaN()}
+		if rule, ok := ruleMap[name]; ok {
+			item.Limit = rule.Tolerance
+			if rule.Relative {
+				item.Limit = rule.Tolerance * math.Abs(item.Base)
+			}
+			var worse float64
+			if dirt := diffMetricDirs[name]; dirt == 0 {
+				worse = math.Abs(item.Cur - item.Base)
+			} else {
+				worse = (item.Base - item.Cur) * float64(dirt)
+			}
+			item.Regress = worse > item.Limit+1e-9
+			anyRegress = anyRegress || item.Regress
+		}
+		res = append(res, item)
+	}
+	return res, anyRegress
+}
+
+func textMetricDiffs(items []*MetricDiff) string {
+	var b bytes.Buffer
+	table := tablewriter.NewWriter(&b)
+	table.SetHeader([]string{"Metric", "Base", "Current", "Change", "Allowed", "Status"})
+	table.SetBorders(tablewriter.Border{Left: true, Top: false, Right: true, Bottom: false})
+	table.SetCenterSeparator("|")
+	table.SetAlignment(tablewriter.ALIGN_RIGHT)
+	fmtNum := func(v float64) string {
+		return strconv.FormatFloat(v, 'f', 4, 64)
+	}
+	for _, it := range items {
+		limit, status := "-", "-"
+		if !math.IsNaN(it.Limit) {
+			limit = fmtNum(it.Limit)
+			status = "ok"
+			if it.Regress {
+				status = "REGRESS"
+			}
+		}
+		table.Append([]string{it.Metric, fmtNum(it.Base), fmtNum(it.Cur), fmtNum(it.Cur - it.Base), limit, status})
+	}
+	table.Render()
+	return b.String()
+}
+
+/*
+RunBtDiff
+Compare two backtest results, exit with code 1 if any metric regresses beyond its rule.
+banbot bt diff -base old/result.json -cur new -rule totProfitPct=5 -rule sharpeRatio=10%
+比较两个回测结果，任意指标退化超过规则时以代码1退出
+*/
+func RunBtDiff(args []string) error {
+	var basePath, curPath string
+	var ruleTexts config.ArrString
+	var sub = flag.NewFlagSet("diff", flag.ExitOnError)
+	sub.StringVar(&basePath, "base", "", "base result.json or backtest output dir")
+	sub.StringVar(&curPath, "cur", "", "current result.json or backtest output dir")
+	sub.Var(&ruleTexts, "rule", "allowed regression: metric=tolerance or metric=tolerance%, can be repeated")
+	err_ := sub.Parse(args)
+	if err_ != nil {
+		return err_
+	}
+	if basePath == "" || curPath == "" {
+		return errs.NewMsg(errs.CodeParamRequired, "-base and -cur are required")
+	}
+	rules := make([]*DiffRule, 0, len(ruleTexts))
+	for _, text := range ruleTexts {
+		rule, err := ParseDiffRule(text)
+		if err != nil {
+			return err
+		}
+		rules = append(rules, rule)
+	}
+	base, err := LoadBtReport(basePath)
+	if err != nil {
+		return err
+	}
+	cur, err := LoadBtReport(curPath)
+	if err != nil {
+		return err
+	}
+	if base.Schema != cur.Schema {
+		log.Warn("schema version differs", zap.Int("base", base.Schema), zap.Int("cur", cur.Schema))
+	}
+	if base.ConfigHash != cur.ConfigHash {
+		log.Info("config changed", zap.String("base", base.ConfigHash), zap.String("cur", cur.ConfigHash))
+	}
+	if base.Metrics.Currency != cur.Metrics.Currency {
+		log.Warn("report currency differs, amounts are not comparable",
+			zap.String("base", base.Metrics.Currency), zap.String("cur", cur.Metrics.Currency))
+	}
+	items, regress := DiffBtReports(base, cur, rules)
+	log.Info("backtest diff:\n" + textMetricDiffs(items))
+	if regress {
+		log.Error("backtest result regressed")
+		os.Exit(1)
+	}
+	return nil
+}
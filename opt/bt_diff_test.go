@@ -0,0 +1,55 @@
+package opt
+
+import "testing"
+
+func TestParseDiffRule(t *testing.T) {
+	rule, err := ParseDiffRule("sharpeRatio=10%")
+	if err != nil || !rule.Relative || rule.Tolerance != 0.1 {
+		t.Fatalf("parse relative rule fail: %v %v", rule, err)
+	}
+	rule, err = ParseDiffRule(" maxDrawDownPct = 2 ")
+	if err != nil || rule.Relative || rule.Tolerance != 2 {
+		t.Fatalf("parse absolute rule fail: %v %v", rule, err)
+	}
+	for _, text := range []string{"unknown=1", "totProfitPct", "totProfitPct=-1", "totProfitPct=abc"} {
+		if _, err = ParseDiffRule(text); err == nil {
+			t.Errorf("rule should be invalid: %s", text)
+		}
+	}
+}
+
+func TestDiffBtReports(t *testing.T) {
+	base := &BtReport{Metrics: &BtMetrics{TotProfitPct: 50, SharpeRatio: 2, MaxDrawDownPct: 10, OrderNum: 100}}
+	cur := &BtReport{Metrics: &BtMetrics{TotProfitPct: 46, SharpeRatio: 1.9, MaxDrawDownPct: 13, OrderNum: 100}}
+	cases := []struct {
+		rules   []string
+		regress bool
+	}{
+		{nil, false},
+		{[]string{"totProfitPct=5"}, false},
+		{[]string{"totProfitPct=3"}, true},
+		{[]string{"sharpeRatio=5%"}, false},
+		{[]string{"sharpeRatio=4%"}, true},
+		{[]string{"maxDrawDownPct=2"}, true},
+		{[]string{"orderNum=0"}, false},
+	}
+	for _, c := range cases {
+		rules := make([]*DiffRule, 0, len(c.rules))
+		for _, text := range c.rules {
+			rule, err := ParseDiffRule(text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			rules = append(rules, rule)
+		}
+		_, regress := DiffBtReports(base, cur, rules)
+		if regress != c.regress {
+			t.Errorf("rules %v: regress %v, expect %v", c.rules, regress, c.regress)
+		}
+	}
+	// higher is better, improving never regresses 越高越好，提升不算退化
+	rule, _ := ParseDiffRule("totProfitPct=0")
+	if _, regress := DiffBtReports(cur, base, []*DiffRule{rule}); regress {
+		t.Error("improvement should not regress")
+	}
+}
@@ -0,0 +1,274 @@
+package opt
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/banbox/banbot/biz"
+	"github.com/banbox/banbot/config"
+	"github.com/banbox/banbot/core"
+	"github.com/banbox/banbot/data"
+	"github.com/banbox/banbot/orm/ormo"
+	"github.com/banbox/banbot/strat"
+	"github.com/banbox/banexg/errs"
+	"github.com/banbox/banexg/log"
+	ta "github.com/banbox/banta"
+	"go.uber.org/zap"
+)
+
+/*
+Golden backtests run BackTest with reference strategies on the bundled klines in testdata/golden, and compare
+orders and metrics with testdata/golden/<case>.golden.json. Update golden files after an intended change with:
+go test ./opt -run TestGoldenBacktest -update
+The exchange markets and symbols are still read from the database of BanDataDir, so the test is skipped when
+BanDataDir is not set. A case is also skipped until its golden file is generated with -update, reviewed and committed.
+黄金回测使用testdata/golden中的K线和参考策略运行BackTest，并将订单和指标与testdata/golden/<case>.golden.json比较。
+预期内的修改后，通过上述命令更新黄金文件。交易所市场和品种仍从BanDataDir的数据库读取，未设置BanDataDir时跳过测试。
+黄金文件通过-update生成、审核并提交之前，对应用例也会跳过。
+*/
+var updateGolden = flag.Bool("update", false, "update golden files of backtest")
+
+const goldenDir = "testdata/golden"
+
+var goldenKlines = map[string]string{
+	"BTC/USDT:USDT": "btc_usdt_1h.json",
+	"ETH/USDT:USDT": "eth_usdt_1h.json",
+}
+
+func init() {
+	strat.AddStratGroup("golden", map[string]strat.FuncMakeStrat{
+		"sma_cross": goldenSmaCross,
+		"limit_tp":  goldenLimitTp,
+	})
+}
+
+// goldenSmaCross market orders on SMA crossover, always in the market 均线交叉市价开平仓，始终持仓
+func goldenSmaCross(pol *config.RunPolicyConfig) *strat.TradeStrat {
+	return &strat.TradeStrat{
+		WarmupNum: 30,
+		OnBar: func(s *strat.StratJob) {
+			e := s.Env
+			cross := ta.Cross(ta.SMA(e.Close, 5), ta.SMA(e.Close, 20))
+			var err *errs.Error
+			if cross == 1 {
+				err = s.CloseOrders(&strat.ExitReq{Tag: "cross_up", Dirt: core.OdDirtShort})
+				if err == nil {
+					err = s.OpenOrder(&strat.EnterReq{Tag: "long"})
+				}
+			} else if cross == -1 {
+				err = s.CloseOrders(&strat.ExitReq{Tag: "cross_down", Dirt: core.OdDirtLong})
+				if err == nil {
+					err = s.OpenOrder(&strat.EnterReq{Tag: "short", Short: true})
+				}
+			}
+			if err != nil {
+				log.Warn("golden sma_cross fail", zap.String("pair", s.Symbol.Symbol), zap.Error(err))
+			}
+		},
+	}
+}
+
+// goldenLimitTp limit entries on deviation from SMA, with stop loss and take profit 偏离均线时限价入场，带止损止盈
+func goldenLimitTp(pol *config.RunPolicyConfig) *strat.TradeStrat {
+	return &strat.TradeStrat{
+		WarmupNum:     30,
+		StopEnterBars: 3,
+		OnBar: func(s *strat.StratJob) {
+			e := s.Env
+			ma := ta.SMA(e.Close, 20).Get(0)
+			atr := ta.ATR(e.High, e.Low, e.Close, 14).Get(0)
+			price := e.Close.Get(0)
+			if s.GetOrderNum(core.OdDirtBoth) > 0 {
+				return
+			}
+			var req *strat.EnterReq
+			if price < ma-atr {
+				req = &strat.EnterReq{Tag: "dip", Limit: price - atr*0.3, StopLoss: price - atr*2,
+					TakeProfit: price + atr*1.5}
+			} else if price > ma+atr {
+				req = &strat.EnterReq{Tag: "spike", Short: true, Limit: price + atr*0.3, StopLoss: price + atr*2,
+					TakeProfit: price - atr*1.5}
+			}
+			if req == nil {
+				return
+			}
+			if err := s.OpenOrder(req); err != nil {
+				log.Warn("golden limit_tp fail", zap.String("pair", s.Symbol.Symbol), zap.Error(err))
+			}
+		},
+	}
+}
+
+type goldenResult struct {
+	Metrics *BtMetrics     `json:"metrics"`
+	Orders  []*goldenOrder `json:"orders"`
+}
+
+type goldenOrder struct {
+	Symbol     string         `json:"symbol"`
+	Short      bool           `json:"short"`
+	Leverage   float64        `json:"leverage"`
+	EnterTag   string         `json:"enterTag"`
+	ExitTag    string         `json:"exitTag"`
+	EnterAt    int64          `json:"enterAt"`
+	ExitAt     int64          `json:"exitAt"`
+	Profit     float64        `json:"profit"`
+	ProfitRate float64        `json:"profitRate"`
+	Enter      *goldenExOrder `json:"enter"`
+	Exit       *goldenExOrder `json:"exit,omitempty"`
+}
+
+type goldenExOrder struct {
+	OrderType string  `json:"orderType"`
+	CreateAt  int64   `json:"createAt"`
+	UpdateAt  int64   `json:"updateAt"`
+	Price     float64 `json:"price"`
+	Average   float64 `json:"average"`
+	Amount    float64 `json:"amount"`
+	Filled    float64 `json:"filled"`
+	Fee       float64 `json:"fee"`
+}
+
+// roundSig keep 10 significant digits, avoid float noise in golden files 保留10位有效数字，避免黄金文件中的浮点噪音
+func roundSig(v float64) float64 {
+	res, _ := strconv.ParseFloat(strconv.FormatFloat(v, 'g', 10, 64), 64)
+	return res
+}
+
+func toGoldenExOrder(od *ormo.ExOrder) *goldenExOrder {
+	if od == nil {
+		return nil
+	}
+	return &goldenExOrder{
+		OrderType: od.OrderType,
+		CreateAt:  od.CreateAt,
+		UpdateAt:  od.UpdateAt,
+		Price:     roundSig(od.Price),
+		Average:   roundSig(od.Average),
+		Amount:    roundSig(od.Amount),
+		Filled:    roundSig(od.Filled),
+		Fee:       roundSig(od.Fee),
+	}
+}
+
+func toGoldenResult(r *BTResult, orders []*ormo.InOutOrder) *goldenResult {
+	res := &goldenResult{Metrics: r.ToReport().Metrics}
+	m := res.Metrics
+	for _, p := range []*float64{&m.TotalInvest, &m.FinBalance, &m.FinWithdraw, &m.TotProfit, &m.TotProfitPct,
+		&m.TotFee, &m.TotCost, &m.WinRatePct, &m.MaxDrawDownPct, &m.ShowDrawDownPct, &m.MaxDrawDownVal,
+		&m.MaxFundOccup, &m.SharpeRatio, &m.SortinoRatio, &m.Score} {
+		*p = roundSig(*p)
+	}
+	for _, od := range orders {
+		res.Orders = append(res.Orders, &goldenOrder{
+			Symbol:     od.Symbol,
+			Short:      od.Short,
+			Leverage:   od.Leverage,
+			EnterTag:   od.EnterTag,
+			ExitTag:    od.ExitTag,
+			EnterAt:    od.EnterAt,
+			ExitAt:     od.ExitAt,
+			Profit:     roundSig(od.Profit),
+			ProfitRate: roundSig(od.ProfitRate),
+			Enter:      toGoldenExOrder(od.Enter),
+			Exit:       toGoldenExOrder(od.Exit),
+		})
+	}
+	slices.SortStableFunc(res.Orders, func(a, b *goldenOrder) int {
+		if a.EnterAt != b.EnterAt {
+			return cmp.Compare(a.EnterAt, b.EnterAt)
+		}
+		return strings.Compare(a.Symbol, b.Symbol)
+	})
+	return res
+}
+
+func TestGoldenBacktest(t *testing.T) {
+	if config.GetDataDirSafe() == "" {
+		t.Skip("env BanDataDir is required for exchange markets and symbols, no bundled fixture yet")
+	}
+	for _, name := range []string{"sma_cross", "limit_tp"} {
+		t.Run(name, func(t *testing.T) {
+			runGoldenCase(t, name)
+		})
+	}
+}
+
+func runGoldenCase(t *testing.T, name string) {
+	dir, err_ := filepath.Abs(goldenDir)
+	if err_ != nil {
+		t.Fatal(err_)
+	}
+	path := filepath.Join(dir, name+".golden.json")
+	if _, err_ = os.Stat(path); os.IsNotExist(err_) && !*updateGolden {
+		t.Skipf("golden file not generated yet: %s, run with -update to create it, then review and commit it", path)
+	}
+	core.SetRunMode(core.RunModeBackTest)
+	args := &config.CmdArgs{
+		Configs: []string{filepath.Join(dir, "config.yml"), filepath.Join(dir, name+".yml")},
+	}
+	err := biz.SetupComsExg(args)
+	if err != nil {
+		t.Fatalf("setup fail, check the database and exchange of BanDataDir: %v", err)
+	}
+	for pair, fileName := range goldenKlines {
+		err = data.AddKlineFile(pair, "1h", filepath.Join(dir, fileName))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer data.ResetKlineFiles()
+
+	core.BotRunning = true
+	biz.ResetVars()
+	b := NewBackTest(false, t.TempDir())
+	b.Run()
+	if b.BTResult == nil || b.BTResult.Plots == nil {
+		t.Fatal("backtest produced no result")
+	}
+	res := toGoldenResult(b.BTResult, ormo.HistODs)
+	if len(res.Orders) == 0 {
+		t.Fatal("no orders from golden backtest, check the strategy or klines")
+	}
+	got, err_ := json.MarshalIndent(res, "", "  ")
+	if err_ != nil {
+		t.Fatal(err_)
+	}
+	got = append(got, '\n')
+	if *updateGolden {
+		if err_ = os.WriteFile(path, got, 0644); err_ != nil {
+			t.Fatal(err_)
+		}
+		t.Logf("golden file updated: %s", path)
+		return
+	}
+	want, err_ := os.ReadFile(path)
+	if err_ != nil {
+		t.Fatal(err_)
+	}
+	if bytes.Equal(want, got) {
+		return
+	}
+	wantLines, gotLines := strings.Split(string(want), "\n"), strings.Split(string(got), "\n")
+	for i := 0; i < max(len(wantLines), len(gotLines)); i++ {
+		var w, g string
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if w != g {
+			t.Fatalf("%s differs from golden at line %d:\nwant: %s\ngot:  %s\nrun with -update if the change is intended",
+				path, i+1, strings.TrimSpace(w), strings.TrimSpace(g))
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package opt
+
+import (
+	"fmt"
+
+	"github.com/banbox/banbot/btime"
+	"github.com/banbox/banbot/core"
+	"github.com/banbox/banbot/orm"
+	"github.com/banbox/banbot/orm/ormo"
+	"github.com/banbox/banexg/errs"
+	utils2 "github.com/banbox/banexg/utils"
+)
+
+const (
+	snapBarsBefore = 30  // bars before entry in the snapshot 快照中入场前的bar数量
+	snapBarsAfter  = 10  // bars after exit in the snapshot 快照中出场后的bar数量
+	snapMaxBars    = 600 // use a larger timeframe when exceeded 超过时使用更大的周期
+)
+
+/*
+DumpOrderSnapshot
+Render the price chart around the entry and exit of the order to a html file
+将订单入场和出场前后的价格图表渲染到html文件
+*/
+func DumpOrderSnapshot(od *ormo.InOutOrder, path string) *errs.Error {
+	exs := orm.GetSymbolByID(int32(od.Sid))
+	if exs == nil {
+		var err *errs.Error
+		exs, err = orm.GetExSymbolCur(od.Symbol)
+		if err != nil {
+			return err
+		}
+	}
+	enterMS, exitMS := od.RealEnterMS(), od.RealExitMS()
+	if exitMS == 0 || od.Status < ormo.InOutStatusFullExit {
+		exitMS = btime.TimeMS()
+	}
+	timeFrame := od.Timeframe
+	tfMSecs := int64(utils2.TFToSecs(timeFrame) * 1000)
+	for _, tf := range []string{"15m", "1h", "4h", "1d"} {
+		if (exitMS-enterMS)/tfMSecs < snapMaxBars {
+			break
+		}
+		curMSecs := int64(utils2.TFToSecs(tf) * 1000)
+		if curMSecs > tfMSecs {
+			timeFrame, tfMSecs = tf, curMSecs
+		}
+	}
+	startMS := enterMS - tfMSecs*snapBarsBefore
+	endMS := exitMS + tfMSecs*snapBarsAfter
+	_, klines, err := orm.GetOHLCV(exs, timeFrame, startMS, endMS, 0, false)
+	if err != nil {
+		return err
+	}
+	if len(klines) == 0 {
+		return errs.NewMsg(core.ErrRunTime, "no klines for %s %s", od.Symbol, timeFrame)
+	}
+	labels := make([]string, 0, len(klines))
+	closes := make([]float64, 0, len(klines))
+	for _, k := range klines {
+		labels = append(labels, btime.ToDateStr(k.Time, core.DefaultDateFmt))
+		closes = append(closes, k.Close)
+	}
+	datasets := []*ChartDs{
+		{Label: "Close", Data: closes},
+	}
+	if od.Enter != nil && od.Enter.Average > 0 {
+		datasets = append(datasets, constDs("Enter", od.Enter.Average, len(klines), "#67c23a"))
+	}
+	if od.Exit != nil && od.Exit.Average > 0 {
+		datasets = append(datasets, constDs("Exit", od.Exit.Average, len(klines), "#f56c6c"))
+	}
+	dirt := "long"
+	if od.Short {
+		dirt = "short"
+	}
+	title := fmt.Sprintf("%s %s %s %s, %s - %s, profit: %.2f%%", od.Symbol, timeFrame, od.Strategy, dirt,
+		btime.ToDateStr(enterMS, core.DefaultDateFmt), btime.ToDateStr(exitMS, core.DefaultDateFmt), od.ProfitRate*100)
+	return DumpChart(path, title, labels, 5, nil, datasets)
+}
+
+func constDs(label string, val float64, size int, color string) *ChartDs {
+	data := make([]float64, size)
+	for i := range data {
+		data[i] = val
+	}
+	return &ChartDs{Label: label, Data: data, BorderColor: color}
+}
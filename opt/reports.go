@@ -53,12 +53,23 @@ type BTResult struct {
 	EndMS           int64          `json:"endMS"`
 	PlotEvery       int            `json:"plotEvery"`
 	TotalInvest     float64        `json:"totalInvest"`
+	Currency        string         `json:"currency"` // Report currency of all values 所有金额的计价币
 	OutDir          string         `json:"outDir"`
 	PairGrps        []*RowItem     `json:"pairGrps"`
 	DateGrps        []*RowItem     `json:"dateGrps"`
 	EnterGrps       []*RowItem     `json:"enterGrps"`
 	ExitGrps        []*RowItem     `json:"exitGrps"`
 	ProfitGrps      []*RowItem     `json:"profitGrps"`
+	HedgeGrps       []*RowItem     `json:"hedgeGrps"` // Combined profits of hedge legs 对冲各腿的合并利润
+	HourGrps        []*RowItem     `json:"hourGrps"`
+	WeekdayGrps     []*RowItem     `json:"weekdayGrps"`
+	HoldGrps        []*RowItem     `json:"holdGrps"`   // By holding duration 按持仓时长
+	RegimeGrps      []*RowItem     `json:"regimeGrps"` // By market regime of config.RegimeSymbol 按基准品种的市场状态
+	SideGrps        []*RowItem     `json:"sideGrps"`
+	LeverageGrps    []*RowItem     `json:"leverageGrps"`
+	QuoteGrps       []*RowItem     `json:"quoteGrps"` // By quote currency 按定价币
+	PnlParts        *PnlParts      `json:"pnlParts"`
+	Benchmarks      []*BenchResult `json:"benchmarks"`
 	TotProfit       float64        `json:"totProfit"`
 	TotCost         float64        `json:"totCost"`
 	TotFee          float64        `json:"totFee"`
@@ -138,6 +149,16 @@ func (r *BTResult) cmdReports(orders []*ormo.InOutOrder) string {
 			{Title: " Profit Ranges ", Handle: textGroupProfitRanges},
 			{Title: " Enter Tag ", Handle: textGroupEntTags},
 			{Title: " Exit Tag ", Handle: textGroupExitTags},
+			{Title: " Hedge Groups ", Handle: textGroupHedges},
+			{Title: " Enter Hours ", Handle: textGroupHours},
+			{Title: " Enter Weekdays ", Handle: textGroupWeekdays},
+			{Title: " Holding Durations ", Handle: textGroupHolds},
+			{Title: " Market Regimes ", Handle: textGroupRegimes},
+			{Title: " Long/Short ", Handle: textGroupSides},
+			{Title: " Leverages ", Handle: textGroupLeverages},
+			{Title: " Quote Currencies ", Handle: textGroupQuotes},
+			{Title: " PnL Breakdown ", Handle: textPnlParts},
+			{Title: " Benchmarks ", Handle: textBenchmarks},
 		}
 		for _, item := range items {
 			tblText = item.Handle(r)
@@ -178,6 +199,8 @@ func (r *BTResult) dumpBtFiles() {
 	r.dumpGraph()
 
 	r.dumpDetail("")
+
+	r.dumpReport()
 }
 
 func (r *BTResult) Collect() {
@@ -189,12 +212,15 @@ func (r *BTResult) Collect() {
 	winCount := float64(0)
 	tfHits := make(map[string]int)
 	for _, od := range orders {
-		sumProfit += od.Profit
-		sumFee += od.Enter.Fee
+		// convert from quote to report currency by the rate at exit
+		// 按平仓时汇率从定价币换算到报告币种
+		rate := od.ReportRate()
+		sumProfit += od.Profit * rate
+		sumFee += od.Enter.Fee * rate
 		if od.Exit != nil {
-			sumFee += od.Exit.Fee
+			sumFee += od.Exit.Fee * rate
 		}
-		sumCost += od.EnterCost() / od.Leverage
+		sumCost += od.EnterCost() / od.Leverage * rate
 		if od.Profit > 0 {
 			winCount += 1
 		}
@@ -202,6 +228,7 @@ func (r *BTResult) Collect() {
 		tfHits[od.Timeframe] = oldNum + 1
 	}
 	r.TfHits = tfHits
+	r.Currency = config.ReportCurrency
 	r.TotProfit = sumProfit
 	r.TotCost = utils.NanInfTo(sumCost, 0)
 	r.TotFee = sumFee
@@ -221,10 +248,19 @@ func (r *BTResult) Collect() {
 		r.groupByProfits(orders)
 		r.groupByEnters(orders)
 		r.groupByExits(orders)
+		r.groupByHedges(orders)
+		r.groupByHours(orders)
+		r.groupByWeekdays(orders)
+		r.groupByHolds(orders)
+		r.groupByRegimes(orders)
+		r.groupBySides(orders)
+		r.groupByLeverages(orders)
+		r.groupByQuotes(orders)
+		r.calcPnlParts(orders)
 	}
 	wallets := biz.GetWallets(config.DefAcc)
-	r.FinBalance = wallets.AvaLegal(nil)
-	r.FinWithdraw = wallets.GetWithdrawLegal(nil)
+	r.FinBalance = biz.LegalToReport(wallets.AvaLegal(nil))
+	r.FinWithdraw = biz.LegalToReport(wallets.GetWithdrawLegal(nil))
 	rangeSecs := (r.EndMS - r.StartMS) / 1000
 	sharpe, sortino, err := CalcMeasuresByReal(r.Plots.Real, rangeSecs, "", 0, 0)
 	if err != nil {
@@ -232,6 +268,7 @@ func (r *BTResult) Collect() {
 	} else {
 		r.SharpeRatio, r.SortinoRatio = sharpe, sortino
 	}
+	r.calcBenchmarks(orders)
 }
 
 func (r *BTResult) textMetrics(orders []*ormo.InOutOrder) string {
@@ -244,6 +281,7 @@ func (r *BTResult) textMetrics(orders []*ormo.InOutOrder) string {
 	table.SetAlignment(tablewriter.ALIGN_RIGHT)
 	table.Append([]string{"Backtest From", btime.ToDateStr(r.StartMS, "")})
 	table.Append([]string{"Backtest To", btime.ToDateStr(r.EndMS, "")})
+	table.Append([]string{"Report Currency", r.Currency})
 	table.Append([]string{"Max Open Orders", strconv.Itoa(r.MaxOpenOrders)})
 	table.Append([]string{"Total Orders/BarNum", fmt.Sprintf("%v/%v", len(orders), r.BarNum)})
 	table.Append([]string{"Total Investment", strconv.FormatFloat(r.TotalInvest, 'f', 0, 64)})
@@ -330,6 +368,46 @@ func textGroupExitTags(r *BTResult) string {
 	return printGroups(r.ExitGrps, "Exit Tag", true, nil, nil)
 }
 
+/*
+groupByHedges
+Group legs opened by StratJob.OpenHedge by the group id, the profit of a row is the combined profit of all legs
+按组ID汇总StratJob.OpenHedge开仓的各腿，每行利润为所有腿的合计利润
+*/
+func (r *BTResult) groupByHedges(orders []*ormo.InOutOrder) {
+	var hedges []*ormo.InOutOrder
+	for _, od := range orders {
+		if od.GroupID() != "" {
+			hedges = append(hedges, od)
+		}
+	}
+	groups := groupItems(hedges, false, func(od *ormo.InOutOrder, i int) string {
+		return od.GroupID()
+	})
+	sort.Slice(groups, func(i, j int) bool {
+		return groups[i].Title < groups[j].Title
+	})
+	r.HedgeGrps = groups
+}
+
+func textGroupHedges(r *BTResult) string {
+	if len(r.HedgeGrps) == 0 {
+		return ""
+	}
+	return printGroups(r.HedgeGrps, "Group", false, []string{"Legs"}, makeHedgeLegs)
+}
+
+func makeHedgeLegs(orders []*ormo.InOutOrder) []string {
+	legs := make([]string, 0, len(orders))
+	for _, od := range orders {
+		dirt := "long"
+		if od.Short {
+			dirt = "short"
+		}
+		legs = append(legs, fmt.Sprintf("%s/%s", od.Symbol, dirt))
+	}
+	return []string{strings.Join(legs, " ")}
+}
+
 func (r *BTResult) groupByProfits(orders []*ormo.InOutOrder) {
 	odNum := len(orders)
 	if odNum == 0 {
@@ -456,14 +534,15 @@ func groupItems(orders []*ormo.InOutOrder, measure bool, getTag func(od *ormo.In
 		sta, ok := groups[tag]
 		duration := max(0, int((od.RealExitMS()-od.RealEnterMS())/1000))
 		isWin := od.Profit >= 0
+		rate := od.ReportRate()
 		if !ok {
 			sta = &RowItem{
 				Title: tag,
 				RowPart: RowPart{
 					OrderNum:     1,
-					ProfitSum:    od.Profit,
+					ProfitSum:    od.Profit * rate,
 					ProfitPctSum: od.ProfitRate,
-					CostSum:      od.EnterCost() / od.Leverage,
+					CostSum:      od.EnterCost() / od.Leverage * rate,
 					Durations:    []int{duration},
 					Orders:       make([]*ormo.InOutOrder, 0, 8),
 				},
@@ -478,9 +557,9 @@ func groupItems(orders []*ormo.InOutOrder, measure bool, getTag func(od *ormo.In
 				sta.WinCount += 1
 			}
 			sta.OrderNum += 1
-			sta.ProfitSum += od.Profit
+			sta.ProfitSum += od.Profit * rate
 			sta.ProfitPctSum += od.ProfitRate
-			sta.CostSum += od.EnterCost() / od.Leverage
+			sta.CostSum += od.EnterCost() / od.Leverage * rate
 			sta.Durations = append(sta.Durations, duration)
 			sta.Orders = append(sta.Orders, od)
 		}
@@ -668,7 +747,7 @@ func DumpOrdersCSV(orders []*ormo.InOutOrder, outPath string) error {
 	defer writer.Flush()
 	heads := []string{"sid", "symbol", "timeframe", "direction", "leverage", "entAt", "entTag", "entPrice",
 		"entAmount", "entCost", "entFee", "exitAt", "exitTag", "exitPrice", "exitAmount", "exitGot",
-		"exitFee", "maxPftRate", "maxDrawDown", "profitRate", "profit", "strategy"}
+		"exitFee", "maxPftRate", "maxDrawDown", "profitRate", "profit", "strategy", "liqPrice"}
 	if err_ = writer.Write(heads); err_ != nil {
 		return err_
 	}
@@ -698,6 +777,9 @@ func DumpOrdersCSV(orders []*ormo.InOutOrder, outPath string) error {
 		row[19] = strconv.FormatFloat(od.ProfitRate, 'f', 4, 64)
 		row[20] = strconv.FormatFloat(od.Profit, 'f', 8, 64)
 		row[21] = od.Strategy
+		if liqPrice := od.GetInfoFloat64(ormo.OdInfoLiqPrice); liqPrice > 0 {
+			row[22] = strconv.FormatFloat(liqPrice, 'f', -1, 64)
+		}
 		if err_ = writer.Write(row); err_ != nil {
 			return err_
 		}
@@ -797,7 +879,7 @@ func (r *BTResult) dumpGraph() {
 	title := "Real-time Assets/Balances/Unrealized P&L/Withdrawals/Concurrent Orders"
 	tplPath := fmt.Sprintf("%s/lines.html", config.GetDataDir())
 	tplData, _ := os.ReadFile(tplPath)
-	err := DumpChart(outPath, title, r.Plots.Labels, 5, tplData, []*ChartDs{
+	dsList := []*ChartDs{
 		{Label: "Real", Data: r.Plots.Real},
 		{Label: "Available", Data: r.Plots.Available},
 		{Label: "Profit", Data: r.Plots.Profit, Hidden: true},
@@ -805,7 +887,9 @@ func (r *BTResult) dumpGraph() {
 		{Label: "Withdraw", Data: r.Plots.WithDraw, Hidden: true},
 		{Label: "OrderNum", Data: odNum, YAxisID: "yRight", Hidden: true},
 		{Label: "JobNum", Data: jobNum, YAxisID: "yRight", Hidden: true},
-	})
+	}
+	dsList = append(dsList, r.benchChartDs()...)
+	err := DumpChart(outPath, title, r.Plots.Labels, 5, tplData, dsList)
 	if err != nil {
 		log.Error("save assets.html fail", zap.Error(err))
 	}
@@ -844,7 +928,8 @@ func (r *BTResult) dumpDetail(outPath string) {
 DelBigObjects 删除大对象引用，避免内存泄露
 */
 func (r *BTResult) DelBigObjects() {
-	grpList := [][]*RowItem{r.PairGrps, r.DateGrps, r.EnterGrps, r.ExitGrps, r.ProfitGrps}
+	grpList := [][]*RowItem{r.PairGrps, r.DateGrps, r.EnterGrps, r.ExitGrps, r.ProfitGrps, r.HedgeGrps,
+		r.HourGrps, r.WeekdayGrps, r.HoldGrps, r.RegimeGrps, r.SideGrps, r.LeverageGrps, r.QuoteGrps}
 	for _, gp := range grpList {
 		for _, p := range gp {
 			p.Orders = nil
@@ -859,7 +944,7 @@ func (r *BTResult) logState(startMS, timeMS int64, odNum int) {
 	}
 	r.EndMS = timeMS
 	wallets := biz.GetWallets(config.DefAcc)
-	totalLegal := wallets.TotalLegal(nil, true)
+	totalLegal := biz.LegalToReport(wallets.TotalLegal(nil, true))
 	r.MinReal = min(r.MinReal, totalLegal)
 	if totalLegal >= r.MaxReal {
 		r.MaxReal = totalLegal
@@ -867,7 +952,7 @@ func (r *BTResult) logState(startMS, timeMS int64, odNum int) {
 		drawDownPct := (r.MaxReal - totalLegal) * 100 / r.MaxReal
 		r.MaxDrawDownPct = max(r.MaxDrawDownPct, drawDownPct)
 		r.MaxDrawDownVal = max(r.MaxDrawDownVal, r.MaxReal-totalLegal)
-		maxOccupy := r.MaxReal - wallets.AvaLegal(nil)
+		maxOccupy := r.MaxReal - biz.LegalToReport(wallets.AvaLegal(nil))
 		r.MaxFundOccup = max(r.MaxFundOccup, maxOccupy)
 		r.MaxOccupForPair = max(r.MaxOccupForPair, maxOccupy/float64(len(core.Pairs)))
 	}
@@ -927,14 +1012,16 @@ func (r *BTResult) logPlot(wallets *biz.BanWallets, timeMS int64, odNum int, tot
 			}
 		}
 	}
+	// values are in the report currency, converted with the current rates
+	// 各值以报告币种计价，按当前汇率换算
 	if totalLegal < 0 {
-		totalLegal = wallets.TotalLegal(nil, true)
+		totalLegal = biz.LegalToReport(wallets.TotalLegal(nil, true))
 	}
-	avaLegal := wallets.AvaLegal(nil)
-	profitLegal := wallets.UnrealizedPOLLegal(nil)
-	drawLegal := wallets.GetWithdrawLegal(nil)
+	avaLegal := biz.LegalToReport(wallets.AvaLegal(nil))
+	profitLegal := biz.LegalToReport(wallets.UnrealizedPOLLegal(nil))
+	drawLegal := biz.LegalToReport(wallets.GetWithdrawLegal(nil))
 	curDate := btime.ToDateStr(timeMS, "")
-	r.donePftLegal += ormo.LegalDoneProfits(r.histOdOff)
+	r.donePftLegal += biz.LegalToReport(ormo.LegalDoneProfits(r.histOdOff))
 	r.histOdOff = len(ormo.HistODs)
 	r.Plots.Labels = append(r.Plots.Labels, curDate)
 	r.Plots.OdNum = append(r.Plots.OdNum, odNum)
@@ -1221,7 +1308,7 @@ func calcBtResult(odList []*ormo.InOutOrder, funds map[string]float64, outDir st
 	wallets := biz.GetWallets(config.DefAcc)
 	wallets.SetWallets(funds)
 	wallets.TryUpdateStakePctAmt()
-	totalLegal := wallets.TotalLegal(nil, false)
+	totalLegal := biz.LegalToReport(wallets.TotalLegal(nil, false))
 	if totalLegal == 0 {
 		return nil, errs.NewMsg(errs.CodeRunTime, "TotalLegal of wallets is empty")
 	}
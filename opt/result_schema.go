@@ -0,0 +1,299 @@
+package opt
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/banbox/banbot/btime"
+	"github.com/banbox/banbot/config"
+	"github.com/banbox/banbot/core"
+	"github.com/banbox/banexg/errs"
+	"github.com/banbox/banexg/log"
+	utils2 "github.com/banbox/banexg/utils"
+	"go.uber.org/zap"
+)
+
+/*
+BtSchemaVersion
+Version of the json schema of BtReport, increase it when a field is renamed, removed or changes its meaning.
+Adding fields is compatible and doesn't require a new version. See doc/bt_result.md
+BtReport的json结构版本，字段重命名、删除或含义变更时需增加。新增字段是兼容的，无需新版本。见doc/bt_result.md
+*/
+const BtSchemaVersion = 1
+
+const (
+	BtReportKind = "banbot.backtest"
+	BtReportName = "result.json"
+)
+
+// Keys of BtReport.Groups BtReport.Groups的键
+const (
+	GrpPair     = "pair"
+	GrpDate     = "date"
+	GrpEnterTag = "enterTag"
+	GrpExitTag  = "exitTag"
+	GrpProfit   = "profit"
+	GrpHedge    = "hedge"
+	GrpHour     = "hour"
+	GrpWeekday  = "weekday"
+	GrpHold     = "hold"
+	GrpRegime   = "regime"
+	GrpSide     = "side"
+	GrpLeverage = "leverage"
+	GrpQuote    = "quote"
+)
+
+/*
+BtReport
+Stable and documented backtest result for machine reading, dumped as result.json
+稳定且有文档的回测结果，用于机器读取，保存为result.json
+*/
+type BtReport struct {
+	Schema     int                      `json:"schema"`
+	Kind       string                   `json:"kind"`
+	Version    string                   `json:"version"`    // banbot version 版本
+	ConfigHash string                   `json:"configHash"` // sha256 of the desensitized config yaml 脱敏配置yaml的sha256
+	CreateMS   int64                    `json:"createMS"`
+	StartMS    int64                    `json:"startMS"`
+	EndMS      int64                    `json:"endMS"`
+	Metrics    *BtMetrics               `json:"metrics"`
+	PnlParts   *PnlParts                `json:"pnlParts,omitempty"`
+	Benchmarks []*BtBenchRow            `json:"benchmarks,omitempty"`
+	Groups     map[string][]*BtGroupRow `json:"groups"`
+	Equity     *BtEquity                `json:"equity"`
+}
+
+type BtMetrics struct {
+	Currency        string  `json:"currency"` // report currency of all values 所有金额的计价币
+	TotalInvest     float64 `json:"totalInvest"`
+	FinBalance      float64 `json:"finBalance"`
+	FinWithdraw     float64 `json:"finWithdraw"`
+	TotProfit       float64 `json:"totProfit"`
+	TotProfitPct    float64 `json:"totProfitPct"`
+	TotFee          float64 `json:"totFee"`
+	TotCost         float64 `json:"totCost"`
+	OrderNum        int     `json:"orderNum"`
+	BarNum          int     `json:"barNum"`
+	WinRatePct      float64 `json:"winRatePct"`
+	MaxDrawDownPct  float64 `json:"maxDrawDownPct"`  // by real-time assets 按实时资产
+	ShowDrawDownPct float64 `json:"showDrawDownPct"` // by the equity curve 按权益曲线
+	MaxDrawDownVal  float64 `json:"maxDrawDownVal"`
+	MaxOpenOrders   int     `json:"maxOpenOrders"`
+	MaxFundOccup    float64 `json:"maxFundOccup"`
+	SharpeRatio     float64 `json:"sharpeRatio"`
+	SortinoRatio    float64 `json:"sortinoRatio"`
+	Score           float64 `json:"score"`
+}
+
+type BtGroupRow struct {
+	Title        string  `json:"title"`
+	OrderNum     int     `json:"orderNum"`
+	WinCount     int     `json:"winCount"`
+	ProfitSum    float64 `json:"profitSum"`
+	ProfitPctSum float64 `json:"profitPctSum"` // sum of profit rates of orders 订单利润率之和
+	CostSum      float64 `json:"costSum"`
+	AvgHoldSecs  int     `json:"avgHoldSecs"`
+	Sharpe       float64 `json:"sharpe"`
+	Sortino      float64 `json:"sortino"`
+}
+
+type BtBenchRow struct {
+	Name        string  `json:"name"`
+	ProfitPct   float64 `json:"profitPct"`
+	Alpha       float64 `json:"alpha"`
+	Beta        float64 `json:"beta"`
+	InfoRatio   float64 `json:"infoRatio"`
+	TrackError  float64 `json:"trackError"`
+	UpCapture   float64 `json:"upCapture"`
+	DownCapture float64 `json:"downCapture"`
+	Correlation float64 `json:"correlation"`
+}
+
+/*
+BtEquity
+Sampled equity series, all arrays have the same length as Times
+采样的权益序列，所有数组长度与Times一致
+*/
+type BtEquity struct {
+	Times      []int64              `json:"times"` // 13-digit timestamps 13位时间戳
+	Real       []float64            `json:"real"`
+	Available  []float64            `json:"available"`
+	Profit     []float64            `json:"profit"`
+	UnPOL      []float64            `json:"unPOL"`
+	Withdraw   []float64            `json:"withdraw"`
+	Benchmarks map[string][]float64 `json:"benchmarks,omitempty"`
+}
+
+/*
+ToReport
+Convert to the versioned BtReport, Collect should be called before
+转为带版本的BtReport，调用前需先执行Collect
+*/
+func (r *BTResult) ToReport() *BtReport {
+	res := &BtReport{
+		Schema:     BtSchemaVersion,
+		Kind:       BtReportKind,
+		Version:    core.Version,
+		ConfigHash: configHash(),
+		CreateMS:   r.CreateMS,
+		StartMS:    r.StartMS,
+		EndMS:      r.EndMS,
+		Metrics: &BtMetrics{
+			Currency:        r.Currency,
+			TotalInvest:     r.TotalInvest,
+			FinBalance:      r.FinBalance,
+			FinWithdraw:     r.FinWithdraw,
+			TotProfit:       r.TotProfit,
+			TotProfitPct:    r.TotProfitPct,
+			TotFee:          r.TotFee,
+			TotCost:         r.TotCost,
+			OrderNum:        r.OrderNum,
+			BarNum:          r.BarNum,
+			WinRatePct:      r.WinRatePct,
+			MaxDrawDownPct:  r.MaxDrawDownPct,
+			ShowDrawDownPct: r.ShowDrawDownPct,
+			MaxDrawDownVal:  r.MaxDrawDownVal,
+			MaxOpenOrders:   r.MaxOpenOrders,
+			MaxFundOccup:    r.MaxFundOccup,
+			SharpeRatio:     r.SharpeRatio,
+			SortinoRatio:    r.SortinoRatio,
+			Score:           r.Score(),
+		},
+		PnlParts: r.PnlParts,
+		Groups:   make(map[string][]*BtGroupRow),
+		Equity: &BtEquity{
+			Times:     make([]int64, 0, len(r.Plots.Labels)),
+			Real:      r.Plots.Real,
+			Available: r.Plots.Available,
+			Profit:    r.Plots.Profit,
+			UnPOL:     r.Plots.UnrealizedPOL,
+			Withdraw:  r.Plots.WithDraw,
+		},
+	}
+	grpMap := map[string][]*RowItem{
+		GrpPair:     r.PairGrps,
+		GrpDate:     r.DateGrps,
+		GrpEnterTag: r.EnterGrps,
+		GrpExitTag:  r.ExitGrps,
+		GrpProfit:   r.ProfitGrps,
+		GrpHedge:    r.HedgeGrps,
+		GrpHour:     r.HourGrps,
+		GrpWeekday:  r.WeekdayGrps,
+		GrpHold:     r.HoldGrps,
+		GrpRegime:   r.RegimeGrps,
+		GrpSide:     r.SideGrps,
+		GrpLeverage: r.LeverageGrps,
+		GrpQuote:    r.QuoteGrps,
+	}
+	for key, items := range grpMap {
+		rows := make([]*BtGroupRow, 0, len(items))
+		for _, it := range items {
+			row := &BtGroupRow{
+				Title:        it.Title,
+				OrderNum:     it.OrderNum,
+				WinCount:     it.WinCount,
+				ProfitSum:    it.ProfitSum,
+				ProfitPctSum: it.ProfitPctSum,
+				CostSum:      it.CostSum,
+				Sharpe:       it.Sharpe,
+				Sortino:      it.Sortino,
+			}
+			if len(it.Durations) > 0 {
+				var sumSecs int
+				for _, v := range it.Durations {
+					sumSecs += v
+				}
+				row.AvgHoldSecs = sumSecs / len(it.Durations)
+			}
+			rows = append(rows, row)
+		}
+		res.Groups[key] = rows
+	}
+	for _, label := range r.Plots.Labels {
+		timeMS, err_ := btime.ParseTimeMSBy(core.DefaultDateFmt, label)
+		if err_ != nil {
+			log.Warn("parse plot label fail", zap.String("label", label), zap.Error(err_))
+		}
+		res.Equity.Times = append(res.Equity.Times, timeMS)
+	}
+	for _, b := range r.Benchmarks {
+		res.Benchmarks = append(res.Benchmarks, &BtBenchRow{
+			Name:        b.Name,
+			ProfitPct:   b.ProfitPct,
+			Alpha:       b.Alpha,
+			Beta:        b.Beta,
+			InfoRatio:   b.InfoRatio,
+			TrackError:  b.TrackError,
+			UpCapture:   b.UpCapture,
+			DownCapture: b.DownCapture,
+			Correlation: b.Correlation,
+		})
+		if res.Equity.Benchmarks == nil {
+			res.Equity.Benchmarks = make(map[string][]float64)
+		}
+		res.Equity.Benchmarks[b.Name] = b.Equity
+	}
+	return res
+}
+
+func configHash() string {
+	data, err := config.DumpYaml(true)
+	if err != nil {
+		log.Warn("dump config for hash fail", zap.Error(err))
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (r *BTResult) dumpReport() {
+	data, err_ := utils2.Marshal(r.ToReport())
+	if err_ != nil {
+		log.Error("marshal backtest report fail", zap.Error(err_))
+		return
+	}
+	err_ = os.WriteFile(filepath.Join(r.OutDir, BtReportName), data, 0644)
+	if err_ != nil {
+		log.Error("write backtest report fail", zap.Error(err_))
+	}
+}
+
+/*
+LoadBtReport
+Load BtReport from result.json, path can also be the output directory of a backtest
+从result.json加载BtReport，路径也可以是回测的输出目录
+*/
+func LoadBtReport(path string) (*BtReport, *errs.Error) {
+	path = config.ParsePath(path)
+	if info, err_ := os.Stat(path); err_ == nil && info.IsDir() {
+		path = filepath.Join(path, BtReportName)
+	}
+	data, err_ := os.ReadFile(path)
+	if err_ != nil {
+		return nil, errs.New(errs.CodeIOReadFail, err_)
+	}
+	var res BtReport
+	err_ = utils2.Unmarshal(data, &res, utils2.JsonNumDefault)
+	if err_ != nil {
+		return nil, errs.New(errs.CodeUnmarshalFail, err_)
+	}
+	if res.Kind != BtReportKind {
+		return nil, errs.NewMsg(errs.CodeParamInvalid, "not a backtest result: %s", path)
+	}
+	if res.Schema > BtSchemaVersion {
+		return nil, errs.NewMsg(errs.CodeParamInvalid, "result schema %v is newer than supported %v, please upgrade: %s",
+			res.Schema, BtSchemaVersion, path)
+	}
+	if res.Metrics == nil {
+		return nil, errs.NewMsg(errs.CodeParamInvalid, "metrics missing in %s", path)
+	}
+	return &res, nil
+}
+
+func (r *BtReport) String() string {
+	return fmt.Sprintf("%s schema %v, %s ~ %s", r.Kind, r.Schema, btime.ToDateStr(r.StartMS, ""),
+		btime.ToDateStr(r.EndMS, ""))
+}
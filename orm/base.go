@@ -122,6 +122,10 @@ func Setup() *errs.Error {
 		return err2
 	}
 	defer conn.Release()
+	err2 = sess.LoadListDates()
+	if err2 != nil {
+		return err2
+	}
 	if exg.Default != nil {
 		_, err2 = LoadMarkets(exg.Default, false)
 		if err2 != nil {
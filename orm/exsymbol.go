@@ -74,7 +74,19 @@ func GetSymbolByID(id int32) *ExSymbol {
 	return item
 }
 
+/*
+GetExSymbolCur
+Get ExSymbol of the default exchange; a venue key like exchange:market:symbol is also accepted, see VenueKey
+获取默认交易所的ExSymbol；也支持形如exchange:market:symbol的场所键，见VenueKey
+*/
 func GetExSymbolCur(symbol string) (*ExSymbol, *errs.Error) {
+	if exgName, market, pair := core.ParseVenueKey(symbol); exgName != "" {
+		item := GetExSymbol2(exgName, market, pair)
+		if item == nil {
+			return nil, errs.NewMsg(core.ErrInvalidSymbol, "%s not exist in %d cache", symbol, len(keySymbolMap))
+		}
+		return item, nil
+	}
 	return GetExSymbol(exg.Default, symbol)
 }
 
@@ -132,18 +144,23 @@ func EnsureExgSymbols(exchange banexg.BanExchange) *errs.Error {
 		// 中国期货需要在EnsureSymbols后再次调用LoadMarkets传入symbols才能加载成功
 		_, err = LoadMarkets(exchange, false)
 	} else {
-		// Mark the coins that are not returned by the exchange as delisted
-		var editList []*ExSymbol
+		// Mark the coins that are not returned by the exchange as delisted, and those returned again as relisted
+		// 将交易所未返回的币标记为已退市，再次返回的标记为重新上市
+		var editList, relists []*ExSymbol
+		curMS := btime.UTCStamp()
 		for _, exs := range idSymbolMap {
-			if exs.Exchange != exInfo.ID || exs.Market != exInfo.MarketType || exs.DelistMs > 0 {
+			if exs.Exchange != exInfo.ID || exs.Market != exInfo.MarketType {
 				continue
 			}
-			if _, ok := exInfo.Markets[exs.Symbol]; !ok {
-				exs.DelistMs = btime.UTCStamp()
+			_, ok := exInfo.Markets[exs.Symbol]
+			if exs.DelistMs == 0 && !ok {
+				exs.DelistMs = curMS
 				editList = append(editList, exs)
+			} else if exs.DelistMs > 0 && exs.DelistMs <= curMS && ok {
+				relists = append(relists, exs)
 			}
 		}
-		if len(editList) > 0 {
+		if len(editList) > 0 || len(relists) > 0 {
 			ctx := context.Background()
 			sess, conn, err := Conn(ctx)
 			if err != nil {
@@ -151,13 +168,16 @@ func EnsureExgSymbols(exchange banexg.BanExchange) *errs.Error {
 			}
 			defer conn.Release()
 			for _, exs := range editList {
-				err_ := sess.SetListMS(context.Background(), SetListMSParams{
-					ID:       exs.ID,
-					ListMs:   exs.ListMs,
-					DelistMs: exs.DelistMs,
-				})
-				if err_ != nil {
-					return NewDbErr(core.ErrDbExecFail, err_)
+				err = sess.SaveListMS(exs)
+				if err != nil {
+					return err
+				}
+			}
+			for _, exs := range relists {
+				log.Info("symbol relisted", zap.String("symbol", exs.Symbol), zap.String("exg", exs.Exchange))
+				err = sess.Relist(exs, curMS)
+				if err != nil {
+					return err
 				}
 			}
 		}
@@ -176,6 +196,11 @@ func EnsureCurSymbols(symbols []string) *errs.Error {
 	for _, symbol := range symbols {
 		mar, ok := marMap[symbol]
 		if !ok {
+			// delisted symbols in backtest are kept in cache 回测时已退市的品种保留在缓存中
+			if exs := GetExSymbol2(exgId, marketType, symbol); exs != nil {
+				exsList = append(exsList, exs)
+				continue
+			}
 			return errs.NewMsg(core.ErrInvalidSymbol, symbol)
 		}
 		exsList = append(exsList, &ExSymbol{
@@ -334,6 +359,10 @@ func (s *ExSymbol) ToShort() string {
 	}
 }
 
+func (s *ExSymbol) VenueKey() string {
+	return core.VenueKey(s.Exchange, s.Market, s.Symbol)
+}
+
 func (s *ExSymbol) InfoBy() string {
 	if s.Exchange == "china" && s.Market != banexg.MarketSpot {
 		// 中国非股票现货市场，K线的info字段存储持仓量，使用last归集
@@ -372,13 +401,9 @@ func InitListDates() *errs.Error {
 			changed = true
 		}
 		if changed {
-			err_ := sess.SetListMS(context.Background(), SetListMSParams{
-				ID:       exs.ID,
-				ListMs:   exs.ListMs,
-				DelistMs: exs.DelistMs,
-			})
-			if err_ != nil {
-				return NewDbErr(core.ErrDbExecFail, err_)
+			err = sess.SaveListMS(exs)
+			if err != nil {
+				return err
 			}
 		}
 	}
@@ -438,13 +463,9 @@ func EnsureListDates(sess *Queries, exchange banexg.BanExchange, exsMap map[int3
 		}
 		if len(klines) > 0 {
 			exs.ListMs = klines[0].Time
-			err_ := sess.SetListMS(context.Background(), SetListMSParams{
-				ID:       exs.ID,
-				ListMs:   exs.ListMs,
-				DelistMs: exs.DelistMs,
-			})
-			if err_ != nil {
-				return NewDbErr(core.ErrDbExecFail, err_)
+			err = sess.SaveListMS(exs)
+			if err != nil {
+				return err
 			}
 		}
 	}
@@ -69,3 +69,32 @@ func TestGetExSymbol(t *testing.T) {
 		})
 	}
 }
+
+func TestListPeriodAt(t *testing.T) {
+	exs := &ExSymbol{ID: -1, Symbol: "AAA/USDT", ListMs: 1000, DelistMs: 5000}
+	listDateLock.Lock()
+	sidListDates[exs.ID] = []*ListDate{
+		{Sid: exs.ID, ListMs: 1000, DelistMs: 3000},
+		{Sid: exs.ID, ListMs: 4000, DelistMs: 5000},
+	}
+	listDateLock.Unlock()
+	defer func() {
+		listDateLock.Lock()
+		delete(sidListDates, exs.ID)
+		listDateLock.Unlock()
+	}()
+	cases := map[int64]bool{500: false, 1000: true, 2999: true, 3000: false, 3500: false, 4500: true, 5000: false}
+	for timeMS, expect := range cases {
+		if exs.TradableAt(timeMS) != expect {
+			t.Errorf("TradableAt(%v) should be %v", timeMS, expect)
+		}
+	}
+	_, delistMS, _ := exs.ListPeriodAt(4500)
+	if delistMS != 5000 {
+		t.Errorf("delistMS should be 5000, got %v", delistMS)
+	}
+	noHis := &ExSymbol{ID: -2, ListMs: 1000}
+	if !noHis.TradableAt(99999) || noHis.TradableAt(999) {
+		t.Error("fallback to ListMs fail")
+	}
+}
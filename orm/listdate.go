@@ -0,0 +1,181 @@
+package orm
+
+import (
+	"context"
+	"slices"
+
+	"github.com/banbox/banbot/core"
+	"github.com/banbox/banexg/errs"
+	"github.com/sasha-s/go-deadlock"
+)
+
+var (
+	sidListDates = make(map[int32][]*ListDate) // listing periods of each sid, sorted by ListMs 每个品种的上市区间，按ListMs排序
+	listDateLock deadlock.Mutex
+)
+
+/*
+LoadListDates
+Load the listing and delisting history of all symbols into the cache
+加载所有品种的上市和退市历史到缓存
+*/
+func (q *Queries) LoadListDates() *errs.Error {
+	items, err_ := q.ListListDates(context.Background())
+	if err_ != nil {
+		return NewDbErr(core.ErrDbReadFail, err_)
+	}
+	res := make(map[int32][]*ListDate)
+	for _, it := range items {
+		res[it.Sid] = append(res[it.Sid], it)
+	}
+	listDateLock.Lock()
+	sidListDates = res
+	listDateLock.Unlock()
+	return nil
+}
+
+/*
+GetListDates
+Listing periods of the symbol sorted by time, the DelistMs of the last one is 0 if it's still listed
+品种的上市区间，按时间排序，若仍在上市，最后一个的DelistMs为0
+*/
+func GetListDates(sid int32) []*ListDate {
+	listDateLock.Lock()
+	defer listDateLock.Unlock()
+	return slices.Clone(sidListDates[sid])
+}
+
+/*
+SaveListMS
+Save ListMs and DelistMs of the symbol, and sync them to the first and last listing period
+保存品种的ListMs和DelistMs，并同步到第一个和最后一个上市区间
+*/
+func (q *Queries) SaveListMS(exs *ExSymbol) *errs.Error {
+	err_ := q.SetListMS(context.Background(), SetListMSParams{
+		ID:       exs.ID,
+		ListMs:   exs.ListMs,
+		DelistMs: exs.DelistMs,
+	})
+	if err_ != nil {
+		return NewDbErr(core.ErrDbExecFail, err_)
+	}
+	if exs.ListMs == 0 && exs.DelistMs == 0 {
+		return nil
+	}
+	listDateLock.Lock()
+	defer listDateLock.Unlock()
+	arr := sidListDates[exs.ID]
+	if len(arr) == 0 {
+		return q.addListDate(exs.ID, exs.ListMs, exs.DelistMs)
+	}
+	first, last := arr[0], arr[len(arr)-1]
+	if exs.ListMs > 0 && first.ListMs != exs.ListMs {
+		first.ListMs = exs.ListMs
+		err := q.setListDate(first)
+		if err != nil {
+			return err
+		}
+	}
+	if last.DelistMs != exs.DelistMs {
+		last.DelistMs = exs.DelistMs
+		return q.setListDate(last)
+	}
+	return nil
+}
+
+/*
+Relist
+Mark the delisted symbol as listed again from timeMS, a new listing period is added
+将已退市的品种标记为从timeMS重新上市，会添加一个新的上市区间
+*/
+func (q *Queries) Relist(exs *ExSymbol, timeMS int64) *errs.Error {
+	listDateLock.Lock()
+	arr := sidListDates[exs.ID]
+	if len(arr) == 0 && exs.ListMs > 0 {
+		// keep the old period before relisting 保留重新上市前的旧区间
+		err := q.addListDate(exs.ID, exs.ListMs, exs.DelistMs)
+		if err != nil {
+			listDateLock.Unlock()
+			return err
+		}
+	}
+	err := q.addListDate(exs.ID, timeMS, 0)
+	listDateLock.Unlock()
+	if err != nil {
+		return err
+	}
+	exs.DelistMs = 0
+	return q.SaveListMS(exs)
+}
+
+func (q *Queries) addListDate(sid int32, listMS, delistMS int64) *errs.Error {
+	id, err_ := q.AddListDate(context.Background(), AddListDateParams{
+		Sid:      sid,
+		ListMs:   listMS,
+		DelistMs: delistMS,
+	})
+	if err_ != nil {
+		return NewDbErr(core.ErrDbExecFail, err_)
+	}
+	sidListDates[sid] = append(sidListDates[sid], &ListDate{ID: id, Sid: sid, ListMs: listMS, DelistMs: delistMS})
+	return nil
+}
+
+func (q *Queries) setListDate(item *ListDate) *errs.Error {
+	err_ := q.SetListDate(context.Background(), SetListDateParams{
+		ID:       item.ID,
+		ListMs:   item.ListMs,
+		DelistMs: item.DelistMs,
+	})
+	if err_ != nil {
+		return NewDbErr(core.ErrDbExecFail, err_)
+	}
+	return nil
+}
+
+/*
+ListPeriodAt
+Return the listing period containing timeMS: listMS, delistMS, ok. delistMS is 0 if not delisted yet.
+Fall back to ListMs and DelistMs of the symbol when no history is recorded. An unknown ListMs is regarded as listed.
+返回包含timeMS的上市区间：listMS, delistMS, ok。尚未退市时delistMS为0。
+无历史记录时使用品种的ListMs和DelistMs。ListMs未知时视为已上市
+*/
+func (s *ExSymbol) ListPeriodAt(timeMS int64) (int64, int64, bool) {
+	listDateLock.Lock()
+	arr := sidListDates[s.ID]
+	listDateLock.Unlock()
+	if len(arr) == 0 {
+		arr = []*ListDate{{ListMs: s.ListMs, DelistMs: s.DelistMs}}
+	}
+	for _, it := range arr {
+		if it.ListMs <= timeMS && (it.DelistMs == 0 || timeMS < it.DelistMs) {
+			return it.ListMs, it.DelistMs, true
+		}
+	}
+	return 0, 0, false
+}
+
+/*
+TradableAt
+Whether the symbol is listed and not delisted at timeMS
+品种在timeMS时是否已上市且未退市
+*/
+func (s *ExSymbol) TradableAt(timeMS int64) bool {
+	_, _, ok := s.ListPeriodAt(timeMS)
+	return ok
+}
+
+/*
+GetTradableSymbols
+Symbols of the exchange and market which are tradable at timeMS, including those delisted later, keyed by symbol
+交易所和市场中在timeMS时可交易的品种，包括之后退市的品种，键为symbol
+*/
+func GetTradableSymbols(exgName, market string, timeMS int64) map[string]*ExSymbol {
+	res := GetExSymbolMap(exgName, market)
+	for symbol, exs := range res {
+		if !exs.TradableAt(timeMS) {
+			delete(res, symbol)
+		}
+	}
+	return res
+}
@@ -30,6 +30,13 @@ type ExSymbol struct {
 	DelistMs int64  `json:"delist_ms"`
 }
 
+type HaLease struct {
+	Name     string `json:"name"`
+	Holder   string `json:"holder"`
+	ExpireAt int64  `json:"expire_at"`
+	UpdateAt int64  `json:"update_at"`
+}
+
 type InsKline struct {
 	ID        int32  `json:"id"`
 	Sid       int32  `json:"sid"`
@@ -66,3 +73,10 @@ type KlineUn struct {
 	Volume    float64 `json:"volume"`
 	Info      float64 `json:"info"`
 }
+
+type ListDate struct {
+	ID       int64 `json:"id"`
+	Sid      int32 `json:"sid"`
+	ListMs   int64 `json:"list_ms"`
+	DelistMs int64 `json:"delist_ms"`
+}
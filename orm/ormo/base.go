@@ -115,6 +115,31 @@ func OpenNum(account string, status int64) int {
 	return openNum
 }
 
+/*
+GetGroupODs
+Returns open orders of all accounts sharing the group id, such as linked legs of a hedge
+返回所有账户中共享组ID的未平仓订单，如对冲的关联腿
+*/
+func GetGroupODs(group string) []*InOutOrder {
+	var res []*InOutOrder
+	if group == "" {
+		return res
+	}
+	mOpenLock.Lock()
+	for accKey, ods := range accOpenODs {
+		lock, _ := lockOpenMap[accKey]
+		lock.Lock()
+		for _, od := range ods {
+			if od.Status < InOutStatusFullExit && od.GroupID() == group {
+				res = append(res, od)
+			}
+		}
+		lock.Unlock()
+	}
+	mOpenLock.Unlock()
+	return res
+}
+
 /*
 SaveDirtyODs
 Find unsaved orders from open orders and save them all to the database
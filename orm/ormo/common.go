@@ -37,7 +37,7 @@ func decodeIOrderInfo(text string) map[string]interface{} {
 				if floatVal, ok := val.(float64); ok {
 					result[key] = int64(math.Round(floatVal))
 				}
-			} else if key == OdInfoStopLoss || key == OdInfoTakeProfit {
+			} else if key == OdInfoStopLoss || key == OdInfoTakeProfit || key == OdInfoTrailStop {
 				if mapVal, ok := val.(map[string]interface{}); ok {
 					state := decodeTriggerState(mapVal)
 					if state == nil {
@@ -46,6 +46,10 @@ func decodeIOrderInfo(text string) map[string]interface{} {
 						result[key] = state
 					}
 				}
+			} else if key == OdInfoEnterAlgo || key == OdInfoExitAlgo {
+				if mapVal, ok := val.(map[string]interface{}); ok {
+					result[key] = decodeAlgoState(mapVal)
+				}
 			}
 		}
 	}
@@ -68,6 +72,12 @@ func decodeTriggerState(data map[string]interface{}) *TriggerState {
 	if v, ok := data["order_id"].(string); ok {
 		ts.OrderId = v
 	}
+	if v, ok := data["best"].(float64); ok {
+		ts.Best = v
+	}
+	if v, ok := data["native"].(bool); ok {
+		ts.Native = v
+	}
 
 	// 处理嵌套的Old字段
 	if oldData, ok := data["old"].(map[string]interface{}); ok {
@@ -91,5 +101,58 @@ func decodeTrigger(data map[string]interface{}) *ExitTrigger {
 	if v, ok := data["tag"].(string); ok {
 		ts.Tag = v
 	}
+	if v, ok := data["callback"].(float64); ok {
+		ts.CallBack = v
+	}
+	if v, ok := data["activation"].(float64); ok {
+		ts.Activation = v
+	}
+	if v, ok := data["oco"].(bool); ok {
+		ts.OCO = v
+	}
 	return ts
 }
+
+func decodeAlgoState(data map[string]interface{}) *AlgoState {
+	res := &AlgoState{ExecAlgo: &ExecAlgo{}}
+	if v, ok := data["name"].(string); ok {
+		res.Name = v
+	}
+	if v, ok := data["minutes"].(float64); ok {
+		res.Minutes = v
+	}
+	if v, ok := data["slices"].(float64); ok {
+		res.Slices = int(v)
+	}
+	if v, ok := data["visible"].(float64); ok {
+		res.Visible = v
+	}
+	if v, ok := data["max_rate"].(float64); ok {
+		res.MaxRate = v
+	}
+	if v, ok := data["start_ms"].(float64); ok {
+		res.StartMS = int64(v)
+	}
+	if v, ok := data["next_ms"].(float64); ok {
+		res.NextMS = int64(v)
+	}
+	if v, ok := data["filled"].(float64); ok {
+		res.Filled = v
+	}
+	if v, ok := data["cost"].(float64); ok {
+		res.Cost = v
+	}
+	if v, ok := data["fee"].(float64); ok {
+		res.Fee = v
+	}
+	if v, ok := data["fee_type"].(string); ok {
+		res.FeeType = v
+	}
+	if v, ok := data["child"].(string); ok {
+		res.Child = v
+	}
+	if v, ok := data["child_num"].(float64); ok {
+		res.ChildNum = int(v)
+	}
+	return res
+}
@@ -40,9 +40,27 @@ const (
 	OdInfoStopAfter  = "StopAfter"
 	OdInfoStopLoss   = "StopLoss"
 	OdInfoTakeProfit = "TakeProfit"
+	OdInfoTrailStop  = "TrailStop"
 	OdInfoClientID   = "ClientID"
+	OdInfoEnterAlgo  = "EnterAlgo"
+	OdInfoExitAlgo   = "ExitAlgo"
+	OdInfoGroup      = "Group"
+	OdInfoExitSignal = "ExitSignal" // Price when the exit was requested 请求平仓时的价格
+	OdInfoFunding    = "Funding"    // Funding fee paid, negative if received 支付的资金费，收取时为负
+	OdInfoFundAt     = "FundAt"     // Settle time of the latest funding fee charged 最近一次扣除的资金费的结算时间
+	OdInfoReportRate = "ReportRate" // Rate from quote to report currency at the last profit update 最近更新利润时定价币到报告币种的汇率
+	OdInfoLiqPrice   = "LiqPrice"   // Liquidation price at the last wallet check in backtest 回测中最近一次钱包检查时的强平价格
 )
 
+const (
+	AlgoTWAP    = "twap"    // Time-weighted average price 时间加权均价
+	AlgoIceberg = "iceberg" // Show only a small slice of the total amount 只展示总数量的一小部分
+	AlgoPOV     = "pov"     // Percentage of traded volume 按市场成交量比例参与
+)
+
+// ExitTriggerKeys all keys of exit triggers in InOutOrder.Info 订单Info中所有离场触发的键
+var ExitTriggerKeys = []string{OdInfoStopLoss, OdInfoTakeProfit, OdInfoTrailStop}
+
 const (
 	OdActionEnter      = "Enter"
 	OdActionExit       = "Exit"
@@ -50,4 +68,5 @@ const (
 	OdActionLimitExit  = "LimitExit"
 	OdActionStopLoss   = "StopLoss"
 	OdActionTakeProfit = "TakeProfit"
+	OdActionTrailStop  = "TrailStop"
 )
@@ -4,6 +4,18 @@
 
 package ormo
 
+type ApiAudit struct {
+	ID       int64  `json:"id"`
+	User     string `json:"user"`
+	Ip       string `json:"ip"`
+	Account  string `json:"account"`
+	Method   string `json:"method"`
+	Path     string `json:"path"`
+	Payload  string `json:"payload"`
+	Status   int64  `json:"status"`
+	CreateAt int64  `json:"create_at"`
+}
+
 type BotTask struct {
 	ID       int64  `json:"id"`
 	Mode     string `json:"mode"`
@@ -57,3 +69,22 @@ type IOrder struct {
 	Profit      float64 `json:"profit"`
 	Info        string  `json:"info"`
 }
+
+type SignalLog struct {
+	ID       int64   `json:"id"`
+	IdemKey  string  `json:"idem_key"`
+	Nonce    string  `json:"nonce"`
+	Account  string  `json:"account"`
+	Strategy string  `json:"strategy"`
+	Symbol   string  `json:"symbol"`
+	Action   string  `json:"action"`
+	Side     string  `json:"side"`
+	Size     float64 `json:"size"`
+	Payload  string  `json:"payload"`
+	Ip       string  `json:"ip"`
+	Status   string  `json:"status"`
+	Message  string  `json:"message"`
+	OdIds    string  `json:"od_ids"`
+	CreateAt int64   `json:"create_at"`
+	UpdateAt int64   `json:"update_at"`
+}
@@ -145,6 +145,37 @@ func (i *InOutOrder) Key() string {
 	return i.idKey
 }
 
+/*
+VenueKey
+Key of the symbol on the exchange and market the order is on, same as bar.Symbol of the kline feed and the key of
+its price, see core.VenueKey
+订单所在交易所和市场上品种的键，与K线的bar.Symbol及其价格的键一致，见core.VenueKey
+*/
+func (i *InOutOrder) VenueKey() string {
+	exs := orm.GetSymbolByID(int32(i.Sid))
+	if exs == nil {
+		return i.Symbol
+	}
+	return exs.VenueKey()
+}
+
+// CurPrice latest price of the order's symbol on its venue, -1 if not loaded 订单所在场所品种的最新价格，未加载时为-1
+func (i *InOutOrder) CurPrice() float64 {
+	return core.GetPriceSafe(i.VenueKey())
+}
+
+// RefPrice latest price of the order, fall back to the entry price if not loaded 订单最新价格，未加载时使用入场价格
+func (i *InOutOrder) RefPrice() float64 {
+	if price := i.CurPrice(); price > 0 {
+		return price
+	} else if i.Enter != nil && i.Enter.Average > 0 {
+		return i.Enter.Average
+	} else if i.Enter != nil && i.Enter.Price > 0 {
+		return i.Enter.Price
+	}
+	return i.InitPrice
+}
+
 /*
 KeyAlign 开单时间戳按时间周期对齐，方便回测和实盘订单对比
 */
@@ -213,7 +244,16 @@ func (i *InOutOrder) UpdateProfits(price float64) {
 	if i.Exit != nil && !math.IsNaN(i.Exit.Fee) && !math.IsInf(i.Exit.Fee, 0) {
 		exitFee = i.Exit.Fee
 	}
-	i.Profit = profitVal - enterFee - exitFee
+	i.Profit = profitVal - enterFee - exitFee - i.GetInfoFloat64(OdInfoFunding)
+	quote, report := core.QuoteCode(i.Symbol), config.ReportCurrency
+	if report != "" && quote != report && !(core.IsFiat(quote) && core.IsFiat(report)) {
+		// record the rate to report currency, the rate at exit is kept after the order closed
+		// 记录到报告币种的汇率，订单平仓后保留平仓时的汇率
+		quoteRate, reportRate := core.LegalRate(quote), core.LegalRate(config.ReportCurrency)
+		if quoteRate > 0 && reportRate > 0 {
+			i.SetInfo(OdInfoReportRate, quoteRate/reportRate)
+		}
+	}
 	entPrice := i.InitPrice
 	if i.Enter.Average > 0 {
 		entPrice = i.Enter.Average
@@ -245,6 +285,13 @@ Calculates commission for entry/exit orders. Must be called after Filled is assi
 */
 func (i *InOutOrder) UpdateFee(price float64, forEnter bool, isHistory bool) *errs.Error {
 	exchange := exg.Default
+	if exs := orm.GetSymbolByID(int32(i.Sid)); exs != nil {
+		var err *errs.Error
+		exchange, err = exg.GetVenue(exs.Exchange, exs.Market)
+		if err != nil {
+			return err
+		}
+	}
 	exOrder := i.Enter
 	if !forEnter {
 		exOrder = i.Exit
@@ -255,7 +302,7 @@ func (i *InOutOrder) UpdateFee(price float64, forEnter bool, isHistory bool) *er
 			// 历史已完成订单，不使用当前价格判断是否为maker，直接认为maker
 			maker = true
 		} else {
-			maker = core.IsMaker(i.Symbol, exOrder.Side, price)
+			maker = core.IsMaker(i.VenueKey(), exOrder.Side, price)
 		}
 	}
 	fee, err := exchange.CalculateFee(i.Symbol, exOrder.OrderType, exOrder.Side, exOrder.Filled, price, maker, nil)
@@ -335,16 +382,7 @@ When calling this function on a real drive, it will be saved to the database
 */
 func (i *InOutOrder) LocalExit(exitAt int64, tag string, price float64, msg, odType string) *errs.Error {
 	if price == 0 {
-		newPrice := core.GetPrice(i.Symbol)
-		if newPrice > 0 {
-			price = newPrice
-		} else if i.Enter.Average > 0 {
-			price = i.Enter.Average
-		} else if i.Enter.Price > 0 {
-			price = i.Enter.Price
-		} else {
-			price = i.InitPrice
-		}
+		price = i.RefPrice()
 	}
 	if i.Enter.Status < OdStatusClosed {
 		i.Enter.Status = OdStatusClosed
@@ -409,9 +447,15 @@ func (i *InOutOrder) CutPart(enterAmt, exitAmt float64) *InOutOrder {
 		DirtyInfo:  true,
 		idKey:      i.idKey,
 	}
+	i.loadInfo()
 	for key, val := range i.Info {
 		part.Info[key] = val
 	}
+	if funding := i.GetInfoFloat64(OdInfoFunding); funding != 0 {
+		// split funding fee paid by amount 按数量拆分已付资金费
+		part.Info[OdInfoFunding] = funding * enterRate
+		i.SetInfo(OdInfoFunding, funding*(1-enterRate))
+	}
 	// The enter.at of the original order needs to be+1 to prevent conflicts with sub orders that have been split.
 	// 原来订单的enter_at需要+1，防止和拆分的子订单冲突。
 	i.EnterAt += 1
@@ -599,7 +643,7 @@ func (i *InOutOrder) GetInfoText() (string, *errs.Error) {
 func (i *InOutOrder) SetExitTrigger(key string, args *ExitTrigger) {
 	var empty *TriggerState
 	tg := utils2.GetMapVal(i.Info, key, empty)
-	if args == nil || args.Price == 0 {
+	if args == nil || args.Price == 0 && args.CallBack == 0 {
 		if tg != nil && tg.OrderId != "" {
 			tg.ExitTrigger = &ExitTrigger{}
 			i.SetInfo(key, tg)
@@ -613,13 +657,17 @@ func (i *InOutOrder) SetExitTrigger(key string, args *ExitTrigger) {
 	var rangeVal float64
 	if args.Limit != 0 {
 		rangeVal = math.Abs(i.InitPrice - args.Limit)
-	} else {
+	} else if args.Price != 0 {
 		rangeVal = math.Abs(i.InitPrice - args.Price)
+	} else {
+		// trailing stop not activated yet 跟踪止损尚未激活
+		rangeVal = i.InitPrice * args.CallBack
 	}
 	var changed = true
 	if tg.ExitTrigger != nil {
 		old := tg.ExitTrigger
-		changed = old.Price != args.Price || old.Limit != args.Limit || old.Rate != args.Rate
+		changed = old.Price != args.Price || old.Limit != args.Limit || old.Rate != args.Rate ||
+			old.CallBack != args.CallBack || old.Activation != args.Activation
 	}
 	tg.Range = rangeVal
 	tg.ExitTrigger = args
@@ -637,6 +685,84 @@ func (i *InOutOrder) SetTakeProfit(args *ExitTrigger) {
 	i.SetExitTrigger(OdInfoTakeProfit, args)
 }
 
+/*
+SetTrailStop
+Set a trailing stop, args.CallBack is required. The stop price moves with the best price after activated
+设置跟踪止损，args.CallBack必填。激活后止损价格跟随最优价格移动
+*/
+func (i *InOutOrder) SetTrailStop(args *ExitTrigger) {
+	if args != nil && args.CallBack > 0 && args.Price == 0 {
+		old := i.GetTrailStop()
+		if old != nil && old.Best > 0 {
+			args.Price = CalcTrailPrice(old.Best, args.CallBack, i.Short)
+		}
+	}
+	i.SetExitTrigger(OdInfoTrailStop, args)
+}
+
+/*
+SetBracket
+Set stop loss and take profit as an OCO bracket, the one hit first cancels the other
+设置止损和止盈为OCO括号单，先触发的一方取消另一方
+*/
+func (i *InOutOrder) SetBracket(sl, tp *ExitTrigger) {
+	if sl != nil {
+		sl.OCO = true
+	}
+	if tp != nil {
+		tp.OCO = true
+	}
+	i.SetStopLoss(sl)
+	i.SetTakeProfit(tp)
+}
+
+/*
+CancelOCO
+Cancel other exit triggers when the hit trigger is OCO, return whether cancelled
+触发的是OCO时，取消其他的离场触发，返回是否已取消
+*/
+func (i *InOutOrder) CancelOCO(key string) bool {
+	tg := i.GetExitTrigger(key)
+	if tg == nil || tg.ExitTrigger == nil || !tg.OCO {
+		return false
+	}
+	for _, k := range ExitTriggerKeys {
+		if k != key && i.GetExitTrigger(k) != nil {
+			i.SetExitTrigger(k, nil)
+		}
+	}
+	return true
+}
+
+/*
+UpdateTrailStop
+Emulate the trailing stop on client side with the latest price range, native trailing stop on exchange is skipped.
+The stop price is only moved when changed by at least minRate of it, to avoid re-placing the stop order too often.
+Return whether the stop price changed.
+在客户端使用最新价格区间模拟跟踪止损，交易所原生跟踪止损跳过。止损价格变化至少minRate时才移动，避免频繁重新挂止损单。
+返回止损价格是否变化
+*/
+func (i *InOutOrder) UpdateTrailStop(high, low, minRate float64) bool {
+	tg := i.GetTrailStop()
+	if tg == nil || tg.Native {
+		return false
+	}
+	oldPrice := tg.Price
+	if !tg.UpdateTrail(i.Short, high, low) {
+		return false
+	}
+	// best price is updated 最优价格已更新
+	i.DirtyInfo = true
+	if oldPrice > 0 && math.Abs(tg.Price-oldPrice) < oldPrice*minRate {
+		tg.Price = oldPrice
+		return false
+	}
+	tg.Range = math.Abs(i.InitPrice - tg.Price)
+	i.DirtyInfo = true
+	fireOdEdit(i, OdInfoTrailStop)
+	return true
+}
+
 func (i *InOutOrder) GetExitTrigger(key string) *TriggerState {
 	i.loadInfo()
 	var empty *TriggerState
@@ -651,6 +777,93 @@ func (i *InOutOrder) GetTakeProfit() *TriggerState {
 	return i.GetExitTrigger(OdInfoTakeProfit)
 }
 
+func (i *InOutOrder) GetTrailStop() *TriggerState {
+	return i.GetExitTrigger(OdInfoTrailStop)
+}
+
+/*
+SetExecAlgo
+Set the execution algorithm of enter or exit sub order, nil to cancel
+设置入场或出场子订单的执行算法，nil表示取消
+*/
+func (i *InOutOrder) SetExecAlgo(isEnter bool, algo *ExecAlgo) {
+	key := OdInfoExitAlgo
+	if isEnter {
+		key = OdInfoEnterAlgo
+	}
+	if algo == nil {
+		i.SetInfo(key, nil)
+	} else {
+		i.SetInfo(key, &AlgoState{ExecAlgo: algo})
+	}
+}
+
+func (i *InOutOrder) GetExecAlgo(isEnter bool) *AlgoState {
+	key := OdInfoExitAlgo
+	if isEnter {
+		key = OdInfoEnterAlgo
+	}
+	i.loadInfo()
+	var empty *AlgoState
+	return utils2.GetMapVal(i.Info, key, empty)
+}
+
+/*
+GroupID
+Id shared by linked orders such as legs of a hedge, empty for independent orders
+关联订单（如对冲的各腿）共享的ID，独立订单为空
+*/
+func (i *InOutOrder) GroupID() string {
+	return i.GetInfoString(OdInfoGroup)
+}
+
+/*
+ReportRate
+Rate to convert Profit and cost of the order from its quote currency to config.ReportCurrency, 1 if same or fiat
+将订单Profit和成本从定价币换算到config.ReportCurrency的汇率，相同或为法币时为1
+*/
+func (i *InOutOrder) ReportRate() float64 {
+	rate := i.GetInfoFloat64(OdInfoReportRate)
+	if rate <= 0 {
+		return 1
+	}
+	return rate
+}
+
+// ReportProfit Profit in config.ReportCurrency 以config.ReportCurrency计价的利润
+func (i *InOutOrder) ReportProfit() float64 {
+	return i.Profit * i.ReportRate()
+}
+
+/*
+AddFunding
+Accumulate the funding fee of the position, positive if paid, negative if received. It's deducted from Profit
+累加仓位的资金费，支付为正，收取为负。会从Profit中扣除
+*/
+func (i *InOutOrder) AddFunding(amount float64) {
+	if amount == 0 {
+		return
+	}
+	i.SetInfo(OdInfoFunding, i.GetInfoFloat64(OdInfoFunding)+amount)
+}
+
+/*
+CalcGroupProfit
+Combined profit and profit rate of linked orders, the rate is relative to the sum of margin
+关联订单的合计利润和利润率，利润率相对保证金之和
+*/
+func CalcGroupProfit(orders []*InOutOrder) (float64, float64) {
+	var profit, cost float64
+	for _, od := range orders {
+		profit += od.Profit
+		cost += od.EnterCost() / od.Leverage
+	}
+	if cost == 0 {
+		return profit, 0
+	}
+	return profit, profit / cost
+}
+
 /*
 ClientId
 Generate the exchange's ClientOrderId
@@ -1057,6 +1270,7 @@ func (q *Queries) getExOrders(sql string, args []interface{}) ([]*ExOrder, *errs
 }
 
 type GetOrdersArgs struct {
+	ID          int64 // >0 represents the specified order 大于0表示指定订单
 	Strategy    string
 	Pairs       []string
 	TimeFrame   string
@@ -1082,6 +1296,10 @@ func (q *Queries) GetOrders(args GetOrdersArgs) ([]*InOutOrder, *errs.Error) {
 		b.WriteString(fmt.Sprintf("and task_id=$%v ", len(sqlParams)+1))
 		sqlParams = append(sqlParams, args.TaskID)
 	}
+	if args.ID > 0 {
+		b.WriteString(fmt.Sprintf("and id=$%v ", len(sqlParams)+1))
+		sqlParams = append(sqlParams, args.ID)
+	}
 	if args.AfterID > 0 {
 		b.WriteString(fmt.Sprintf(" and id > $%v ", len(sqlParams)+1))
 		sqlParams = append(sqlParams, args.AfterID)
@@ -1269,6 +1487,9 @@ func (s *TriggerState) SaveOld() {
 		s.Old.Price = s.Price
 		s.Old.Limit = s.Limit
 		s.Old.Rate = s.Rate
+		s.Old.CallBack = s.CallBack
+		s.Old.Activation = s.Activation
+		s.Old.OCO = s.OCO
 		if s.Tag != "" {
 			s.Old.Tag = s.Tag
 		}
@@ -1280,23 +1501,182 @@ func (s *TriggerState) Clone() *TriggerState {
 		return nil
 	}
 	return &TriggerState{
-		ExitTrigger: &ExitTrigger{
-			Price: s.Price,
-			Limit: s.Limit,
-			Rate:  s.Rate,
-			Tag:   s.Tag,
-		},
-		Range:   s.Rate,
-		Hit:     s.Hit,
-		OrderId: s.OrderId,
+		ExitTrigger: s.ExitTrigger.Clone(),
+		Range:       s.Range,
+		Hit:         s.Hit,
+		OrderId:     s.OrderId,
+		Best:        s.Best,
+		Native:      s.Native,
+	}
+}
+
+/*
+UpdateTrail
+Update the best price and stop price of trailing stop with the reached price range, return whether the stop price changed
+使用到达的价格区间更新跟踪止损的最优价格和止损价格，返回止损价格是否变化
+*/
+func (s *TriggerState) UpdateTrail(short bool, high, low float64) bool {
+	if s == nil || s.ExitTrigger == nil || s.CallBack <= 0 {
+		return false
+	}
+	best := high
+	if short {
+		best = low
+	}
+	if s.Best == 0 {
+		if s.Activation > 0 && (short && low > s.Activation || !short && high < s.Activation) {
+			// activation price not reached 尚未到达激活价格
+			return false
+		}
+	} else if short && best >= s.Best || !short && best <= s.Best {
+		return false
+	}
+	s.Best = best
+	price := CalcTrailPrice(best, s.CallBack, short)
+	if price == s.Price {
+		return false
+	}
+	s.Price = price
+	return true
+}
+
+/*
+CalcTrailPrice
+Calculate the stop price of trailing stop from the best price
+根据最优价格计算跟踪止损的止损价格
+*/
+func CalcTrailPrice(best, callBack float64, short bool) float64 {
+	if short {
+		return best * (1 + callBack)
+	}
+	return best * (1 - callBack)
+}
+
+/*
+Validate
+Check whether the args of execution algorithm are valid
+检查执行算法的参数是否有效
+*/
+func (a *ExecAlgo) Validate() *errs.Error {
+	switch a.Name {
+	case AlgoTWAP:
+		if a.Minutes <= 0 {
+			return errs.NewMsg(errs.CodeParamRequired, "minutes is required for twap")
+		}
+	case AlgoIceberg:
+		if a.Visible <= 0 {
+			return errs.NewMsg(errs.CodeParamRequired, "visible is required for iceberg")
+		}
+	case AlgoPOV:
+		if a.MaxRate <= 0 {
+			return errs.NewMsg(errs.CodeParamRequired, "max_rate is required for pov")
+		}
+	default:
+		return errs.NewMsg(errs.CodeParamInvalid, "unknown exec algo: %s", a.Name)
+	}
+	if a.MaxRate < 0 || a.MaxRate > 1 {
+		return errs.NewMsg(errs.CodeParamInvalid, "max_rate should in (0, 1], current: %f", a.MaxRate)
+	}
+	return nil
+}
+
+func (a *ExecAlgo) sliceNum() int {
+	if a.Slices > 0 {
+		return a.Slices
+	}
+	return max(1, int(math.Ceil(a.Minutes)))
+}
+
+/*
+EndMS
+Deadline of TWAP, all remaining amount should be submitted after this. 0 for other algos
+TWAP的截止时间，此后应提交所有剩余数量。其他算法为0
+*/
+func (s *AlgoState) EndMS() int64 {
+	if s.Name != AlgoTWAP {
+		return 0
 	}
+	return s.StartMS + int64(s.Minutes*60000)
+}
+
+/*
+SliceAmount
+Amount of the next child order. total is the amount of the parent sub order, vol is the traded volume
+of market since last child order, only used by POV
+下一个子订单的数量。total为父子订单的数量，vol为上个子订单以来的市场成交量，仅POV使用
+*/
+func (s *AlgoState) SliceAmount(total float64, curMS int64, vol float64) float64 {
+	remain := total - s.Filled
+	if remain <= 0 {
+		return 0
+	}
+	switch s.Name {
+	case AlgoTWAP:
+		if curMS >= s.EndMS() {
+			return remain
+		}
+		num := s.sliceNum()
+		intv := s.Minutes * 60000 / float64(num)
+		due := min(num, int(float64(curMS-s.StartMS)/intv)+1)
+		return max(0, total*float64(due)/float64(num)-s.Filled)
+	case AlgoIceberg:
+		return min(remain, s.Visible)
+	case AlgoPOV:
+		return min(remain, vol*s.MaxRate)
+	}
+	return remain
+}
+
+/*
+NextChildMS
+The earliest time for the child order after curMS
+curMS之后下一个子订单的最早时间
+*/
+func (s *AlgoState) NextChildMS(curMS int64) int64 {
+	switch s.Name {
+	case AlgoTWAP:
+		intv := int64(s.Minutes*60000) / int64(s.sliceNum())
+		if intv <= 0 {
+			return curMS
+		}
+		return s.StartMS + ((curMS-s.StartMS)/intv+1)*intv
+	case AlgoPOV:
+		return curMS + 60000
+	}
+	// Iceberg: next slice is shown once the current one is filled
+	// 冰山单：当前部分成交后立刻展示下一部分
+	return curMS
+}
+
+/*
+AddFill
+Roll up a finished child order
+汇总一个已完成的子订单
+*/
+func (s *AlgoState) AddFill(amount, price, fee float64, feeType string) {
+	s.Filled += amount
+	s.Cost += amount * price
+	s.Fee += fee
+	if feeType != "" {
+		s.FeeType = feeType
+	}
+}
+
+func (s *AlgoState) Average() float64 {
+	if s.Filled == 0 {
+		return 0
+	}
+	return s.Cost / s.Filled
 }
 
 func (t *ExitTrigger) Equal(o *ExitTrigger) bool {
 	if t == nil || o == nil {
 		return (t != nil) == (o != nil)
 	}
-	if t.Price != o.Price || t.Limit != o.Limit || t.Rate != o.Limit {
+	if t.Price != o.Price || t.Limit != o.Limit || t.Rate != o.Rate {
+		return false
+	}
+	if t.CallBack != o.CallBack || t.Activation != o.Activation {
 		return false
 	}
 	return true
@@ -1307,10 +1687,13 @@ func (t *ExitTrigger) Clone() *ExitTrigger {
 		return nil
 	}
 	return &ExitTrigger{
-		Price: t.Price,
-		Limit: t.Limit,
-		Rate:  t.Rate,
-		Tag:   t.Tag,
+		Price:      t.Price,
+		Limit:      t.Limit,
+		Rate:       t.Rate,
+		Tag:        t.Tag,
+		CallBack:   t.CallBack,
+		Activation: t.Activation,
+		OCO:        t.OCO,
 	}
 }
 
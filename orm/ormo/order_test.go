@@ -1,10 +1,12 @@
 package ormo
 
 import (
+	"math"
 	"path/filepath"
 	"testing"
 
 	"github.com/banbox/banbot/config"
+	"github.com/banbox/banbot/core"
 	"github.com/banbox/banbot/orm"
 	"github.com/banbox/banexg/errs"
 )
@@ -27,3 +29,155 @@ func TestGetOrders(t *testing.T) {
 	defer conn.Close()
 	sess.GetOrders(GetOrdersArgs{})
 }
+
+func TestAlgoSliceAmount(t *testing.T) {
+	state := &AlgoState{ExecAlgo: &ExecAlgo{Name: AlgoTWAP, Minutes: 10, Slices: 5}, StartMS: 0}
+	cases := []struct {
+		curMS  int64
+		filled float64
+		expect float64
+	}{
+		{0, 0, 20},
+		{60000, 20, 0},
+		{120000, 20, 20},
+		{450000, 40, 40},
+		{600000, 80, 20},
+	}
+	for _, c := range cases {
+		state.Filled = c.filled
+		res := state.SliceAmount(100, c.curMS, 0)
+		if res != c.expect {
+			t.Errorf("twap at %v filled %v: expect %v, got %v", c.curMS, c.filled, c.expect, res)
+		}
+	}
+	iceberg := &AlgoState{ExecAlgo: &ExecAlgo{Name: AlgoIceberg, Visible: 30}, Filled: 80}
+	if res := iceberg.SliceAmount(100, 0, 0); res != 20 {
+		t.Errorf("iceberg: expect 20, got %v", res)
+	}
+	pov := &AlgoState{ExecAlgo: &ExecAlgo{Name: AlgoPOV, MaxRate: 0.1}}
+	if res := pov.SliceAmount(100, 0, 500); res != 50 {
+		t.Errorf("pov: expect 50, got %v", res)
+	}
+}
+
+func TestCalcGroupProfit(t *testing.T) {
+	spot := &InOutOrder{
+		IOrder: &IOrder{Leverage: 1, Profit: 30},
+		Enter:  &ExOrder{Average: 100, Filled: 10},
+	}
+	perp := &InOutOrder{
+		IOrder: &IOrder{Leverage: 2, Short: true, Profit: -20},
+		Enter:  &ExOrder{Average: 100, Filled: 10},
+	}
+	profit, rate := CalcGroupProfit([]*InOutOrder{spot, perp})
+	if profit != 10 || rate != 10.0/1500 {
+		t.Errorf("expect 10 %v, got %v %v", 10.0/1500, profit, rate)
+	}
+}
+
+func TestReportProfit(t *testing.T) {
+	oldCur := config.ReportCurrency
+	config.ReportCurrency = "USDT"
+	defer func() {
+		config.ReportCurrency = oldCur
+	}()
+	core.SetBarPrice("BTC/USDT", 50000)
+	od := &InOutOrder{
+		IOrder: &IOrder{Symbol: "ETH/BTC", Status: InOutStatusFullEnter, Leverage: 1},
+		Enter:  &ExOrder{Average: 0.05, Filled: 2},
+	}
+	od.UpdateProfits(0.06)
+	if math.Abs(od.ReportProfit()-1000) > 1e-6 {
+		t.Errorf("expect 1000 USDT, got %v", od.ReportProfit())
+	}
+	// rate at exit is kept after the price changes
+	core.SetBarPrice("BTC/USDT", 60000)
+	if math.Abs(od.ReportProfit()-1000) > 1e-6 {
+		t.Errorf("expect rate kept, got %v", od.ReportProfit())
+	}
+	usdt := &InOutOrder{
+		IOrder: &IOrder{Symbol: "ETH/USDT", Status: InOutStatusFullEnter, Leverage: 1},
+		Enter:  &ExOrder{Average: 2000, Filled: 1},
+	}
+	usdt.UpdateProfits(2100)
+	if usdt.ReportRate() != 1 || usdt.ReportProfit() != 100 {
+		t.Errorf("expect 100 USDT with rate 1, got %v %v", usdt.ReportProfit(), usdt.ReportRate())
+	}
+}
+
+func TestCancelOCO(t *testing.T) {
+	newOd := func() *InOutOrder {
+		od := &InOutOrder{IOrder: &IOrder{Symbol: "BTC/USDT", Status: InOutStatusFullEnter, InitPrice: 100}}
+		od.SetBracket(&ExitTrigger{Price: 90}, &ExitTrigger{Price: 120})
+		od.SetTrailStop(&ExitTrigger{CallBack: 0.05})
+		return od
+	}
+	od := newOd()
+	// trailing stop is not oco, nothing cancelled 跟踪止损不是OCO，不取消
+	if od.CancelOCO(OdInfoTrailStop) || od.GetStopLoss() == nil || od.GetTakeProfit() == nil {
+		t.Errorf("non-oco trigger should not cancel others")
+	}
+	if !od.CancelOCO(OdInfoStopLoss) {
+		t.Errorf("oco stop loss should cancel others")
+	}
+	if od.GetStopLoss() == nil || od.GetTakeProfit() != nil || od.GetTrailStop() != nil {
+		t.Errorf("only hit trigger should be kept, sl: %v, tp: %v, trail: %v",
+			od.GetStopLoss(), od.GetTakeProfit(), od.GetTrailStop())
+	}
+	od = newOd()
+	if !od.CancelOCO(OdInfoTakeProfit) || od.GetStopLoss() != nil || od.GetTakeProfit() == nil {
+		t.Errorf("oco take profit should cancel stop loss")
+	}
+}
+
+func TestUpdateTrail(t *testing.T) {
+	tg := &TriggerState{ExitTrigger: &ExitTrigger{CallBack: 0.1, Activation: 110}}
+	// long: not active until high reaches activation 多单：最高价到达激活价前不激活
+	if tg.UpdateTrail(false, 105, 95) || tg.Best != 0 || tg.Price != 0 {
+		t.Errorf("should not active below activation, best: %v", tg.Best)
+	}
+	if !tg.UpdateTrail(false, 112, 100) || tg.Best != 112 || math.Abs(tg.Price-100.8) > 1e-9 {
+		t.Errorf("should active at 112, best: %v, price: %v", tg.Best, tg.Price)
+	}
+	// lower high doesn't move the stop 更低的最高价不移动止损
+	if tg.UpdateTrail(false, 111, 101) || tg.Best != 112 {
+		t.Errorf("stop should not move back, best: %v", tg.Best)
+	}
+	if !tg.UpdateTrail(false, 120, 110) || math.Abs(tg.Price-108) > 1e-9 {
+		t.Errorf("stop should follow new high, price: %v", tg.Price)
+	}
+	// short: active when low reaches activation 空单：最低价到达激活价时激活
+	tg = &TriggerState{ExitTrigger: &ExitTrigger{CallBack: 0.1, Activation: 90}}
+	if tg.UpdateTrail(true, 100, 91) {
+		t.Errorf("short should not active above activation")
+	}
+	if !tg.UpdateTrail(true, 95, 80) || tg.Best != 80 || math.Abs(tg.Price-88) > 1e-9 {
+		t.Errorf("short should active at 80, best: %v, price: %v", tg.Best, tg.Price)
+	}
+}
+
+func TestUpdateTrailStopStep(t *testing.T) {
+	od := &InOutOrder{IOrder: &IOrder{Symbol: "BTC/USDT", Status: InOutStatusFullEnter, InitPrice: 100}}
+	od.SetTrailStop(&ExitTrigger{CallBack: 0.1})
+	if !od.UpdateTrailStop(100, 99, 0.01) || math.Abs(od.GetTrailStop().Price-90) > 1e-9 {
+		t.Fatalf("first update should set stop price, got %v", od.GetTrailStop().Price)
+	}
+	// 90 -> 90.45, less than 1% 变化不足1%
+	if od.UpdateTrailStop(100.5, 99, 0.01) {
+		t.Errorf("small move should not change the stop")
+	}
+	tg := od.GetTrailStop()
+	if tg.Price != 90 || tg.Best != 100.5 {
+		t.Errorf("stop should be kept while best updated, price: %v, best: %v", tg.Price, tg.Best)
+	}
+	// 90 -> 91.8, moved by 2% 变化2%
+	if !od.UpdateTrailStop(102, 100, 0.01) || math.Abs(od.GetTrailStop().Price-91.8) > 1e-9 {
+		t.Errorf("large move should change the stop, got %v", od.GetTrailStop().Price)
+	}
+	// native trailing stop is left to exchange 原生跟踪止损交由交易所
+	tg = od.GetTrailStop()
+	tg.Native = true
+	if od.UpdateTrailStop(120, 110, 0) {
+		t.Errorf("native trailing stop should not be emulated")
+	}
+}
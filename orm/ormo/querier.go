@@ -9,18 +9,24 @@ import (
 )
 
 type Querier interface {
+	AddApiAudit(ctx context.Context, arg AddApiAuditParams) error
 	AddExOrder(ctx context.Context, arg AddExOrderParams) (int64, error)
 	AddIOrder(ctx context.Context, arg AddIOrderParams) (int64, error)
+	AddSignal(ctx context.Context, arg AddSignalParams) (int64, error)
 	AddTask(ctx context.Context, arg AddTaskParams) (*BotTask, error)
 	FindTask(ctx context.Context, arg FindTaskParams) (*BotTask, error)
 	GetExOrders(ctx context.Context, inoutID int64) ([]*ExOrder, error)
 	GetIOrder(ctx context.Context, id int64) (*IOrder, error)
+	GetSignal(ctx context.Context, idemKey string) (*SignalLog, error)
 	GetTask(ctx context.Context, id int64) (*BotTask, error)
 	GetTaskPairs(ctx context.Context, arg GetTaskPairsParams) ([]string, error)
+	ListApiAudits(ctx context.Context, arg ListApiAuditsParams) ([]*ApiAudit, error)
+	ListSignals(ctx context.Context, arg ListSignalsParams) ([]*SignalLog, error)
 	ListTaskPairs(ctx context.Context, arg ListTaskPairsParams) ([]string, error)
 	ListTasks(ctx context.Context) ([]*BotTask, error)
 	SetExOrder(ctx context.Context, arg SetExOrderParams) error
 	SetIOrder(ctx context.Context, arg SetIOrderParams) error
+	SetSignalResult(ctx context.Context, arg SetSignalResultParams) error
 }
 
 var _ Querier = (*Queries)(nil)
@@ -0,0 +1,97 @@
+package ormo
+
+import (
+	"database/sql"
+
+	"github.com/banbox/banbot/core"
+	"github.com/banbox/banbot/orm"
+	"github.com/banbox/banexg/errs"
+	"github.com/sasha-s/go-deadlock"
+)
+
+const (
+	SignalPending = "pending"
+	SignalDone    = "done"
+	SignalFail    = "fail"
+	SignalReject  = "rejected" // bad signature, timestamp or replayed nonce 签名、时间戳错误或重放的随机数
+)
+
+// create signal_log table for trade databases created before it's added 为添加信号表之前创建的交易数据库创建表
+const ddlSignal = `CREATE TABLE IF NOT EXISTS signal_log
+(
+    id        INTEGER PRIMARY KEY AUTOINCREMENT,
+    idem_key  TEXT    NOT NULL,
+    nonce     TEXT    NOT NULL,
+    account   TEXT    NOT NULL,
+    strategy  TEXT    NOT NULL,
+    symbol    TEXT    NOT NULL,
+    action    TEXT    NOT NULL,
+    side      TEXT    NOT NULL,
+    size      REAL    NOT NULL,
+    payload   TEXT    NOT NULL,
+    ip        TEXT    NOT NULL,
+    status    TEXT    NOT NULL,
+    message   TEXT    NOT NULL,
+    od_ids    TEXT    NOT NULL,
+    create_at INTEGER NOT NULL,
+    update_at INTEGER NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_signal_key ON signal_log (idem_key);
+CREATE INDEX IF NOT EXISTS idx_signal_create ON signal_log (create_at);
+CREATE INDEX IF NOT EXISTS idx_signal_nonce ON signal_log (nonce);`
+
+// create api_audit table for trade databases created before it's added 为添加审计表之前创建的交易数据库创建表
+const ddlApiAudit = `CREATE TABLE IF NOT EXISTS api_audit
+(
+    id        INTEGER PRIMARY KEY AUTOINCREMENT,
+    user      TEXT    NOT NULL,
+    ip        TEXT    NOT NULL,
+    account   TEXT    NOT NULL,
+    method    TEXT    NOT NULL,
+    path      TEXT    NOT NULL,
+    payload   TEXT    NOT NULL,
+    status    INTEGER NOT NULL,
+    create_at INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_audit_create ON api_audit (create_at);`
+
+var (
+	tableInits = make(map[string]bool)
+	tableLock  deadlock.Mutex
+)
+
+/*
+SignalConn
+Writable connection of the trade database with signal_log table ensured
+确保signal_log表存在的交易数据库可写连接
+*/
+func SignalConn() (*Queries, *sql.DB, *errs.Error) {
+	return connEnsure("signal_log", ddlSignal)
+}
+
+/*
+AuditConn
+Writable connection of the trade database with api_audit table ensured
+确保api_audit表存在的交易数据库可写连接
+*/
+func AuditConn() (*Queries, *sql.DB, *errs.Error) {
+	return connEnsure("api_audit", ddlApiAudit)
+}
+
+// connEnsure run the ddl of table once per process 每个进程只执行一次表的ddl
+func connEnsure(table, ddl string) (*Queries, *sql.DB, *errs.Error) {
+	sess, db, err := Conn(orm.DbTrades, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	tableLock.Lock()
+	defer tableLock.Unlock()
+	if _, ok := tableInits[table]; !ok {
+		if _, err_ := db.Exec(ddl); err_ != nil {
+			db.Close()
+			return nil, nil, errs.New(core.ErrDbExecFail, err_)
+		}
+		tableInits[table] = true
+	}
+	return sess, db, nil
+}
@@ -9,6 +9,36 @@ import (
 	"context"
 )
 
+const addApiAudit = `-- name: AddApiAudit :exec
+insert into api_audit ("user", "ip", "account", "method", "path", "payload", "status", "create_at")
+values (?, ?, ?, ?, ?, ?, ?, ?)
+`
+
+type AddApiAuditParams struct {
+	User     string `json:"user"`
+	Ip       string `json:"ip"`
+	Account  string `json:"account"`
+	Method   string `json:"method"`
+	Path     string `json:"path"`
+	Payload  string `json:"payload"`
+	Status   int64  `json:"status"`
+	CreateAt int64  `json:"create_at"`
+}
+
+func (q *Queries) AddApiAudit(ctx context.Context, arg AddApiAuditParams) error {
+	_, err := q.db.ExecContext(ctx, addApiAudit,
+		arg.User,
+		arg.Ip,
+		arg.Account,
+		arg.Method,
+		arg.Path,
+		arg.Payload,
+		arg.Status,
+		arg.CreateAt,
+	)
+	return err
+}
+
 const addExOrder = `-- name: AddExOrder :one
 insert into exorder ("task_id", "inout_id", "symbol", "enter", "order_type", "order_id", "side",
                      "create_at", "price", "average", "amount", "filled", "status", "fee", "fee_type", "update_at")
@@ -119,6 +149,54 @@ func (q *Queries) AddIOrder(ctx context.Context, arg AddIOrderParams) (int64, er
 	return id, err
 }
 
+const addSignal = `-- name: AddSignal :one
+insert into signal_log ("idem_key", "nonce", "account", "strategy", "symbol", "action", "side", "size",
+                        "payload", "ip", "status", "message", "od_ids", "create_at", "update_at")
+values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+    RETURNING id
+`
+
+type AddSignalParams struct {
+	IdemKey  string  `json:"idem_key"`
+	Nonce    string  `json:"nonce"`
+	Account  string  `json:"account"`
+	Strategy string  `json:"strategy"`
+	Symbol   string  `json:"symbol"`
+	Action   string  `json:"action"`
+	Side     string  `json:"side"`
+	Size     float64 `json:"size"`
+	Payload  string  `json:"payload"`
+	Ip       string  `json:"ip"`
+	Status   string  `json:"status"`
+	Message  string  `json:"message"`
+	OdIds    string  `json:"od_ids"`
+	CreateAt int64   `json:"create_at"`
+	UpdateAt int64   `json:"update_at"`
+}
+
+func (q *Queries) AddSignal(ctx context.Context, arg AddSignalParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, addSignal,
+		arg.IdemKey,
+		arg.Nonce,
+		arg.Account,
+		arg.Strategy,
+		arg.Symbol,
+		arg.Action,
+		arg.Side,
+		arg.Size,
+		arg.Payload,
+		arg.Ip,
+		arg.Status,
+		arg.Message,
+		arg.OdIds,
+		arg.CreateAt,
+		arg.UpdateAt,
+	)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
 const addTask = `-- name: AddTask :one
 insert into bottask
 ("mode", "name", "create_at", "start_at", "stop_at", "info")
@@ -157,6 +235,24 @@ func (q *Queries) AddTask(ctx context.Context, arg AddTaskParams) (*BotTask, err
 	return &i, err
 }
 
+const countSignalNonce = `-- name: CountSignalNonce :one
+select count(*) from signal_log
+where nonce = ? and create_at >= ? and status != ?
+`
+
+type CountSignalNonceParams struct {
+	Nonce    string `json:"nonce"`
+	CreateAt int64  `json:"create_at"`
+	Status   string `json:"status"`
+}
+
+func (q *Queries) CountSignalNonce(ctx context.Context, arg CountSignalNonceParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countSignalNonce, arg.Nonce, arg.CreateAt, arg.Status)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const findTask = `-- name: FindTask :one
 select id, mode, name, create_at, start_at, stop_at, info from bottask
 where mode = ? and name = ?
@@ -264,6 +360,35 @@ func (q *Queries) GetIOrder(ctx context.Context, id int64) (*IOrder, error) {
 	return &i, err
 }
 
+const getSignal = `-- name: GetSignal :one
+select id, idem_key, nonce, account, strategy, symbol, action, side, size, payload, ip, status, message, od_ids, create_at, update_at from signal_log
+where idem_key = ?
+`
+
+func (q *Queries) GetSignal(ctx context.Context, idemKey string) (*SignalLog, error) {
+	row := q.db.QueryRowContext(ctx, getSignal, idemKey)
+	var i SignalLog
+	err := row.Scan(
+		&i.ID,
+		&i.IdemKey,
+		&i.Nonce,
+		&i.Account,
+		&i.Strategy,
+		&i.Symbol,
+		&i.Action,
+		&i.Side,
+		&i.Size,
+		&i.Payload,
+		&i.Ip,
+		&i.Status,
+		&i.Message,
+		&i.OdIds,
+		&i.CreateAt,
+		&i.UpdateAt,
+	)
+	return &i, err
+}
+
 const getTask = `-- name: GetTask :one
 select id, mode, name, create_at, start_at, stop_at, info from bottask
 where id = ?
@@ -318,6 +443,103 @@ func (q *Queries) GetTaskPairs(ctx context.Context, arg GetTaskPairsParams) ([]s
 	return items, nil
 }
 
+const listApiAudits = `-- name: ListApiAudits :many
+select id, user, ip, account, method, path, payload, status, create_at from api_audit
+where create_at >= ?
+order by id desc
+limit ?
+`
+
+type ListApiAuditsParams struct {
+	CreateAt int64 `json:"create_at"`
+	Limit    int64 `json:"limit"`
+}
+
+func (q *Queries) ListApiAudits(ctx context.Context, arg ListApiAuditsParams) ([]*ApiAudit, error) {
+	rows, err := q.db.QueryContext(ctx, listApiAudits, arg.CreateAt, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*ApiAudit
+	for rows.Next() {
+		var i ApiAudit
+		if err := rows.Scan(
+			&i.ID,
+			&i.User,
+			&i.Ip,
+			&i.Account,
+			&i.Method,
+			&i.Path,
+			&i.Payload,
+			&i.Status,
+			&i.CreateAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listSignals = `-- name: ListSignals :many
+select id, idem_key, nonce, account, strategy, symbol, action, side, size, payload, ip, status, message, od_ids, create_at, update_at from signal_log
+where create_at >= ?
+order by id desc
+limit ?
+`
+
+type ListSignalsParams struct {
+	CreateAt int64 `json:"create_at"`
+	Limit    int64 `json:"limit"`
+}
+
+func (q *Queries) ListSignals(ctx context.Context, arg ListSignalsParams) ([]*SignalLog, error) {
+	rows, err := q.db.QueryContext(ctx, listSignals, arg.CreateAt, arg.Limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*SignalLog
+	for rows.Next() {
+		var i SignalLog
+		if err := rows.Scan(
+			&i.ID,
+			&i.IdemKey,
+			&i.Nonce,
+			&i.Account,
+			&i.Strategy,
+			&i.Symbol,
+			&i.Action,
+			&i.Side,
+			&i.Size,
+			&i.Payload,
+			&i.Ip,
+			&i.Status,
+			&i.Message,
+			&i.OdIds,
+			&i.CreateAt,
+			&i.UpdateAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listTaskPairs = `-- name: ListTaskPairs :many
 select symbol from iorder
 where task_id = ?
@@ -529,3 +751,27 @@ func (q *Queries) SetIOrder(ctx context.Context, arg SetIOrderParams) error {
 	)
 	return err
 }
+
+const setSignalResult = `-- name: SetSignalResult :exec
+update signal_log set "status" = ?, "message" = ?, "od_ids" = ?, "update_at" = ?
+where id = ?
+`
+
+type SetSignalResultParams struct {
+	Status   string `json:"status"`
+	Message  string `json:"message"`
+	OdIds    string `json:"od_ids"`
+	UpdateAt int64  `json:"update_at"`
+	ID       int64  `json:"id"`
+}
+
+func (q *Queries) SetSignalResult(ctx context.Context, arg SetSignalResultParams) error {
+	_, err := q.db.ExecContext(ctx, setSignalResult,
+		arg.Status,
+		arg.Message,
+		arg.OdIds,
+		arg.UpdateAt,
+		arg.ID,
+	)
+	return err
+}
@@ -5,6 +5,13 @@ type ExitTrigger struct {
 	Limit float64 `json:"limit,omitempty"` // Submit limit order price after triggering, otherwise market order. 触发后提交限价单价格，否则市价单
 	Rate  float64 `json:"rate,omitempty"`  // Stop-profit and stop-loss ratio, (0,1], 0 means all. 止盈止损比例，(0,1]，0表示全部
 	Tag   string  `json:"tag,omitempty"`   // Reason, used for ExitTag. 原因，用于ExitTag
+	// Callback rate of trailing stop, (0,1), Price is the current stop price calculated from the best price
+	// 跟踪止损的回调比率，(0,1)，Price为根据最优价格计算的当前止损价格
+	CallBack float64 `json:"callback,omitempty"`
+	// Activation price of trailing stop, 0 means active immediately 跟踪止损的激活价格，0表示立即激活
+	Activation float64 `json:"activation,omitempty"`
+	// One-Cancels-Other: cancel other triggers of the order once this is hit. 二选一：此触发后取消订单的其他触发
+	OCO bool `json:"oco,omitempty"`
 }
 
 type TriggerState struct {
@@ -13,4 +20,31 @@ type TriggerState struct {
 	Hit     bool         `json:"hit,omitempty"`   // whether trigger price has been triggered? 是否已触发
 	OrderId string       `json:"order_id,omitempty"`
 	Old     *ExitTrigger `json:"old,omitempty"`
+	Best    float64      `json:"best,omitempty"`   // Best price since trailing stop activated, 0 means inactive 跟踪止损激活后的最优价格，0表示未激活
+	Native  bool         `json:"native,omitempty"` // Trailing stop is executed by exchange natively 跟踪止损由交易所原生执行
+}
+
+/*
+ExecAlgo
+Execution algorithm to split a large sub order into child orders
+将大额子订单拆分为多个小订单执行的算法
+*/
+type ExecAlgo struct {
+	Name    string  `json:"name"`               // AlgoTWAP/AlgoIceberg/AlgoPOV
+	Minutes float64 `json:"minutes,omitempty"`  // TWAP: total execution minutes. TWAP：总执行分钟数
+	Slices  int     `json:"slices,omitempty"`   // TWAP: number of child orders, default one per minute. TWAP：子订单数量，默认每分钟一个
+	Visible float64 `json:"visible,omitempty"`  // Iceberg: visible amount of each child order. 冰山单：每个子订单的可见数量
+	MaxRate float64 `json:"max_rate,omitempty"` // POV: max share of traded volume, (0,1]. POV：占市场成交量的最大比例
+}
+
+type AlgoState struct {
+	*ExecAlgo
+	StartMS  int64   `json:"start_ms,omitempty"`
+	NextMS   int64   `json:"next_ms,omitempty"`   // Earliest time to submit next child order 下个子订单的最早提交时间
+	Filled   float64 `json:"filled,omitempty"`    // Filled amount of finished child orders 已完成子订单的成交数量
+	Cost     float64 `json:"cost,omitempty"`      // Filled value of finished child orders 已完成子订单的成交额
+	Fee      float64 `json:"fee,omitempty"`       // Fee of finished child orders 已完成子订单的手续费
+	FeeType  string  `json:"fee_type,omitempty"`  // Fee currency 手续费币种
+	Child    string  `json:"child,omitempty"`     // ID of the pending child order on exchange 交易所上未完成的子订单ID
+	ChildNum int     `json:"child_num,omitempty"` // Number of submitted child orders 已提交的子订单数量
 }
@@ -2,14 +2,27 @@ package ormu
 
 import (
 	"database/sql"
+	"path/filepath"
+
+	"github.com/banbox/banbot/config"
 	"github.com/banbox/banbot/core"
 	"github.com/banbox/banbot/orm"
 	"github.com/banbox/banexg/errs"
 )
 
 func Conn() (*Queries, *sql.DB, *errs.Error) {
-	db, err := orm.DbLite(orm.DbUI, core.DevDbPath, true)
+	path := core.DevDbPath
+	if path == "" {
+		// live bots started without the dev server 未通过dev服务启动的实盘机器人
+		path = filepath.Join(config.GetDataDir(), "dev.db")
+	}
+	db, err := orm.DbLite(orm.DbUI, path, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	err = ensureJournal(db, path)
 	if err != nil {
+		db.Close()
 		return nil, nil, err
 	}
 	return New(db), db, nil
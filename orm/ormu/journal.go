@@ -0,0 +1,137 @@
+package ormu
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"strings"
+
+	"github.com/banbox/banbot/core"
+	"github.com/banbox/banexg/errs"
+	"github.com/sasha-s/go-deadlock"
+)
+
+const (
+	JournalLive     = "live"
+	JournalBackTest = "backtest"
+)
+
+// create journal table for ui databases created before it's added 为添加日志表之前创建的ui数据库创建表
+const ddlJournal = `CREATE TABLE IF NOT EXISTS journal
+(
+    id        INTEGER PRIMARY KEY AUTOINCREMENT,
+    mode      TEXT    NOT NULL,
+    task_id   INTEGER NOT NULL,
+    od_id     INTEGER NOT NULL,
+    symbol    TEXT    NOT NULL,
+    strategy  TEXT    NOT NULL,
+    note      TEXT    NOT NULL,
+    tags      TEXT    NOT NULL,
+    rating    INTEGER NOT NULL,
+    snapshot  TEXT    NOT NULL,
+    create_at INTEGER NOT NULL,
+    update_at INTEGER NOT NULL
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_journal_od ON journal (mode, task_id, od_id);`
+
+var (
+	journalInits = make(map[string]bool)
+	journalLock  deadlock.Mutex
+)
+
+func ensureJournal(db *sql.DB, path string) *errs.Error {
+	journalLock.Lock()
+	defer journalLock.Unlock()
+	if _, ok := journalInits[path]; ok {
+		return nil
+	}
+	if _, err_ := db.Exec(ddlJournal); err_ != nil {
+		return errs.New(core.ErrDbExecFail, err_)
+	}
+	journalInits[path] = true
+	return nil
+}
+
+/*
+NormTags
+Trim, dedupe and sort comma separated tags
+对逗号分隔的标签去空格、去重并排序
+*/
+func NormTags(text string) string {
+	var tags []string
+	for _, tag := range strings.Split(text, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	slices.Sort(tags)
+	return strings.Join(tags, ",")
+}
+
+func (j *Journal) TagList() []string {
+	if j == nil || j.Tags == "" {
+		return nil
+	}
+	return strings.Split(j.Tags, ",")
+}
+
+/*
+JournalFilter
+Filter orders by their journal entries, an empty filter matches all orders
+按日志条目过滤订单，空过滤器匹配所有订单
+*/
+type JournalFilter struct {
+	Tags      string `query:"tags"`      // comma separated, match any 逗号分隔，匹配任意一个
+	MinRating int64  `query:"minRating"` // 1-5
+	HasNote   bool   `query:"hasNote"`
+}
+
+func (f *JournalFilter) IsEmpty() bool {
+	return f.Tags == "" && f.MinRating == 0 && !f.HasNote
+}
+
+func (f *JournalFilter) Match(j *Journal) bool {
+	if f.IsEmpty() {
+		return true
+	}
+	if j == nil {
+		return false
+	}
+	if f.MinRating > 0 && j.Rating < f.MinRating {
+		return false
+	}
+	if f.HasNote && j.Note == "" {
+		return false
+	}
+	if f.Tags != "" {
+		tags := j.TagList()
+		for _, tag := range strings.Split(f.Tags, ",") {
+			if slices.Contains(tags, strings.TrimSpace(tag)) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+/*
+GetJournalMap
+Journal entries of the task keyed by order id
+任务的日志条目，键为订单ID
+*/
+func (q *Queries) GetJournalMap(mode string, taskID int64) (map[int64]*Journal, *errs.Error) {
+	items, err_ := q.ListJournals(context.Background(), ListJournalsParams{
+		Mode:   mode,
+		TaskID: taskID,
+	})
+	if err_ != nil {
+		return nil, errs.New(core.ErrDbReadFail, err_)
+	}
+	res := make(map[int64]*Journal, len(items))
+	for _, it := range items {
+		res[it.OdID] = it
+	}
+	return res, nil
+}
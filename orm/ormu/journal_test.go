@@ -0,0 +1,45 @@
+package ormu
+
+import "testing"
+
+func TestNormTags(t *testing.T) {
+	cases := map[string]string{
+		"":                       "",
+		" , ,":                   "",
+		"fomo":                   "fomo",
+		"news, fomo,news , late": "fomo,late,news",
+		"b,a,b,a":                "a,b",
+	}
+	for text, want := range cases {
+		if res := NormTags(text); res != want {
+			t.Errorf("NormTags(%q) expect %q, got %q", text, want, res)
+		}
+	}
+}
+
+func TestJournalFilterMatch(t *testing.T) {
+	j := &Journal{Note: "entered too early", Tags: NormTags("late,fomo"), Rating: 3}
+	empty := &Journal{}
+	cases := []struct {
+		name  string
+		flt   JournalFilter
+		j     *Journal
+		match bool
+	}{
+		{"empty filter", JournalFilter{}, nil, true},
+		{"no journal", JournalFilter{MinRating: 1}, nil, false},
+		{"rating ok", JournalFilter{MinRating: 3}, j, true},
+		{"rating low", JournalFilter{MinRating: 4}, j, false},
+		{"has note", JournalFilter{HasNote: true}, j, true},
+		{"no note", JournalFilter{HasNote: true}, empty, false},
+		{"any tag", JournalFilter{Tags: "news, fomo"}, j, true},
+		{"no tag", JournalFilter{Tags: "news"}, j, false},
+		{"tag and rating", JournalFilter{Tags: "fomo", MinRating: 4}, j, false},
+		{"tag on empty", JournalFilter{Tags: "fomo"}, empty, false},
+	}
+	for _, c := range cases {
+		if res := c.flt.Match(c.j); res != c.match {
+			t.Errorf("%s: expect %v, got %v", c.name, c.match, res)
+		}
+	}
+}
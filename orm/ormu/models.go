@@ -4,6 +4,21 @@
 
 package ormu
 
+type Journal struct {
+	ID       int64  `json:"id"`
+	Mode     string `json:"mode"`
+	TaskID   int64  `json:"taskId"`
+	OdID     int64  `json:"odId"`
+	Symbol   string `json:"symbol"`
+	Strategy string `json:"strategy"`
+	Note     string `json:"note"`
+	Tags     string `json:"tags"`
+	Rating   int64  `json:"rating"`
+	Snapshot string `json:"snapshot"`
+	CreateAt int64  `json:"createAt"`
+	UpdateAt int64  `json:"updateAt"`
+}
+
 type Task struct {
 	ID          int64   `json:"id"`
 	Mode        string  `json:"mode"`
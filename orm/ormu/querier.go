@@ -10,9 +10,13 @@ import (
 
 type Querier interface {
 	AddTask(ctx context.Context, arg AddTaskParams) (*Task, error)
+	DelJournal(ctx context.Context, id int64) error
 	DelTasks(ctx context.Context, ids []int64) error
+	GetJournal(ctx context.Context, arg GetJournalParams) (*Journal, error)
 	GetTask(ctx context.Context, id int64) (*Task, error)
 	GetTaskOptions(ctx context.Context) ([]*GetTaskOptionsRow, error)
+	ListJournals(ctx context.Context, arg ListJournalsParams) ([]*Journal, error)
+	SetJournal(ctx context.Context, arg SetJournalParams) (*Journal, error)
 	SetTaskNote(ctx context.Context, arg SetTaskNoteParams) error
 	SetTaskPath(ctx context.Context, arg SetTaskPathParams) error
 	UpdateTask(ctx context.Context, arg UpdateTaskParams) error
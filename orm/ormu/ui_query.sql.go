@@ -87,6 +87,15 @@ func (q *Queries) AddTask(ctx context.Context, arg AddTaskParams) (*Task, error)
 	return &i, err
 }
 
+const delJournal = `-- name: DelJournal :exec
+delete from journal where id = ?
+`
+
+func (q *Queries) DelJournal(ctx context.Context, id int64) error {
+	_, err := q.db.ExecContext(ctx, delJournal, id)
+	return err
+}
+
 const delTasks = `-- name: DelTasks :exec
 delete from task where id in (/*SLICE:ids*/?)
 `
@@ -106,6 +115,37 @@ func (q *Queries) DelTasks(ctx context.Context, ids []int64) error {
 	return err
 }
 
+const getJournal = `-- name: GetJournal :one
+select id, mode, task_id, od_id, symbol, strategy, note, tags, rating, snapshot, create_at, update_at from journal
+where mode = ? and task_id = ? and od_id = ?
+`
+
+type GetJournalParams struct {
+	Mode   string `json:"mode"`
+	TaskID int64  `json:"taskId"`
+	OdID   int64  `json:"odId"`
+}
+
+func (q *Queries) GetJournal(ctx context.Context, arg GetJournalParams) (*Journal, error) {
+	row := q.db.QueryRowContext(ctx, getJournal, arg.Mode, arg.TaskID, arg.OdID)
+	var i Journal
+	err := row.Scan(
+		&i.ID,
+		&i.Mode,
+		&i.TaskID,
+		&i.OdID,
+		&i.Symbol,
+		&i.Strategy,
+		&i.Note,
+		&i.Tags,
+		&i.Rating,
+		&i.Snapshot,
+		&i.CreateAt,
+		&i.UpdateAt,
+	)
+	return &i, err
+}
+
 const getTask = `-- name: GetTask :one
 select id, mode, args, config, path, strats, periods, pairs, create_at, start_at, stop_at, status, progress, order_num, profit_rate, win_rate, max_drawdown, sharpe, info, note from task
 where id = ?
@@ -178,6 +218,108 @@ func (q *Queries) GetTaskOptions(ctx context.Context) ([]*GetTaskOptionsRow, err
 	return items, nil
 }
 
+const listJournals = `-- name: ListJournals :many
+select id, mode, task_id, od_id, symbol, strategy, note, tags, rating, snapshot, create_at, update_at from journal
+where mode = ? and task_id = ?
+order by od_id
+`
+
+type ListJournalsParams struct {
+	Mode   string `json:"mode"`
+	TaskID int64  `json:"taskId"`
+}
+
+func (q *Queries) ListJournals(ctx context.Context, arg ListJournalsParams) ([]*Journal, error) {
+	rows, err := q.db.QueryContext(ctx, listJournals, arg.Mode, arg.TaskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []*Journal
+	for rows.Next() {
+		var i Journal
+		if err := rows.Scan(
+			&i.ID,
+			&i.Mode,
+			&i.TaskID,
+			&i.OdID,
+			&i.Symbol,
+			&i.Strategy,
+			&i.Note,
+			&i.Tags,
+			&i.Rating,
+			&i.Snapshot,
+			&i.CreateAt,
+			&i.UpdateAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setJournal = `-- name: SetJournal :one
+insert into journal
+(mode, task_id, od_id, symbol, strategy, note, tags, rating, snapshot, create_at, update_at)
+values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+on conflict(mode, task_id, od_id) do update set note=excluded.note, tags=excluded.tags,
+    rating=excluded.rating, snapshot=excluded.snapshot, update_at=excluded.update_at
+    returning id, mode, task_id, od_id, symbol, strategy, note, tags, rating, snapshot, create_at, update_at
+`
+
+type SetJournalParams struct {
+	Mode     string `json:"mode"`
+	TaskID   int64  `json:"taskId"`
+	OdID     int64  `json:"odId"`
+	Symbol   string `json:"symbol"`
+	Strategy string `json:"strategy"`
+	Note     string `json:"note"`
+	Tags     string `json:"tags"`
+	Rating   int64  `json:"rating"`
+	Snapshot string `json:"snapshot"`
+	CreateAt int64  `json:"createAt"`
+	UpdateAt int64  `json:"updateAt"`
+}
+
+func (q *Queries) SetJournal(ctx context.Context, arg SetJournalParams) (*Journal, error) {
+	row := q.db.QueryRowContext(ctx, setJournal,
+		arg.Mode,
+		arg.TaskID,
+		arg.OdID,
+		arg.Symbol,
+		arg.Strategy,
+		arg.Note,
+		arg.Tags,
+		arg.Rating,
+		arg.Snapshot,
+		arg.CreateAt,
+		arg.UpdateAt,
+	)
+	var i Journal
+	err := row.Scan(
+		&i.ID,
+		&i.Mode,
+		&i.TaskID,
+		&i.OdID,
+		&i.Symbol,
+		&i.Strategy,
+		&i.Note,
+		&i.Tags,
+		&i.Rating,
+		&i.Snapshot,
+		&i.CreateAt,
+		&i.UpdateAt,
+	)
+	return &i, err
+}
+
 const setTaskNote = `-- name: SetTaskNote :exec
 update task set note=? where id = ?
 `
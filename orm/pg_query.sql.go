@@ -92,6 +92,26 @@ type AddSymbolsParams struct {
 	Symbol   string `json:"symbol"`
 }
 
+const addListDate = `-- name: AddListDate :one
+insert into list_dates
+(sid, list_ms, delist_ms)
+values ($1, $2, $3)
+    returning id
+`
+
+type AddListDateParams struct {
+	Sid      int32 `json:"sid"`
+	ListMs   int64 `json:"list_ms"`
+	DelistMs int64 `json:"delist_ms"`
+}
+
+func (q *Queries) AddListDate(ctx context.Context, arg AddListDateParams) (int64, error) {
+	row := q.db.QueryRow(ctx, addListDate, arg.Sid, arg.ListMs, arg.DelistMs)
+	var id int64
+	err := row.Scan(&id)
+	return id, err
+}
+
 const delAdjFactors = `-- name: DelAdjFactors :exec
 delete from adj_factors
 where sid=$1
@@ -226,6 +246,23 @@ func (q *Queries) GetAllInsKlines(ctx context.Context) ([]*InsKline, error) {
 	return items, nil
 }
 
+const getHaLease = `-- name: GetHaLease :one
+select name, holder, expire_at, update_at from ha_lease
+where name = $1
+`
+
+func (q *Queries) GetHaLease(ctx context.Context, name string) (*HaLease, error) {
+	row := q.db.QueryRow(ctx, getHaLease, name)
+	var i HaLease
+	err := row.Scan(
+		&i.Name,
+		&i.Holder,
+		&i.ExpireAt,
+		&i.UpdateAt,
+	)
+	return &i, err
+}
+
 const getInsKline = `-- name: GetInsKline :one
 select id, sid, timeframe, start_ms, stop_ms from ins_kline
 where sid=$1
@@ -373,6 +410,36 @@ func (q *Queries) ListKInfos(ctx context.Context) ([]*KInfo, error) {
 	return items, nil
 }
 
+const listListDates = `-- name: ListListDates :many
+select id, sid, list_ms, delist_ms from list_dates
+order by sid, list_ms
+`
+
+func (q *Queries) ListListDates(ctx context.Context) ([]*ListDate, error) {
+	rows, err := q.db.Query(ctx, listListDates)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	items := []*ListDate{}
+	for rows.Next() {
+		var i ListDate
+		if err := rows.Scan(
+			&i.ID,
+			&i.Sid,
+			&i.ListMs,
+			&i.DelistMs,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, &i)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const listSymbols = `-- name: ListSymbols :many
 select id, exchange, exg_real, market, symbol, combined, list_ms, delist_ms from exsymbol
 where exchange = $1
@@ -408,6 +475,21 @@ func (q *Queries) ListSymbols(ctx context.Context, exchange string) ([]*ExSymbol
 	return items, nil
 }
 
+const releaseHaLease = `-- name: ReleaseHaLease :exec
+update ha_lease set expire_at = 0
+where name = $1 and holder = $2
+`
+
+type ReleaseHaLeaseParams struct {
+	Name   string `json:"name"`
+	Holder string `json:"holder"`
+}
+
+func (q *Queries) ReleaseHaLease(ctx context.Context, arg ReleaseHaLeaseParams) error {
+	_, err := q.db.Exec(ctx, releaseHaLease, arg.Name, arg.Holder)
+	return err
+}
+
 const setKHole = `-- name: SetKHole :exec
 update khole set start = $2, stop = $3, no_data = $4
 where id = $1
@@ -452,6 +534,22 @@ func (q *Queries) SetKInfo(ctx context.Context, arg SetKInfoParams) error {
 	return err
 }
 
+const setListDate = `-- name: SetListDate :exec
+update list_dates set list_ms = $2, delist_ms = $3
+where id = $1
+`
+
+type SetListDateParams struct {
+	ID       int64 `json:"id"`
+	ListMs   int64 `json:"list_ms"`
+	DelistMs int64 `json:"delist_ms"`
+}
+
+func (q *Queries) SetListDate(ctx context.Context, arg SetListDateParams) error {
+	_, err := q.db.Exec(ctx, setListDate, arg.ID, arg.ListMs, arg.DelistMs)
+	return err
+}
+
 const setListMS = `-- name: SetListMS :exec
 update exsymbol set list_ms = $2, delist_ms = $3
 where id = $1
@@ -467,3 +565,24 @@ func (q *Queries) SetListMS(ctx context.Context, arg SetListMSParams) error {
 	_, err := q.db.Exec(ctx, setListMS, arg.ID, arg.ListMs, arg.DelistMs)
 	return err
 }
+
+const tryHaLease = `-- name: TryHaLease :execrows
+insert into ha_lease (name, holder, expire_at, update_at)
+values ($1, $2, (extract(epoch from now()) * 1000)::int8 + $3::int8, (extract(epoch from now()) * 1000)::int8)
+on conflict (name) do update set holder = excluded.holder, expire_at = excluded.expire_at, update_at = excluded.update_at
+where ha_lease.holder = excluded.holder or ha_lease.expire_at < excluded.update_at
+`
+
+type TryHaLeaseParams struct {
+	Name    string `json:"name"`
+	Holder  string `json:"holder"`
+	LeaseMs int64  `json:"lease_ms"`
+}
+
+func (q *Queries) TryHaLease(ctx context.Context, arg TryHaLeaseParams) (int64, error) {
+	result, err := q.db.Exec(ctx, tryHaLease, arg.Name, arg.Holder, arg.LeaseMs)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
@@ -17,3 +17,27 @@ BEGIN
     ALTER TABLE public.exsymbol ALTER COLUMN symbol TYPE varchar(50);
     END IF;
 END $$;
+
+-- version 3
+-- 添加list_dates表，记录品种的上市和退市历史，并从exsymbol初始化
+CREATE TABLE IF NOT EXISTS "public"."list_dates"
+(
+    "id"        BIGSERIAL NOT NULL PRIMARY KEY,
+    "sid"       int4      not null,
+    "list_ms"   int8      not null,
+    "delist_ms" int8      default 0  not null
+);
+CREATE INDEX IF NOT EXISTS "idx_list_dates_sid" ON "public"."list_dates" USING btree ("sid");
+INSERT INTO list_dates (sid, list_ms, delist_ms)
+SELECT id, list_ms, delist_ms FROM exsymbol
+WHERE list_ms > 0 AND NOT EXISTS (SELECT 1 FROM list_dates);
+
+-- version 4
+-- 添加ha_lease表，记录高可用模式下实盘机器人的主节点租约
+CREATE TABLE IF NOT EXISTS "public"."ha_lease"
+(
+    "name"      varchar(50)  NOT NULL PRIMARY KEY,
+    "holder"    varchar(100) not null,
+    "expire_at" int8         not null,
+    "update_at" int8         not null
+);
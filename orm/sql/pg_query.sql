@@ -17,6 +17,34 @@ values ($1, $2, $3, $4);
 update exsymbol set list_ms = $2, delist_ms = $3
 where id = $1;
 
+-- name: ListListDates :many
+select * from list_dates
+order by sid, list_ms;
+
+-- name: AddListDate :one
+insert into list_dates
+(sid, list_ms, delist_ms)
+values ($1, $2, $3)
+    returning id;
+
+-- name: SetListDate :exec
+update list_dates set list_ms = $2, delist_ms = $3
+where id = $1;
+
+-- name: TryHaLease :execrows
+insert into ha_lease (name, holder, expire_at, update_at)
+values ($1, $2, (extract(epoch from now()) * 1000)::int8 + sqlc.arg(lease_ms)::int8, (extract(epoch from now()) * 1000)::int8)
+on conflict (name) do update set holder = excluded.holder, expire_at = excluded.expire_at, update_at = excluded.update_at
+where ha_lease.holder = excluded.holder or ha_lease.expire_at < excluded.update_at;
+
+-- name: GetHaLease :one
+select * from ha_lease
+where name = $1;
+
+-- name: ReleaseHaLease :exec
+update ha_lease set expire_at = 0
+where name = $1 and holder = $2;
+
 
 
 -- name: ListKInfos :many
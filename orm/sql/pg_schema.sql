@@ -66,6 +66,32 @@ CREATE TABLE "public"."exsymbol"
 );
 CREATE UNIQUE INDEX "ix_exsymbol_unique" ON "public"."exsymbol" ("exchange", "market", "symbol");
 
+-- ----------------------------
+-- Table structure for list_dates, listing and delisting history of symbols
+-- ----------------------------
+DROP TABLE IF EXISTS "public"."list_dates";
+CREATE TABLE "public"."list_dates"
+(
+    "id"        BIGSERIAL NOT NULL PRIMARY KEY,
+    "sid"       int4      not null,
+    "list_ms"   int8      not null,
+    "delist_ms" int8      default 0  not null
+);
+CREATE INDEX "idx_list_dates_sid" ON "public"."list_dates" USING btree ("sid");
+
+
+-- ----------------------------
+-- Table structure for ha_lease, leader lease of live bots in HA mode
+-- ----------------------------
+DROP TABLE IF EXISTS "public"."ha_lease";
+CREATE TABLE "public"."ha_lease"
+(
+    "name"      varchar(50)  NOT NULL PRIMARY KEY,
+    "holder"    varchar(100) not null,
+    "expire_at" int8         not null,
+    "update_at" int8         not null
+);
+
 
 -- ----------------------------
 -- Table structure for calendars
@@ -99,3 +99,37 @@ update exorder set
                    "update_at" = ?
 where id = ?;
 
+
+-- name: AddSignal :one
+insert into signal_log ("idem_key", "nonce", "account", "strategy", "symbol", "action", "side", "size",
+                        "payload", "ip", "status", "message", "od_ids", "create_at", "update_at")
+values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+    RETURNING id;
+
+-- name: GetSignal :one
+select * from signal_log
+where idem_key = ?;
+
+-- name: CountSignalNonce :one
+select count(*) from signal_log
+where nonce = ? and create_at >= ? and status != ?;
+
+-- name: SetSignalResult :exec
+update signal_log set "status" = ?, "message" = ?, "od_ids" = ?, "update_at" = ?
+where id = ?;
+
+-- name: ListSignals :many
+select * from signal_log
+where create_at >= ?
+order by id desc
+limit ?;
+
+-- name: AddApiAudit :exec
+insert into api_audit ("user", "ip", "account", "method", "path", "payload", "status", "create_at")
+values (?, ?, ?, ?, ?, ?, ?, ?);
+
+-- name: ListApiAudits :many
+select * from api_audit
+where create_at >= ?
+order by id desc
+limit ?;
@@ -73,3 +73,50 @@ CREATE TABLE iorder
 
 CREATE INDEX idx_io_status  ON iorder (status);
 CREATE INDEX idx_io_task_id ON iorder (task_id);
+
+-- ----------------------------
+-- Table structure for signal_log
+-- ----------------------------
+--DROP TABLE IF EXISTS signal_log;
+CREATE TABLE signal_log
+(
+    id        INTEGER PRIMARY KEY AUTOINCREMENT,
+    idem_key  TEXT    NOT NULL, -- idempotency key of the signal
+    nonce     TEXT    NOT NULL,
+    account   TEXT    NOT NULL,
+    strategy  TEXT    NOT NULL,
+    symbol    TEXT    NOT NULL,
+    action    TEXT    NOT NULL, -- enter, exit
+    side      TEXT    NOT NULL, -- long, short
+    size      REAL    NOT NULL,
+    payload   TEXT    NOT NULL, -- raw request body
+    ip        TEXT    NOT NULL,
+    status    TEXT    NOT NULL, -- pending, done, fail, rejected
+    message   TEXT    NOT NULL,
+    od_ids    TEXT    NOT NULL, -- comma separated ids of orders
+    create_at INTEGER NOT NULL,
+    update_at INTEGER NOT NULL
+);
+
+CREATE UNIQUE INDEX idx_signal_key ON signal_log (idem_key);
+CREATE INDEX idx_signal_create ON signal_log (create_at);
+CREATE INDEX idx_signal_nonce ON signal_log (nonce);
+
+-- ----------------------------
+-- Table structure for api_audit
+-- ----------------------------
+--DROP TABLE IF EXISTS api_audit;
+CREATE TABLE api_audit
+(
+    id        INTEGER PRIMARY KEY AUTOINCREMENT,
+    user      TEXT    NOT NULL, -- login user, or token:name for api tokens
+    ip        TEXT    NOT NULL,
+    account   TEXT    NOT NULL,
+    method    TEXT    NOT NULL,
+    path      TEXT    NOT NULL,
+    payload   TEXT    NOT NULL, -- request body, truncated if too long
+    status    INTEGER NOT NULL, -- http status code of response
+    create_at INTEGER NOT NULL
+);
+
+CREATE INDEX idx_audit_create ON api_audit (create_at);
@@ -24,3 +24,22 @@ where id = ?;
 -- name: GetTaskOptions :many
 select strats, periods, start_at, stop_at from task;
 
+-- name: SetJournal :one
+insert into journal
+(mode, task_id, od_id, symbol, strategy, note, tags, rating, snapshot, create_at, update_at)
+values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+on conflict(mode, task_id, od_id) do update set note=excluded.note, tags=excluded.tags,
+    rating=excluded.rating, snapshot=excluded.snapshot, update_at=excluded.update_at
+    returning *;
+
+-- name: GetJournal :one
+select * from journal
+where mode = ? and task_id = ? and od_id = ?;
+
+-- name: ListJournals :many
+select * from journal
+where mode = ? and task_id = ?
+order by od_id;
+
+-- name: DelJournal :exec
+delete from journal where id = ?;
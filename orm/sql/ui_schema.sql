@@ -22,3 +22,21 @@ CREATE TABLE task
     info      TEXT    NOT NULL, -- 存放不需检索的信息
     note      TEXT    NOT NULL
 );
+
+DROP TABLE IF EXISTS journal;
+CREATE TABLE journal
+(
+    id        INTEGER PRIMARY KEY AUTOINCREMENT,
+    mode      TEXT    NOT NULL, -- live, backtest
+    task_id   INTEGER NOT NULL, -- bottask.id for live, task.id for backtest
+    od_id     INTEGER NOT NULL,
+    symbol    TEXT    NOT NULL,
+    strategy  TEXT    NOT NULL,
+    note      TEXT    NOT NULL,
+    tags      TEXT    NOT NULL, -- comma separated
+    rating    INTEGER NOT NULL, -- 0 for not rated, 1-5
+    snapshot  TEXT    NOT NULL, -- path of the chart snapshot
+    create_at INTEGER NOT NULL,
+    update_at INTEGER NOT NULL
+);
+CREATE UNIQUE INDEX idx_journal_od ON journal (mode, task_id, od_id);
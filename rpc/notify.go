@@ -8,11 +8,14 @@ import (
 	"github.com/banbox/banexg/errs"
 	"github.com/banbox/banexg/log"
 	"github.com/banbox/banexg/utils"
+	"github.com/sasha-s/go-deadlock"
 	"maps"
 )
 
 var (
-	channels = make([]IWebHook, 0, 2)
+	channels   = make([]IWebHook, 0, 2)
+	msgSubs    []func(msg map[string]interface{}) // listeners of all rpc messages 所有rpc消息的监听者
+	lockMsgSub deadlock.Mutex
 )
 
 func InitRPC() *errs.Error {
@@ -72,8 +75,22 @@ func initWebHooks() *errs.Error {
 	return nil
 }
 
+/*
+AddMsgSub
+Listen all messages sent by SendMsg, even if no rpc channels are configured. The callback receives a copy of msg.
+监听所有SendMsg发送的消息，即使未配置rpc渠道。回调收到的是msg的副本
+*/
+func AddMsgSub(cb func(msg map[string]interface{})) {
+	lockMsgSub.Lock()
+	msgSubs = append(msgSubs, cb)
+	lockMsgSub.Unlock()
+}
+
 func SendMsg(msg map[string]interface{}) {
-	if len(channels) == 0 {
+	lockMsgSub.Lock()
+	subs := msgSubs
+	lockMsgSub.Unlock()
+	if len(channels) == 0 && len(subs) == 0 {
 		return
 	}
 	account := utils.GetMapVal(msg, "account", "")
@@ -82,6 +99,12 @@ func SendMsg(msg map[string]interface{}) {
 		botName += "/" + account
 	}
 	msg["name"] = botName
+	for _, cb := range subs {
+		cb(maps.Clone(msg))
+	}
+	if len(channels) == 0 {
+		return
+	}
 	msgType := utils.GetMapVal(msg, "type", "")
 	item, ok := config.Webhook[msgType]
 	if !ok {
@@ -3,8 +3,10 @@ package strat
 import (
 	"fmt"
 	testcom "github.com/banbox/banbot/_testcom"
+	"github.com/banbox/banbot/orm"
 	"github.com/banbox/banbot/orm/ormo"
 	"github.com/banbox/banbot/utils"
+	"github.com/banbox/banexg"
 	ta "github.com/banbox/banta"
 	"testing"
 )
@@ -78,3 +80,29 @@ func TestStratJob_DrawDownExit(t *testing.T) {
 //	}
 //	t.Logf("%s %d %d", stgy.Name, stgy.Version, stgy.WarmupNum)
 //}
+
+func TestSpreadFeed(t *testing.T) {
+	key := SpreadKey("BTC/USDT:USDT", "ETH/USDT:USDT", SpreadRatio, 0)
+	legA, legB, mode, window, err := ParseSpreadKey(key)
+	if err != nil || legA != "BTC/USDT:USDT" || legB != "ETH/USDT:USDT" || mode != SpreadRatio || window != defSpreadWindow {
+		t.Fatalf("parse spread key fail: %v %v %v %v %v", legA, legB, mode, window, err)
+	}
+	feed := &SpreadFeed{Key: key, LegA: legA, LegB: legB, Mode: mode, Window: window, TimeFrame: "1h", Beta: 1}
+	makeBar := func(symbol string, time int64, price float64) *orm.InfoKline {
+		return &orm.InfoKline{PairTFKline: &banexg.PairTFKline{
+			Kline:     banexg.Kline{Time: time, Open: price, High: price * 1.1, Low: price * 0.9, Close: price},
+			Symbol:    symbol,
+			TimeFrame: "1h",
+		}}
+	}
+	if res := feed.onLegBar(makeBar(legA, 1000, 100)); res != nil {
+		t.Errorf("spread bar should wait for leg B")
+	}
+	if res := feed.onLegBar(makeBar(legB, 0, 50)); res != nil {
+		t.Errorf("spread bar should not be built from bars of different time")
+	}
+	res := feed.onLegBar(makeBar(legB, 1000, 50))
+	if res == nil || res.Symbol != key || res.Close != 2 || res.Time != 1000 {
+		t.Errorf("bad spread bar: %v", res)
+	}
+}
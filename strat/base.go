@@ -2,6 +2,7 @@ package strat
 
 import (
 	"fmt"
+	"github.com/banbox/banbot/btime"
 	"github.com/banbox/banbot/config"
 	"github.com/banbox/banbot/core"
 	"github.com/banbox/banbot/orm/ormo"
@@ -119,16 +120,28 @@ func (s *StratJob) OpenOrder(req *EnterReq) *errs.Error {
 	}
 	if math.IsNaN(req.Limit+req.Amount+req.Leverage+req.CostRate+req.LegalCost) ||
 		math.IsNaN(req.StopLoss+req.StopLossVal+req.StopLossLimit+req.StopLossRate) ||
-		math.IsNaN(req.TakeProfit+req.TakeProfitVal+req.TakeProfitLimit+req.TakeProfitRate) {
+		math.IsNaN(req.TakeProfit+req.TakeProfitVal+req.TakeProfitLimit+req.TakeProfitRate) ||
+		math.IsNaN(req.TrailCallBack+req.TrailActivation+req.TrailRate) {
 		AddAccFailOpen(s.Account, FailOpenNanNum)
 		return errs.NewMsg(errs.CodeParamInvalid, "nan in EnterReq")
 	}
+	if req.Account != "" {
+		if _, ok := config.Accounts[req.Account]; !ok && core.EnvReal {
+			return errs.NewMsg(errs.CodeParamInvalid, "account not found: %s", req.Account)
+		}
+	}
+	if req.ExecAlgo != nil {
+		err := req.ExecAlgo.Validate()
+		if err != nil {
+			return err
+		}
+	}
 	// 检查价格是否有效
 	dirFlag := 1.0
 	if req.Short {
 		dirFlag = -1.0
 	}
-	enterPrice := core.GetPrice(symbol)
+	enterPrice := core.GetPrice(s.Symbol.VenueKey())
 	if req.Limit > 0 {
 		if (req.Limit-enterPrice)*dirFlag < 0 {
 			enterPrice = req.Limit
@@ -138,11 +151,22 @@ func (s *StratJob) OpenOrder(req *EnterReq) *errs.Error {
 		if req.CostRate == 0 {
 			req.CostRate = 1
 		}
-		req.LegalCost = s.Strat.GetStakeAmount(s) * req.CostRate
+		// stake amount is in the report currency, convert to legal
+		// 开单金额以报告币种计价，换算为法币
+		reportRate, err := core.GetLegalRate(config.ReportCurrency)
+		if err != nil {
+			return err
+		}
+		quoteRate, err := core.GetLegalRate(core.QuoteCode(symbol))
+		if err != nil {
+			return err
+		}
+		req.LegalCost = s.Strat.GetStakeAmount(s) * req.CostRate * reportRate
 		avgVol := s.avgVolume(5) // 最近5个蜡烛成交量
-		reqAmt := req.LegalCost / enterPrice
+		legalPrice := enterPrice * quoteRate
+		reqAmt := req.LegalCost / legalPrice
 		if avgVol > 0 && reqAmt/avgVol > config.OpenVolRate {
-			req.LegalCost = avgVol * config.OpenVolRate * enterPrice
+			req.LegalCost = avgVol * config.OpenVolRate * legalPrice
 			if core.LiveMode {
 				log.Info(fmt.Sprintf("%v open amt rate: %.1f > open_vol_rate(%.1f), cut to cost: %.1f",
 					symbol, reqAmt/avgVol, config.OpenVolRate, req.LegalCost))
@@ -226,6 +250,18 @@ func (s *StratJob) OpenOrder(req *EnterReq) *errs.Error {
 			log.Warn("takeProfit disabled", zap.String("stagy", s.Strat.Name), zap.String("pair", symbol))
 		}
 	}
+	// 检查跟踪止损
+	if req.TrailCallBack > 0 {
+		if req.TrailCallBack >= 1 {
+			AddAccFailOpen(s.Account, FailOpenBadStopLoss)
+			return errs.NewMsg(errs.CodeParamInvalid, "%s trail callback %f must in (0,1)", symbol, req.TrailCallBack)
+		} else if !s.ExgStopLoss {
+			req.TrailCallBack = 0
+			if isLiveMode {
+				log.Warn("trailStop disabled", zap.String("strategy", s.Strat.Name), zap.String("pair", symbol))
+			}
+		}
+	}
 	if req.Limit > 0 && req.OrderType == 0 {
 		req.OrderType = core.OrderTypeLimit
 	}
@@ -264,6 +300,12 @@ func (s *StratJob) CloseOrders(req *ExitReq) *errs.Error {
 	} else if req.ExitRate == 0 {
 		req.ExitRate = 1
 	}
+	if req.ExecAlgo != nil {
+		err := req.ExecAlgo.Validate()
+		if err != nil {
+			return err
+		}
+	}
 	if req.Limit > 0 && req.OrderType == 0 {
 		req.OrderType = core.OrderTypeLimit
 	}
@@ -285,6 +327,145 @@ func (s *StratJob) CloseOrders(req *ExitReq) *errs.Error {
 	return nil
 }
 
+/*
+OpenHedge
+Open the main order on the job's symbol together with linked legs on other markets of the same base asset,
+such as long spot and short perpetual. Legs are sized by the amount of the main order and share a group id,
+they are exited together and reported with the combined profit. Return the group id.
+The klines of the legs should be subscribed by OnPairInfos to update their prices.
+在任务品种上开主订单，同时在同一基础资产的其他市场上开关联腿，如现货多+永续空。
+腿的数量按主订单数量计算，共享一个组ID，一起平仓并合并统计利润。返回组ID。
+应通过OnPairInfos订阅各腿的K线以更新其价格。
+*/
+func (s *StratJob) OpenHedge(req *EnterReq, legs ...*HedgeLeg) (string, *errs.Error) {
+	if len(legs) == 0 {
+		return "", errs.NewMsg(errs.CodeParamRequired, "hedge legs are required")
+	}
+	exs := s.Symbol
+	legReqs := make([]*EnterReq, 0, len(legs))
+	for _, leg := range legs {
+		if leg.Market == "" {
+			return "", errs.NewMsg(errs.CodeParamRequired, "HedgeLeg.Market is required")
+		}
+		if leg.Short && !banexg.IsContract(leg.Market) {
+			return "", errs.NewMsg(errs.CodeParamInvalid, "short leg is invalid for spot")
+		}
+		if leg.Ratio < 0 {
+			return "", errs.NewMsg(errs.CodeParamInvalid, "HedgeLeg.Ratio must >= 0, current: %f", leg.Ratio)
+		} else if leg.Ratio == 0 {
+			leg.Ratio = 1
+		}
+		symbol := leg.Symbol
+		if symbol == "" {
+			symbol = hedgeLegSymbol(exs.Symbol, leg.Market)
+		}
+		exgName, account := exs.Exchange, leg.Account
+		if core.EnvReal {
+			if account == "" {
+				if _, accMarket := config.GetAccVenue(s.Account); accMarket != leg.Market {
+					account = config.GetVenueAccount(exgName, leg.Market)
+					if account == "" {
+						return "", errs.NewMsg(errs.CodeParamInvalid, "no account for %s %s", exgName, leg.Market)
+					}
+				}
+			} else if _, ok := config.Accounts[account]; !ok {
+				return "", errs.NewMsg(errs.CodeParamInvalid, "account not found: %s", account)
+			}
+			if account != "" {
+				exgName, _ = config.GetAccVenue(account)
+			}
+		}
+		legReqs = append(legReqs, &EnterReq{
+			Tag:       req.Tag,
+			StratName: req.StratName,
+			Short:     leg.Short,
+			Amount:    leg.Ratio,
+			Leverage:  leg.Leverage,
+			Account:   account,
+			Symbol:    core.VenueKey(exgName, leg.Market, symbol),
+		})
+	}
+	err := s.OpenOrder(req)
+	if err != nil {
+		return "", err
+	}
+	amount := req.Amount
+	if amount == 0 {
+		price := req.Limit
+		if price == 0 {
+			price = core.GetPrice(exs.VenueKey())
+		}
+		if price > 0 {
+			amount = req.LegalCost / price
+		}
+	}
+	if amount <= 0 {
+		// Remove the main order which can't be sized 移除无法计算数量的主订单
+		s.Entrys = s.Entrys[:len(s.Entrys)-1]
+		s.OrderNum -= 1
+		return "", errs.NewMsg(errs.CodeParamInvalid, "%s price unknown, can't size hedge legs", exs.Symbol)
+	}
+	group := s.newGroupID()
+	req.Group = group
+	for _, leg := range legReqs {
+		leg.Amount *= amount
+		leg.Group = group
+		s.Entrys = append(s.Entrys, leg)
+	}
+	return group, nil
+}
+
+func (s *StratJob) newGroupID() string {
+	hedgeSeq += 1
+	return fmt.Sprintf("%s_%d_%d", s.Strat.Name, btime.TimeMS(), hedgeSeq)
+}
+
+/*
+CloseHedge
+Exit all legs of the group opened by OpenHedge
+退出OpenHedge开仓的组内所有腿
+*/
+func (s *StratJob) CloseHedge(group, tag string) *errs.Error {
+	if group == "" {
+		return errs.NewMsg(errs.CodeParamRequired, "group is required")
+	}
+	if tag == "" {
+		return errs.NewMsg(errs.CodeParamRequired, "tag is required")
+	}
+	s.Exits = append(s.Exits, &ExitReq{
+		Tag:       tag,
+		StratName: s.Strat.Name,
+		Group:     group,
+	})
+	return nil
+}
+
+/*
+GetGroupOrders
+Open orders of all legs in the group, use ormo.CalcGroupProfit to get the combined profit
+组内所有腿的未平仓订单，使用ormo.CalcGroupProfit获取合计利润
+*/
+func (s *StratJob) GetGroupOrders(group string) []*ormo.InOutOrder {
+	return ormo.GetGroupODs(group)
+}
+
+/*
+hedgeLegSymbol
+Symbol of the same base and quote on the market: base/quote for spot, base/quote:quote for linear and base/USD:base for inverse
+市场上相同base和quote的品种：现货为base/quote，U本位为base/quote:quote，币本位为base/USD:base
+*/
+func hedgeLegSymbol(symbol, market string) string {
+	base, quote, _, _ := core.SplitSymbol(symbol)
+	switch market {
+	case banexg.MarketLinear:
+		return fmt.Sprintf("%s/%s:%s", base, quote, quote)
+	case banexg.MarketInverse:
+		return fmt.Sprintf("%s/USD:%s", base, base)
+	default:
+		return fmt.Sprintf("%s/%s", base, quote)
+	}
+}
+
 /*
 avgVolume
 Calculate the average trading volume of the latest num candlesticks
@@ -445,7 +626,15 @@ func (s *StratJob) Position(dirt float64, enterTag string) float64 {
 		}
 		totalCost += od.HoldCost()
 	}
-	return totalCost / s.Strat.GetStakeAmount(s)
+	// convert cost in quote and stake amount in report currency both to legal
+	// 定价币的成本和报告币种的开单金额都换算为法币
+	quoteRate := core.LegalRate(core.QuoteCode(s.Symbol.Symbol))
+	reportRate := core.LegalRate(config.ReportCurrency)
+	if quoteRate <= 0 || reportRate <= 0 {
+		// rates are not loaded, position is unknown 汇率未加载，仓位未知
+		return 0
+	}
+	return totalCost * quoteRate / (s.Strat.GetStakeAmount(s) * reportRate)
 }
 
 func (s *StratJob) GetOrders(dirt float64) []*ormo.InOutOrder {
@@ -511,22 +700,15 @@ func (s *StratJob) setAllExitTrigger(dirt float64, key string, args *ormo.ExitTr
 		if setPos < core.AmtDust {
 			od.SetExitTrigger(key, nil)
 		} else {
+			tg := args.Clone()
 			if setPos >= size+core.AmtDust {
-				od.SetExitTrigger(key, &ormo.ExitTrigger{
-					Price: args.Price,
-					Limit: args.Limit,
-					Tag:   args.Tag,
-				})
+				tg.Rate = 0
 				setPos -= size
 			} else {
-				od.SetExitTrigger(key, &ormo.ExitTrigger{
-					Price: args.Price,
-					Limit: args.Limit,
-					Rate:  setPos / size,
-					Tag:   args.Tag,
-				})
+				tg.Rate = setPos / size
 				setPos = 0
 			}
+			od.SetExitTrigger(key, tg)
 		}
 	}
 }
@@ -538,3 +720,12 @@ func (s *StratJob) SetAllStopLoss(dirt float64, args *ormo.ExitTrigger) {
 func (s *StratJob) SetAllTakeProfit(dirt float64, args *ormo.ExitTrigger) {
 	s.setAllExitTrigger(dirt, ormo.OdInfoTakeProfit, args)
 }
+
+/*
+SetAllTrailStop
+Set trailing stop for all orders, args.CallBack is required
+为所有订单设置跟踪止损，args.CallBack必填
+*/
+func (s *StratJob) SetAllTrailStop(dirt float64, args *ormo.ExitTrigger) {
+	s.setAllExitTrigger(dirt, ormo.OdInfoTrailStop, args)
+}
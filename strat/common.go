@@ -177,6 +177,10 @@ func (q *ExitReq) Clone() *ExitReq {
 		OrderID:    q.OrderID,
 		UnFillOnly: q.UnFillOnly,
 		Force:      q.Force,
+		ExecAlgo:   q.ExecAlgo,
+		Account:    q.Account,
+		Symbol:     q.Symbol,
+		Group:      q.Group,
 	}
 	return res
 }
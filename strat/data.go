@@ -30,6 +30,12 @@ var (
 	lockAccFailOpen deadlock.Mutex
 
 	WsSubUnWatch func(map[string][]string)
+
+	hedgeSeq int // Sequence to generate group ids of hedges 生成对冲组ID的序号
+
+	spreadFeeds = make(map[string]*SpreadFeed)   // spreadKey_tf: SpreadFeed 订阅的价差源
+	legSpreads  = make(map[string][]*SpreadFeed) // leg_tf: SpreadFeeds 各腿对应的价差源
+	lockSpreads deadlock.Mutex
 )
 
 var (
@@ -222,8 +222,20 @@ func LoadStratJobs(pairs []string, tfScores map[string]map[string]float64) (map[
 				if _, ok := core.TFSecs[tf]; !ok {
 					core.TFSecs[tf] = utils2.TFToSecs(tf)
 				}
-				// 确保添加到pairTfWarms中
-				pairTfs.Update(pair, tf, 0)
+				legs := []string{pair}
+				if IsSpreadKey(pair) {
+					// Spreads are built from the klines of legs 价差由两腿的K线合成
+					legA, legB, _, _, err := ParseSpreadKey(pair)
+					if err != nil {
+						continue
+					}
+					legs = []string{legA, legB}
+				}
+				for _, leg := range legs {
+					envKeys[strings.Join([]string{leg, tf}, "_")] = true
+					// 确保添加到pairTfWarms中
+					pairTfs.Update(leg, tf, 0)
+				}
 			}
 		}
 		AccInfoJobs[acc] = newJobMap
@@ -248,6 +260,7 @@ func LoadStratJobs(pairs []string, tfScores map[string]map[string]float64) (map[
 			delete(stgMap, name)
 		}
 	}
+	resetSpreads(envKeys)
 	// Remove useless items from Envs
 	// 从Envs中删除无用的项
 	for envKey := range Envs {
@@ -361,7 +374,7 @@ func printFailTfScores(stratName string, pairTfScores map[string]map[string]floa
 }
 
 func initBarEnv(exs *orm.ExSymbol, tf string) *ta.BarEnv {
-	envKey := strings.Join([]string{exs.Symbol, tf}, "_")
+	envKey := strings.Join([]string{exs.VenueKey(), tf}, "_")
 	env, ok := Envs[envKey]
 	if !ok {
 		tfMSecs := int64(utils2.TFToSecs(tf) * 1000)
@@ -454,6 +467,21 @@ func ensureStratJob(stgy *TradeStrat, tf string, exs *orm.ExSymbol, env *ta.BarE
 				if pair == "_cur_" {
 					pair = exs.Symbol
 					initBarEnv(exs, s.TimeFrame)
+				} else if IsSpreadKey(pair) {
+					feed, err := initSpreadFeed(pair, s.TimeFrame)
+					if err != nil {
+						log.Warn("skip invalid spread", zap.String("strat", job.Strat.Name),
+							zap.String("pair", pair), zap.Error(err))
+						continue
+					}
+					// Subscribe klines of both legs, the spread is built from them
+					// 订阅两腿的K线，价差由其合成
+					warmNum := s.WarmupNum
+					if feed.Mode == SpreadHedge {
+						warmNum += feed.Window
+					}
+					logWarm(feed.LegA, s.TimeFrame, warmNum)
+					logWarm(feed.LegB, s.TimeFrame, warmNum)
 				} else {
 					curExs, err := orm.GetExSymbolCur(pair)
 					if err != nil {
@@ -464,7 +492,9 @@ func ensureStratJob(stgy *TradeStrat, tf string, exs *orm.ExSymbol, env *ta.BarE
 					initBarEnv(curExs, s.TimeFrame)
 				}
 				hasInfoSubs = true
-				logWarm(pair, s.TimeFrame, s.WarmupNum)
+				if !IsSpreadKey(pair) {
+					logWarm(pair, s.TimeFrame, s.WarmupNum)
+				}
 				jobKey := strings.Join([]string{pair, s.TimeFrame}, "_")
 				items, ok := infoJobs[jobKey]
 				if !ok {
@@ -0,0 +1,348 @@
+package strat
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/banbox/banbot/config"
+	"github.com/banbox/banbot/core"
+	"github.com/banbox/banbot/orm"
+	"github.com/banbox/banbot/utils"
+	"github.com/banbox/banexg"
+	"github.com/banbox/banexg/errs"
+	utils2 "github.com/banbox/banexg/utils"
+	ta "github.com/banbox/banta"
+)
+
+const (
+	SpreadRatio = "ratio" // close of leg A / close of leg B, legs are sized with the same value 腿A收盘价/腿B收盘价，两腿按相同价值下单
+	SpreadHedge = "hedge" // leg A - beta * leg B, beta by rolling OLS, leg B is sized by beta 腿A - beta*腿B，beta由滚动OLS计算，腿B按beta下单
+
+	spreadSep       = "~"
+	defSpreadWindow = 100
+)
+
+/*
+SpreadFeed
+Synthetic kline feed of the spread between two symbols, bars are built when both legs have the bar of the same time
+两个品种间价差的合成K线源，两腿都有同一时间的bar时生成价差bar
+*/
+type SpreadFeed struct {
+	Key       string // Key of the spread, see SpreadKey 价差的键，见SpreadKey
+	LegA      string // Symbol or venue key of leg A 腿A的品种或场所键
+	LegB      string // Symbol or venue key of leg B 腿B的品种或场所键
+	Mode      string // SpreadRatio or SpreadHedge
+	Window    int    // Number of bars for rolling OLS beta 滚动OLS beta的bar数量
+	TimeFrame string
+	Alpha     float64 // Latest OLS intercept of A on B 最新的A对B的OLS截距
+	Beta      float64 // Latest hedge ratio, amount of B for 1 unit of A 最新对冲比例，每单位A对应B的数量
+	barA      *orm.InfoKline
+	barB      *orm.InfoKline
+	closesA   []float64
+	closesB   []float64
+}
+
+/*
+SpreadKey
+Key of a spread symbol to subscribe in OnPairInfos, like legA~legB~hedge~100. window is only used for SpreadHedge, 0 for default 100
+在OnPairInfos中订阅的价差品种键，形如legA~legB~hedge~100。window仅用于SpreadHedge，0表示默认100
+*/
+func SpreadKey(legA, legB, mode string, window int) string {
+	if mode != SpreadHedge || window <= 0 {
+		return strings.Join([]string{legA, legB, mode}, spreadSep)
+	}
+	return strings.Join([]string{legA, legB, mode, strconv.Itoa(window)}, spreadSep)
+}
+
+func IsSpreadKey(key string) bool {
+	return strings.Contains(key, spreadSep)
+}
+
+/*
+ParseSpreadKey
+Parse the key from SpreadKey, return legA, legB, mode, window
+解析SpreadKey返回的键，返回legA, legB, mode, window
+*/
+func ParseSpreadKey(key string) (string, string, string, int, *errs.Error) {
+	arr := strings.Split(key, spreadSep)
+	if len(arr) < 3 || len(arr) > 4 || arr[0] == "" || arr[1] == "" {
+		return "", "", "", 0, errs.NewMsg(errs.CodeParamInvalid, "invalid spread key: %s", key)
+	}
+	mode, window := arr[2], defSpreadWindow
+	if mode != SpreadRatio && mode != SpreadHedge {
+		return "", "", "", 0, errs.NewMsg(errs.CodeParamInvalid, "invalid spread mode: %s", mode)
+	}
+	if len(arr) == 4 {
+		num, err_ := strconv.Atoi(arr[3])
+		if err_ != nil || num < 3 {
+			return "", "", "", 0, errs.NewMsg(errs.CodeParamInvalid, "invalid spread window: %s", key)
+		}
+		window = num
+	}
+	return arr[0], arr[1], mode, window, nil
+}
+
+/*
+GetSpread
+Get the spread feed subscribed by OnPairInfos, nil if not found
+获取通过OnPairInfos订阅的价差源，未找到返回nil
+*/
+func GetSpread(key, tf string) *SpreadFeed {
+	lockSpreads.Lock()
+	feed, _ := spreadFeeds[strings.Join([]string{key, tf}, "_")]
+	lockSpreads.Unlock()
+	return feed
+}
+
+/*
+initSpreadFeed
+Register the spread feed and the BarEnv of the spread and its legs
+注册价差源，以及价差和两腿的BarEnv
+*/
+func initSpreadFeed(key, tf string) (*SpreadFeed, *errs.Error) {
+	envKey := strings.Join([]string{key, tf}, "_")
+	lockSpreads.Lock()
+	defer lockSpreads.Unlock()
+	if feed, ok := spreadFeeds[envKey]; ok {
+		return feed, nil
+	}
+	legA, legB, mode, window, err := ParseSpreadKey(key)
+	if err != nil {
+		return nil, err
+	}
+	for _, leg := range []string{legA, legB} {
+		exs, err := orm.GetExSymbolCur(leg)
+		if err != nil {
+			return nil, err
+		}
+		initBarEnv(exs, tf)
+	}
+	feed := &SpreadFeed{
+		Key:       key,
+		LegA:      legA,
+		LegB:      legB,
+		Mode:      mode,
+		Window:    window,
+		TimeFrame: tf,
+		Beta:      1,
+	}
+	spreadFeeds[envKey] = feed
+	for _, leg := range []string{legA, legB} {
+		legKey := strings.Join([]string{leg, tf}, "_")
+		legSpreads[legKey] = append(legSpreads[legKey], feed)
+	}
+	if _, ok := Envs[envKey]; !ok {
+		Envs[envKey] = &ta.BarEnv{
+			Exchange:   core.ExgName,
+			MarketType: core.Market,
+			Symbol:     key,
+			TimeFrame:  tf,
+			TFMSecs:    int64(utils2.TFToSecs(tf) * 1000),
+			MaxCache:   core.NumTaCache,
+			Data:       map[string]interface{}{},
+		}
+	}
+	return feed, nil
+}
+
+/*
+resetSpreads
+Remove spread feeds not in the keys of pair_tf
+移除不在pair_tf键中的价差源
+*/
+func resetSpreads(envKeys map[string]bool) {
+	lockSpreads.Lock()
+	for envKey := range spreadFeeds {
+		if !envKeys[envKey] {
+			delete(spreadFeeds, envKey)
+		}
+	}
+	legSpreads = make(map[string][]*SpreadFeed)
+	for _, feed := range spreadFeeds {
+		for _, leg := range []string{feed.LegA, feed.LegB} {
+			legKey := strings.Join([]string{leg, feed.TimeFrame}, "_")
+			legSpreads[legKey] = append(legSpreads[legKey], feed)
+		}
+	}
+	lockSpreads.Unlock()
+}
+
+/*
+FeedSpreads
+Update spread feeds with the kline of a leg, return spread bars completed by this bar
+使用某条腿的K线更新价差源，返回由此bar完成的价差bar
+*/
+func FeedSpreads(bar *orm.InfoKline) []*orm.InfoKline {
+	legKey := strings.Join([]string{bar.Symbol, bar.TimeFrame}, "_")
+	lockSpreads.Lock()
+	feeds, _ := legSpreads[legKey]
+	lockSpreads.Unlock()
+	var res []*orm.InfoKline
+	for _, feed := range feeds {
+		if spBar := feed.onLegBar(bar); spBar != nil {
+			res = append(res, spBar)
+		}
+	}
+	return res
+}
+
+func (f *SpreadFeed) onLegBar(bar *orm.InfoKline) *orm.InfoKline {
+	if bar.Symbol == f.LegA {
+		f.barA = bar
+	} else {
+		f.barB = bar
+	}
+	a, b := f.barA, f.barB
+	if a == nil || b == nil || a.Time != b.Time {
+		return nil
+	}
+	f.barA, f.barB = nil, nil
+	if f.Mode == SpreadHedge {
+		f.closesA = append(f.closesA, a.Close)
+		f.closesB = append(f.closesB, b.Close)
+		if len(f.closesA) > f.Window {
+			f.closesA = f.closesA[len(f.closesA)-f.Window:]
+			f.closesB = f.closesB[len(f.closesB)-f.Window:]
+		}
+		if len(f.closesA) >= 3 {
+			alpha, beta := utils.OLSBeta(f.closesA, f.closesB)
+			if !math.IsNaN(beta) && !math.IsInf(beta, 0) {
+				f.Alpha, f.Beta = alpha, beta
+			}
+		}
+	}
+	openV, closeV := f.Value(a.Open, b.Open), f.Value(a.Close, b.Close)
+	highV, lowV := f.Value(a.High, b.High), f.Value(a.Low, b.Low)
+	return &orm.InfoKline{
+		PairTFKline: &banexg.PairTFKline{
+			Kline: banexg.Kline{
+				Time:   a.Time,
+				Open:   openV,
+				High:   max(openV, closeV, highV, lowV),
+				Low:    min(openV, closeV, highV, lowV),
+				Close:  closeV,
+				Volume: min(a.Volume, b.Volume),
+			},
+			Symbol:    f.Key,
+			TimeFrame: f.TimeFrame,
+		},
+		IsWarmUp: a.IsWarmUp || b.IsWarmUp,
+	}
+}
+
+/*
+Value
+Spread value of the prices of leg A and leg B
+腿A和腿B价格的价差值
+*/
+func (f *SpreadFeed) Value(priceA, priceB float64) float64 {
+	if f.Mode == SpreadRatio {
+		if priceB == 0 {
+			return 0
+		}
+		return priceA / priceB
+	}
+	return priceA - f.Beta*priceB
+}
+
+/*
+LegAmounts
+Amounts of leg A and leg B for the cost in quote currency of leg A
+按腿A的计价币花费计算腿A和腿B的数量
+*/
+func (f *SpreadFeed) LegAmounts(legalCost float64) (float64, float64, *errs.Error) {
+	priceA := core.GetPriceSafe(f.LegA)
+	priceB := core.GetPriceSafe(f.LegB)
+	if priceA <= 0 || priceB <= 0 {
+		return 0, 0, errs.NewMsg(errs.CodeParamInvalid, "price unknown for spread: %s", f.Key)
+	}
+	rateA, err := core.GetLegalRate(core.QuoteCode(f.LegA))
+	if err != nil {
+		return 0, 0, err
+	}
+	rateB, err := core.GetLegalRate(core.QuoteCode(f.LegB))
+	if err != nil {
+		return 0, 0, err
+	}
+	priceA *= rateA
+	priceB *= rateB
+	amountA := legalCost / priceA
+	if f.Mode == SpreadRatio {
+		return amountA, legalCost / priceB, nil
+	}
+	return amountA, amountA * math.Abs(f.Beta), nil
+}
+
+/*
+OpenSpread
+Enter both legs of the spread subscribed by OnPairInfos in one request, a long spread is long A and short B
+(reversed when beta < 0). The legs share a group id and can be exited by CloseHedge. Return the group id.
+The amount of leg A is req.Amount, or calculated from req.LegalCost / req.CostRate.
+一次请求开仓通过OnPairInfos订阅的价差的两条腿，做多价差即做多A做空B（beta<0时反向）。
+两腿共享组ID，可通过CloseHedge平仓。返回组ID。腿A的数量为req.Amount，或根据req.LegalCost/req.CostRate计算
+*/
+func (s *StratJob) OpenSpread(key string, req *EnterReq) (string, *errs.Error) {
+	if req.Tag == "" {
+		return "", errs.NewMsg(errs.CodeParamRequired, "tag is Required")
+	}
+	feed := GetSpread(key, s.TimeFrame)
+	if feed == nil {
+		return "", errs.NewMsg(errs.CodeParamInvalid, "spread %s/%s not subscribed", key, s.TimeFrame)
+	}
+	if !s.CanOpen(req.Short) {
+		AddAccFailOpen(s.Account, FailOpenBadDirtOrLimit)
+		return "", errs.NewMsg(errs.CodeParamInvalid, "open order disabled")
+	}
+	if req.StratName == "" {
+		req.StratName = s.Strat.Name
+	}
+	legalCost := req.LegalCost
+	if req.Amount == 0 && legalCost == 0 {
+		if req.CostRate == 0 {
+			req.CostRate = 1
+		}
+		reportRate, err := core.GetLegalRate(config.ReportCurrency)
+		if err != nil {
+			return "", err
+		}
+		legalCost = s.Strat.GetStakeAmount(s) * req.CostRate * reportRate
+	}
+	amountA, amountB, err := feed.LegAmounts(legalCost)
+	if err != nil {
+		return "", err
+	}
+	if req.Amount > 0 {
+		amountB *= req.Amount / amountA
+		amountA = req.Amount
+	}
+	shortB := !req.Short
+	if feed.Mode == SpreadHedge && feed.Beta < 0 {
+		shortB = req.Short
+	}
+	legs := []*EnterReq{
+		{Symbol: feed.LegA, Short: req.Short, Amount: amountA},
+		{Symbol: feed.LegB, Short: shortB, Amount: amountB},
+	}
+	for _, leg := range legs {
+		exs, err := orm.GetExSymbolCur(leg.Symbol)
+		if err != nil {
+			return "", err
+		}
+		if leg.Short && !banexg.IsContract(exs.Market) {
+			return "", errs.NewMsg(errs.CodeParamInvalid, "short leg is invalid for spot: %s", leg.Symbol)
+		}
+	}
+	group := s.newGroupID()
+	for _, leg := range legs {
+		leg.Tag = req.Tag
+		leg.StratName = req.StratName
+		leg.OrderType = req.OrderType
+		leg.Leverage = req.Leverage
+		leg.Account = req.Account
+		leg.Group = group
+		s.Entrys = append(s.Entrys, leg)
+	}
+	s.OrderNum += len(legs)
+	return group, nil
+}
@@ -137,8 +137,39 @@ type EnterReq struct {
 	TakeProfitLimit float64 // Profit taking limit price, TakeProfit is not available for use 止盈限制价格，不提供使用TakeProfit
 	TakeProfitRate  float64 // Take profit exit ratio, 0 indicates full exit, needs to be between (0,1) 止盈退出比率，0表示全部退出，需介于(0,1]之间
 	TakeProfitTag   string  // Reason for profit taking 止盈原因
+	TrailCallBack   float64 // Callback rate of trailing stop, enabled when >0 跟踪止损回调比率，大于0时启用
+	TrailActivation float64 // Activation price of trailing stop, 0 means active immediately 跟踪止损激活价格，0表示立即激活
+	TrailRate       float64 // Trailing stop exit ratio, 0 means all exits 跟踪止损退出比例，0表示全部退出
+	TrailTag        string  // Reason for trailing stop 跟踪止损原因
+	Bracket         bool    // Set stop loss and take profit as OCO bracket 止损和止盈作为OCO括号单，一方触发后取消另一方
 	StopBars        int     // If the entry limit order exceeds how many bars and is not executed, it will be cancelled 入场限价单超过多少个bar未成交则取消
 	ClientID        string  // used as suffix of ClientOrderID to exchange
+
+	// Split into child orders by TWAP/iceberg/POV when not nil 不为空时按TWAP/冰山/POV拆分为子订单执行
+	ExecAlgo *ormo.ExecAlgo
+
+	// Place the order to this account (may be on another exchange) instead of the job's, for arbitrage between exchanges
+	// 将订单下到此账户（可在其他交易所）而非任务所属账户，用于交易所间套利
+	Account string
+	// Symbol of the order instead of the job's, can be a venue key like exchange:market:symbol. Used by hedge legs
+	// 订单的品种（替代任务的品种），可以是exchange:market:symbol形式的场所键。用于对冲腿
+	Symbol string
+	// Id shared by linked legs, set by StratJob.OpenHedge 关联腿共享的ID，由StratJob.OpenHedge设置
+	Group string
+}
+
+/*
+HedgeLeg
+A linked leg on another market for the same base asset, opened by StratJob.OpenHedge
+同一基础资产在其他市场上的关联腿，由StratJob.OpenHedge开仓
+*/
+type HedgeLeg struct {
+	Market   string  // Market of the leg, e.g. linear/spot 腿所在市场
+	Symbol   string  // Symbol of the leg, default: same base and quote on Market 腿的品种，默认：Market上相同的base和quote
+	Account  string  // Account to place the leg, default: the job's account 腿下单的账户，默认为任务所属账户
+	Short    bool    // Direction of the leg 腿的方向
+	Ratio    float64 // Amount of the leg relative to the main order, default 1 腿相对主订单的数量比例，默认1
+	Leverage float64 // Leverage of the leg 腿的杠杆
 }
 
 /*
@@ -158,6 +189,17 @@ type ExitReq struct {
 	UnFillOnly bool    // When True, exit orders which hasn't been filled only. True时只退出尚未入场的部分
 	FilledOnly bool    // Only exit orders that have already entered when True True时只退出已入场的订单
 	Force      bool    // Whether to force exit 是否强制退出
+
+	// Split into child orders by TWAP/iceberg/POV when not nil 不为空时按TWAP/冰山/POV拆分为子订单执行
+	ExecAlgo *ormo.ExecAlgo
+
+	// Exit orders of this account instead of the job's, see EnterReq.Account 退出此账户而非任务所属账户的订单
+	Account string
+	// Exit orders of this symbol instead of the job's, can be a venue key like exchange:market:symbol
+	// 退出此品种而非任务品种的订单，可以是exchange:market:symbol形式的场所键
+	Symbol string
+	// Exit all legs sharing this group id on all accounts 退出所有账户中共享此组ID的所有腿
+	Group string
 }
 
 type accStratLimits map[string]*stgLimits
@@ -0,0 +1,191 @@
+package utils
+
+import (
+	"math"
+
+	"github.com/banbox/banexg/errs"
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat"
+)
+
+/*
+Critical values of the Engle-Granger cointegration test for two variables with a constant (MacKinnon, asymptotic)
+双变量带常数项的Engle-Granger协整检验临界值（MacKinnon渐近值）
+*/
+const (
+	CointCrit1  = -3.90
+	CointCrit5  = -3.34
+	CointCrit10 = -3.04
+)
+
+type CointResult struct {
+	Alpha    float64 // Intercept of y = alpha + beta * x 截距
+	Beta     float64 // Hedge ratio 对冲比例
+	Stat     float64 // ADF t-statistic of residuals, the smaller the more stationary 残差的ADF统计量，越小越平稳
+	HalfLife float64 // Bars for the spread to revert half way to the mean, 0 if not mean reverting 价差回归均值一半所需bar数，不回归时为0
+}
+
+/*
+IsCoint
+Whether the pair is cointegrated at the significance level: 0.01, 0.05 or 0.1
+在显著性水平下是否协整：0.01, 0.05, 0.1
+*/
+func (r *CointResult) IsCoint(level float64) bool {
+	crit := CointCrit5
+	if level <= 0.01 {
+		crit = CointCrit1
+	} else if level >= 0.1 {
+		crit = CointCrit10
+	}
+	return r.Stat < crit
+}
+
+/*
+OLSBeta
+Ordinary least squares fit of y = alpha + beta * x, return alpha, beta
+普通最小二乘拟合y = alpha + beta * x，返回alpha, beta
+*/
+func OLSBeta(y, x []float64) (float64, float64) {
+	if len(y) != len(x) || len(y) < 2 {
+		return math.NaN(), math.NaN()
+	}
+	return stat.LinearRegression(x, y, nil, false)
+}
+
+/*
+RollingOLSBeta
+Beta of y on x for each position using the latest window items, NaN for the first window-1 positions
+每个位置使用最近window项计算的y对x的beta，前window-1个位置为NaN
+*/
+func RollingOLSBeta(y, x []float64, window int) []float64 {
+	res := make([]float64, len(y))
+	for i := range res {
+		if i+1 < window || window < 2 || i >= len(x) {
+			res[i] = math.NaN()
+			continue
+		}
+		_, res[i] = OLSBeta(y[i+1-window:i+1], x[i+1-window:i+1])
+	}
+	return res
+}
+
+/*
+ADFStat
+t-statistic of the augmented Dickey-Fuller test with a constant, the smaller the more stationary.
+lags < 0 means auto selected by 12*(n/100)^0.25
+带常数项的增强迪基-福勒检验t统计量，越小越平稳。lags < 0表示按12*(n/100)^0.25自动选择
+*/
+func ADFStat(series []float64, lags int) (float64, error) {
+	n := len(series)
+	if lags < 0 {
+		lags = int(math.Floor(12 * math.Pow(float64(n)/100, 0.25)))
+	}
+	rows, cols := n-1-lags, 2+lags
+	if rows <= cols+1 {
+		return 0, errs.NewMsg(errs.CodeParamInvalid, "series too short for adf test: %v, lags: %v", n, lags)
+	}
+	diffs := make([]float64, n-1)
+	for i := range diffs {
+		diffs[i] = series[i+1] - series[i]
+	}
+	xMat := mat.NewDense(rows, cols, nil)
+	yVec := mat.NewVecDense(rows, nil)
+	for r := 0; r < rows; r++ {
+		t := r + lags
+		yVec.SetVec(r, diffs[t])
+		xMat.Set(r, 0, 1)
+		xMat.Set(r, 1, series[t])
+		for j := 1; j <= lags; j++ {
+			xMat.Set(r, 1+j, diffs[t-j])
+		}
+	}
+	coefs, ses, err := olsFit(xMat, yVec)
+	if err != nil {
+		return 0, err
+	}
+	if ses[1] == 0 {
+		return math.Inf(-1), nil
+	}
+	return coefs[1] / ses[1], nil
+}
+
+/*
+CointTest
+Engle-Granger two-step cointegration test: fit y on x by OLS, then ADF test on the residuals
+Engle-Granger两步协整检验：先用OLS拟合y对x，再对残差做ADF检验
+*/
+func CointTest(y, x []float64, lags int) (*CointResult, error) {
+	if len(y) != len(x) {
+		return nil, errs.NewMsg(errs.CodeParamInvalid, "length mismatch: %v != %v", len(y), len(x))
+	}
+	alpha, beta := OLSBeta(y, x)
+	if math.IsNaN(beta) {
+		return nil, errs.NewMsg(errs.CodeParamInvalid, "at least two items are required")
+	}
+	resids := make([]float64, len(y))
+	for i := range y {
+		resids[i] = y[i] - alpha - beta*x[i]
+	}
+	adf, err := ADFStat(resids, lags)
+	if err != nil {
+		return nil, err
+	}
+	return &CointResult{
+		Alpha:    alpha,
+		Beta:     beta,
+		Stat:     adf,
+		HalfLife: HalfLife(resids),
+	}, nil
+}
+
+/*
+HalfLife
+Half life of mean reversion of the spread by fitting diff(s) = a + b * s[t-1], 0 if not mean reverting
+通过拟合diff(s) = a + b * s[t-1]计算价差均值回归的半衰期，不回归时为0
+*/
+func HalfLife(spread []float64) float64 {
+	if len(spread) < 3 {
+		return 0
+	}
+	lagged := spread[:len(spread)-1]
+	diffs := make([]float64, len(lagged))
+	for i := range diffs {
+		diffs[i] = spread[i+1] - spread[i]
+	}
+	_, b := OLSBeta(diffs, lagged)
+	if math.IsNaN(b) || b >= 0 {
+		return 0
+	}
+	return -math.Ln2 / b
+}
+
+/*
+olsFit
+Multiple linear regression of y on columns of x, return coefficients and their standard errors
+y对x各列的多元线性回归，返回系数及其标准误差
+*/
+func olsFit(x *mat.Dense, y *mat.VecDense) ([]float64, []float64, error) {
+	rows, cols := x.Dims()
+	var xtx, inv mat.Dense
+	xtx.Mul(x.T(), x)
+	if err := inv.Inverse(&xtx); err != nil {
+		return nil, nil, errs.New(errs.CodeRunTime, err)
+	}
+	var xty, coef, fitted mat.VecDense
+	xty.MulVec(x.T(), y)
+	coef.MulVec(&inv, &xty)
+	fitted.MulVec(x, &coef)
+	var rss float64
+	for i := 0; i < rows; i++ {
+		diff := y.AtVec(i) - fitted.AtVec(i)
+		rss += diff * diff
+	}
+	sigma2 := rss / float64(rows-cols)
+	coefs := make([]float64, cols)
+	ses := make([]float64, cols)
+	for i := 0; i < cols; i++ {
+		coefs[i] = coef.AtVec(i)
+		ses[i] = math.Sqrt(sigma2 * inv.At(i, i))
+	}
+	return coefs, ses, nil
+}
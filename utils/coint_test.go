@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestCointTest(t *testing.T) {
+	rnd := rand.New(rand.NewSource(7))
+	num := 500
+	x := make([]float64, num)
+	y := make([]float64, num)
+	price := 100.0
+	for i := 0; i < num; i++ {
+		price += rnd.NormFloat64()
+		x[i] = price
+		y[i] = 3 + 2*price + rnd.NormFloat64()*0.5
+	}
+	res, err := CointTest(y, x, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(res.Beta-2) > 0.05 {
+		t.Errorf("beta should be near 2, got %v", res.Beta)
+	}
+	if !res.IsCoint(0.01) {
+		t.Errorf("should be cointegrated, adf: %v", res.Stat)
+	}
+	if res.HalfLife <= 0 || res.HalfLife > 5 {
+		t.Errorf("half life should in (0, 5], got %v", res.HalfLife)
+	}
+	betas := RollingOLSBeta(y, x, 50)
+	if !math.IsNaN(betas[48]) || math.Abs(betas[num-1]-2) > 0.2 {
+		t.Errorf("bad rolling beta: %v %v", betas[48], betas[num-1])
+	}
+}
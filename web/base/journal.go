@@ -0,0 +1,119 @@
+package base
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/banbox/banbot/btime"
+	"github.com/banbox/banbot/config"
+	"github.com/banbox/banbot/opt"
+	"github.com/banbox/banbot/orm/ormo"
+	"github.com/banbox/banbot/orm/ormu"
+	"github.com/gofiber/fiber/v2"
+)
+
+/*
+JournalArgs
+Fields to update for the journal entry of an order, nil fields are kept unchanged
+要更新的订单日志字段，nil字段保持不变
+*/
+type JournalArgs struct {
+	OrderID  int64   `json:"orderId" validate:"required"`
+	Note     *string `json:"note"`
+	Tags     *string `json:"tags"`
+	Rating   *int64  `json:"rating" validate:"omitempty,min=0,max=5"`
+	Snapshot bool    `json:"snapshot"` // render the chart snapshot around entry and exit 渲染入场和出场前后的图表快照
+}
+
+/*
+SaveJournal
+Create or update the journal entry of the order in the ui database
+在ui数据库中创建或更新订单的日志条目
+*/
+func SaveJournal(mode string, taskID int64, od *ormo.InOutOrder, args *JournalArgs) (*ormu.Journal, error) {
+	qu, conn, err := ormu.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	ctx := context.Background()
+	old, err_ := qu.GetJournal(ctx, ormu.GetJournalParams{Mode: mode, TaskID: taskID, OdID: od.ID})
+	if err_ != nil && !errors.Is(err_, sql.ErrNoRows) {
+		return nil, err_
+	}
+	curMS := btime.UTCStamp()
+	item := ormu.SetJournalParams{
+		Mode:     mode,
+		TaskID:   taskID,
+		OdID:     od.ID,
+		Symbol:   od.Symbol,
+		Strategy: od.Strategy,
+		CreateAt: curMS,
+		UpdateAt: curMS,
+	}
+	if err_ == nil {
+		item.Note, item.Tags, item.Rating = old.Note, old.Tags, old.Rating
+		item.Snapshot, item.CreateAt = old.Snapshot, old.CreateAt
+	}
+	if args.Note != nil {
+		item.Note = *args.Note
+	}
+	if args.Tags != nil {
+		item.Tags = ormu.NormTags(*args.Tags)
+	}
+	if args.Rating != nil {
+		item.Rating = *args.Rating
+	}
+	if args.Snapshot {
+		dir := filepath.Join(config.GetDataDir(), "journal")
+		if err_ = os.MkdirAll(dir, 0755); err_ != nil {
+			return nil, err_
+		}
+		path := filepath.Join(dir, fmt.Sprintf("%s_%d_%d.html", mode, taskID, od.ID))
+		if err = opt.DumpOrderSnapshot(od, path); err != nil {
+			return nil, err
+		}
+		item.Snapshot = path
+	}
+	return qu.SetJournal(ctx, item)
+}
+
+/*
+GetJournals
+Journal entries of the task keyed by order id
+任务的日志条目，键为订单ID
+*/
+func GetJournals(mode string, taskID int64) (map[int64]*ormu.Journal, error) {
+	qu, conn, err := ormu.Conn()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	res, err := qu.GetJournalMap(mode, taskID)
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+/*
+SendSnapshot
+Respond the chart snapshot html of the order
+返回订单的图表快照html
+*/
+func SendSnapshot(c *fiber.Ctx, mode string, taskID, orderID int64) error {
+	qu, conn, err := ormu.Conn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	item, err_ := qu.GetJournal(context.Background(), ormu.GetJournalParams{Mode: mode, TaskID: taskID, OdID: orderID})
+	if err_ != nil || item.Snapshot == "" {
+		return fiber.NewError(fiber.StatusNotFound, "snapshot not found")
+	}
+	return c.SendFile(item.Snapshot)
+}
@@ -73,6 +73,8 @@ func regApiDev(api fiber.Router) {
 	api.Get("/download", handleDownload)
 	api.Get("/compare_assets", getCompareAssets)
 	api.Post("/update_note", handleUpdateNote)
+	api.Post("/bt_journal", handleBtJournal)
+	api.Get("/bt_journal_snapshot", getBtJournalSnapshot)
 	api.Post("/del_bt_reports", delBacktestReports)
 }
 
@@ -873,6 +875,7 @@ func getBtOrders(c *fiber.Ctx) error {
 		ExitTag  string `query:"exit_tag"`
 		StartMS  int64  `query:"start_ms"`
 		EndMS    int64  `query:"end_ms"`
+		ormu.JournalFilter
 	}
 	var args = new(OrderArgs)
 	if err := base.VerifyArg(c, args, base.ArgQuery); err != nil {
@@ -905,8 +908,15 @@ func getBtOrders(c *fiber.Ctx) error {
 	lock.Lock()
 	defer lock.Unlock()
 
+	journals, err2 := qu.GetJournalMap(ormu.JournalBackTest, args.TaskID)
+	if err2 != nil {
+		return err2
+	}
 	var orders = make([]*ormo.InOutOrder, 0, len(allOrders)/10)
 	for _, od := range allOrders {
+		if !args.JournalFilter.Match(journals[od.ID]) {
+			continue
+		}
 		if args.Symbol != "" && od.Symbol != args.Symbol {
 			continue
 		}
@@ -940,12 +950,81 @@ func getBtOrders(c *fiber.Ctx) error {
 		orders = orders[start:end]
 	}
 
+	pageJournals := make(map[int64]*ormu.Journal)
+	for _, od := range orders {
+		if j, ok := journals[od.ID]; ok {
+			pageJournals[od.ID] = j
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"total":    total,
+		"orders":   orders,
+		"journals": pageJournals,
+	})
+}
+
+// handleBtJournal 更新回测订单的日志：备注、标签、评分和图表快照
+func handleBtJournal(c *fiber.Ctx) error {
+	type BtJournalArgs struct {
+		TaskID int64 `json:"taskId" validate:"required"`
+		base.JournalArgs
+	}
+	var args = new(BtJournalArgs)
+	if err := base.VerifyArg(c, args, base.ArgBody); err != nil {
+		return err
+	}
+	od, err := getBtOrder(args.TaskID, args.OrderID)
+	if err != nil {
+		return err
+	}
+	item, err := base.SaveJournal(ormu.JournalBackTest, args.TaskID, od, &args.JournalArgs)
+	if err != nil {
+		return err
+	}
 	return c.JSON(fiber.Map{
-		"total":  total,
-		"orders": orders,
+		"data": item,
 	})
 }
 
+// getBtJournalSnapshot 获取回测订单的图表快照
+func getBtJournalSnapshot(c *fiber.Ctx) error {
+	type SnapArgs struct {
+		TaskID  int64 `query:"task_id" validate:"required"`
+		OrderID int64 `query:"order_id" validate:"required"`
+	}
+	var args = new(SnapArgs)
+	if err := base.VerifyArg(c, args, base.ArgQuery); err != nil {
+		return err
+	}
+	return base.SendSnapshot(c, ormu.JournalBackTest, args.TaskID, args.OrderID)
+}
+
+func getBtOrder(taskID, orderID int64) (*ormo.InOutOrder, error) {
+	qu, conn, err := ormu.Conn()
+	if err != nil {
+		return nil, err
+	}
+	task, err_ := qu.GetTask(context.Background(), taskID)
+	conn.Close()
+	if err_ != nil {
+		return nil, fmt.Errorf("query task failed: %v", err_)
+	}
+	dbPath := filepath.Join(config.GetDataDir(), "backtest", task.Path, "orders.gob")
+	allOrders, lock, err := getGobOrders(dbPath)
+	if err != nil {
+		return nil, err
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	for _, od := range allOrders {
+		if od.ID == orderID {
+			return od, nil
+		}
+	}
+	return nil, fiber.NewError(fiber.StatusNotFound, "order not found")
+}
+
 // getBtConfig 获取回测配置
 func getBtConfig(c *fiber.Ctx) error {
 	type ConfigArgs struct {
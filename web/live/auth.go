@@ -24,6 +24,7 @@ func regApiPub(api fiber.Router) {
 	api.Post("/login", postLogin)
 	api.Get("/ping", getPing)
 	api.Post("/strat_call", postStratCall)
+	api.Post("/signal", postSignal)
 }
 
 func getPing(c *fiber.Ctx) error {
@@ -179,8 +180,23 @@ func createAuthToken(user string, secret string, expHours float64) (string, erro
 	return token.SignedString([]byte(secret))
 }
 
+/*
+AuthMiddleware
+Authorize by api token in header X-Api-Token, or jwt of login user in header X-Authorization.
+Skipped if authorized by a previous handler.
+通过请求头X-Api-Token中的API令牌，或X-Authorization中登录用户的jwt验证。已被之前的处理器验证时跳过
+*/
 func AuthMiddleware(secret string) fiber.Handler {
 	return func(c *fiber.Ctx) error {
+		if _, ok := c.Locals("auth").(*AuthInfo); ok {
+			return c.Next()
+		}
+		if apiToken := c.Get("X-Api-Token"); apiToken != "" {
+			if err := verifyApiToken(c, apiToken); err != nil {
+				return err
+			}
+			return c.Next()
+		}
 		tokenStr := c.Get("X-Authorization")
 		if tokenStr == "" {
 			return fiber.NewError(fiber.StatusUnauthorized, "missing token")
@@ -189,32 +205,41 @@ func AuthMiddleware(secret string) fiber.Handler {
 		if len(tokenArr) != 2 || tokenArr[0] != "Bearer" {
 			return fiber.NewError(fiber.StatusUnauthorized, "invalid token")
 		}
-		token, err := jwt.Parse(tokenArr[1], func(token *jwt.Token) (interface{}, error) {
-			// Validate the algorithm
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fiber.NewError(fiber.StatusUnauthorized, "invalid token")
-			}
-			return []byte(secret), nil
-		})
+		if err := verifyAuthToken(c, tokenArr[1], secret); err != nil {
+			return err
+		}
+		return c.Next()
+	}
+}
 
-		if err != nil || !token.Valid {
-			return fiber.NewError(fiber.StatusUnauthorized, "invalid token")
+// verifyAuthToken check the jwt token and client ip, set `user`, `accounts` and `auth` to locals 校验jwt和客户端ip，设置user、accounts和auth到locals
+func verifyAuthToken(c *fiber.Ctx, tokenStr, secret string) error {
+	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
+		// Validate the algorithm
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fiber.NewError(fiber.StatusUnauthorized, "invalid token")
 		}
-		if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-			user := claims["user"]
-			c.Locals("user", user)
-			clientIP := c.IP()
-			users := config.GetApiUsers()
-			for _, u := range users {
-				if u.Username == user {
-					if len(u.AllowIPs) > 0 && !utils.ArrContains(u.AllowIPs, clientIP) {
-						return fiber.NewError(fiber.StatusUnauthorized, "unauthorized from ip: "+clientIP)
-					}
-					c.Locals("accounts", u.AccRoles)
-					break
+		return []byte(secret), nil
+	})
+
+	if err != nil || !token.Valid {
+		return fiber.NewError(fiber.StatusUnauthorized, "invalid token")
+	}
+	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
+		user := claims["user"]
+		c.Locals("user", user)
+		clientIP := c.IP()
+		users := config.GetApiUsers()
+		for _, u := range users {
+			if u.Username == user {
+				if len(u.AllowIPs) > 0 && !utils.ArrContains(u.AllowIPs, clientIP) {
+					return fiber.NewError(fiber.StatusUnauthorized, "unauthorized from ip: "+clientIP)
 				}
+				c.Locals("accounts", u.AccRoles)
+				c.Locals("auth", newUserAuth(u))
+				break
 			}
 		}
-		return c.Next()
 	}
+	return nil
 }
@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/banbox/banbot/orm/ormo"
+	"github.com/banbox/banbot/orm/ormu"
 	"github.com/banbox/banexg/log"
 	"go.uber.org/zap"
 
@@ -27,6 +28,7 @@ import (
 	"github.com/banbox/banbot/utils"
 	"github.com/banbox/banbot/web/base"
 	"github.com/banbox/banexg"
+	"github.com/banbox/banexg/errs"
 	utils2 "github.com/banbox/banexg/utils"
 	"github.com/gofiber/fiber/v2"
 	"github.com/shirou/gopsutil/v4/cpu"
@@ -34,27 +36,9 @@ import (
 )
 
 func regApiBiz(api fiber.Router) {
-	api.Get("/version", getVersion)
-	api.Get("/balance", getBalance)
-	api.Post("/refresh_wallet", postRefreshWallet)
-	api.Get("/today_num", getTodayNum)
-	api.Get("/statistics", getStatistics)
-	api.Get("/incomes", getIncomes)
-	api.Get("/task_pairs", getTaskPairs)
-	api.Get("/exs_map", getExsMap)
-	api.Get("/orders", getOrders)
-	api.Post("/calc_profits", postCalcProfits)
-	api.Post("/exit_order", postExitOrder)
-	api.Post("/close_exg_pos", postCloseExgPos)
-	api.Post("/delay_entry", postDelayEntry)
-	api.Get("/config", getConfig)
-	api.Get("/stg_jobs", getStratJobs)
-	api.Get("/performance", getPerformance)
-	api.Post("/start_down_trade", postStartDownTrade)
-	api.Get("/get_down_trade", getDownTrade)
-	api.Get("/group_sta", getGroupSta)
-	api.Get("/log", getLog)
-	api.Get("/bot_info", getBotInfo)
+	for _, r := range bizRoutes {
+		api.Add(r.Method, r.Path, permMiddleware(r), r.Handler)
+	}
 }
 
 type FnAccCB = func(acc string) error
@@ -107,7 +91,7 @@ func walletItems(wallet *biz.BanWallets) []map[string]interface{} {
 			"upol":       item.UnrealizedPOL,
 			"free":       item.Available,
 			"used":       item.Used(),
-			"total_fiat": total * core.GetPrice(coin),
+			"total_fiat": total * max(0, core.GetPriceSafe(coin)),
 		})
 	}
 	return items
@@ -117,7 +101,7 @@ func postRefreshWallet(c *fiber.Ctx) error {
 	return wrapAccount(c, func(account string) error {
 		wallet := biz.GetWallets(account)
 		if core.EnvReal {
-			rsp, err := exg.Default.FetchBalance(map[string]interface{}{
+			rsp, err := exg.Get(account).FetchBalance(map[string]interface{}{
 				banexg.ParamAccount: account,
 			})
 			if err != nil {
@@ -189,6 +173,7 @@ func getStatistics(c *fiber.Ctx) error {
 			return err
 		}
 		defer conn.Close()
+		exgName, market := config.GetAccVenue(acc)
 		taskId := ormo.GetTaskID(acc)
 		orders, err := sess.GetOrders(ormo.GetOrdersArgs{
 			TaskID: taskId,
@@ -301,8 +286,8 @@ func getStatistics(c *fiber.Ctx) error {
 			"totalCost":         totalCost,
 			"botStartMs":        core.StartAt,
 			"runTfs":            utils.KeysOfMap(tfMap),
-			"exchange":          core.ExgName,
-			"market":            core.Market,
+			"exchange":          exgName,
+			"market":            market,
 			"pairs":             utils.KeysOfMap(pairs),
 		})
 	})
@@ -322,6 +307,9 @@ func getOrders(c *fiber.Ctx) error {
 		Source    string `query:"source" validate:"required"`
 		EnterTag  string `query:"enterTag"`
 		ExitTag   string `query:"exitTag"`
+		Tags      string `query:"tags"`
+		MinRating int64  `query:"minRating"`
+		HasNote   bool   `query:"hasNote"`
 	}
 	var data = new(OrderArgs)
 	if err := base.VerifyArg(c, data, base.ArgQuery); err != nil {
@@ -329,8 +317,11 @@ func getOrders(c *fiber.Ctx) error {
 	}
 	type OdWrap struct {
 		*ormo.InOutOrder
-		CurPrice float64 `json:"curPrice"`
+		CurPrice float64       `json:"curPrice"`
+		LiqPrice float64       `json:"liqPrice,omitempty"` // estimated by config margin for open contract orders 按margin配置估算的合约未平仓订单强平价
+		Journal  *ormu.Journal `json:"journal,omitempty"`
 	}
+	jFilter := &ormu.JournalFilter{Tags: data.Tags, MinRating: data.MinRating, HasNote: data.HasNote}
 	getBotOrders := func(acc string) error {
 		sess, conn, err := ormo.Conn(orm.DbTrades, false)
 		if err != nil {
@@ -354,6 +345,11 @@ func getOrders(c *fiber.Ctx) error {
 		} else if data.Dirt == "short" {
 			odDirt = core.OdDirtShort
 		}
+		limit := data.Limit
+		if !jFilter.IsEmpty() {
+			// apply limit after filtering by journals 按日志过滤后再限制数量
+			limit = 0
+		}
 		orders, err := sess.GetOrders(ormo.GetOrdersArgs{
 			TaskID:      taskId,
 			Strategy:    data.Strategy,
@@ -363,7 +359,7 @@ func getOrders(c *fiber.Ctx) error {
 			Dirt:        odDirt,
 			CloseAfter:  data.StartMs,
 			CloseBefore: data.StopMs,
-			Limit:       data.Limit,
+			Limit:       limit,
 			AfterID:     data.AfterID,
 			EnterTag:    data.EnterTag,
 			ExitTag:     data.ExitTag,
@@ -371,13 +367,24 @@ func getOrders(c *fiber.Ctx) error {
 		if err != nil {
 			return err
 		}
+		orders, journals, err_ := filterByJournal(orders, taskId, jFilter)
+		if err_ != nil {
+			return err_
+		}
+		if data.Limit > 0 && len(orders) > data.Limit {
+			orders = orders[:data.Limit]
+		}
+		liqPrices, err := calcLiqPrices(acc, orders)
+		if err != nil {
+			return err
+		}
 		odList := make([]*OdWrap, 0, len(orders))
 		for _, od := range orders {
 			price := float64(0)
 			if od.ExitTag != "" && od.Exit != nil && od.Exit.Price > 0 {
 				price = od.Exit.Price
 			} else {
-				price = core.GetPriceSafe(od.Symbol)
+				price = od.CurPrice()
 				if price > 0 {
 					od.UpdateProfits(price)
 				}
@@ -386,6 +393,8 @@ func getOrders(c *fiber.Ctx) error {
 			odList = append(odList, &OdWrap{
 				InOutOrder: od,
 				CurPrice:   price,
+				LiqPrice:   liqPrices[od.ID],
+				Journal:    journals[od.ID],
 			})
 		}
 		sort.Slice(odList, func(i, j int) bool {
@@ -396,7 +405,7 @@ func getOrders(c *fiber.Ctx) error {
 		})
 	}
 	getExgOrders := func(acc string) error {
-		orders, err := exg.Default.FetchOrders(data.Symbols, data.StartMs, data.Limit, map[string]interface{}{
+		orders, err := exg.Get(acc).FetchOrders(data.Symbols, data.StartMs, data.Limit, map[string]interface{}{
 			banexg.ParamAccount: acc,
 		})
 		if err != nil {
@@ -424,7 +433,7 @@ func getOrders(c *fiber.Ctx) error {
 		if data.Symbols != "" {
 			symbols = strings.Split(data.Symbols, ",")
 		}
-		posList, err := exg.Default.FetchPositions(symbols, map[string]interface{}{
+		posList, err := exg.Get(acc).FetchPositions(symbols, map[string]interface{}{
 			banexg.ParamAccount: acc,
 		})
 		if err != nil {
@@ -447,6 +456,37 @@ func getOrders(c *fiber.Ctx) error {
 	})
 }
 
+/*
+calcLiqPrices
+Estimate liquidation prices of open contract orders of the account by config margin, grouped by settle currency
+按margin配置估算账户中合约未平仓订单的强平价格，按结算币分组计算
+*/
+func calcLiqPrices(acc string, orders []*ormo.InOutOrder) (map[int64]float64, *errs.Error) {
+	res := make(map[int64]float64)
+	if _, market := config.GetAccVenue(acc); !banexg.IsContract(market) {
+		return res, nil
+	}
+	settleOds := make(map[string][]*ormo.InOutOrder)
+	for _, od := range orders {
+		if od.Status >= ormo.InOutStatusFullExit {
+			continue
+		}
+		code := core.QuoteCode(od.Symbol)
+		settleOds[code] = append(settleOds[code], od)
+	}
+	wallets := biz.GetWallets(acc)
+	for code, odList := range settleOds {
+		prices, err := wallets.LiqPrices(odList, code)
+		if err != nil {
+			return nil, err
+		}
+		for id, price := range prices {
+			res[id] = price
+		}
+	}
+	return res, nil
+}
+
 func postCalcProfits(c *fiber.Ctx) error {
 	return wrapAccount(c, func(acc string) error {
 		openOds, lock := ormo.GetOpenODs(acc)
@@ -455,8 +495,9 @@ func postCalcProfits(c *fiber.Ctx) error {
 		}
 		lock.Lock()
 		defer lock.Unlock()
-		items, err := exg.Default.FetchLastPrices(nil, map[string]interface{}{
-			banexg.ParamMarket:  core.Market,
+		exgName, market := config.GetAccVenue(acc)
+		items, err := exg.Get(acc).FetchLastPrices(nil, map[string]interface{}{
+			banexg.ParamMarket:  market,
 			banexg.ParamAccount: acc,
 		})
 		if err != nil {
@@ -466,7 +507,7 @@ func postCalcProfits(c *fiber.Ctx) error {
 		for _, it := range items {
 			prices[it.Symbol] = it.Price
 		}
-		core.SetPrices(prices)
+		core.SetVenuePrices(exgName, market, prices)
 		fails := make(map[string]bool)
 		for _, od := range openOds {
 			if price, ok := prices[od.Symbol]; ok {
@@ -548,9 +589,10 @@ func postCloseExgPos(c *fiber.Ctx) error {
 		return err
 	}
 	return wrapAccount(c, func(acc string) error {
+		_, market := config.GetAccVenue(acc)
 		var reqs []*CloseArgs
 		if data.Symbol == "all" {
-			posList, err := exg.Default.FetchPositions(nil, map[string]interface{}{
+			posList, err := exg.Get(acc).FetchPositions(nil, map[string]interface{}{
 				banexg.ParamAccount: acc,
 			})
 			if err != nil {
@@ -576,10 +618,10 @@ func postCloseExgPos(c *fiber.Ctx) error {
 			params := map[string]interface{}{
 				banexg.ParamAccount: acc,
 			}
-			if banexg.IsContract(core.Market) {
+			if banexg.IsContract(market) {
 				params[banexg.ParamPositionSide] = strings.ToUpper(q.Side)
 			}
-			res, err := exg.Default.CreateOrder(q.Symbol, q.OrderType, side, q.Amount, q.Price, params)
+			res, err := exg.Get(acc).CreateOrder(q.Symbol, q.OrderType, side, q.Amount, q.Price, params)
 			if err != nil {
 				return err
 			}
@@ -609,7 +651,7 @@ func getIncomes(c *fiber.Ctx) error {
 		return err
 	}
 	return wrapAccount(c, func(acc string) error {
-		items, err := exg.Default.FetchIncomeHistory(data.InType, data.Symbol, data.StartTime, data.Limit, map[string]interface{}{
+		items, err := exg.Get(acc).FetchIncomeHistory(data.InType, data.Symbol, data.StartTime, data.Limit, map[string]interface{}{
 			banexg.ParamAccount: acc,
 		})
 		if err != nil {
@@ -636,6 +678,37 @@ func postDelayEntry(c *fiber.Ctx) error {
 	})
 }
 
+/*
+postShutdown
+Stop the bot like SIGTERM, policy overrides `shutdown.policy` when given. Require admin of all accounts.
+像SIGTERM一样停止机器人，传入policy时覆盖shutdown.policy。需要所有账户的管理权限
+*/
+func postShutdown(c *fiber.Ctx) error {
+	type ShutdownArgs struct {
+		Policy string `json:"policy" validate:"omitempty,oneof=keep close_all close_unprotected"`
+	}
+	var data = new(ShutdownArgs)
+	if err := base.VerifyArg(c, data, base.ArgBody); err != nil {
+		return err
+	}
+	auth, _ := c.Locals("auth").(*AuthInfo)
+	for acc := range config.Accounts {
+		if auth == nil || !auth.Allow(acc, ScopeAdmin) {
+			return fiber.NewError(fiber.StatusForbidden, "admin of all accounts is required")
+		}
+	}
+	if data.Policy != "" {
+		biz.SetShutdownPolicy(data.Policy)
+	}
+	log.Warn("shutdown from api", zap.Any("user", c.Locals("user")), zap.String("policy", biz.GetShutdownPolicy()))
+	go func() {
+		// wait for response sent 等待响应发送
+		time.Sleep(time.Millisecond * 300)
+		core.ExitProcess(0)
+	}()
+	return c.JSON(fiber.Map{"code": 200})
+}
+
 func getConfig(c *fiber.Ctx) error {
 	// 因在线更新配置有很多限制，大多数配置无法即刻生效，故暂不提供在线修改
 	data, err := config.DumpYaml(true)
@@ -666,7 +739,7 @@ func getStratJobs(c *fiber.Ctx) error {
 			for stgName, job := range jobMap {
 				var odNum = 0
 				for _, od := range openOds {
-					if od.Symbol == arr[0] && od.Timeframe == arr[1] && od.Strategy == stgName {
+					if od.VenueKey() == arr[0] && od.Timeframe == arr[1] && od.Strategy == stgName {
 						odNum += 1
 					}
 				}
@@ -721,7 +794,8 @@ func getTaskPairs(c *fiber.Ctx) error {
 }
 
 func getExsMap(c *fiber.Ctx) error {
-	exsMap := orm.GetExSymbolMap(core.ExgName, core.Market)
+	// symbols of the default exchange if X-Account is missing 缺少X-Account时返回默认交易所的品种
+	exsMap := orm.GetExSymbolMap(config.GetAccVenue(c.Get("X-Account")))
 	return c.JSON(fiber.Map{
 		"data": exsMap,
 	})
@@ -859,6 +933,9 @@ func getGroupSta(c *fiber.Ctx) error {
 		GroupBy   string `query:"groupBy"`
 		StartTime string `query:"startTime"`
 		EndTime   string `query:"endTime"`
+		Tags      string `query:"tags"`
+		MinRating int64  `query:"minRating"`
+		HasNote   bool   `query:"hasNote"`
 	}
 	var data = new(GroupStaArgs)
 	if err_ := base.VerifyArg(c, data, base.ArgQuery); err_ != nil {
@@ -901,6 +978,11 @@ func getGroupSta(c *fiber.Ctx) error {
 		if err != nil {
 			return err
 		}
+		jFilter := &ormu.JournalFilter{Tags: data.Tags, MinRating: data.MinRating, HasNote: data.HasNote}
+		orders, journals, err_ := filterByJournal(orders, taskId, jFilter)
+		if err_ != nil {
+			return err_
+		}
 		groups := groupOrders(orders, func(od *ormo.InOutOrder) string {
 			if data.GroupBy == "strategy" {
 				return od.Strategy
@@ -908,6 +990,16 @@ func getGroupSta(c *fiber.Ctx) error {
 				return fmt.Sprintf("%v:%v", od.Strategy, od.EnterTag)
 			} else if data.GroupBy == "exitTag" {
 				return fmt.Sprintf("%v:%v", od.Strategy, od.ExitTag)
+			} else if data.GroupBy == "journalTag" {
+				if j := journals[od.ID]; j != nil {
+					return j.Tags
+				}
+				return ""
+			} else if data.GroupBy == "rating" {
+				if j := journals[od.ID]; j != nil {
+					return strconv.FormatInt(j.Rating, 10)
+				}
+				return "0"
 			}
 			return od.Symbol
 		})
@@ -945,9 +1037,6 @@ func postStartDownTrade(c *fiber.Ctx) error {
 	if err_ != nil {
 		return err_
 	}
-	if exg.Default.Info().ID != "binance" {
-		return errors.New("exchange not support")
-	}
 	method := binance.MethodFapiPrivateGetOrderAsyn
 	if data.Source == "income" {
 		method = binance.MethodFapiPrivateGetIncomeAsyn
@@ -955,7 +1044,11 @@ func postStartDownTrade(c *fiber.Ctx) error {
 		method = binance.MethodFapiPrivateGetTradeAsyn
 	}
 	return wrapAccount(c, func(acc string) error {
-		rsp, err := exg.Default.Call(method, map[string]interface{}{
+		exchange := exg.Get(acc)
+		if exchange.Info().ID != "binance" {
+			return errors.New("exchange not support")
+		}
+		rsp, err := exchange.Call(method, map[string]interface{}{
 			banexg.ParamAccount: acc,
 			"startTime":         startMS,
 			"endTime":           endMS,
@@ -977,9 +1070,6 @@ func getDownTrade(c *fiber.Ctx) error {
 	if err_ := base.VerifyArg(c, data, base.ArgQuery); err_ != nil {
 		return err_
 	}
-	if exg.Default.Info().ID != "binance" {
-		return errors.New("exchange not support")
-	}
 	method := binance.MethodFapiPrivateGetOrderAsynId
 	if data.Source == "income" {
 		method = binance.MethodFapiPrivateGetIncomeAsynId
@@ -987,7 +1077,11 @@ func getDownTrade(c *fiber.Ctx) error {
 		method = binance.MethodFapiPrivateGetTradeAsynId
 	}
 	return wrapAccount(c, func(acc string) error {
-		rsp, err := exg.Default.Call(method, map[string]interface{}{
+		exchange := exg.Get(acc)
+		if exchange.Info().ID != "binance" {
+			return errors.New("exchange not support")
+		}
+		rsp, err := exchange.Call(method, map[string]interface{}{
 			banexg.ParamAccount: acc,
 			"downloadId":        data.ID,
 			"timestamp":         btime.UTCStamp(),
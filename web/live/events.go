@@ -0,0 +1,269 @@
+package live
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/banbox/banbot/biz"
+	"github.com/banbox/banbot/btime"
+	"github.com/banbox/banbot/orm/ormo"
+	"github.com/banbox/banbot/rpc"
+	"github.com/banbox/banbot/strat"
+	"github.com/banbox/banexg/log"
+	"github.com/banbox/banexg/utils"
+	"github.com/gofiber/contrib/websocket"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sasha-s/go-deadlock"
+	"go.uber.org/zap"
+)
+
+const (
+	EvtOrder   = "order"   // order lifecycle from strat.FireOdChange 订单生命周期事件
+	EvtWallet  = "wallet"  // wallet updated from exchange balances 钱包余额更新
+	EvtTrigger = "trigger" // stop loss/take profit order placed or cancelled 止损止盈单下单或撤销
+	EvtNotify  = "notify"  // rpc notification from rpc.SendMsg rpc通知消息
+)
+
+// evtBufSize messages queued for each client, new messages are dropped for slow clients when full 每个客户端的消息队列大小，满时丢弃
+const evtBufSize = 256
+
+var (
+	evtClients   = make(map[*EvtClient]bool)
+	lockEvtCli   deadlock.RWMutex
+	evtSubsOnce  sync.Once
+	odChgEvtName = map[int]string{
+		strat.OdChgNew:       "new",
+		strat.OdChgEnter:     "enter",
+		strat.OdChgEnterFill: "enter_fill",
+		strat.OdChgExit:      "exit",
+		strat.OdChgExitFill:  "exit_fill",
+	}
+)
+
+/*
+EvtClient
+Websocket client of live events, filtered by accounts and strategies. Empty filter means all.
+Accounts are always limited to acc_roles of the login user if configured.
+实时事件的websocket客户端，按账户和策略过滤，过滤为空表示全部。账户始终限制在登录用户配置的acc_roles中
+*/
+type EvtClient struct {
+	Conn       *websocket.Conn
+	remote     string
+	auth       *AuthInfo // events of accounts without read scope are skipped 跳过无查看权限账户的事件
+	accounts   map[string]bool
+	strategies map[string]bool
+	out        chan []byte
+	lock       deadlock.Mutex
+}
+
+// regEventSubs listen orders, wallets, triggers and rpc messages once 只注册一次订单、钱包、触发单和rpc消息的监听
+func regEventSubs() {
+	evtSubsOnce.Do(func() {
+		strat.AddOdSub("*", func(acc string, od *ormo.InOutOrder, evt int) {
+			// the order is shared with the order manager, marshal a clone 订单与订单管理器共享，序列化副本
+			odCopy := od.Clone()
+			odCopy.NanInfTo(0)
+			broadcastEvt(EvtOrder, acc, od.Strategy, map[string]interface{}{
+				"event":    odChgEvtName[evt],
+				"order":    odCopy,
+				"curPrice": od.CurPrice(),
+			})
+		})
+		biz.AddWalletSub(func(wallets *biz.BanWallets) {
+			broadcastEvt(EvtWallet, wallets.Account, "", map[string]interface{}{
+				"items": walletItems(wallets),
+				"total": wallets.FiatValue(true),
+			})
+		})
+		biz.AddTriggerSub(func(acc string, od *ormo.InOutOrder, key string) {
+			broadcastEvt(EvtTrigger, acc, od.Strategy, map[string]interface{}{
+				"id":     od.ID,
+				"symbol": od.Symbol,
+				"key":    key,
+				"state":  od.GetExitTrigger(key),
+			})
+		})
+		rpc.AddMsgSub(func(msg map[string]interface{}) {
+			acc := utils.GetMapVal(msg, "account", "")
+			stgName := utils.GetMapVal(msg, "strategy", "")
+			broadcastEvt(EvtNotify, acc, stgName, msg)
+		})
+	})
+}
+
+/*
+broadcastEvt
+Send an event to all matched clients without blocking, events without account or strategy are not filtered by it.
+The event is marshaled once before taking the clients lock.
+非阻塞地向所有匹配的客户端发送事件，没有账户或策略的事件不按其过滤。事件在获取客户端锁之前序列化一次
+*/
+func broadcastEvt(evtType, acc, stgName string, data interface{}) {
+	lockEvtCli.RLock()
+	cliNum := len(evtClients)
+	lockEvtCli.RUnlock()
+	if cliNum == 0 {
+		return
+	}
+	msg, err := utils.Marshal(map[string]interface{}{
+		"type":     evtType,
+		"account":  acc,
+		"strategy": stgName,
+		"time":     btime.UTCStamp(),
+		"data":     data,
+	})
+	if err != nil {
+		log.Warn("marshal event fail", zap.String("type", evtType), zap.Error(err))
+		return
+	}
+	lockEvtCli.RLock()
+	defer lockEvtCli.RUnlock()
+	for client := range evtClients {
+		if !client.match(acc, stgName) {
+			continue
+		}
+		select {
+		case client.out <- msg:
+		default:
+			log.Warn("event client too slow, drop", zap.String("addr", client.remote), zap.String("type", evtType))
+		}
+	}
+}
+
+func (c *EvtClient) match(acc, stgName string) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if acc != "" {
+		if c.auth == nil || !c.auth.Allow(acc, ScopeRead) {
+			return false
+		}
+		if len(c.accounts) > 0 && !c.accounts[acc] {
+			return false
+		}
+	}
+	if stgName != "" && len(c.strategies) > 0 && !c.strategies[stgName] {
+		return false
+	}
+	return true
+}
+
+// SetFilter replace filters of accounts and strategies 替换账户和策略过滤
+func (c *EvtClient) SetFilter(accounts, strategies []string) {
+	c.lock.Lock()
+	c.accounts = toSet(accounts)
+	c.strategies = toSet(strategies)
+	c.lock.Unlock()
+}
+
+func toSet(items []string) map[string]bool {
+	res := make(map[string]bool)
+	for _, it := range items {
+		if it = strings.TrimSpace(it); it != "" {
+			res[it] = true
+		}
+	}
+	return res
+}
+
+func splitQuery(val string) []string {
+	if val == "" {
+		return nil
+	}
+	return strings.Split(val, ",")
+}
+
+/*
+WsAuthMiddleware
+Authorize websocket upgrade by the jwt token in query `token` or api token in `api_token`, since browsers can't set headers for websocket
+通过query中的token或api_token参数验证websocket升级请求，因浏览器无法为websocket设置请求头
+*/
+func WsAuthMiddleware(secret string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if !websocket.IsWebSocketUpgrade(c) {
+			return fiber.ErrUpgradeRequired
+		}
+		if apiToken := c.Query("api_token"); apiToken != "" {
+			if err := verifyApiToken(c, apiToken); err != nil {
+				return err
+			}
+			return c.Next()
+		}
+		tokenStr := c.Query("token")
+		if tokenStr == "" {
+			return fiber.NewError(fiber.StatusUnauthorized, "missing token")
+		}
+		if err := verifyAuthToken(c, tokenStr, secret); err != nil {
+			return err
+		}
+		return c.Next()
+	}
+}
+
+/*
+wsEvents
+Push order, wallet, trigger and rpc notify events. Filter with query `accounts` and `strategies` (comma separated),
+or send {"action": "filter", "accounts": [...], "strategies": [...]} to replace filters.
+推送订单、钱包、触发单和rpc通知事件。可通过query的accounts和strategies(逗号分隔)过滤，或发送filter消息替换过滤条件
+*/
+func wsEvents(c *websocket.Conn) {
+	client := &EvtClient{
+		Conn:   c,
+		remote: c.RemoteAddr().String(),
+		out:    make(chan []byte, evtBufSize),
+	}
+	client.auth, _ = c.Locals("auth").(*AuthInfo)
+	client.SetFilter(splitQuery(c.Query("accounts")), splitQuery(c.Query("strategies")))
+	lockEvtCli.Lock()
+	evtClients[client] = true
+	lockEvtCli.Unlock()
+	log.Debug("event client joined", zap.String("addr", client.remote))
+	done := make(chan struct{})
+	go func() {
+		client.writeForever()
+		close(done)
+	}()
+	client.readForever()
+	lockEvtCli.Lock()
+	delete(evtClients, client)
+	lockEvtCli.Unlock()
+	close(client.out)
+	// the conn can't be used after the handler returns 处理函数返回后conn不可再使用
+	<-done
+	log.Debug("event client removed", zap.String("addr", client.remote))
+}
+
+func (c *EvtClient) readForever() {
+	for {
+		mt, data, err := c.Conn.ReadMessage()
+		if err != nil || mt == websocket.CloseMessage {
+			return
+		}
+		if mt != websocket.TextMessage {
+			continue
+		}
+		var msg struct {
+			Action     string   `json:"action"`
+			Accounts   []string `json:"accounts"`
+			Strategies []string `json:"strategies"`
+		}
+		err = utils.Unmarshal(data, &msg, utils.JsonNumAuto)
+		if err != nil || msg.Action != "filter" {
+			log.Info("unexpected event ws msg", zap.String("str", string(data)))
+			continue
+		}
+		c.SetFilter(msg.Accounts, msg.Strategies)
+	}
+}
+
+func (c *EvtClient) writeForever() {
+	for data := range c.out {
+		err := c.Conn.WriteMessage(websocket.TextMessage, data)
+		if err != nil {
+			log.Warn("write event fail", zap.String("addr", c.remote), zap.Error(err))
+			// close conn to stop readForever
+			_ = c.Conn.Close()
+			for range c.out {
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package live
+
+import (
+	"testing"
+)
+
+func TestEvtClientMatch(t *testing.T) {
+	client := &EvtClient{auth: &AuthInfo{Scopes: map[string]map[string]bool{
+		"acc1": toSet(roleScopes[RoleView]),
+		"acc2": toSet(roleScopes[RoleTrade]),
+	}}}
+	cases := []struct {
+		acc      string
+		stgName  string
+		accounts []string
+		stgs     []string
+		ok       bool
+	}{
+		{"acc1", "ma", nil, nil, true},
+		{"acc3", "ma", nil, nil, false},
+		// events without account are not filtered by account 无账户的事件不按账户过滤
+		{"", "ma", []string{"acc1"}, nil, true},
+		{"acc2", "ma", []string{"acc1"}, nil, false},
+		{"acc1", "ma", []string{"acc1", "acc2"}, []string{"ma"}, true},
+		{"acc1", "rsi", nil, []string{"ma"}, false},
+		{"acc1", "", nil, []string{"ma"}, true},
+		// filters can't widen the scopes 过滤不能扩大权限范围
+		{"acc3", "ma", []string{"acc3"}, nil, false},
+	}
+	for _, c := range cases {
+		client.SetFilter(c.accounts, c.stgs)
+		if got := client.match(c.acc, c.stgName); got != c.ok {
+			t.Errorf("match(%q, %q) with accounts %v strategies %v = %v, want %v",
+				c.acc, c.stgName, c.accounts, c.stgs, got, c.ok)
+		}
+	}
+	// no auth: only events without account 无认证：只接收无账户事件
+	client = &EvtClient{}
+	if client.match("acc1", "") || !client.match("", "ma") {
+		t.Errorf("client without auth should only match events without account")
+	}
+}
+
+func TestBroadcastEvtScopes(t *testing.T) {
+	newClient := func(scopes map[string]map[string]bool) *EvtClient {
+		return &EvtClient{
+			remote: "test",
+			auth:   &AuthInfo{Scopes: scopes},
+			out:    make(chan []byte, 4),
+		}
+	}
+	all := newClient(map[string]map[string]bool{"*": toSet(roleScopes[RoleView])})
+	acc1 := newClient(map[string]map[string]bool{"acc1": toSet(roleScopes[RoleView])})
+	lockEvtCli.Lock()
+	evtClients[all] = true
+	evtClients[acc1] = true
+	lockEvtCli.Unlock()
+	defer func() {
+		lockEvtCli.Lock()
+		delete(evtClients, all)
+		delete(evtClients, acc1)
+		lockEvtCli.Unlock()
+	}()
+	broadcastEvt(EvtWallet, "acc1", "", map[string]interface{}{"total": 1})
+	broadcastEvt(EvtWallet, "acc2", "", map[string]interface{}{"total": 2})
+	if len(all.out) != 2 {
+		t.Errorf("client of all accounts should get 2 events, got %d", len(all.out))
+	}
+	if len(acc1.out) != 1 {
+		t.Errorf("client of acc1 should get 1 event, got %d", len(acc1.out))
+	}
+}
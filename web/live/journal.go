@@ -0,0 +1,85 @@
+package live
+
+import (
+	"github.com/banbox/banbot/orm"
+	"github.com/banbox/banbot/orm/ormo"
+	"github.com/banbox/banbot/orm/ormu"
+	"github.com/banbox/banbot/web/base"
+	"github.com/gofiber/fiber/v2"
+)
+
+func postJournal(c *fiber.Ctx) error {
+	var data = new(base.JournalArgs)
+	if err := base.VerifyArg(c, data, base.ArgBody); err != nil {
+		return err
+	}
+	return wrapAccount(c, func(acc string) error {
+		taskId := ormo.GetTaskID(acc)
+		od, err := getBotOrder(taskId, data.OrderID)
+		if err != nil {
+			return err
+		}
+		item, err := base.SaveJournal(ormu.JournalLive, taskId, od, data)
+		if err != nil {
+			return err
+		}
+		return c.JSON(fiber.Map{
+			"data": item,
+		})
+	})
+}
+
+func getJournalSnapshot(c *fiber.Ctx) error {
+	type SnapArgs struct {
+		OrderID int64 `query:"orderId" validate:"required"`
+	}
+	var data = new(SnapArgs)
+	if err := base.VerifyArg(c, data, base.ArgQuery); err != nil {
+		return err
+	}
+	return wrapAccount(c, func(acc string) error {
+		return base.SendSnapshot(c, ormu.JournalLive, ormo.GetTaskID(acc), data.OrderID)
+	})
+}
+
+func getBotOrder(taskId, orderId int64) (*ormo.InOutOrder, error) {
+	sess, conn, err := ormo.Conn(orm.DbTrades, false)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	orders, err := sess.GetOrders(ormo.GetOrdersArgs{
+		TaskID: taskId,
+		ID:     orderId,
+		Limit:  1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(orders) == 0 {
+		return nil, fiber.NewError(fiber.StatusNotFound, "order not found")
+	}
+	return orders[0], nil
+}
+
+/*
+filterByJournal
+Attach journal entries to orders and keep those matching the filter
+为订单附加日志条目，并保留匹配过滤器的订单
+*/
+func filterByJournal(orders []*ormo.InOutOrder, taskId int64, flt *ormu.JournalFilter) ([]*ormo.InOutOrder, map[int64]*ormu.Journal, error) {
+	journals, err := base.GetJournals(ormu.JournalLive, taskId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if flt.IsEmpty() {
+		return orders, journals, nil
+	}
+	res := make([]*ormo.InOutOrder, 0, len(orders))
+	for _, od := range orders {
+		if flt.Match(journals[od.ID]) {
+			res = append(res, od)
+		}
+	}
+	return res, journals, nil
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/banbox/banbot/web/ui"
 	"github.com/banbox/banexg/errs"
 	"github.com/banbox/banexg/log"
+	"github.com/gofiber/contrib/websocket"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"go.uber.org/zap"
@@ -35,10 +36,14 @@ func StartApi() *errs.Error {
 	}))
 
 	// register routes 注册路由
-	base.RegApiKline(app.Group("/api/kline"))
+	base.RegApiKline(app.Group(klineRoute.Path, AuthMiddleware(cfg.JWTSecretKey), permMiddleware(klineRoute)))
 	base.RegApiWebsocket(app.Group("/api/ws"))
 	regApiBiz(app.Group("/api/bot", AuthMiddleware(cfg.JWTSecretKey)))
+	app.Get(eventsRoute.Path, WsAuthMiddleware(cfg.JWTSecretKey), permMiddleware(eventsRoute), websocket.New(wsEvents))
+	regEventSubs()
 	regApiPub(app.Group("/api"))
+	app.Get(metricsRoute.Path, metricsAuth(cfg.MetricsToken), AuthMiddleware(cfg.JWTSecretKey),
+		permMiddleware(metricsRoute), getMetrics)
 
 	// 添加静态文件服务
 	err_ := ui.ServeStatic(app)
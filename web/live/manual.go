@@ -0,0 +1,314 @@
+package live
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/banbox/banbot/biz"
+	"github.com/banbox/banbot/config"
+	"github.com/banbox/banbot/core"
+	"github.com/banbox/banbot/orm"
+	"github.com/banbox/banbot/orm/ormo"
+	"github.com/banbox/banbot/strat"
+	"github.com/banbox/banbot/web/base"
+	"github.com/gofiber/fiber/v2"
+)
+
+/*
+findPairJob
+Find the running job of a pair for manual orders, the first job by strategy name is used if strategy is empty
+查找品种运行中的任务用于手动下单，策略为空时使用按策略名排序的第一个任务
+*/
+func findPairJob(acc, pair, stgName string) *strat.StratJob {
+	var names []string
+	var jobs = make(map[string]*strat.StratJob)
+	for _, items := range strat.GetJobs(acc) {
+		for name, job := range items {
+			if job.Symbol.Symbol != pair || stgName != "" && name != stgName {
+				continue
+			}
+			names = append(names, name)
+			jobs[name] = job
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	slices.Sort(names)
+	return jobs[names[0]]
+}
+
+// checkTriggers stop loss should be below and take profit above the price for long orders, reverse for short 多单止损应低于价格、止盈高于价格，空单相反
+func checkTriggers(short bool, price, stopLoss, takeProfit float64) error {
+	if price <= 0 {
+		return nil
+	}
+	dirFlag := 1.0
+	if short {
+		dirFlag = -1
+	}
+	if stopLoss > 0 && (price-stopLoss)*dirFlag <= 0 {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("invalid stopLoss %v for price %v", stopLoss, price))
+	}
+	if takeProfit > 0 && (takeProfit-price)*dirFlag <= 0 {
+		return fiber.NewError(fiber.StatusBadRequest, fmt.Sprintf("invalid takeProfit %v for price %v", takeProfit, price))
+	}
+	return nil
+}
+
+func getOpenOrder(acc string, orderID int64) *ormo.InOutOrder {
+	openOds, lock := ormo.GetOpenODs(acc)
+	lock.Lock()
+	defer lock.Unlock()
+	for _, od := range openOds {
+		if od.ID == orderID {
+			return od
+		}
+	}
+	return nil
+}
+
+/*
+postOpenOrder
+Open an order for a pair manually. It's owned by the running job of strategy on the pair (the first job if strategy
+is empty), and is a limit order when price is given. The cost defaults to the stake amount of the job.
+手动为品种开单。订单归属于该品种上指定策略的运行中任务（策略为空时为第一个任务），指定price时为限价单。默认金额为任务的开单金额
+*/
+func postOpenOrder(c *fiber.Ctx) error {
+	type OpenArgs struct {
+		Symbol     string  `json:"symbol" validate:"required"`
+		Strategy   string  `json:"strategy"`
+		Side       string  `json:"side" validate:"required,oneof=long short"`
+		Price      float64 `json:"price"`
+		Amount     float64 `json:"amount"`
+		LegalCost  float64 `json:"legalCost"`
+		Leverage   float64 `json:"leverage"`
+		StopLoss   float64 `json:"stopLoss"`
+		TakeProfit float64 `json:"takeProfit"`
+		Tag        string  `json:"tag"`
+	}
+	var data = new(OpenArgs)
+	if err := base.VerifyArg(c, data, base.ArgBody); err != nil {
+		return err
+	}
+	return wrapAccount(c, func(acc string) error {
+		job := findPairJob(acc, data.Symbol, data.Strategy)
+		if job == nil {
+			return fiber.NewError(fiber.StatusNotFound, "no job running for "+data.Symbol)
+		}
+		short := data.Side == "short"
+		price := data.Price
+		if price == 0 {
+			price = core.GetPriceSafe(job.Symbol.VenueKey())
+		}
+		if err := checkTriggers(short, price, data.StopLoss, data.TakeProfit); err != nil {
+			return err
+		}
+		req := &strat.EnterReq{
+			Tag:        data.Tag,
+			StratName:  job.Strat.Name,
+			Short:      short,
+			OrderType:  core.OrderTypeMarket,
+			Amount:     data.Amount,
+			LegalCost:  data.LegalCost,
+			Leverage:   data.Leverage,
+			StopLoss:   data.StopLoss,
+			TakeProfit: data.TakeProfit,
+		}
+		if req.Tag == "" {
+			req.Tag = core.EnterTagUserOpen
+		}
+		if data.Price > 0 {
+			req.OrderType = core.OrderTypeLimit
+			req.Limit = data.Price
+		}
+		if req.Amount == 0 && req.LegalCost == 0 {
+			rate, err := core.GetLegalRate(config.ReportCurrency)
+			if err != nil {
+				return err
+			}
+			req.LegalCost = job.Strat.GetStakeAmount(job) * rate
+		}
+		od, err := biz.EnterAccOrder(acc, job.Symbol, job.TimeFrame, req)
+		if err != nil {
+			return err
+		}
+		return c.JSON(fiber.Map{
+			"order": od,
+		})
+	})
+}
+
+/*
+postEditTriggers
+Edit stop loss/take profit of an open order, omitted fields are unchanged and 0 cancels it
+修改未平仓订单的止损止盈，未传的字段不变，0表示取消
+*/
+func postEditTriggers(c *fiber.Ctx) error {
+	type EditArgs struct {
+		OrderID         int64    `json:"orderId" validate:"required"`
+		StopLoss        *float64 `json:"stopLoss"`
+		StopLossLimit   float64  `json:"stopLossLimit"`
+		TakeProfit      *float64 `json:"takeProfit"`
+		TakeProfitLimit float64  `json:"takeProfitLimit"`
+	}
+	var data = new(EditArgs)
+	if err := base.VerifyArg(c, data, base.ArgBody); err != nil {
+		return err
+	}
+	return wrapAccount(c, func(acc string) error {
+		od := getOpenOrder(acc, data.OrderID)
+		if od == nil {
+			return fiber.NewError(fiber.StatusNotFound, "order not found")
+		}
+		var stopLoss, takeProfit float64
+		if data.StopLoss != nil {
+			stopLoss = *data.StopLoss
+		}
+		if data.TakeProfit != nil {
+			takeProfit = *data.TakeProfit
+		}
+		if err := checkTriggers(od.Short, od.CurPrice(), stopLoss, takeProfit); err != nil {
+			return err
+		}
+		lock := od.Lock()
+		defer lock.Unlock()
+		if od.Status >= ormo.InOutStatusFullExit {
+			return fiber.NewError(fiber.StatusBadRequest, "order already exited")
+		}
+		if data.StopLoss != nil {
+			od.SetStopLoss(editTrigger(od.GetStopLoss(), stopLoss, data.StopLossLimit))
+		}
+		if data.TakeProfit != nil {
+			od.SetTakeProfit(editTrigger(od.GetTakeProfit(), takeProfit, data.TakeProfitLimit))
+		}
+		if err := od.Save(nil); err != nil {
+			return err
+		}
+		return c.JSON(fiber.Map{
+			"stopLoss":   od.GetStopLoss(),
+			"takeProfit": od.GetTakeProfit(),
+		})
+	})
+}
+
+/*
+editTrigger
+Copy the current trigger and change price and limit only, keep OCO, rate and other fields
+复制当前触发并仅修改价格和限价，保留OCO、比例等其他字段
+*/
+func editTrigger(old *ormo.TriggerState, price, limit float64) *ormo.ExitTrigger {
+	res := &ormo.ExitTrigger{Tag: core.ExitTagUserExit}
+	if old != nil && old.ExitTrigger != nil {
+		*res = *old.ExitTrigger
+	}
+	res.Price = price
+	res.Limit = limit
+	return res
+}
+
+/*
+postAdjustPosition
+Add to an open order by opening a new order of the same strategy, direction and triggers, or partially reduce it.
+amount is the quantity of the symbol; for reduce, rate in (0,1) can be used instead.
+通过开一个相同策略、方向和止损止盈的新订单来加仓，或部分减仓。amount为标的数量；减仓时也可使用(0,1)之间的rate
+*/
+/*
+reduceAmount
+Amount to reduce by amount (prior) or rate of the filled position, the rate should be in (0, 1). Return amount and rate
+按数量（优先）或已成交仓位的比例减仓，比例需在(0, 1)之间。返回减仓数量和比例
+*/
+func reduceAmount(od *ormo.InOutOrder, amount, rate float64) (float64, float64, error) {
+	filled := od.Enter.Filled
+	if filled <= 0 {
+		return 0, 0, fiber.NewError(fiber.StatusBadRequest, "order not filled yet")
+	}
+	if amount > 0 {
+		if amount > filled {
+			return 0, 0, fiber.NewError(fiber.StatusBadRequest,
+				fmt.Sprintf("amount %v exceeds the filled %v", amount, filled))
+		}
+		rate = amount / filled
+	}
+	if rate <= 0 || rate >= 1 {
+		return 0, 0, fiber.NewError(fiber.StatusBadRequest, "reduce rate should be in (0, 1), use exit_order to close all")
+	}
+	return filled * rate, rate, nil
+}
+
+func postAdjustPosition(c *fiber.Ctx) error {
+	type AdjustArgs struct {
+		OrderID   int64   `json:"orderId" validate:"required"`
+		Action    string  `json:"action" validate:"required,oneof=add reduce"`
+		Amount    float64 `json:"amount"`
+		LegalCost float64 `json:"legalCost"`
+		Rate      float64 `json:"rate"`
+		Price     float64 `json:"price"`
+	}
+	var data = new(AdjustArgs)
+	if err := base.VerifyArg(c, data, base.ArgBody); err != nil {
+		return err
+	}
+	return wrapAccount(c, func(acc string) error {
+		od := getOpenOrder(acc, data.OrderID)
+		if od == nil {
+			return fiber.NewError(fiber.StatusNotFound, "order not found")
+		}
+		if data.Action == "reduce" {
+			amount, rate, err_ := reduceAmount(od, data.Amount, data.Rate)
+			if err_ != nil {
+				return err_
+			}
+			req := &strat.ExitReq{
+				Tag:    core.ExitTagUserExit,
+				Amount: amount,
+				Force:  true,
+			}
+			if data.Price > 0 {
+				req.OrderType = core.OrderTypeLimit
+				req.Limit = data.Price
+			}
+			_, _, err := biz.CloseAccOrders(acc, []*ormo.InOutOrder{od}, req)
+			if err != nil {
+				return err
+			}
+			return c.JSON(fiber.Map{
+				"rate": rate,
+			})
+		}
+		if data.Amount == 0 && data.LegalCost == 0 {
+			return fiber.NewError(fiber.StatusBadRequest, "amount or legalCost is required")
+		}
+		exgName, market := config.GetAccVenue(acc)
+		exs := orm.GetExSymbol2(exgName, market, od.Symbol)
+		if exs == nil {
+			return fiber.NewError(fiber.StatusNotFound, "symbol not found: "+od.Symbol)
+		}
+		req := &strat.EnterReq{
+			Tag:       core.EnterTagUserOpen,
+			StratName: od.Strategy,
+			Short:     od.Short,
+			OrderType: core.OrderTypeMarket,
+			Amount:    data.Amount,
+			LegalCost: data.LegalCost,
+			Leverage:  od.Leverage,
+		}
+		if data.Price > 0 {
+			req.OrderType = core.OrderTypeLimit
+			req.Limit = data.Price
+		}
+		if tg := od.GetStopLoss(); tg != nil {
+			req.StopLoss, req.StopLossLimit = tg.Price, tg.Limit
+		}
+		if tg := od.GetTakeProfit(); tg != nil {
+			req.TakeProfit, req.TakeProfitLimit = tg.Price, tg.Limit
+		}
+		newOd, err := biz.EnterAccOrder(acc, exs, od.Timeframe, req)
+		if err != nil {
+			return err
+		}
+		return c.JSON(fiber.Map{
+			"order": newOd,
+		})
+	})
+}
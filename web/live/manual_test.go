@@ -0,0 +1,67 @@
+package live
+
+import (
+	"testing"
+
+	"github.com/banbox/banbot/core"
+	"github.com/banbox/banbot/orm/ormo"
+)
+
+func TestEditTrigger(t *testing.T) {
+	od := &ormo.InOutOrder{
+		IOrder: &ormo.IOrder{Symbol: "BTC/USDT", Status: ormo.InOutStatusFullEnter, InitPrice: 100},
+	}
+	od.SetBracket(&ormo.ExitTrigger{Price: 90, Rate: 0.5, Tag: "sl"}, &ormo.ExitTrigger{Price: 120, Tag: "tp"})
+
+	od.SetStopLoss(editTrigger(od.GetStopLoss(), 95, 94))
+	sl := od.GetStopLoss()
+	if sl == nil || sl.ExitTrigger == nil {
+		t.Fatalf("stop loss missing after edit")
+	}
+	want := ormo.ExitTrigger{Price: 95, Limit: 94, Rate: 0.5, Tag: "sl", OCO: true}
+	if *sl.ExitTrigger != want {
+		t.Errorf("stop loss = %+v, want %+v", *sl.ExitTrigger, want)
+	}
+	tp := od.GetTakeProfit()
+	if tp == nil || tp.Price != 120 || !tp.OCO {
+		t.Errorf("take profit should be unchanged, got %+v", tp)
+	}
+
+	// new trigger is tagged as user exit 新建的触发标记为用户平仓
+	res := editTrigger(nil, 110, 0)
+	if res.Price != 110 || res.Tag != core.ExitTagUserExit || res.OCO {
+		t.Errorf("new trigger = %+v", *res)
+	}
+}
+
+func TestReduceAmount(t *testing.T) {
+	od := &ormo.InOutOrder{
+		IOrder: &ormo.IOrder{Symbol: "BTC/USDT", Status: ormo.InOutStatusFullEnter, InitPrice: 100},
+		Enter:  &ormo.ExOrder{Amount: 2, Filled: 1},
+	}
+	cases := []struct {
+		amount  float64
+		rate    float64
+		wantAmt float64
+		ok      bool
+	}{
+		// rate is relative to the filled amount 比例相对已成交数量
+		{0.5, 0, 0.5, true},
+		{0.25, 0.9, 0.25, true},
+		{0, 0.3, 0.3, true},
+		{1, 0, 0, false},
+		{1.5, 0, 0, false},
+		{0, 1, 0, false},
+		{0, 0, 0, false},
+	}
+	for _, c := range cases {
+		amt, _, err := reduceAmount(od, c.amount, c.rate)
+		if (err == nil) != c.ok || amt != c.wantAmt {
+			t.Errorf("reduceAmount(%v, %v) = %v, %v, want %v", c.amount, c.rate, amt, err, c.wantAmt)
+		}
+	}
+	od.Enter.Filled = 0
+	if _, _, err := reduceAmount(od, 0.5, 0); err == nil {
+		t.Errorf("reduce should fail for unfilled order")
+	}
+}
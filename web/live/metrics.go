@@ -0,0 +1,107 @@
+package live
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/banbox/banbot/biz"
+	"github.com/banbox/banbot/btime"
+	"github.com/banbox/banbot/config"
+	"github.com/banbox/banbot/core"
+	"github.com/banbox/banbot/orm/ormo"
+	"github.com/gofiber/fiber/v2"
+)
+
+/*
+metricsAuth
+Authorize /metrics by metrics_token in header "Authorization: Bearer", which is supported by prometheus scrape configs.
+The token can read all accounts. Other requests are left to AuthMiddleware.
+通过请求头"Authorization: Bearer"中的metrics_token验证/metrics，prometheus抓取配置支持此方式。
+此令牌可查看所有账户。其他请求交由AuthMiddleware验证
+*/
+func metricsAuth(token string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		tokenStr, ok := strings.CutPrefix(c.Get(fiber.HeaderAuthorization), "Bearer ")
+		if ok && token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(tokenStr)) == 1 {
+			auth := &AuthInfo{User: "metrics", Scopes: map[string]map[string]bool{"*": toSet([]string{ScopeRead})}}
+			c.Locals("user", auth.User)
+			c.Locals("auth", auth)
+		}
+		return c.Next()
+	}
+}
+
+/*
+getMetrics
+Prometheus metrics in text format: gauges are calculated on each scrape, counters and histograms are from core.WriteMetrics.
+Gauges of accounts without read scope are omitted.
+prometheus文本格式的指标：仪表在每次抓取时计算，计数器和直方图来自core.WriteMetrics。省略无查看权限账户的仪表
+*/
+func getMetrics(c *fiber.Ctx) error {
+	auth, _ := c.Locals("auth").(*AuthInfo)
+	var b bytes.Buffer
+	writeKlineLags(&b)
+	writeAccountMetrics(&b, auth)
+	core.WriteMetrics(&b)
+	c.Set(fiber.HeaderContentType, "text/plain; version=0.0.4; charset=utf-8")
+	return c.Send(b.Bytes())
+}
+
+// writeKlineLags seconds since the latest bar of each pair from core.PairCopiedMs 各品种距最新bar的秒数
+func writeKlineLags(w io.Writer) {
+	curMS := btime.UTCStamp()
+	core.PairCopiedLock.RLock()
+	lags := make(map[string]float64, len(core.PairCopiedMs))
+	for pair, wait := range core.PairCopiedMs {
+		lags[pair] = float64(curMS-wait[0]) / 1000
+	}
+	core.PairCopiedLock.RUnlock()
+	core.WriteMetricHead(w, core.MetricKlineLag, core.MetricGauge)
+	for _, pair := range sortedKeys(lags) {
+		core.WriteMetric(w, core.MetricKlineLag, lags[pair], "pair", pair)
+	}
+}
+
+// writeAccountMetrics open orders by strategy, wallet equity and unrealized pnl of each account 各账户按策略的未平仓订单数、钱包权益和未实现盈亏
+func writeAccountMetrics(w io.Writer, auth *AuthInfo) {
+	accs := make([]string, 0, len(config.Accounts))
+	for acc := range config.Accounts {
+		if auth != nil && auth.Allow(acc, ScopeRead) {
+			accs = append(accs, acc)
+		}
+	}
+	slices.Sort(accs)
+	core.WriteMetricHead(w, core.MetricOpenOrders, core.MetricGauge)
+	for _, acc := range accs {
+		openOds, lock := ormo.GetOpenODs(acc)
+		counts := make(map[string]float64)
+		lock.Lock()
+		for _, od := range openOds {
+			counts[od.Strategy] += 1
+		}
+		lock.Unlock()
+		for _, stgName := range sortedKeys(counts) {
+			core.WriteMetric(w, core.MetricOpenOrders, counts[stgName], "account", acc, "strategy", stgName)
+		}
+	}
+	core.WriteMetricHead(w, core.MetricWalletEquity, core.MetricGauge)
+	for _, acc := range accs {
+		core.WriteMetric(w, core.MetricWalletEquity, biz.GetWallets(acc).TotalLegal(nil, true), "account", acc)
+	}
+	core.WriteMetricHead(w, core.MetricUnrealizedPnl, core.MetricGauge)
+	for _, acc := range accs {
+		core.WriteMetric(w, core.MetricUnrealizedPnl, biz.GetWallets(acc).UnrealizedPOLLegal(nil), "account", acc)
+	}
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
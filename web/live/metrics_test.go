@@ -0,0 +1,75 @@
+package live
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/banbox/banbot/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestMetricsAuth(t *testing.T) {
+	app := fiber.New()
+	app.Get("/metrics", metricsAuth("secret"), AuthMiddleware("jwt"), permMiddleware(metricsRoute),
+		func(c *fiber.Ctx) error {
+			return c.SendString("ok")
+		})
+	cases := []struct {
+		header string
+		status int
+	}{
+		{"", fiber.StatusUnauthorized},
+		{"secret", fiber.StatusUnauthorized},
+		{"Bearer wrong", fiber.StatusUnauthorized},
+		{"Bearer secret", fiber.StatusOK},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(fiber.MethodGet, "/metrics", nil)
+		if c.header != "" {
+			req.Header.Set(fiber.HeaderAuthorization, c.header)
+		}
+		rsp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("request fail: %v", err)
+		}
+		if rsp.StatusCode != c.status {
+			t.Errorf("header %q: status %d, want %d", c.header, rsp.StatusCode, c.status)
+		}
+	}
+}
+
+func TestMetricsApiToken(t *testing.T) {
+	oldCfg := config.APIServer
+	config.APIServer = &config.APIServerConfig{Tokens: []*config.APITokenConfig{
+		{Name: "reader", Token: config.HashApiToken("read-token"), Scopes: []string{ScopeRead}},
+		{Name: "trader", Token: config.HashApiToken("trade-token"), Scopes: []string{ScopeTrade}},
+	}}
+	defer func() {
+		config.APIServer = oldCfg
+	}()
+	app := fiber.New()
+	app.Get("/metrics", metricsAuth(""), AuthMiddleware("jwt"), permMiddleware(metricsRoute),
+		func(c *fiber.Ctx) error {
+			return c.SendString("ok")
+		})
+	cases := []struct {
+		token  string
+		status int
+	}{
+		{"read-token", fiber.StatusOK},
+		{"trade-token", fiber.StatusForbidden},
+		{"bad-token", fiber.StatusUnauthorized},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(fiber.MethodGet, "/metrics", nil)
+		req.Header.Set("X-Api-Token", c.token)
+		req.Header.Set(fiber.HeaderAuthorization, "Bearer ")
+		rsp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("request fail: %v", err)
+		}
+		if rsp.StatusCode != c.status {
+			t.Errorf("token %s: status %d, want %d", c.token, rsp.StatusCode, c.status)
+		}
+	}
+}
@@ -0,0 +1,319 @@
+package live
+
+import (
+	"context"
+	"crypto/subtle"
+	"strings"
+
+	"github.com/banbox/banbot/btime"
+	"github.com/banbox/banbot/config"
+	"github.com/banbox/banbot/core"
+	"github.com/banbox/banbot/orm/ormo"
+	"github.com/banbox/banbot/web/base"
+	"github.com/banbox/banexg/errs"
+	"github.com/banbox/banexg/log"
+	"github.com/banbox/banexg/utils"
+	"github.com/gofiber/fiber/v2"
+	"go.uber.org/zap"
+)
+
+const (
+	RoleView  = "view"  // read only 只读
+	RoleTrade = "trade" // read and trade 查看和交易
+	RoleAdmin = "admin" // all permissions 所有权限
+
+	ScopeRead  = "read"  // view orders, wallets, statistics 查看订单、钱包、统计
+	ScopeTrade = "trade" // open/exit orders, refresh wallets, delay entry 开平仓、刷新钱包、延迟入场
+	ScopeAdmin = "admin" // view config, logs, audits, download trades 查看配置、日志、审计、下载交易
+)
+
+// auditMaxBody request body longer than this is truncated in audit log 超过此长度的请求体在审计日志中被截断
+const auditMaxBody = 4096
+
+var roleScopes = map[string][]string{
+	RoleView:  {ScopeRead},
+	RoleTrade: {ScopeRead, ScopeTrade},
+	RoleAdmin: {ScopeRead, ScopeTrade, ScopeAdmin},
+}
+
+/*
+ApiRoute
+Route of /api/bot with the scope required on the account of header X-Account
+/api/bot下的路由，及在请求头X-Account账户上需要的权限范围
+*/
+type ApiRoute struct {
+	Method  string
+	Path    string
+	Scope   string
+	Handler fiber.Handler
+}
+
+// bizRoutes permission matrix of /api/bot, enforced by permMiddleware /api/bot的权限矩阵，由permMiddleware统一校验
+var bizRoutes = []*ApiRoute{
+	{fiber.MethodGet, "/version", ScopeRead, getVersion},
+	{fiber.MethodGet, "/balance", ScopeRead, getBalance},
+	{fiber.MethodPost, "/refresh_wallet", ScopeTrade, postRefreshWallet},
+	{fiber.MethodGet, "/today_num", ScopeRead, getTodayNum},
+	{fiber.MethodGet, "/statistics", ScopeRead, getStatistics},
+	{fiber.MethodGet, "/incomes", ScopeRead, getIncomes},
+	{fiber.MethodGet, "/task_pairs", ScopeRead, getTaskPairs},
+	{fiber.MethodGet, "/exs_map", ScopeRead, getExsMap},
+	{fiber.MethodGet, "/orders", ScopeRead, getOrders},
+	{fiber.MethodPost, "/calc_profits", ScopeRead, postCalcProfits},
+	{fiber.MethodPost, "/exit_order", ScopeTrade, postExitOrder},
+	{fiber.MethodPost, "/open_order", ScopeTrade, postOpenOrder},
+	{fiber.MethodPost, "/edit_triggers", ScopeTrade, postEditTriggers},
+	{fiber.MethodPost, "/adjust_position", ScopeTrade, postAdjustPosition},
+	{fiber.MethodGet, "/signals", ScopeRead, getSignals},
+	{fiber.MethodPost, "/close_exg_pos", ScopeTrade, postCloseExgPos},
+	{fiber.MethodPost, "/delay_entry", ScopeTrade, postDelayEntry},
+	{fiber.MethodPost, "/shutdown", ScopeAdmin, postShutdown},
+	{fiber.MethodGet, "/config", ScopeAdmin, getConfig},
+	{fiber.MethodGet, "/stg_jobs", ScopeRead, getStratJobs},
+	{fiber.MethodGet, "/performance", ScopeRead, getPerformance},
+	{fiber.MethodPost, "/start_down_trade", ScopeAdmin, postStartDownTrade},
+	{fiber.MethodGet, "/get_down_trade", ScopeRead, getDownTrade},
+	{fiber.MethodGet, "/group_sta", ScopeRead, getGroupSta},
+	{fiber.MethodPost, "/journal", ScopeTrade, postJournal},
+	{fiber.MethodGet, "/journal_snapshot", ScopeRead, getJournalSnapshot},
+	{fiber.MethodGet, "/log", ScopeAdmin, getLog},
+	{fiber.MethodGet, "/bot_info", ScopeRead, getBotInfo},
+	{fiber.MethodGet, "/audits", ScopeAdmin, getAudits},
+	{fiber.MethodGet, "/auth_info", ScopeRead, getAuthInfo},
+}
+
+// routes outside /api/bot, also enforced by permMiddleware /api/bot之外的路由，同样由permMiddleware校验
+var (
+	klineRoute   = &ApiRoute{Path: "/api/kline", Scope: ScopeRead}
+	eventsRoute  = &ApiRoute{Method: fiber.MethodGet, Path: "/api/events", Scope: ScopeRead}
+	metricsRoute = &ApiRoute{Method: fiber.MethodGet, Path: "/metrics", Scope: ScopeRead}
+)
+
+/*
+AuthInfo
+Scopes of the login user or api token on each account, "*" means all accounts
+登录用户或API令牌在每个账户上的权限范围，"*"表示所有账户
+*/
+type AuthInfo struct {
+	User   string                     `json:"user"`
+	Scopes map[string]map[string]bool `json:"scopes"`
+}
+
+/*
+newUserAuth
+Scopes of login user from acc_roles. The role is view when empty, and unknown roles have no scope.
+A user without acc_roles can only read all accounts.
+根据acc_roles计算登录用户的权限，角色为空时为view，未知角色无权限。未配置acc_roles的用户只能查看所有账户
+*/
+func newUserAuth(u *config.UserConfig) *AuthInfo {
+	res := &AuthInfo{User: u.Username, Scopes: make(map[string]map[string]bool)}
+	if len(u.AccRoles) == 0 {
+		res.Scopes["*"] = toSet(roleScopes[RoleView])
+		return res
+	}
+	for acc, role := range u.AccRoles {
+		role = strings.ToLower(strings.TrimSpace(role))
+		if role == "" {
+			role = RoleView
+		}
+		scopes, ok := roleScopes[role]
+		if !ok {
+			log.Warn("unknown role, no permission granted", zap.String("user", u.Username),
+				zap.String("acc", acc), zap.String("role", role))
+		}
+		res.Scopes[acc] = toSet(scopes)
+	}
+	return res
+}
+
+// newTokenAuth scopes of api token, all accounts when accounts is empty API令牌的权限，accounts为空时为所有账户
+func newTokenAuth(t *config.APITokenConfig) *AuthInfo {
+	res := &AuthInfo{User: "token:" + t.Name, Scopes: make(map[string]map[string]bool)}
+	scopes := toSet(t.Scopes)
+	if len(t.Accounts) == 0 {
+		res.Scopes["*"] = scopes
+	}
+	for _, acc := range t.Accounts {
+		res.Scopes[acc] = scopes
+	}
+	return res
+}
+
+/*
+Allow
+Check whether the scope is granted on the account. When acc is empty, it's allowed if granted on any account.
+检查账户上是否有此权限。acc为空时，任一账户有此权限即允许
+*/
+func (a *AuthInfo) Allow(acc, scope string) bool {
+	if a.Scopes["*"][scope] {
+		return true
+	}
+	if acc != "" {
+		return a.Scopes[acc][scope]
+	}
+	for _, scopes := range a.Scopes {
+		if scopes[scope] {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+verifyApiToken
+Check api token from config and client ip, set `user`, `accounts` and `auth` to locals
+校验配置中的API令牌和客户端ip，设置user、accounts和auth到locals
+*/
+func verifyApiToken(c *fiber.Ctx, tokenStr string) error {
+	cfg := config.APIServer
+	if cfg == nil {
+		return fiber.NewError(fiber.StatusUnauthorized, "invalid token")
+	}
+	// tokens are stored as hashes, compare hashes with the same length in constant time
+	// 令牌以哈希保存，以恒定时间比较等长的哈希
+	tokenHash := []byte(config.HashApiToken(tokenStr))
+	for _, t := range cfg.Tokens {
+		if t.Token == "" || subtle.ConstantTimeCompare([]byte(t.Token), tokenHash) != 1 {
+			continue
+		}
+		clientIP := c.IP()
+		if len(t.AllowIPs) > 0 && !utils.ArrContains(t.AllowIPs, clientIP) {
+			return fiber.NewError(fiber.StatusUnauthorized, "unauthorized from ip: "+clientIP)
+		}
+		if t.ExpireAt != "" {
+			expMS, err_ := btime.ParseTimeMS(t.ExpireAt)
+			if err_ != nil || expMS <= btime.UTCStamp() {
+				return fiber.NewError(fiber.StatusUnauthorized, "token expired")
+			}
+		}
+		// accounts is nil for all accounts, which is the same as users without acc_roles 为nil表示所有账户
+		var accRoles map[string]string
+		if len(t.Accounts) > 0 {
+			accRoles = make(map[string]string)
+			for _, acc := range t.Accounts {
+				accRoles[acc] = strings.Join(t.Scopes, ",")
+			}
+		}
+		auth := newTokenAuth(t)
+		c.Locals("user", auth.User)
+		c.Locals("accounts", accRoles)
+		c.Locals("auth", auth)
+		return nil
+	}
+	return fiber.NewError(fiber.StatusUnauthorized, "invalid token")
+}
+
+/*
+permMiddleware
+Reject requests without the scope of route on account X-Account, and save mutating calls to api_audit
+拒绝在X-Account账户上没有路由所需权限的请求，并将修改类请求记录到api_audit
+*/
+func permMiddleware(r *ApiRoute) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		auth, _ := c.Locals("auth").(*AuthInfo)
+		if auth == nil {
+			return fiber.NewError(fiber.StatusUnauthorized, "unknown user")
+		}
+		acc := c.Get("X-Account")
+		if !auth.Allow(acc, r.Scope) {
+			// denied calls are always audited 拒绝的调用始终记录审计
+			err := c.App().Config().ErrorHandler(c, fiber.NewError(fiber.StatusForbidden,
+				"permission denied, require scope: "+r.Scope))
+			saveAudit(c, auth.User, acc)
+			return err
+		}
+		if r.Scope == ScopeRead || r.Method == fiber.MethodGet {
+			return c.Next()
+		}
+		err := c.Next()
+		if err != nil {
+			// write error response now to record the status code 立即写入错误响应以记录状态码
+			err = c.App().Config().ErrorHandler(c, err)
+		}
+		saveAudit(c, auth.User, acc)
+		return err
+	}
+}
+
+func saveAudit(c *fiber.Ctx, user, acc string) {
+	body := string(c.Body())
+	if len(body) > auditMaxBody {
+		body = body[:auditMaxBody] + "..."
+	}
+	sess, conn, err := ormo.AuditConn()
+	if err != nil {
+		log.Error("save api audit fail", zap.String("path", c.Path()), zap.Error(err))
+		return
+	}
+	defer conn.Close()
+	err_ := sess.AddApiAudit(context.Background(), ormo.AddApiAuditParams{
+		User:     user,
+		Ip:       c.IP(),
+		Account:  acc,
+		Method:   c.Method(),
+		Path:     c.Path(),
+		Payload:  body,
+		Status:   int64(c.Response().StatusCode()),
+		CreateAt: btime.UTCStamp(),
+	})
+	if err_ != nil {
+		log.Error("save api audit fail", zap.String("path", c.Path()), zap.Error(err_))
+	}
+}
+
+/*
+allowAccRow
+Whether a row of the account can be returned to the caller with the scope.
+Rows without account require the scope on all accounts.
+是否可将此账户的记录返回给具有该权限的调用者。无账户的记录需要在所有账户上具有该权限
+*/
+func allowAccRow(auth *AuthInfo, acc, scope string) bool {
+	if auth == nil {
+		return false
+	} else if acc == "" {
+		return auth.Scopes["*"][scope]
+	}
+	return auth.Allow(acc, scope)
+}
+
+// getAudits recent mutating and denied api calls of accounts with admin scope 有管理权限账户的最近修改类和被拒绝的api调用
+func getAudits(c *fiber.Ctx) error {
+	type AuditArgs struct {
+		StartMS int64 `query:"startMs"`
+		Limit   int64 `query:"limit"`
+	}
+	var data = new(AuditArgs)
+	if err := base.VerifyArg(c, data, base.ArgQuery); err != nil {
+		return err
+	}
+	if data.Limit <= 0 {
+		data.Limit = 100
+	}
+	sess, conn, err := ormo.AuditConn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	items, err_ := sess.ListApiAudits(context.Background(), ormo.ListApiAuditsParams{
+		CreateAt: data.StartMS,
+		Limit:    data.Limit,
+	})
+	if err_ != nil {
+		return errs.New(core.ErrDbReadFail, err_)
+	}
+	auth, _ := c.Locals("auth").(*AuthInfo)
+	res := make([]*ormo.ApiAudit, 0, len(items))
+	for _, it := range items {
+		if allowAccRow(auth, it.Account, ScopeAdmin) {
+			res = append(res, it)
+		}
+	}
+	return c.JSON(fiber.Map{
+		"data": res,
+	})
+}
+
+// getAuthInfo scopes of current user or token, for UI to hide unavailable actions 当前用户或令牌的权限，供UI隐藏不可用操作
+func getAuthInfo(c *fiber.Ctx) error {
+	return c.JSON(c.Locals("auth"))
+}
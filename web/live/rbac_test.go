@@ -0,0 +1,152 @@
+package live
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/banbox/banbot/config"
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestAuthInfoAllow(t *testing.T) {
+	auth := &AuthInfo{Scopes: map[string]map[string]bool{
+		"acc1": toSet(roleScopes[RoleTrade]),
+		"acc2": toSet(roleScopes[RoleView]),
+	}}
+	cases := []struct {
+		acc   string
+		scope string
+		ok    bool
+	}{
+		{"acc1", ScopeTrade, true},
+		{"acc1", ScopeAdmin, false},
+		{"acc2", ScopeRead, true},
+		{"acc2", ScopeTrade, false},
+		{"acc3", ScopeRead, false},
+		// empty account: granted on any account 空账户：任一账户有权限即可
+		{"", ScopeTrade, true},
+		{"", ScopeAdmin, false},
+	}
+	for _, c := range cases {
+		if got := auth.Allow(c.acc, c.scope); got != c.ok {
+			t.Errorf("Allow(%q, %s) = %v, want %v", c.acc, c.scope, got, c.ok)
+		}
+	}
+	all := &AuthInfo{Scopes: map[string]map[string]bool{"*": toSet([]string{ScopeRead})}}
+	if !all.Allow("any", ScopeRead) || all.Allow("any", ScopeTrade) {
+		t.Errorf("scopes of * should apply to all accounts")
+	}
+}
+
+func TestNewUserAuth(t *testing.T) {
+	auth := newUserAuth(&config.UserConfig{Username: "ban"})
+	if !auth.Allow("acc1", ScopeRead) || auth.Allow("acc1", ScopeTrade) {
+		t.Errorf("user without acc_roles should only read all accounts, got %v", auth.Scopes)
+	}
+	auth = newUserAuth(&config.UserConfig{Username: "ban", AccRoles: map[string]string{
+		"acc1": " Admin ",
+		"acc2": "",
+		"acc3": "owner",
+	}})
+	cases := []struct {
+		acc   string
+		scope string
+		ok    bool
+	}{
+		{"acc1", ScopeAdmin, true},
+		{"acc2", ScopeRead, true},
+		{"acc2", ScopeTrade, false},
+		{"acc3", ScopeRead, false},
+		{"acc4", ScopeRead, false},
+	}
+	for _, c := range cases {
+		if got := auth.Allow(c.acc, c.scope); got != c.ok {
+			t.Errorf("Allow(%s, %s) = %v, want %v", c.acc, c.scope, got, c.ok)
+		}
+	}
+}
+
+func TestVerifyApiToken(t *testing.T) {
+	oldCfg := config.APIServer
+	config.APIServer = &config.APIServerConfig{Tokens: []*config.APITokenConfig{
+		{Name: "reader", Token: config.HashApiToken("read-token"), Scopes: []string{ScopeRead}},
+		{Name: "trader", Token: config.HashApiToken("trade-token"), Scopes: []string{ScopeTrade}, Accounts: []string{"acc1"}},
+		{Name: "ip", Token: config.HashApiToken("ip-token"), Scopes: []string{ScopeRead}, AllowIPs: []string{"10.1.1.1"}},
+		{Name: "expired", Token: config.HashApiToken("old-token"), Scopes: []string{ScopeRead}, ExpireAt: "2000-01-01"},
+	}}
+	defer func() {
+		config.APIServer = oldCfg
+	}()
+	app := fiber.New()
+	app.Get("/check", func(c *fiber.Ctx) error {
+		if err := verifyApiToken(c, c.Get("X-Api-Token")); err != nil {
+			return err
+		}
+		auth := c.Locals("auth").(*AuthInfo)
+		acc := c.Get("X-Account")
+		if !auth.Allow(acc, c.Query("scope")) {
+			return fiber.ErrForbidden
+		}
+		return c.SendString(auth.User)
+	})
+	cases := []struct {
+		name   string
+		token  string
+		acc    string
+		scope  string
+		status int
+	}{
+		{"read all", "read-token", "acc2", ScopeRead, fiber.StatusOK},
+		{"read can't trade", "read-token", "acc2", ScopeTrade, fiber.StatusForbidden},
+		{"trade own account", "trade-token", "acc1", ScopeTrade, fiber.StatusOK},
+		{"trade other account", "trade-token", "acc2", ScopeTrade, fiber.StatusForbidden},
+		{"wrong token", "bad-token", "acc1", ScopeRead, fiber.StatusUnauthorized},
+		// the stored hash itself is not a valid token 保存的哈希本身不是有效令牌
+		{"hash as token", config.HashApiToken("read-token"), "acc1", ScopeRead, fiber.StatusUnauthorized},
+		{"ip not allowed", "ip-token", "acc1", ScopeRead, fiber.StatusUnauthorized},
+		{"expired", "old-token", "acc1", ScopeRead, fiber.StatusUnauthorized},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(fiber.MethodGet, "/check?scope="+c.scope, nil)
+		req.Header.Set("X-Api-Token", c.token)
+		req.Header.Set("X-Account", c.acc)
+		rsp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("%s: request fail: %v", c.name, err)
+		}
+		if rsp.StatusCode != c.status {
+			t.Errorf("%s: status %d, want %d", c.name, rsp.StatusCode, c.status)
+		}
+	}
+}
+
+func TestAllowAccRow(t *testing.T) {
+	auth := &AuthInfo{Scopes: map[string]map[string]bool{
+		"acc1": toSet(roleScopes[RoleAdmin]),
+		"acc2": toSet(roleScopes[RoleView]),
+	}}
+	cases := []struct {
+		acc   string
+		scope string
+		ok    bool
+	}{
+		{"acc1", ScopeAdmin, true},
+		{"acc2", ScopeRead, true},
+		{"acc2", ScopeAdmin, false},
+		{"acc3", ScopeRead, false},
+		// rows without account need the scope on all accounts 无账户的记录需要所有账户的权限
+		{"", ScopeRead, false},
+	}
+	for _, c := range cases {
+		if got := allowAccRow(auth, c.acc, c.scope); got != c.ok {
+			t.Errorf("allowAccRow(%q, %s) = %v, want %v", c.acc, c.scope, got, c.ok)
+		}
+	}
+	all := &AuthInfo{Scopes: map[string]map[string]bool{"*": toSet([]string{ScopeRead})}}
+	if !allowAccRow(all, "", ScopeRead) || !allowAccRow(all, "acc3", ScopeRead) {
+		t.Errorf("scopes of * should allow rows of all accounts")
+	}
+	if allowAccRow(nil, "acc1", ScopeRead) {
+		t.Errorf("rows should be hidden without auth")
+	}
+}
@@ -0,0 +1,375 @@
+package live
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/banbox/banbot/biz"
+	"github.com/banbox/banbot/btime"
+	"github.com/banbox/banbot/config"
+	"github.com/banbox/banbot/core"
+	"github.com/banbox/banbot/orm/ormo"
+	"github.com/banbox/banbot/strat"
+	"github.com/banbox/banbot/web/base"
+	"github.com/banbox/banexg/errs"
+	"github.com/banbox/banexg/log"
+	"github.com/banbox/banexg/utils"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sasha-s/go-deadlock"
+	"go.uber.org/zap"
+)
+
+var (
+	signalNonces = make(map[string]int64) // nonce: expire timestamp(13 digits) 随机数：过期时间戳
+	lockNonces   deadlock.Mutex
+	rejectSeq    atomic.Int64 // sequence of rejected signals in signal_log 被拒绝信号在signal_log中的序号
+)
+
+/*
+SignalReq
+Typed schema of signal webhook, mapped to EnterReq/ExitReq without custom strategy code
+信号webhook的类型化结构，无需策略代码即可映射为EnterReq/ExitReq
+*/
+type SignalReq struct {
+	ID         string  `json:"id"`                                          // idempotency key, nonce is used if empty 幂等键，为空时使用nonce
+	Nonce      string  `json:"nonce" validate:"required"`                   // unique for each request 每次请求唯一
+	Timestamp  int64   `json:"timestamp" validate:"required"`               // 10 or 13 digits 10位或13位时间戳
+	Account    string  `json:"account"`                                     // can be empty when only one account 只有一个账户时可为空
+	Strategy   string  `json:"strategy"`                                    // enter: job of strategy, exit: only orders of strategy 入场：策略任务，退出：仅该策略订单
+	Pair       string  `json:"pair" validate:"required"`                    // symbol like BTC/USDT:USDT
+	Side       string  `json:"side" validate:"required,oneof=long short"`   // long/short
+	Action     string  `json:"action" validate:"required,oneof=enter exit"` // enter/exit
+	Size       float64 `json:"size"`                                        // amount of pair, enter: stake amount if 0, exit: all if 0 标的数量，入场为0时按开单金额，退出为0时全部
+	Price      float64 `json:"price"`                                       // limit price, market order if 0 限价，为0时市价
+	StopLoss   float64 `json:"sl"`
+	TakeProfit float64 `json:"tp"`
+	Tag        string  `json:"tag"`
+}
+
+/*
+VerifySignature
+Check hex encoded HMAC-SHA256 of body with secret, a prefix "sha256=" is allowed
+校验body的HMAC-SHA256十六进制签名，允许"sha256="前缀
+*/
+func VerifySignature(secret string, body []byte, signature string) bool {
+	signature = strings.TrimPrefix(strings.TrimSpace(signature), "sha256=")
+	sig, err := hex.DecodeString(signature)
+	if err != nil || len(sig) == 0 {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(sig, mac.Sum(nil))
+}
+
+// signalWindowMS max milliseconds between the signal timestamp and server time 信号时间戳与服务器时间的最大毫秒差
+func signalWindowMS() int64 {
+	window := int64(config.APIServer.SignalWindow)
+	if window <= 0 {
+		window = 300
+	}
+	return window * 1000
+}
+
+/*
+checkSignalNonce
+Reject signals out of the timestamp window or with a used nonce in the window
+拒绝时间戳超出窗口或在窗口内重复使用随机数的信号
+*/
+func checkSignalNonce(nonce string, stampMS, curMS int64) error {
+	windowMS := signalWindowMS()
+	if stampMS < curMS-windowMS || stampMS > curMS+windowMS {
+		return fiber.NewError(fiber.StatusUnauthorized, "timestamp out of window")
+	}
+	lockNonces.Lock()
+	defer lockNonces.Unlock()
+	for key, expMS := range signalNonces {
+		if expMS < curMS {
+			delete(signalNonces, key)
+		}
+	}
+	if _, ok := signalNonces[nonce]; ok {
+		return fiber.NewError(fiber.StatusConflict, "nonce already used")
+	}
+	signalNonces[nonce] = stampMS + windowMS
+	return nil
+}
+
+/*
+checkSignalNonceDb
+Reject nonces recorded in signal_log, the in-memory nonces are lost after restart. A replayed signal is within the window
+of its timestamp, so the original was received after curMS - 2 * window.
+拒绝signal_log中已记录的随机数，内存中的随机数在重启后丢失。重放信号在其时间戳窗口内，所以原信号接收时间晚于curMS - 2 * 窗口
+*/
+func checkSignalNonceDb(sess *ormo.Queries, nonce string, curMS int64) error {
+	num, err_ := sess.CountSignalNonce(context.Background(), ormo.CountSignalNonceParams{
+		Nonce:    nonce,
+		CreateAt: curMS - signalWindowMS()*2,
+		Status:   ormo.SignalReject,
+	})
+	if err_ != nil {
+		return errs.New(core.ErrDbReadFail, err_)
+	} else if num > 0 {
+		return fiber.NewError(fiber.StatusConflict, "nonce already used")
+	}
+	return nil
+}
+
+/*
+postSignal
+Signal webhook authorized by HMAC-SHA256 of the raw body in header X-Signature. Signals are recorded in signal_log,
+a signal with a processed idempotency key returns the recorded outcome without trading again.
+信号webhook，通过请求头X-Signature中原始body的HMAC-SHA256签名验证。信号记录到signal_log，已处理的幂等键直接返回记录的结果而不再交易
+*/
+func postSignal(c *fiber.Ctx) error {
+	cfg := config.APIServer
+	if cfg == nil || cfg.SignalSecret == "" {
+		return fiber.NewError(fiber.StatusNotFound, "signal webhook disabled")
+	}
+	body := c.Body()
+	curMS := btime.UTCStamp()
+	if !VerifySignature(cfg.SignalSecret, body, c.Get("X-Signature")) {
+		return rejectSignal(c, nil, curMS, fiber.NewError(fiber.StatusUnauthorized, "invalid signature"))
+	}
+	var req = new(SignalReq)
+	if err := base.VerifyArg(c, req, base.ArgBody); err != nil {
+		return err
+	}
+	stampMS := req.Timestamp
+	if stampMS < 1e12 {
+		stampMS *= 1000
+	}
+	if err := checkSignalNonce(req.Nonce, stampMS, curMS); err != nil {
+		return rejectSignal(c, req, curMS, err)
+	}
+	if req.ID == "" {
+		req.ID = req.Nonce
+	}
+	if req.Account == "" && len(config.Accounts) == 1 {
+		for acc := range config.Accounts {
+			req.Account = acc
+		}
+	}
+	if _, ok := config.Accounts[req.Account]; !ok && core.EnvReal {
+		return fiber.NewError(fiber.StatusBadRequest, "account not found: "+req.Account)
+	}
+	sess, conn, err := ormo.SignalConn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err := checkSignalNonceDb(sess, req.Nonce, curMS); err != nil {
+		return rejectSignal(c, req, curMS, err)
+	}
+	ctx := context.Background()
+	old, err_ := sess.GetSignal(ctx, req.ID)
+	if err_ == nil {
+		return c.JSON(fiber.Map{"duplicate": true, "signal": old})
+	} else if !errors.Is(err_, sql.ErrNoRows) {
+		return errs.New(core.ErrDbReadFail, err_)
+	}
+	sigId, err_ := sess.AddSignal(ctx, ormo.AddSignalParams{
+		IdemKey:  req.ID,
+		Nonce:    req.Nonce,
+		Account:  req.Account,
+		Strategy: req.Strategy,
+		Symbol:   req.Pair,
+		Action:   req.Action,
+		Side:     req.Side,
+		Size:     req.Size,
+		Payload:  string(body),
+		Ip:       c.IP(),
+		Status:   ormo.SignalPending,
+		CreateAt: curMS,
+		UpdateAt: curMS,
+	})
+	if err_ != nil {
+		// the same key may be inserted by a concurrent request 相同的键可能被并发请求插入
+		old, err2 := sess.GetSignal(ctx, req.ID)
+		if err2 == nil {
+			return c.JSON(fiber.Map{"duplicate": true, "signal": old})
+		}
+		return errs.New(core.ErrDbExecFail, err_)
+	}
+	odList, err := execSignal(req)
+	status, msg := ormo.SignalDone, ""
+	if err != nil {
+		status, msg = ormo.SignalFail, err.Short()
+		log.Warn("exec signal fail", zap.String("id", req.ID), zap.String("pair", req.Pair), zap.String("err", msg))
+	}
+	odIds := make([]string, 0, len(odList))
+	for _, od := range odList {
+		odIds = append(odIds, strconv.FormatInt(od.ID, 10))
+	}
+	err_ = sess.SetSignalResult(ctx, ormo.SetSignalResultParams{
+		Status:   status,
+		Message:  msg,
+		OdIds:    strings.Join(odIds, ","),
+		UpdateAt: btime.UTCStamp(),
+		ID:       sigId,
+	})
+	if err_ != nil {
+		log.Error("save signal result fail", zap.String("id", req.ID), zap.Error(err_))
+	}
+	return c.JSON(fiber.Map{
+		"id":     sigId,
+		"status": status,
+		"msg":    msg,
+		"orders": odIds,
+	})
+}
+
+/*
+rejectSignal
+Count the rejected signal and return err. A nil req means a bad signature, the unsigned body is only logged by a
+truncated hash so that anyone can't grow the database; signed ones are recorded in signal_log with a unique key,
+so the idempotency key of the real signal is not occupied.
+统计被拒绝的信号并返回err。req为nil表示签名错误，未签名的body只记录截断的哈希到日志，避免任何人都可增大数据库；
+已签名的记录到signal_log，每条记录使用唯一键，不占用真实信号的幂等键
+*/
+func rejectSignal(c *fiber.Ctx, req *SignalReq, curMS int64, err error) error {
+	if req == nil {
+		core.AddMetric(core.MetricSignalRejects, 1, "reason", "signature")
+		sum := sha256.Sum256(c.Body())
+		log.Warn("signal rejected", zap.String("ip", c.IP()), zap.String("hash", hex.EncodeToString(sum[:8])),
+			zap.Int("len", len(c.Body())), zap.Error(err))
+		return err
+	}
+	core.AddMetric(core.MetricSignalRejects, 1, "reason", "nonce")
+	log.Warn("signal rejected", zap.String("ip", c.IP()), zap.String("nonce", req.Nonce), zap.Error(err))
+	sess, conn, err2 := ormo.SignalConn()
+	if err2 != nil {
+		log.Error("save rejected signal fail", zap.Error(err2))
+		return err
+	}
+	defer conn.Close()
+	_, err_ := sess.AddSignal(context.Background(), ormo.AddSignalParams{
+		IdemKey:  fmt.Sprintf("rejected_%d_%d", curMS, rejectSeq.Add(1)),
+		Nonce:    req.Nonce,
+		Account:  req.Account,
+		Strategy: req.Strategy,
+		Symbol:   req.Pair,
+		Action:   req.Action,
+		Side:     req.Side,
+		Size:     req.Size,
+		Payload:  string(c.Body()),
+		Ip:       c.IP(),
+		Status:   ormo.SignalReject,
+		Message:  err.Error(),
+		CreateAt: curMS,
+		UpdateAt: curMS,
+	})
+	if err_ != nil {
+		log.Error("save rejected signal fail", zap.Error(err_))
+	}
+	return err
+}
+
+// execSignal map the signal to EnterReq/ExitReq and execute by the order manager 将信号映射为EnterReq/ExitReq并由订单管理器执行
+func execSignal(req *SignalReq) ([]*ormo.InOutOrder, *errs.Error) {
+	short := req.Side == "short"
+	if req.Action == "exit" {
+		exitReq := &strat.ExitReq{
+			Tag:       req.Tag,
+			StratName: req.Strategy,
+			Dirt:      core.OdDirtLong,
+			Amount:    req.Size,
+			Force:     true,
+		}
+		if exitReq.Tag == "" {
+			exitReq.Tag = core.ExitTagUserExit
+		}
+		if short {
+			exitReq.Dirt = core.OdDirtShort
+		}
+		if req.Price > 0 {
+			exitReq.OrderType = core.OrderTypeLimit
+			exitReq.Limit = req.Price
+		}
+		return biz.ExitAccOrders(req.Account, req.Pair, exitReq)
+	}
+	job := findPairJob(req.Account, req.Pair, req.Strategy)
+	if job == nil {
+		return nil, errs.NewMsg(errs.CodeParamInvalid, "no job running for %s", req.Pair)
+	}
+	price := req.Price
+	if price == 0 {
+		price = core.GetPriceSafe(job.Symbol.VenueKey())
+	}
+	if err := checkTriggers(short, price, req.StopLoss, req.TakeProfit); err != nil {
+		return nil, errs.NewMsg(errs.CodeParamInvalid, "%s", err.Error())
+	}
+	entReq := &strat.EnterReq{
+		Tag:        req.Tag,
+		StratName:  job.Strat.Name,
+		Short:      short,
+		OrderType:  core.OrderTypeMarket,
+		Amount:     req.Size,
+		StopLoss:   req.StopLoss,
+		TakeProfit: req.TakeProfit,
+	}
+	if entReq.Tag == "" {
+		entReq.Tag = core.EnterTagThird
+	}
+	if req.Price > 0 {
+		entReq.OrderType = core.OrderTypeLimit
+		entReq.Limit = req.Price
+	}
+	if entReq.Amount == 0 {
+		rate, err := core.GetLegalRate(config.ReportCurrency)
+		if err != nil {
+			return nil, err
+		}
+		entReq.LegalCost = job.Strat.GetStakeAmount(job) * rate
+	}
+	od, err := biz.EnterAccOrder(req.Account, job.Symbol, job.TimeFrame, entReq)
+	if err != nil {
+		return nil, err
+	}
+	return []*ormo.InOutOrder{od}, nil
+}
+
+// getSignals recent signals and their outcomes of accounts with read scope 有查看权限账户的最近信号及其结果
+func getSignals(c *fiber.Ctx) error {
+	type SignalArgs struct {
+		StartMS int64 `query:"startMs"`
+		Limit   int64 `query:"limit"`
+	}
+	var data = new(SignalArgs)
+	if err := base.VerifyArg(c, data, base.ArgQuery); err != nil {
+		return err
+	}
+	if data.Limit <= 0 {
+		data.Limit = 100
+	}
+	sess, conn, err := ormo.SignalConn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	items, err_ := sess.ListSignals(context.Background(), ormo.ListSignalsParams{
+		CreateAt: data.StartMS,
+		Limit:    data.Limit,
+	})
+	if err_ != nil {
+		return errs.New(core.ErrDbReadFail, err_)
+	}
+	auth, _ := c.Locals("auth").(*AuthInfo)
+	res := make([]*ormo.SignalLog, 0, len(items))
+	for _, it := range items {
+		if allowAccRow(auth, it.Account, ScopeRead) {
+			res = append(res, it)
+		}
+	}
+	return c.JSON(fiber.Map{
+		"data": res,
+	})
+}
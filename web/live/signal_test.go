@@ -0,0 +1,123 @@
+package live
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"testing"
+
+	"github.com/banbox/banbot/config"
+	"github.com/banbox/banbot/orm/ormo"
+)
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte(`{"nonce":"a1","pair":"BTC/USDT:USDT"}`)
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write(body)
+	sig := hex.EncodeToString(mac.Sum(nil))
+	cases := []struct {
+		name   string
+		secret string
+		body   []byte
+		sig    string
+		ok     bool
+	}{
+		{"valid", "secret", body, sig, true},
+		{"prefix", "secret", body, "sha256=" + sig, true},
+		{"wrong secret", "other", body, sig, false},
+		{"tampered body", "secret", []byte(`{"nonce":"a1","pair":"ETH/USDT:USDT"}`), sig, false},
+		{"not hex", "secret", body, "xyz", false},
+		{"empty", "secret", body, "", false},
+	}
+	for _, c := range cases {
+		if got := VerifySignature(c.secret, c.body, c.sig); got != c.ok {
+			t.Errorf("%s: got %v, want %v", c.name, got, c.ok)
+		}
+	}
+}
+
+func TestCheckSignalNonce(t *testing.T) {
+	oldCfg := config.APIServer
+	config.APIServer = &config.APIServerConfig{SignalWindow: 60}
+	lockNonces.Lock()
+	signalNonces = make(map[string]int64)
+	lockNonces.Unlock()
+	defer func() {
+		config.APIServer = oldCfg
+	}()
+	curMS := int64(1735689600000)
+	cases := []struct {
+		name    string
+		nonce   string
+		stampMS int64
+		curMS   int64
+		ok      bool
+	}{
+		{"valid", "n1", curMS, curMS, true},
+		{"replay", "n1", curMS, curMS + 1000, false},
+		{"stale", "n2", curMS - 61000, curMS, false},
+		{"future", "n3", curMS + 61000, curMS, false},
+		{"edge of window", "n4", curMS - 60000, curMS, true},
+		// nonce can be reused after the window expires 窗口过期后随机数可重用
+		{"reuse after expire", "n1", curMS + 70000, curMS + 70000, true},
+	}
+	for _, c := range cases {
+		err := checkSignalNonce(c.nonce, c.stampMS, c.curMS)
+		if (err == nil) != c.ok {
+			t.Errorf("%s: got err %v, want ok %v", c.name, err, c.ok)
+		}
+	}
+}
+
+func TestCheckSignalNonceDb(t *testing.T) {
+	oldCfg := config.APIServer
+	config.APIServer = &config.APIServerConfig{SignalWindow: 60}
+	defer func() {
+		config.APIServer = oldCfg
+	}()
+	db, err_ := sql.Open("sqlite", ":memory:")
+	if err_ != nil {
+		t.Fatal(err_)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+	_, err_ = db.Exec(`CREATE TABLE signal_log (id INTEGER PRIMARY KEY AUTOINCREMENT, idem_key TEXT NOT NULL,
+		nonce TEXT NOT NULL, account TEXT NOT NULL, strategy TEXT NOT NULL, symbol TEXT NOT NULL, action TEXT NOT NULL,
+		side TEXT NOT NULL, size REAL NOT NULL, payload TEXT NOT NULL, ip TEXT NOT NULL, status TEXT NOT NULL,
+		message TEXT NOT NULL, od_ids TEXT NOT NULL, create_at INTEGER NOT NULL, update_at INTEGER NOT NULL)`)
+	if err_ != nil {
+		t.Fatal(err_)
+	}
+	sess := ormo.New(db)
+	curMS := int64(1735689600000)
+	for _, it := range []struct {
+		key, nonce, status string
+	}{{"a", "used", ormo.SignalDone}, {"rejected_1", "rejected", ormo.SignalReject}} {
+		_, err_ = sess.AddSignal(context.Background(), ormo.AddSignalParams{
+			IdemKey: it.key, Nonce: it.nonce, Status: it.status, CreateAt: curMS, UpdateAt: curMS})
+		if err_ != nil {
+			t.Fatal(err_)
+		}
+	}
+	cases := []struct {
+		name  string
+		nonce string
+		curMS int64
+		ok    bool
+	}{
+		// recorded nonces are rejected after restart 重启后已记录的随机数被拒绝
+		{"replay", "used", curMS + 1000, false},
+		{"new", "fresh", curMS, true},
+		// rejected signals don't use the nonce 被拒绝的信号不占用随机数
+		{"rejected", "rejected", curMS, true},
+		{"expired", "used", curMS + 121000, true},
+	}
+	for _, c := range cases {
+		err := checkSignalNonceDb(sess, c.nonce, c.curMS)
+		if (err == nil) != c.ok {
+			t.Errorf("%s: got err %v, want ok %v", c.name, err, c.ok)
+		}
+	}
+}
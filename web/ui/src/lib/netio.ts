@@ -30,6 +30,11 @@ const requestApi = async function(method: ApiType, url: string,
         });
       } 
       url = `${siteShot.apiHost}/api${url}`
+      const accShot = get(acc);
+      if(!headers && accShot.token){
+        // kline api of bot requires login token 机器人的K线接口需要登录token
+        headers = {'X-Authorization': 'Bearer ' + accShot.token}
+      }
     }
     site.update((s) => {
       s.loading = true;